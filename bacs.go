@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	bacsPaymentScheme string = "Bacs"
+	bacsCurrency      string = "GBP"
+	sortCodeBankCode  string = "GBDSC"
+
+	bacsCreditTransactionCode string = "99"
+	bacsContraTransactionCode string = "17"
+	bacsContraReference       string = "CONTRA"
+
+	bacsLabelLength     int    = 80
+	bacsRecordLength    int    = 100
+	bacsNameLength      int    = 18
+	bacsRecordSeparator string = "\r\n"
+	bacsMaxAmountPence  int64  = 99999999999
+)
+
+var (
+	// The characters allowed in the Bacs text fields
+	bacsCharset = regexp.MustCompile(`[^A-Z0-9.&/\- ]`)
+
+	sortCodePattern          = regexp.MustCompile(`^[0-9]{6}$`)
+	bacsAccountNumberPattern = regexp.MustCompile(`^[0-9]{8}$`)
+	serviceUserNumberPattern = regexp.MustCompile(`^[0-9]{6}$`)
+)
+
+// A bacsServiceUser is the originator of the submitted files, identified by the service user number (SUN) sponsored by its bank
+type bacsServiceUser struct {
+	number string
+	name   string
+}
+
+// A bacsAccount is a sort code and account number pair
+type bacsAccount struct {
+	sortCode      string
+	accountNumber string
+	accountName   string
+}
+
+// renderBacsStandard18 writes the payments into a Bacs Standard 18 file processed on the processing date
+func renderBacsStandard18(payments []Payment, serviceUser bacsServiceUser, serial string, processingDate time.Time,
+	createdAt time.Time) ([]byte, error) {
+
+	var originatingAccounts []bacsAccount
+	paymentsByAccount := make(map[bacsAccount][]Payment)
+
+	for _, payment := range payments {
+		if err := validateBacsPayment(payment); err != nil {
+			return nil, err
+		}
+		account := newBacsAccount(payment.Attributes.DebtorParty)
+		if _, ok := paymentsByAccount[account]; !ok {
+			originatingAccounts = append(originatingAccounts, account)
+		}
+		paymentsByAccount[account] = append(paymentsByAccount[account], payment)
+	}
+
+	var records []string
+	var creditTotal, debitTotal int64
+	var creditCount, debitCount int
+
+	for _, account := range originatingAccounts {
+		var contraTotal int64
+		for _, payment := range paymentsByAccount[account] {
+			amount := bacsPence(payment.Attributes.Amount)
+			beneficiary := payment.Attributes.BeneficiaryParty.debtorParty()
+			destination := newBacsAccount(beneficiary)
+
+			records = append(records, bacsRecord(destination, bacsCreditTransactionCode, account, amount,
+				serviceUser.name, payment.Attributes.Reference, defaultText(destination.accountName, beneficiary.Name)))
+
+			contraTotal += amount
+			creditCount++
+		}
+
+		if contraTotal > bacsMaxAmountPence {
+			return nil, fmt.Errorf("total of the payments from account %s %s exceeds the Bacs contra limit", account.sortCode, account.accountNumber)
+		}
+		records = append(records, bacsRecord(account, bacsContraTransactionCode, account, contraTotal,
+			serviceUser.name, bacsContraReference, account.accountName))
+
+		creditTotal += contraTotal
+		debitTotal += contraTotal
+		debitCount++
+	}
+
+	fileID := fmt.Sprintf("%-17s", "A"+serviceUser.number+"S  "+serviceUser.number)
+	created := bacsJulianDate(createdAt)
+
+	header := []string{
+		bacsLabel("VOL1", serial, "0", strings.Repeat(" ", 30), serviceUser.number, strings.Repeat(" ", 32), "1"),
+		bacsLabel("HDR1", fileID, serial, "00010001", "      ", created, created, " 000000", strings.Repeat(" ", 20)),
+		bacsLabel("HDR2", "F0200000100", strings.Repeat(" ", 35), "00"),
+		bacsLabel("UHL1", bacsJulianDate(processingDate), "999999    ", "000000", "1 DAILY  ", "001")}
+
+	trailer := []string{
+		bacsLabel("EOF1", fileID, serial, "00010001", "      ", created, created, " 000000", strings.Repeat(" ", 20)),
+		bacsLabel("EOF2", "F0200000100", strings.Repeat(" ", 35), "00"),
+		bacsLabel("UTL1", fmt.Sprintf("%013d%013d%07d%07d", debitTotal, creditTotal, debitCount, creditCount))}
+
+	file := append(append(header, records...), trailer...)
+	return []byte(strings.Join(file, bacsRecordSeparator) + bacsRecordSeparator), nil
+}
+
+// A Bacs payment is a GBP credit between two sort code accounts
+func validateBacsPayment(payment Payment) error {
+	attributes := payment.Attributes
+	debtor := attributes.DebtorParty
+	beneficiary := attributes.BeneficiaryParty.debtorParty()
+
+	valid := attributes.Currency == bacsCurrency && attributes.Amount > 0 &&
+		bacsPence(attributes.Amount) <= bacsMaxAmountPence &&
+		isBacsAccount(debtor.sponsorParty()) && isBacsAccount(beneficiary.sponsorParty())
+
+	if !valid {
+		return &InvalidPaymentError{payment}
+	}
+	return nil
+}
+
+func isBacsAccount(party SponsorParty) bool {
+	return party.BankIDCode == sortCodeBankCode && sortCodePattern.MatchString(party.BankID) &&
+		bacsAccountNumberPattern.MatchString(party.AccountNumber)
+}
+
+func newBacsAccount(party DebtorParty) bacsAccount {
+	sponsor := party.sponsorParty()
+	return bacsAccount{sortCode: sponsor.BankID, accountNumber: sponsor.AccountNumber, accountName: party.AccountName}
+}
+
+// bacsRecord formats a detail or a contra record of a Standard 18 file
+func bacsRecord(destination bacsAccount, transactionCode string, origin bacsAccount, amount int64,
+	name string, reference string, accountName string) string {
+
+	record := destination.sortCode + destination.accountNumber + "0" + transactionCode +
+		origin.sortCode + origin.accountNumber + "    " + fmt.Sprintf("%011d", amount) +
+		bacsText(name) + bacsText(reference) + bacsText(accountName)
+
+	return fmt.Sprintf("%-*s", bacsRecordLength, record)
+}
+
+func bacsLabel(fields ...string) string {
+	return fmt.Sprintf("%-*s", bacsLabelLength, strings.Join(fields, ""))
+}
+
+// bacsText converts the text to the Bacs character set and pads or truncates it to 18 characters
+func bacsText(text string) string {
+	text = bacsCharset.ReplaceAllString(strings.ToUpper(transliterate(text)), " ")
+	return fmt.Sprintf("%-*s", bacsNameLength, truncateText(text, bacsNameLength))
+}
+
+func bacsPence(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// The dates of the labels are in the ' YYDDD' format, the year and the day of the year preceded by a space
+func bacsJulianDate(date time.Time) string {
+	return fmt.Sprintf(" %s%03d", date.Format("06"), date.YearDay())
+}
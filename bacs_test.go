@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	. "github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type BatchRepositoryMock struct {
+	batches   map[string]PaymentBatch
+	submitted map[string]string
+}
+
+func NewBatchRepositoryMock() *BatchRepositoryMock {
+	return &BatchRepositoryMock{batches: make(map[string]PaymentBatch), submitted: make(map[string]string)}
+}
+
+func (m *BatchRepositoryMock) InsertBatch(batch PaymentBatch) (err error) {
+	for _, paymentID := range batch.PaymentIDs {
+		if _, ok := m.submitted[paymentID]; ok {
+			return &PaymentAlreadySubmittedError{batch.ID}
+		}
+	}
+	for _, paymentID := range batch.PaymentIDs {
+		m.submitted[paymentID] = batch.ID
+	}
+	m.batches[batch.ID] = batch
+	return nil
+}
+
+func (m *BatchRepositoryMock) GetBatch(batchID string) (batch PaymentBatch, err error) {
+	batch, ok := m.batches[batchID]
+	if !ok {
+		return batch, &PaymentBatchNotFoundError{batchID}
+	}
+	return batch, nil
+}
+
+func (m *BatchRepositoryMock) GetSubmittedPayments(paymentIDs []string) (submitted map[string]bool, err error) {
+	submitted = make(map[string]bool)
+	for _, paymentID := range paymentIDs {
+		if _, ok := m.submitted[paymentID]; ok {
+			submitted[paymentID] = true
+		}
+	}
+	return submitted, nil
+}
+
+// Test Bacs Standard 18 file
+
+func TestRenderBacsStandard18MatchesGolden(t *testing.T) {
+	payments := MockBacsPayments(t)[:3]
+	processingDate := time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC)
+	createdAt := time.Date(2019, 5, 3, 11, 0, 0, 0, time.UTC)
+
+	file, err := renderBacsStandard18(payments, bacsServiceUser{"123456", "Payments Backend"}, "B00001", processingDate, createdAt)
+	Nil(t, err)
+
+	AssertGolden(t, "test_resources/bacs/standard18.golden.txt", file)
+}
+
+func TestRenderBacsStandard18Structure(t *testing.T) {
+	payments := MockBacsPayments(t)[:3]
+
+	file, err := renderBacsStandard18(payments, bacsServiceUser{"123456", "Payments Backend"}, "B00001", time.Now(), time.Now())
+	Nil(t, err)
+
+	records := strings.Split(strings.TrimSuffix(string(file), bacsRecordSeparator), bacsRecordSeparator)
+	Equal(t, 4+5+3, len(records))
+
+	var labels []string
+	for _, record := range append(append([]string{}, records[:4]...), records[9:]...) {
+		Equal(t, bacsLabelLength, len(record))
+		labels = append(labels, record[:4])
+	}
+	Equal(t, []string{"VOL1", "HDR1", "HDR2", "UHL1", "EOF1", "EOF2", "UTL1"}, labels)
+
+	details := records[4:9]
+	for _, record := range details {
+		Equal(t, bacsRecordLength, len(record))
+	}
+
+	// The payments of the same debtor account are followed by the contra record debiting the account
+	Equal(t, "4030003192681909920330171268996    00000010021", details[0][:46])
+	Equal(t, "6016131122334409920330171268996    00000000099", details[1][:46])
+	Equal(t, "2033017126899601720330171268996    00000010120", details[2][:46])
+	Equal(t, "CONTRA            ", details[2][64:82])
+	Equal(t, "MULLER & SOHNE    ", details[1][82:100])
+	Equal(t, "3096348765432109940127612345678    00000125000", details[3][:46])
+	Equal(t, "4012761234567801740127612345678    00000125000", details[4][:46])
+
+	Equal(t, "UTL1"+"0000000135120"+"0000000135120"+"0000002"+"0000003", strings.TrimSpace(records[11]))
+}
+
+func TestRenderBacsStandard18InvalidPayment(t *testing.T) {
+	payment := MockBacsPayments(t)[0]
+	payment.Attributes.Currency = "EUR"
+
+	_, err := renderBacsStandard18([]Payment{payment}, bacsServiceUser{"123456", "Payments Backend"}, "B00001", time.Now(), time.Now())
+
+	IsType(t, &InvalidPaymentError{}, err)
+}
+
+// Test Bacs batch handlers
+
+func TestCreateBacsBatchSuccessful(t *testing.T) {
+	repository := NewBatchRepositoryMock()
+
+	response := ServeBatchHTTP(methodPost, bacsBatchesPath+"?organisation_id=123&processing_date=2019-05-06", MockBacsPayments(t), repository)
+
+	Equal(t, 201, response.Code)
+	Equal(t, 1, len(repository.batches))
+	Equal(t, 3, len(repository.submitted))
+	Contains(t, response.Header().Get("Location"), "/v1/payments/batches/")
+	True(t, strings.HasPrefix(response.Body.String(), "VOL1"))
+}
+
+func TestCreateBacsBatchExcludesSubmittedPayments(t *testing.T) {
+	repository := NewBatchRepositoryMock()
+	repository.submitted["b1"] = "previous"
+	repository.submitted["b3"] = "previous"
+
+	response := ServeBatchHTTP(methodPost, bacsBatchesPath+"?organisation_id=123&processing_date=2019-05-06", MockBacsPayments(t), repository)
+
+	Equal(t, 201, response.Code)
+	for _, batch := range repository.batches {
+		Equal(t, []string{"b2"}, batch.PaymentIDs)
+	}
+}
+
+func TestCreateBacsBatchNothingToSubmit(t *testing.T) {
+	repository := NewBatchRepositoryMock()
+	repository.submitted["b4"] = "previous"
+
+	response := ServeBatchHTTP(methodPost, bacsBatchesPath+"?organisation_id=123&processing_date=2019-06-06", MockBacsPayments(t), repository)
+
+	Equal(t, 404, response.Code)
+	Equal(t, 0, len(repository.batches))
+}
+
+func TestCreateBacsBatchInvalidDate(t *testing.T) {
+	response := ServeBatchHTTP(methodPost, bacsBatchesPath+"?organisation_id=123&processing_date=tomorrow", MockBacsPayments(t), NewBatchRepositoryMock())
+
+	Equal(t, 400, response.Code)
+}
+
+func TestGetPaymentBatchSuccessful(t *testing.T) {
+	repository := NewBatchRepositoryMock()
+	repository.batches["1"] = PaymentBatch{ID: "1", PaymentScheme: bacsPaymentScheme, ProcessingDate: "2019-05-06", File: "VOL1"}
+
+	response := ServeBatchHTTP(methodGet, preparePaymentURL(paymentBatchPath, "1"), nil, repository)
+
+	Equal(t, 200, response.Code)
+	Equal(t, "VOL1", response.Body.String())
+}
+
+func TestGetPaymentBatchNotFound(t *testing.T) {
+	response := ServeBatchHTTP(methodGet, preparePaymentURL(paymentBatchPath, "1"), nil, NewBatchRepositoryMock())
+
+	Equal(t, 404, response.Code)
+}
+
+// ---------------------------------------------------- //
+
+func MockBacsPayments(t *testing.T) []Payment {
+	file, err := ioutil.ReadFile("test_resources/bacs/bacs_payments.json")
+	Nil(t, err)
+
+	var payments PaymentListResult
+	Nil(t, json.Unmarshal(file, &payments))
+	return payments.Data
+}
+
+func ServeBatchHTTP(method string, url string, payments []Payment, repository BatchRepository) *httptest.ResponseRecorder {
+	setPaymentRepository(&PaymentRepositoryMock{mode: successful, payments: payments})
+	setBatchRepository(repository)
+	setBacsServiceUser("123456", "Payments Backend")
+
+	var body io.Reader = http.NoBody
+	request, _ := http.NewRequest(method, url, body)
+
+	response := httptest.NewRecorder()
+	configureRouter().ServeHTTP(response, request)
+
+	return response
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var batchRepository BatchRepository
+
+var bacsOriginator bacsServiceUser
+
+var sepaInitiatingParty string
+
+func setBatchRepository(repository BatchRepository) {
+	batchRepository = repository
+}
+
+func setBacsServiceUser(number string, name string) {
+	bacsOriginator = bacsServiceUser{number: number, name: name}
+}
+
+func setSepaInitiatingParty(name string) {
+	sepaInitiatingParty = name
+}
+
+// A paymentBatchFormat describes how the file of a batch of the payment scheme is downloaded
+type paymentBatchFormat struct {
+	contentType string
+	extension   string
+}
+
+var paymentBatchFormats = map[string]paymentBatchFormat{
+	bacsPaymentScheme: {"text/plain; charset=US-ASCII", "txt"},
+	sepaPaymentScheme: {"application/xml; charset=UTF-8", "xml"}}
+
+// createBacsBatchEndpoint writes the unsubmitted Bacs payments of the organisation due on the processing date into a Standard 18 file
+func createBacsBatchEndpoint(writer http.ResponseWriter, request *http.Request) {
+	processingDate, err := time.Parse(iso20022DateLayout, request.URL.Query().Get("processing_date"))
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	filter := PaymentFilter{
+		OrganisationID: request.URL.Query().Get("organisation_id"),
+		PaymentScheme:  bacsPaymentScheme,
+		ProcessingDate: processingDate.Format(iso20022DateLayout)}
+
+	createPaymentBatch(writer, request, filter, nil, func(batch PaymentBatch, payments []Payment) ([]byte, error) {
+		serial := strings.ToUpper(strings.ReplaceAll(batch.ID, "-", ""))[:6]
+		return renderBacsStandard18(payments, bacsOriginator, serial, processingDate, batch.SubmittedAt)
+	})
+}
+
+// createSepaBatchEndpoint writes the unsubmitted SEPA payments of the organisation into a pain.001.001.03 file
+func createSepaBatchEndpoint(writer http.ResponseWriter, request *http.Request) {
+	processingDate := request.URL.Query().Get("processing_date")
+	if len(processingDate) > 0 {
+		if _, err := time.Parse(iso20022DateLayout, processingDate); err != nil {
+			prepareFailureHeader(writer, request, err)
+			return
+		}
+	}
+
+	filter := PaymentFilter{
+		OrganisationID: request.URL.Query().Get("organisation_id"),
+		PaymentScheme:  sepaPaymentScheme,
+		ProcessingDate: processingDate}
+
+	createPaymentBatch(writer, request, filter, isSepaEligible, func(batch PaymentBatch, payments []Payment) ([]byte, error) {
+		messageID := strings.ReplaceAll(batch.ID, "-", "")
+		return renderSepaCreditTransfer(payments, sepaInitiatingParty, messageID, batch.SubmittedAt)
+	})
+}
+
+// createPaymentBatch renders the unsubmitted eligible payments matching the filter into the file of a new batch
+func createPaymentBatch(writer http.ResponseWriter, request *http.Request, filter PaymentFilter,
+	eligible func(payment Payment) bool, render func(batch PaymentBatch, payments []Payment) ([]byte, error)) {
+
+	if len(filter.OrganisationID) == 0 {
+		prepareFailureHeader(writer, request, fmt.Errorf("organisation_id is required"))
+		return
+	}
+
+	payments, err := contextPaymentRepository(request.Context()).FindPayments(filter)
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	payments, err = excludeSubmittedPayments(payments)
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	if eligible != nil {
+		payments = filterPayments(payments, eligible)
+	}
+
+	if len(payments) == 0 {
+		prepareFailureHeader(writer, request, &EmptyPaymentBatchError{filter})
+		return
+	}
+
+	newUUID, _ := uuid.NewUUID()
+	batch := PaymentBatch{
+		ID:             newUUID.String(),
+		OrganisationID: filter.OrganisationID,
+		PaymentScheme:  filter.PaymentScheme,
+		ProcessingDate: filter.ProcessingDate,
+		SubmittedAt:    time.Now().UTC()}
+
+	for _, payment := range payments {
+		batch.PaymentIDs = append(batch.PaymentIDs, payment.ID)
+	}
+
+	file, err := render(batch, payments)
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+	batch.File = string(file)
+
+	err = batchRepository.InsertBatch(batch)
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	writer.Header().Set("Location", prepareFullPaymentURL(request.Host, paymentBatchPath, batch.ID))
+	writeBatchFile(writer, batch, http.StatusCreated)
+}
+
+func getPaymentBatchEndpoint(writer http.ResponseWriter, request *http.Request) {
+	batchID := mux.Vars(request)["id"]
+
+	batch, err := batchRepository.GetBatch(batchID)
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	writeBatchFile(writer, batch, http.StatusOK)
+}
+
+func excludeSubmittedPayments(payments []Payment) (unsubmitted []Payment, err error) {
+	var paymentIDs []string
+	for _, payment := range payments {
+		paymentIDs = append(paymentIDs, payment.ID)
+	}
+
+	submitted, err := batchRepository.GetSubmittedPayments(paymentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return filterPayments(payments, func(payment Payment) bool { return !submitted[payment.ID] }), nil
+}
+
+func filterPayments(payments []Payment, keep func(payment Payment) bool) (kept []Payment) {
+	for _, payment := range payments {
+		if keep(payment) {
+			kept = append(kept, payment)
+		}
+	}
+	return kept
+}
+
+func writeBatchFile(writer http.ResponseWriter, batch PaymentBatch, statusCode int) {
+	format := paymentBatchFormats[batch.PaymentScheme]
+	name := strings.ToLower(batch.PaymentScheme)
+	if len(batch.ProcessingDate) > 0 {
+		name += "-" + batch.ProcessingDate
+	}
+
+	writer.Header().Set("Content-Type", format.contentType)
+	writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-%s.%s\"", name, batch.ID, format.extension))
+	writer.WriteHeader(statusCode)
+	_, _ = writer.Write([]byte(batch.File))
+}
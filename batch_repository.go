@@ -0,0 +1,110 @@
+package main
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log/slog"
+)
+
+const (
+	paymentBatchesCollectionName     string = "payment_batches"
+	paymentSubmissionsCollectionName string = "payment_submissions"
+)
+
+// BatchRepository is an interface which defines the methods must be implemented by a specific repository that persist payment batches to storage
+type BatchRepository interface {
+	InsertBatch(batch PaymentBatch) (err error)
+
+	GetBatch(batchID string) (batch PaymentBatch, err error)
+
+	GetSubmittedPayments(paymentIDs []string) (submitted map[string]bool, err error)
+}
+
+// A paymentSubmission marks a payment as submitted by a batch
+type paymentSubmission struct {
+	PaymentID string `bson:"_id"`
+	BatchID   string `bson:"batch_id"`
+}
+
+type mongoBatchRepository struct {
+	client *mongo.Client
+}
+
+// InsertBatch marks the payments of the batch as submitted and stores the batch
+func (m *mongoBatchRepository) InsertBatch(batch PaymentBatch) (err error) {
+	ctx := getContextWithTimeout()
+
+	var submissions []interface{}
+	for _, paymentID := range batch.PaymentIDs {
+		submissions = append(submissions, paymentSubmission{PaymentID: paymentID, BatchID: batch.ID})
+	}
+
+	_, err = m.submissions().InsertMany(ctx, submissions)
+	if err != nil {
+		m.removeSubmissions(batch.ID)
+		if isDuplicateKeyError(err) {
+			return &PaymentAlreadySubmittedError{batch.ID}
+		}
+		slog.ErrorContext(ctx, "Unexpected error while submitting batch", "error", err)
+		return &PersistenceError{}
+	}
+
+	_, err = m.batches().InsertOne(ctx, batch)
+	if err != nil {
+		m.removeSubmissions(batch.ID)
+		slog.ErrorContext(ctx, "Unexpected error while inserting batch", "error", err)
+		return &PersistenceError{}
+	}
+	return nil
+}
+
+func (m *mongoBatchRepository) GetBatch(batchID string) (batch PaymentBatch, err error) {
+	err = m.batches().FindOne(getContextWithTimeout(), bson.M{"_id": batchID}).Decode(&batch)
+
+	if err == mongo.ErrNoDocuments {
+		return batch, &PaymentBatchNotFoundError{batchID}
+	}
+
+	if err != nil {
+		slog.Error("Unexpected error while loading batch", "error", err)
+		return batch, &PersistenceError{}
+	}
+	return batch, nil
+}
+
+func (m *mongoBatchRepository) GetSubmittedPayments(paymentIDs []string) (submitted map[string]bool, err error) {
+	ctx := getContextWithTimeout()
+	submitted = make(map[string]bool)
+
+	cursor, err := m.submissions().Find(ctx, bson.M{"_id": bson.M{"$in": paymentIDs}})
+	if err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while loading submissions", "error", err)
+		return submitted, &PersistenceError{}
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+
+	for cursor.Next(ctx) {
+		var submission paymentSubmission
+		if err = cursor.Decode(&submission); err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while loading submissions", "error", err)
+			return submitted, &PersistenceError{}
+		}
+		submitted[submission.PaymentID] = true
+	}
+	return submitted, nil
+}
+
+func (m *mongoBatchRepository) removeSubmissions(batchID string) {
+	_, err := m.submissions().DeleteMany(getContextWithTimeout(), bson.M{"batch_id": batchID})
+	if err != nil {
+		slog.Error("Unexpected error while removing submissions of batch", "batch_id", batchID, "error", err)
+	}
+}
+
+func (m *mongoBatchRepository) batches() *mongo.Collection {
+	return m.client.Database(databaseName).Collection(paymentBatchesCollectionName)
+}
+
+func (m *mongoBatchRepository) submissions() *mongo.Collection {
+	return m.client.Database(databaseName).Collection(paymentSubmissionsCollectionName)
+}
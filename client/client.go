@@ -0,0 +1,338 @@
+// Package client is the Go client of the payments API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	paymentsPath         string = "/v2/payments"
+	exportPath           string = "/v1/payments/export"
+	idempotencyKeyHeader string = "Idempotency-Key"
+
+	defaultMaxRetries int           = 3
+	defaultBackoff    time.Duration = 100 * time.Millisecond
+	defaultPageSize   int           = 50
+)
+
+// A Client calls the payments API of the server at the base URL, it is safe for concurrent use
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	backoff    time.Duration
+
+	authorization string
+}
+
+// An Option configures a Client
+type Option func(client *Client)
+
+// WithHTTPClient makes the client send the requests with the http client rather than the default one
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(client *Client) {
+		client.httpClient = httpClient
+	}
+}
+
+// WithRetries sets how many times a failed request is retried and how long the first retry waits
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(client *Client) {
+		client.maxRetries = maxRetries
+		client.backoff = backoff
+	}
+}
+
+// WithBasicAuth makes the client authenticate the requests with the username and the password
+func WithBasicAuth(username string, password string) Option {
+	return func(client *Client) {
+		client.authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+	}
+}
+
+// WithBearerToken makes the client authenticate the requests with the token
+func WithBearerToken(token string) Option {
+	return func(client *Client) {
+		client.authorization = "Bearer " + token
+	}
+}
+
+// New returns a client of the server at the base URL, e.g. http://127.0.0.1:8000
+func New(baseURL string, options ...Option) *Client {
+	client := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		maxRetries: defaultMaxRetries,
+		backoff:    defaultBackoff}
+
+	for _, option := range options {
+		option(client)
+	}
+	return client
+}
+
+// Create stores a new payment with a new idempotency key and returns it with its id and version
+func (c *Client) Create(ctx context.Context, payment Payment) (Payment, error) {
+	idempotencyKey, err := uuid.NewRandom()
+	if err != nil {
+		return Payment{}, err
+	}
+	return c.CreateWithKey(ctx, payment, idempotencyKey.String())
+}
+
+// CreateWithKey stores a new payment with the idempotency key and returns it with its id and version
+func (c *Client) CreateWithKey(ctx context.Context, payment Payment, idempotencyKey string) (Payment, error) {
+	body, err := json.Marshal(payment)
+	if err != nil {
+		return Payment{}, err
+	}
+
+	response, _, err := c.do(ctx, http.MethodPost, paymentsPath, body, http.Header{idempotencyKeyHeader: {idempotencyKey}})
+	if err != nil {
+		return Payment{}, err
+	}
+	defer closeResponse(response)
+
+	if response.StatusCode != http.StatusCreated {
+		return Payment{}, statusError(response.StatusCode, http.MethodPost, paymentsPath, payment.ID, payment.Version)
+	}
+
+	location, err := url.Parse(response.Header.Get("Location"))
+	if err != nil {
+		return Payment{}, err
+	}
+
+	payment.ID = path.Base(location.Path)
+	payment.Version = 1
+	return payment, nil
+}
+
+// Get returns the current state of the payment
+func (c *Client) Get(ctx context.Context, paymentID string) (Payment, error) {
+	paymentPath := paymentsPath + "/" + url.PathEscape(paymentID)
+
+	response, _, err := c.do(ctx, http.MethodGet, paymentPath, nil, nil)
+	if err != nil {
+		return Payment{}, err
+	}
+	defer closeResponse(response)
+
+	if response.StatusCode != http.StatusOK {
+		return Payment{}, statusError(response.StatusCode, http.MethodGet, paymentPath, paymentID, 0)
+	}
+
+	var result paymentResult
+	if err = json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return Payment{}, err
+	}
+	return result.Data, nil
+}
+
+// Update replaces the payment of its version and returns it with its new version
+func (c *Client) Update(ctx context.Context, payment Payment) (Payment, error) {
+	paymentPath := paymentsPath + "/" + url.PathEscape(payment.ID)
+
+	body, err := json.Marshal(payment)
+	if err != nil {
+		return Payment{}, err
+	}
+
+	response, _, err := c.do(ctx, http.MethodPut, paymentPath, body, nil)
+	if err != nil {
+		return Payment{}, err
+	}
+	defer closeResponse(response)
+
+	if response.StatusCode != http.StatusOK {
+		return Payment{}, statusError(response.StatusCode, http.MethodPut, paymentPath, payment.ID, payment.Version)
+	}
+
+	payment.Version = payment.Version + 1
+	return payment, nil
+}
+
+// Delete deletes the payment, a retried delete which finds the payment already deleted succeeds
+func (c *Client) Delete(ctx context.Context, paymentID string) error {
+	paymentPath := paymentsPath + "/" + url.PathEscape(paymentID)
+
+	response, retried, err := c.do(ctx, http.MethodDelete, paymentPath, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(response)
+
+	// A retried delete finds the payment missing when an earlier attempt has deleted it before failing
+	if retried && response.StatusCode == http.StatusNotFound {
+		return nil
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return statusError(response.StatusCode, http.MethodDelete, paymentPath, paymentID, 0)
+	}
+	return nil
+}
+
+// List returns the iterator over the payments matching the filter, ordered by id
+func (c *Client) List(ctx context.Context, filter Filter) *PaymentIterator {
+	query := filterQuery(filter)
+
+	pageSize := filter.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	query.Set("limit", strconv.Itoa(pageSize))
+
+	return &PaymentIterator{ctx: ctx, client: c, query: query, more: true}
+}
+
+// Export streams the payments matching the filter as the media type, either text/csv or application/x-ndjson
+func (c *Client) Export(ctx context.Context, filter Filter, mediaType string, columns []string) (io.ReadCloser, error) {
+	query := filterQuery(filter)
+	if len(columns) > 0 {
+		query.Set("columns", strings.Join(columns, ","))
+	}
+	requestPath := exportPath + "?" + query.Encode()
+
+	response, _, err := c.do(ctx, http.MethodGet, requestPath, nil, http.Header{"Accept": {mediaType}})
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		closeResponse(response)
+		return nil, statusError(response.StatusCode, http.MethodGet, requestPath, "", 0)
+	}
+	return response.Body, nil
+}
+
+// filterQuery returns the query parameters of the criteria set in the filter
+func filterQuery(filter Filter) url.Values {
+	query := url.Values{}
+	for name, value := range map[string]string{
+		"organisation_id": filter.OrganisationID,
+		"payment_scheme":  filter.PaymentScheme,
+		"processing_date": filter.ProcessingDate} {
+		if len(value) > 0 {
+			query.Set(name, value)
+		}
+	}
+	return query
+}
+
+// do sends the request and retries the idempotent ones with exponential backoff while they fail with 5xx code
+func (c *Client) do(ctx context.Context, method string, requestPath string, body []byte, header http.Header) (*http.Response, bool, error) {
+	backoff := c.backoff
+	maxRetries := 0
+	if method == http.MethodGet || method == http.MethodDelete || len(header.Get(idempotencyKeyHeader)) > 0 {
+		maxRetries = c.maxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		request, err := http.NewRequestWithContext(ctx, method, c.baseURL+requestPath, bytes.NewReader(body))
+		if err != nil {
+			return nil, false, err
+		}
+		if body != nil {
+			request.Header.Set("Content-Type", "application/json")
+		}
+		if len(c.authorization) > 0 {
+			request.Header.Set("Authorization", c.authorization)
+		}
+		for name, values := range header {
+			request.Header[name] = values
+		}
+
+		response, err := c.httpClient.Do(request)
+		if (err == nil && response.StatusCode < http.StatusInternalServerError) || attempt == maxRetries || ctx.Err() != nil {
+			return response, attempt > 0, err
+		}
+		if err == nil {
+			closeResponse(response)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, attempt > 0, ctx.Err()
+		case <-time.After(backoff):
+			backoff = backoff * 2
+		}
+	}
+}
+
+// closeResponse reads the rest of the body, so the connection is reused, and closes it
+func closeResponse(response *http.Response) {
+	_, _ = io.Copy(io.Discard, response.Body)
+	_ = response.Body.Close()
+}
+
+// A PaymentIterator iterates over the payments of a list, fetching its pages one by one
+type PaymentIterator struct {
+	ctx     context.Context
+	client  *Client
+	query   url.Values
+	more    bool
+	page    []Payment
+	payment Payment
+	err     error
+}
+
+// Next advances to the next payment, it returns false when the list is over or a page failed to be fetched
+func (i *PaymentIterator) Next() bool {
+	for len(i.page) == 0 {
+		if i.err != nil || !i.more {
+			return false
+		}
+		i.fetch()
+	}
+
+	i.payment, i.page = i.page[0], i.page[1:]
+	return true
+}
+
+// Payment returns the payment the iterator is at
+func (i *PaymentIterator) Payment() Payment {
+	return i.payment
+}
+
+// Err returns the error which stopped the iterator before the list was over
+func (i *PaymentIterator) Err() error {
+	return i.err
+}
+
+func (i *PaymentIterator) fetch() {
+	pagePath := paymentsPath + "?" + i.query.Encode()
+
+	response, _, err := i.client.do(i.ctx, http.MethodGet, pagePath, nil, nil)
+	if err != nil {
+		i.err = err
+		return
+	}
+	defer closeResponse(response)
+
+	if response.StatusCode != http.StatusOK {
+		i.err = statusError(response.StatusCode, http.MethodGet, pagePath, "", 0)
+		return
+	}
+
+	var result paymentListResult
+	if i.err = json.NewDecoder(response.Body).Decode(&result); i.err != nil {
+		return
+	}
+
+	i.page = result.Data
+	i.more = len(result.Links.Next) > 0 && len(result.Data) > 0
+	if i.more {
+		i.query.Set("after", result.Data[len(result.Data)-1].ID)
+	}
+}
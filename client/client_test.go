@@ -0,0 +1,189 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCreateRetriedWithSameIdempotencyKey(t *testing.T) {
+	var attempts int32
+	var keys []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		keys = append(keys, request.Header.Get(idempotencyKeyHeader))
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			writer.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		writer.Header().Set("Location", "http://"+request.Host+paymentsPath+"/abc")
+		writer.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	payment, err := New(server.URL, WithRetries(3, time.Millisecond)).Create(context.Background(), Payment{OrganisationID: "123"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", payment.ID)
+	assert.Equal(t, 1, payment.Version)
+	assert.Equal(t, "123", payment.OrganisationID)
+	assert.Equal(t, int32(3), attempts)
+	assert.Equal(t, 3, len(keys))
+	assert.NotEmpty(t, keys[0])
+	assert.Equal(t, keys[0], keys[1])
+	assert.Equal(t, keys[0], keys[2])
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	var attempts int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		writer.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, WithRetries(2, time.Millisecond)).Get(context.Background(), "abc")
+
+	assert.IsType(t, &PersistenceError{}, err)
+	assert.Equal(t, int32(3), attempts)
+}
+
+func TestClientErrorsNotRetried(t *testing.T) {
+	var attempts int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		writer.WriteHeader(http.StatusConflict)
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, WithRetries(3, time.Millisecond)).Update(context.Background(), Payment{ID: "abc", Version: 2})
+
+	assert.Equal(t, &PaymentVersionConflictError{"abc", 2}, err)
+	assert.Equal(t, int32(1), attempts)
+}
+
+func TestUpdateNotRetried(t *testing.T) {
+	var attempts int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		writer.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, WithRetries(3, time.Millisecond)).Update(context.Background(), Payment{ID: "abc", Version: 2})
+
+	assert.Equal(t, &UnexpectedStatusError{502}, err)
+	assert.Equal(t, int32(1), attempts)
+}
+
+func TestRetriedDeleteOfDeletedPayment(t *testing.T) {
+	var attempts int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			writer.WriteHeader(http.StatusGatewayTimeout)
+			return
+		}
+		writer.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, WithRetries(3, time.Millisecond))
+
+	assert.Nil(t, client.Delete(context.Background(), "abc"))
+	assert.Equal(t, int32(2), attempts)
+	assert.Equal(t, &PaymentNotFoundError{"abc"}, client.Delete(context.Background(), "abc"))
+}
+
+func TestStatusErrors(t *testing.T) {
+	assert.Equal(t, &PersistenceError{}, statusError(500, http.MethodGet, "/v2/payments/1", "1", 0))
+	assert.Equal(t, &PaymentNotFoundError{"1"}, statusError(404, http.MethodGet, "/v2/payments/1", "1", 0))
+	assert.Equal(t, &PaymentVersionConflictError{"1", 3}, statusError(409, http.MethodPut, "/v2/payments/1", "1", 3))
+	assert.Equal(t, &InvalidRequestError{http.MethodPost, "/v2/payments"}, statusError(400, http.MethodPost, "/v2/payments", "", 0))
+	assert.Equal(t, &UnexpectedStatusError{502}, statusError(502, http.MethodGet, "/v2/payments", "", 0))
+}
+
+func TestBackoffStoppedByContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := New(server.URL, WithRetries(5, time.Second)).Delete(ctx, "abc")
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestListFollowsNextPages(t *testing.T) {
+	pages := map[string]paymentListResult{
+		"": {
+			Data:  []Payment{{ID: "1"}, {ID: "2"}},
+			Links: links{Next: "next"}},
+		"2": {
+			Data: []Payment{{ID: "3"}}}}
+	var queries []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		queries = append(queries, request.URL.RawQuery)
+		_ = json.NewEncoder(writer).Encode(pages[request.URL.Query().Get("after")])
+	}))
+	defer server.Close()
+
+	payments := New(server.URL).List(context.Background(), Filter{OrganisationID: "123", PageSize: 2})
+
+	var ids []string
+	for payments.Next() {
+		ids = append(ids, payments.Payment().ID)
+	}
+
+	assert.Nil(t, payments.Err())
+	assert.Equal(t, []string{"1", "2", "3"}, ids)
+	assert.Equal(t, []string{"limit=2&organisation_id=123", "after=2&limit=2&organisation_id=123"}, queries)
+}
+
+func TestListStoppedByError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	payments := New(server.URL).List(context.Background(), Filter{PageSize: 500})
+
+	assert.False(t, payments.Next())
+	assert.IsType(t, &InvalidRequestError{}, payments.Err())
+}
+
+func TestExportWithCredentials(t *testing.T) {
+	var query, accept, authorization string
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		query, accept, authorization = request.URL.RawQuery, request.Header.Get("Accept"), request.Header.Get("Authorization")
+		_, _ = writer.Write([]byte("id\n1\n"))
+	}))
+	defer server.Close()
+
+	export, err := New(server.URL, WithBasicAuth("support", "pass")).Export(context.Background(), Filter{PaymentScheme: "FPS"}, "text/csv", []string{"id"})
+	assert.Nil(t, err)
+	defer export.Close()
+
+	content, err := io.ReadAll(export)
+	assert.Nil(t, err)
+	assert.Equal(t, "id\n1\n", string(content))
+	assert.Equal(t, "columns=id&payment_scheme=FPS", query)
+	assert.Equal(t, "text/csv", accept)
+	assert.Equal(t, "Basic c3VwcG9ydDpwYXNz", authorization)
+}
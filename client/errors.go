@@ -0,0 +1,67 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// A PersistenceError is returned when the server failed to read or store the payments (500 code)
+type PersistenceError struct{}
+
+func (e PersistenceError) Error() string {
+	return "Server failed to process the payments"
+}
+
+// A PaymentNotFoundError is returned when the payment doesn't exist (404 code)
+type PaymentNotFoundError struct {
+	PaymentID string
+}
+
+func (e PaymentNotFoundError) Error() string {
+	return fmt.Sprintf("Payment with id '%s' is not found", e.PaymentID)
+}
+
+// A PaymentVersionConflictError is returned when the change of a payment conflicts with its stored state (409 code)
+type PaymentVersionConflictError struct {
+	PaymentID string
+	Version   int
+}
+
+func (e PaymentVersionConflictError) Error() string {
+	return fmt.Sprintf("Payment with id '%s' and version %d conflicts with the stored payment", e.PaymentID, e.Version)
+}
+
+// An InvalidRequestError is returned when the server rejects the payment or the parameters of the request (400 code)
+type InvalidRequestError struct {
+	Method string
+	Path   string
+}
+
+func (e InvalidRequestError) Error() string {
+	return fmt.Sprintf("Request [%s] %s is invalid", e.Method, e.Path)
+}
+
+// An UnexpectedStatusError is returned for the status codes the other errors don't stand for, e.g. a gateway failure
+type UnexpectedStatusError struct {
+	StatusCode int
+}
+
+func (e UnexpectedStatusError) Error() string {
+	return fmt.Sprintf("Unexpected response status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// statusError classifies the failure status code of a response to a request about the payment
+func statusError(statusCode int, method string, path string, paymentID string, version int) error {
+	switch statusCode {
+	case http.StatusInternalServerError:
+		return &PersistenceError{}
+	case http.StatusNotFound:
+		return &PaymentNotFoundError{paymentID}
+	case http.StatusConflict:
+		return &PaymentVersionConflictError{paymentID, version}
+	case http.StatusBadRequest:
+		return &InvalidRequestError{method, path}
+	default:
+		return &UnexpectedStatusError{statusCode}
+	}
+}
@@ -0,0 +1,99 @@
+package client
+
+// A Payment is a structure which represents the data for a single payment
+type Payment struct {
+	Type           string     `json:"type,omitempty"`
+	ID             string     `json:"id,omitempty"`
+	Version        int        `json:"version,omitempty"`
+	OrganisationID string     `json:"organisation_id,omitempty"`
+	Attributes     Attributes `json:"attributes,omitempty"`
+}
+
+// An Attributes is a structure which represents the single payment attributes data
+type Attributes struct {
+	Amount               float64            `json:"amount,string,omitempty"`
+	BeneficiaryParty     BeneficiaryParty   `json:"beneficiary_party,omitempty"`
+	ChargesInformation   ChargesInformation `json:"charges_information,omitempty"`
+	Currency             string             `json:"currency,omitempty"`
+	DebtorParty          DebtorParty        `json:"debtor_party,omitempty"`
+	EndToEndReference    string             `json:"end_to_end_reference,omitempty"`
+	FX                   FX                 `json:"fx,omitempty"`
+	NumericReference     int                `json:"numeric_reference,string,omitempty"`
+	PaymentID            string             `json:"payment_id,omitempty"`
+	PaymentPurpose       string             `json:"payment_purpose,omitempty"`
+	PaymentScheme        string             `json:"payment_scheme,omitempty"`
+	PaymentType          string             `json:"payment_type,omitempty"`
+	ProcessingDate       string             `json:"processing_date,omitempty"`
+	Reference            string             `json:"reference,omitempty"`
+	SchemePaymentSubType string             `json:"scheme_payment_sub_type,omitempty"`
+	SchemePaymentType    string             `json:"scheme_payment_type,omitempty"`
+	SponsorParty         SponsorParty       `json:"sponsor_party,omitempty"`
+}
+
+// A SponsorParty is a structure which represents the data for sponsor party attribute
+type SponsorParty struct {
+	AccountNumber string `json:"account_number,omitempty"`
+	BankID        string `json:"bank_id,omitempty"`
+	BankIDCode    string `json:"bank_id_code,omitempty"`
+}
+
+// A DebtorParty is a structure which represents the data for debtor party attribute
+type DebtorParty struct {
+	*SponsorParty
+	AccountName       string `json:"account_name,omitempty"`
+	AccountNumberCode string `json:"account_number_code,omitempty"`
+	Address           string `json:"address,omitempty"`
+	Name              string `json:"name,omitempty"`
+}
+
+// A BeneficiaryParty is a structure which represents the data for beneficiary party attribute
+type BeneficiaryParty struct {
+	*DebtorParty
+	AccountType int `json:"account_type,omitempty"`
+}
+
+// A ChargesInformation is a structure which represents the data for charges information attribute
+type ChargesInformation struct {
+	BearerCode    string          `json:"bearer_code,omitempty"`
+	SenderCharges []SenderCharges `json:"sender_charges,omitempty"`
+	Amount        float64         `json:"receiver_charges_amount,string,omitempty"`
+	Currency      string          `json:"receiver_charges_currency,omitempty"`
+}
+
+// A SenderCharges is a structure which represents the data for a single sender charge within the payment
+type SenderCharges struct {
+	Amount   float64 `json:"amount,string,omitempty"`
+	Currency string  `json:"currency,omitempty"`
+}
+
+// An FX is a structure which represents the data for foreign exchange attribute
+type FX struct {
+	ContractReference string  `json:"contract_reference,omitempty"`
+	ExchangeRate      float64 `json:"exchange_rate,string,omitempty"`
+	OriginalAmount    float64 `json:"original_amount,string,omitempty"`
+	OriginalCurrency  string  `json:"original_currency,omitempty"`
+}
+
+// A Filter selects the payments of a list, an empty criterion matches all payments
+type Filter struct {
+	OrganisationID string
+	PaymentScheme  string
+	ProcessingDate string
+	PageSize       int
+}
+
+// The links and the payments of the responses of the API
+type links struct {
+	Self string `json:"self,omitempty"`
+	Next string `json:"next,omitempty"`
+}
+
+type paymentResult struct {
+	Data  Payment `json:"data"`
+	Links links   `json:"links"`
+}
+
+type paymentListResult struct {
+	Data  []Payment `json:"data"`
+	Links links     `json:"links"`
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	. "github.com/stretchr/testify/assert"
+	"github.com/vba270419/payments-backend-go/client"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// FilterRecordingRepositoryMock records the filters the payments are found by
+type FilterRecordingRepositoryMock struct {
+	PaymentRepositoryMock
+	filters []PaymentFilter
+}
+
+func (m *FilterRecordingRepositoryMock) FindPayments(filter PaymentFilter) (payments []Payment, err error) {
+	m.filters = append(m.filters, filter)
+	return m.PaymentRepositoryMock.FindPayments(filter)
+}
+
+// The models of the client package and the models of the server they are copied from
+var clientModels = map[reflect.Type]reflect.Type{
+	reflect.TypeOf(client.Payment{}):            reflect.TypeOf(Payment{}),
+	reflect.TypeOf(client.Attributes{}):         reflect.TypeOf(Attributes{}),
+	reflect.TypeOf(client.SponsorParty{}):       reflect.TypeOf(SponsorParty{}),
+	reflect.TypeOf(client.DebtorParty{}):        reflect.TypeOf(DebtorParty{}),
+	reflect.TypeOf(client.BeneficiaryParty{}):   reflect.TypeOf(BeneficiaryParty{}),
+	reflect.TypeOf(client.ChargesInformation{}): reflect.TypeOf(ChargesInformation{}),
+	reflect.TypeOf(client.SenderCharges{}):      reflect.TypeOf(SenderCharges{}),
+	reflect.TypeOf(client.FX{}):                 reflect.TypeOf(FX{}),
+}
+
+func TestClientModelsFollowModels(t *testing.T) {
+	for clientModel, model := range clientModels {
+		properties := make(map[string]string)
+		for name, kind := range ModelProperties(clientModel) {
+			properties[name] = strings.ReplaceAll(kind, "unknown:client.", "ref:")
+		}
+		Equal(t, ModelProperties(model), properties, clientModel.Name())
+	}
+}
+
+// Test the client against the router
+
+func TestClientCreateAndGetPayment(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful}
+	paymentsClient := ServeClient(t, repository, nil)
+
+	created, err := paymentsClient.Create(context.Background(), client.Payment{OrganisationID: "123"})
+	Nil(t, err)
+	NotEmpty(t, created.ID)
+	Equal(t, 1, created.Version)
+	Equal(t, 1, len(repository.payments))
+	Equal(t, created.ID, repository.payments[0].ID)
+
+	payment, err := paymentsClient.Get(context.Background(), created.ID)
+	Nil(t, err)
+	Equal(t, created.ID, payment.ID)
+	Equal(t, float64(10), payment.Attributes.Amount)
+	Equal(t, "GBP", payment.Attributes.Currency)
+}
+
+func TestClientCreateRetriedOnce(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful}
+	var attempts int
+
+	// The gateway loses the response of the first request, the payment is stored by it though
+	paymentsClient := ServeClient(t, repository, func(writer http.ResponseWriter, request *http.Request, next http.Handler) {
+		attempts++
+		if attempts == 1 {
+			next.ServeHTTP(httptest.NewRecorder(), request)
+			writer.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		next.ServeHTTP(writer, request)
+	})
+
+	created, err := paymentsClient.CreateWithKey(context.Background(), client.Payment{OrganisationID: "123"}, "order-42")
+
+	Nil(t, err)
+	Equal(t, 2, attempts)
+	Equal(t, 1, len(repository.payments))
+	Equal(t, repository.payments[0].ID, created.ID)
+}
+
+func TestClientUpdateAndDeletePayment(t *testing.T) {
+	paymentsClient := ServeClient(t, &PaymentRepositoryMock{mode: successful}, nil)
+
+	updated, err := paymentsClient.Update(context.Background(), client.Payment{ID: "1", OrganisationID: "123", Version: 1})
+	Nil(t, err)
+	Equal(t, 2, updated.Version)
+
+	Nil(t, paymentsClient.Delete(context.Background(), "1"))
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := ServeClient(t, &PaymentRepositoryMock{mode: notFound}, nil).Get(ctx, "1")
+	Equal(t, &client.PaymentNotFoundError{PaymentID: "1"}, err)
+
+	_, err = ServeClient(t, &PaymentRepositoryMock{mode: versionConflict}, nil).Update(ctx, client.Payment{ID: "1", OrganisationID: "123", Version: 3})
+	Equal(t, &client.PaymentVersionConflictError{PaymentID: "1", Version: 3}, err)
+
+	_, err = ServeClient(t, &PaymentRepositoryMock{mode: successful}, nil).Create(ctx, client.Payment{})
+	IsType(t, &client.InvalidRequestError{}, err)
+
+	err = ServeClient(t, &PaymentRepositoryMock{mode: dbFailure}, nil).Delete(ctx, "1")
+	Equal(t, &client.PersistenceError{}, err)
+}
+
+func TestClientListsPages(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful}
+	for i := 1; i <= 7; i++ {
+		repository.payments = append(repository.payments, Payment{ID: fmt.Sprint(i), OrganisationID: fmt.Sprint(i % 2)})
+	}
+	paymentsClient := ServeClient(t, repository, nil)
+
+	var ids []string
+	payments := paymentsClient.List(context.Background(), client.Filter{OrganisationID: "1", PageSize: 2})
+	for payments.Next() {
+		ids = append(ids, payments.Payment().ID)
+	}
+
+	Nil(t, payments.Err())
+	Equal(t, []string{"1", "3", "5", "7"}, ids)
+}
+
+// Test the paging and the idempotency of the payments endpoints
+
+func TestGetAllPaymentsPage(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful}
+	for i := 1; i <= 5; i++ {
+		repository.payments = append(repository.payments, Payment{ID: fmt.Sprint(i), OrganisationID: "123"})
+	}
+	setPaymentRepository(repository)
+	router := configureRouter()
+
+	var result PaymentListResult
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(methodGet, paymentsV2Path+"?limit=2&after=2", http.NoBody))
+	_ = json.NewDecoder(response.Body).Decode(&result)
+
+	Equal(t, 200, response.Code)
+	Equal(t, 2, len(result.Data))
+	Equal(t, "3", result.Data[0].ID)
+	Equal(t, "4", result.Data[1].ID)
+	Contains(t, result.Links.Next, "after=4")
+	Contains(t, result.Links.Next, "limit=2")
+
+	result = PaymentListResult{}
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(methodGet, paymentsV2Path+"?limit=2&after=4", http.NoBody))
+	_ = json.NewDecoder(response.Body).Decode(&result)
+
+	Equal(t, 1, len(result.Data))
+	Equal(t, "5", result.Data[0].ID)
+	Empty(t, result.Links.Next)
+}
+
+func TestGetAllPaymentsPageSelectedByRepository(t *testing.T) {
+	repository := &FilterRecordingRepositoryMock{PaymentRepositoryMock: PaymentRepositoryMock{mode: successful}}
+	for i := 1; i <= 5; i++ {
+		repository.payments = append(repository.payments, Payment{ID: fmt.Sprint(i), OrganisationID: "123"})
+	}
+	setPaymentRepository(repository)
+	router := configureRouter()
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(methodGet, paymentsV2Path+"?limit=2&after=2&organisation_id=123", http.NoBody))
+
+	Equal(t, 200, response.Code)
+	Equal(t, []PaymentFilter{{OrganisationID: "123", After: "2", Limit: 3}}, repository.filters)
+}
+
+func TestGetAllPaymentsInvalidLimit(t *testing.T) {
+	Equal(t, 400, ServeHTTP(methodGet, paymentsV2Path+"?limit=0", http.NoBody, successful).Code)
+	Equal(t, 400, ServeHTTP(methodGet, paymentsV2Path+"?limit=101", http.NoBody, successful).Code)
+}
+
+func TestCreatePaymentIdempotent(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful}
+	setPaymentRepository(repository)
+	router := configureRouter()
+
+	var locations []string
+	for i := 0; i < 2; i++ {
+		request := httptest.NewRequest(methodPost, paymentsV2Path, MockPayment("", "123"))
+		request.Header.Set(idempotencyKeyHeader, "order-42")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+
+		Equal(t, 201, response.Code)
+		locations = append(locations, response.Header().Get("Location"))
+		if i > 0 {
+			Contains(t, response.Body.String(), `"organisation_id":"123"`)
+		}
+	}
+
+	Equal(t, 1, len(repository.payments))
+	Equal(t, locations[0], locations[1])
+}
+
+func TestCreatePaymentIdempotencyKeyReused(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful}
+	setPaymentRepository(repository)
+	router := configureRouter()
+
+	var codes []int
+	for _, organisationID := range []string{"123", "123"} {
+		body, _ := json.Marshal(Payment{OrganisationID: organisationID, Attributes: Attributes{Amount: float64(len(codes) + 1)}})
+		request := httptest.NewRequest(methodPost, paymentsV2Path, bytes.NewReader(body))
+		request.Header.Set(idempotencyKeyHeader, "order-42")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		codes = append(codes, response.Code)
+	}
+
+	Equal(t, []int{201, 422}, codes)
+	Equal(t, 1, len(repository.payments))
+}
+
+// ---------------------------------------------------- //
+
+// ServeClient returns a client of a server routing the requests to the repository, the requests pass through the
+// gateway function when it is set
+func ServeClient(t *testing.T, repository *PaymentRepositoryMock, gateway func(writer http.ResponseWriter, request *http.Request, next http.Handler)) *client.Client {
+	setPaymentRepository(repository)
+	var handler http.Handler = configureRouter()
+
+	if gateway != nil {
+		router := handler
+		handler = http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			gateway(writer, request, router)
+		})
+	}
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return client.New(server.URL, client.WithRetries(2, time.Millisecond))
+}
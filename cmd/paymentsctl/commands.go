@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/vba270419/payments-backend-go/client"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// The environment a command runs in
+type environment struct {
+	client  *client.Client
+	printer printer
+	input   io.Reader
+	output  io.Writer
+}
+
+type command func(ctx context.Context, env *environment, args []string) error
+
+var commands = map[string]command{
+	"create": createCommand,
+	"get":    getCommand,
+	"update": updateCommand,
+	"delete": deleteCommand,
+	"list":   listCommand,
+	"export": exportCommand,
+}
+
+func createCommand(ctx context.Context, env *environment, args []string) error {
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	file := flags.String("f", "", "json file of the payment, - reads standard input")
+	key := flags.String("key", "", "idempotency key of the creation, a new key is used when it is not set")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if len(*file) == 0 {
+		return errors.New("create requires the payment file (-f)")
+	}
+
+	var content []byte
+	var err error
+	if *file == "-" {
+		content, err = io.ReadAll(env.input)
+	} else {
+		content, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		return err
+	}
+
+	var payment client.Payment
+	if err = json.Unmarshal(content, &payment); err != nil {
+		return fmt.Errorf("invalid payment file: %w", err)
+	}
+
+	if len(*key) > 0 {
+		payment, err = env.client.CreateWithKey(ctx, payment, *key)
+	} else {
+		payment, err = env.client.Create(ctx, payment)
+	}
+	if err != nil {
+		return err
+	}
+	return printPayments(env.printer, payment)
+}
+
+func getCommand(ctx context.Context, env *environment, args []string) error {
+	paymentID, err := paymentIDArgument("get", args)
+	if err != nil {
+		return err
+	}
+
+	payment, err := env.client.Get(ctx, paymentID)
+	if err != nil {
+		return err
+	}
+	return printPayments(env.printer, payment)
+}
+
+// updateCommand edits the payment in the editor of $EDITOR and stores it with the version it was fetched with
+func updateCommand(ctx context.Context, env *environment, args []string) error {
+	paymentID, err := paymentIDArgument("update", args)
+	if err != nil {
+		return err
+	}
+
+	payment, err := env.client.Get(ctx, paymentID)
+	if err != nil {
+		return err
+	}
+
+	original, err := json.MarshalIndent(payment, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	edited, err := editDocument(ctx, paymentID+".json", original)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(bytes.TrimSpace(edited), bytes.TrimSpace(original)) {
+		_, err = fmt.Fprintln(env.output, "Payment is not changed")
+		return err
+	}
+
+	var updated client.Payment
+	if err = json.Unmarshal(edited, &updated); err != nil {
+		return fmt.Errorf("invalid payment: %w", err)
+	}
+	updated.ID = payment.ID
+	updated.Version = payment.Version
+
+	updated, err = env.client.Update(ctx, updated)
+	var conflict *client.PaymentVersionConflictError
+	if errors.As(err, &conflict) {
+		return fmt.Errorf("payment '%s' has been changed since version %d, run update again", paymentID, payment.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return printPayments(env.printer, updated)
+}
+
+func deleteCommand(ctx context.Context, env *environment, args []string) error {
+	paymentID, err := paymentIDArgument("delete", args)
+	if err != nil {
+		return err
+	}
+
+	if err = env.client.Delete(ctx, paymentID); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(env.output, "Payment %s is deleted\n", paymentID)
+	return err
+}
+
+func listCommand(ctx context.Context, env *environment, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	filter := filterFlags(flags)
+	flags.IntVar(&filter.PageSize, "page-size", 0, "number of payments fetched with a single request")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	payments := env.client.List(ctx, *filter)
+	for payments.Next() {
+		if err := env.printer.print(payments.Payment()); err != nil {
+			return err
+		}
+	}
+
+	if err := env.printer.close(); err != nil {
+		return err
+	}
+	return payments.Err()
+}
+
+// exportCommand streams the export of the server to the output as it is, the output format doesn't apply to it
+func exportCommand(ctx context.Context, env *environment, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	filter := filterFlags(flags)
+	format := flags.String("format", "csv", "format of the export, csv or ndjson")
+	columns := flags.String("columns", "", "comma separated columns of a CSV export, the server default when not set")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	mediaTypes := map[string]string{"csv": "text/csv", "ndjson": "application/x-ndjson"}
+	mediaType, ok := mediaTypes[*format]
+	if !ok {
+		return fmt.Errorf("unknown export format '%s', expected csv or ndjson", *format)
+	}
+
+	var columnList []string
+	if len(*columns) > 0 {
+		columnList = strings.Split(*columns, ",")
+	}
+
+	export, err := env.client.Export(ctx, *filter, mediaType, columnList)
+	if err != nil {
+		return err
+	}
+	defer export.Close()
+
+	_, err = io.Copy(env.output, export)
+	return err
+}
+
+func filterFlags(flags *flag.FlagSet) *client.Filter {
+	filter := &client.Filter{}
+	flags.StringVar(&filter.OrganisationID, "organisation-id", "", "organisation of the payments")
+	flags.StringVar(&filter.PaymentScheme, "payment-scheme", "", "payment scheme of the payments")
+	flags.StringVar(&filter.ProcessingDate, "processing-date", "", "processing date of the payments (YYYY-MM-DD)")
+	return filter
+}
+
+func paymentIDArgument(command string, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("%s requires a single payment id", command)
+	}
+	return args[0], nil
+}
+
+func printPayments(printer printer, payments ...client.Payment) error {
+	for _, payment := range payments {
+		if err := printer.print(payment); err != nil {
+			return err
+		}
+	}
+	return printer.close()
+}
+
+// editDocument edits the document in a temporary file with the editor of $EDITOR and returns the saved document
+func editDocument(ctx context.Context, name string, document []byte) ([]byte, error) {
+	directory, err := os.MkdirTemp("", "paymentsctl")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(directory)
+
+	file := filepath.Join(directory, name)
+	if err = os.WriteFile(file, document, 0600); err != nil {
+		return nil, err
+	}
+
+	editor := envOrDefault("EDITOR", "vi")
+	command := exec.CommandContext(ctx, "sh", "-c", editor+` "$1"`, "sh", file)
+	command.Stdin, command.Stdout, command.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err = command.Run(); err != nil {
+		return nil, fmt.Errorf("editor %s failed: %w", editor, err)
+	}
+
+	return os.ReadFile(file)
+}
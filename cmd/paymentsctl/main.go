@@ -0,0 +1,89 @@
+// Command paymentsctl manages the payments of a payments server from the command line.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+)
+
+const usage = `Usage: paymentsctl [flags] <command> [arguments]
+
+Commands:
+  create -f <file.json> [-key <idempotency key>]   create the payment of the file ("-" reads standard input)
+  get <id>                                         print the payment
+  update <id>                                      edit the payment with $EDITOR and store it
+  delete <id>                                      delete the payment
+  list [filters]                                   print the payments matching the filters
+  export [-format csv|ndjson] [-columns a,b] [filters]   stream the payments matching the filters
+
+Filters: -organisation-id, -payment-scheme, -processing-date
+
+Flags:
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			_, _ = fmt.Fprintln(os.Stderr, "paymentsctl:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// run executes the command of the arguments
+func run(ctx context.Context, args []string, input io.Reader, output io.Writer) error {
+	flags := flag.NewFlagSet("paymentsctl", flag.ContinueOnError)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+
+	configFile := flags.String("config", defaultConfigFile(), "path to the profiles file")
+	profileName := flags.String("profile", envOrDefault("PAYMENTSCTL_PROFILE", defaultProfile), "name of the profile")
+	server := flags.String("server", "", "URL of the payments server, overrides the server of the profile")
+	format := flags.String("o", tableFormat, "output format, one of table, json or yaml")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	printer, err := newPrinter(*format, output)
+	if err != nil {
+		return err
+	}
+
+	profile, err := loadProfile(*configFile, *profileName)
+	if err != nil {
+		return err
+	}
+	if len(*server) > 0 {
+		profile.Server = *server
+	}
+
+	command, ok := commands[flags.Arg(0)]
+	if !ok {
+		flags.Usage()
+		return fmt.Errorf("unknown command '%s'", flags.Arg(0))
+	}
+
+	return command(ctx, &environment{profile.newClient(), printer, input, output}, flags.Args()[1:])
+}
+
+func envOrDefault(name string, defaultValue string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+	return defaultValue
+}
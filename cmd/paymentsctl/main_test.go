@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	. "github.com/stretchr/testify/assert"
+	"github.com/vba270419/payments-backend-go/client"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// PaymentsServerMock serves the v2 payment routes from memory
+type PaymentsServerMock struct {
+	payments      map[string]client.Payment
+	authorization string
+}
+
+func (s *PaymentsServerMock) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	s.authorization = request.Header.Get("Authorization")
+	paymentID := strings.TrimPrefix(request.URL.Path, "/v2/payments/")
+
+	switch {
+	case request.URL.Path == "/v1/payments/export":
+		writer.Header().Set("Content-Type", request.Header.Get("Accept"))
+		_, _ = writer.Write([]byte("id,version\n1,1\n"))
+	case request.URL.Path == "/v2/payments" && request.Method == http.MethodPost:
+		var payment client.Payment
+		_ = json.NewDecoder(request.Body).Decode(&payment)
+		payment.ID, payment.Version = "new", 1
+		s.payments[payment.ID] = payment
+		writer.Header().Set("Location", "http://"+request.Host+"/v2/payments/new")
+		writer.WriteHeader(http.StatusCreated)
+	case request.URL.Path == "/v2/payments":
+		var page []client.Payment
+		for _, id := range []string{"1", "2"} {
+			if payment, ok := s.payments[id]; ok {
+				page = append(page, payment)
+			}
+		}
+		_ = json.NewEncoder(writer).Encode(map[string]interface{}{"data": page})
+	case request.Method == http.MethodPut:
+		var payment client.Payment
+		_ = json.NewDecoder(request.Body).Decode(&payment)
+		if s.payments[paymentID].Version != payment.Version {
+			writer.WriteHeader(http.StatusConflict)
+			return
+		}
+		payment.Version++
+		s.payments[paymentID] = payment
+	default:
+		payment, ok := s.payments[paymentID]
+		if !ok {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if request.Method == http.MethodDelete {
+			delete(s.payments, paymentID)
+			return
+		}
+		_ = json.NewEncoder(writer).Encode(map[string]interface{}{"data": payment})
+	}
+}
+
+func TestGetPaymentAsTable(t *testing.T) {
+	server := ServePayments(t)
+
+	output, err := RunCommand(t, server, "", "get", "1")
+
+	Nil(t, err)
+	Equal(t, "ID  VERSION  ORGANISATION  AMOUNT  CURRENCY  SCHEME  PROCESSING DATE  REFERENCE\n"+
+		"1   2        123           100.21  GBP       FPS     2017-01-18       Invoice 1\n", output)
+}
+
+func TestGetPaymentAsJSON(t *testing.T) {
+	output, err := RunCommand(t, ServePayments(t), "", "-o", "json", "get", "1")
+
+	Nil(t, err)
+	JSONEq(t, `{"id": "1", "version": 2, "organisation_id": "123", "attributes": {"amount": "100.21", "currency": "GBP",
+		"payment_scheme": "FPS", "processing_date": "2017-01-18", "reference": "Invoice 1", "beneficiary_party": {},
+		"charges_information": {}, "debtor_party": {}, "fx": {}, "sponsor_party": {}}}`, output)
+}
+
+func TestGetPaymentAsYAML(t *testing.T) {
+	output, err := RunCommand(t, ServePayments(t), "", "-o", "yaml", "get", "1")
+
+	Nil(t, err)
+	Contains(t, output, "id: \"1\"\n")
+	Contains(t, output, "organisation_id: \"123\"\n")
+	Contains(t, output, "    amount: \"100.21\"\n")
+}
+
+func TestGetPaymentNotFound(t *testing.T) {
+	_, err := RunCommand(t, ServePayments(t), "", "get", "3")
+
+	Equal(t, &client.PaymentNotFoundError{PaymentID: "3"}, err)
+}
+
+func TestCreatePaymentFromFile(t *testing.T) {
+	server := ServePayments(t)
+	file := filepath.Join(t.TempDir(), "payment.json")
+	_ = os.WriteFile(file, []byte(`{"organisation_id": "456", "attributes": {"amount": "5"}}`), 0600)
+
+	output, err := RunCommand(t, server, "", "-o", "json", "create", "-f", file)
+
+	Nil(t, err)
+	Contains(t, output, `"id": "new"`)
+	Equal(t, "456", server.payments["new"].OrganisationID)
+	Equal(t, float64(5), server.payments["new"].Attributes.Amount)
+}
+
+func TestUpdatePaymentWithEditor(t *testing.T) {
+	server := ServePayments(t)
+	editor := filepath.Join(t.TempDir(), "editor.sh")
+	_ = os.WriteFile(editor, []byte("#!/bin/sh\nsed -i -e 's/Invoice 1/Invoice 2/' -e 's/\"version\": 2/\"version\": 7/' \"$1\"\n"), 0700)
+	t.Setenv("EDITOR", editor)
+
+	output, err := RunCommand(t, server, "", "update", "1")
+
+	Nil(t, err)
+	Contains(t, output, "Invoice 2")
+	Equal(t, "Invoice 2", server.payments["1"].Attributes.Reference)
+	Equal(t, 3, server.payments["1"].Version)
+}
+
+func TestUpdatePaymentNotChanged(t *testing.T) {
+	server := ServePayments(t)
+	t.Setenv("EDITOR", "true")
+
+	output, err := RunCommand(t, server, "", "update", "1")
+
+	Nil(t, err)
+	Equal(t, "Payment is not changed\n", output)
+	Equal(t, 2, server.payments["1"].Version)
+}
+
+func TestDeletePayment(t *testing.T) {
+	server := ServePayments(t)
+
+	output, err := RunCommand(t, server, "", "delete", "2")
+
+	Nil(t, err)
+	Equal(t, "Payment 2 is deleted\n", output)
+	NotContains(t, server.payments, "2")
+}
+
+func TestListPayments(t *testing.T) {
+	output, err := RunCommand(t, ServePayments(t), "", "list", "-organisation-id", "123")
+
+	Nil(t, err)
+	Equal(t, 3, strings.Count(output, "\n"))
+	Contains(t, output, "\n2 ")
+}
+
+func TestExportPayments(t *testing.T) {
+	output, err := RunCommand(t, ServePayments(t), "", "export", "-format", "csv")
+
+	Nil(t, err)
+	Equal(t, "id,version\n1,1\n", output)
+}
+
+func TestProfileCredentials(t *testing.T) {
+	server := ServePayments(t)
+	config := filepath.Join(t.TempDir(), "profiles.yaml")
+	_ = os.WriteFile(config, []byte("staging:\n  server: "+server.URL+"\n  token: secret\n"), 0600)
+
+	_, err := RunCommand(t, server, config, "-profile", "staging", "get", "1")
+
+	Nil(t, err)
+	Equal(t, "Bearer secret", server.authorization)
+}
+
+func TestLoadProfile(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "profiles.yaml")
+	_ = os.WriteFile(config, []byte("default:\n  username: support\n  password: pass\n"), 0600)
+
+	selected, err := loadProfile(config, "default")
+	Nil(t, err)
+	Equal(t, profile{Server: defaultServer, Username: "support", Password: "pass"}, selected)
+
+	_, err = loadProfile(config, "production")
+	EqualError(t, err, "profile 'production' is not found in "+config)
+
+	selected, err = loadProfile(filepath.Join(t.TempDir(), "missing.yaml"), "default")
+	Nil(t, err)
+	Equal(t, profile{Server: defaultServer}, selected)
+}
+
+func TestUnknownOutputFormat(t *testing.T) {
+	_, err := RunCommand(t, ServePayments(t), "", "-o", "xml", "get", "1")
+
+	EqualError(t, err, "unknown output format 'xml', expected table, json or yaml")
+}
+
+// ---------------------------------------------------- //
+
+type PaymentsServer struct {
+	*PaymentsServerMock
+	URL string
+}
+
+func ServePayments(t *testing.T) PaymentsServer {
+	attributes := client.Attributes{Amount: 100.21, Currency: "GBP", PaymentScheme: "FPS", ProcessingDate: "2017-01-18", Reference: "Invoice 1"}
+	mock := &PaymentsServerMock{payments: map[string]client.Payment{
+		"1": {ID: "1", Version: 2, OrganisationID: "123", Attributes: attributes},
+		"2": {ID: "2", Version: 1, OrganisationID: "123"}}}
+
+	server := httptest.NewServer(mock)
+	t.Cleanup(server.Close)
+	return PaymentsServer{mock, server.URL}
+}
+
+// RunCommand runs paymentsctl with the arguments against the server, or against the server of the profile when the
+// profiles file is given, and returns its output
+func RunCommand(t *testing.T, server PaymentsServer, config string, args ...string) (string, error) {
+	if len(config) == 0 {
+		config = filepath.Join(t.TempDir(), "missing.yaml")
+		args = append([]string{"-server", server.URL}, args...)
+	}
+
+	var output bytes.Buffer
+	err := run(context.Background(), append([]string{"-config", config}, args...), strings.NewReader(""), &output)
+	return output.String(), err
+}
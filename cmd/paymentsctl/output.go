@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/vba270419/payments-backend-go/client"
+	"gopkg.in/yaml.v3"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+const (
+	tableFormat string = "table"
+	jsonFormat  string = "json"
+	yamlFormat  string = "yaml"
+)
+
+// The columns of the payments table
+var tableColumns = []string{"ID", "VERSION", "ORGANISATION", "AMOUNT", "CURRENCY", "SCHEME", "PROCESSING DATE", "REFERENCE"}
+
+// A printer writes the payments of a command in the output format
+type printer interface {
+	print(payment client.Payment) error
+	close() error
+}
+
+func newPrinter(format string, output io.Writer) (printer, error) {
+	switch format {
+	case tableFormat:
+		return &tablePrinter{writer: tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)}, nil
+	case jsonFormat:
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+		return &jsonPrinter{encoder}, nil
+	case yamlFormat:
+		return &yamlPrinter{encoder: yaml.NewEncoder(output)}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format '%s', expected table, json or yaml", format)
+	}
+}
+
+// tablePrinter prints a row of the main fields of every payment, the columns are aligned when the printer is closed
+type tablePrinter struct {
+	writer *tabwriter.Writer
+	rows   int
+}
+
+func (p *tablePrinter) print(payment client.Payment) error {
+	if p.rows == 0 {
+		if err := p.row(tableColumns); err != nil {
+			return err
+		}
+	}
+	p.rows++
+
+	return p.row([]string{
+		payment.ID,
+		strconv.Itoa(payment.Version),
+		payment.OrganisationID,
+		strconv.FormatFloat(payment.Attributes.Amount, 'f', -1, 64),
+		payment.Attributes.Currency,
+		payment.Attributes.PaymentScheme,
+		payment.Attributes.ProcessingDate,
+		payment.Attributes.Reference})
+}
+
+func (p *tablePrinter) row(cells []string) error {
+	for i, cell := range cells {
+		separator := "\t"
+		if i == len(cells)-1 {
+			separator = "\n"
+		}
+		if _, err := fmt.Fprint(p.writer, cell+separator); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *tablePrinter) close() error {
+	return p.writer.Flush()
+}
+
+// jsonPrinter prints every payment as an indented json document like the one the server accepts
+type jsonPrinter struct {
+	encoder *json.Encoder
+}
+
+func (p *jsonPrinter) print(payment client.Payment) error {
+	return p.encoder.Encode(payment)
+}
+
+func (p *jsonPrinter) close() error {
+	return nil
+}
+
+// yamlPrinter prints every payment as a YAML document, the properties are named like the json ones
+type yamlPrinter struct {
+	encoder *yaml.Encoder
+}
+
+func (p *yamlPrinter) print(payment client.Payment) error {
+	document, err := json.Marshal(payment)
+	if err != nil {
+		return err
+	}
+
+	var properties map[string]interface{}
+	if err = json.Unmarshal(document, &properties); err != nil {
+		return err
+	}
+	return p.encoder.Encode(properties)
+}
+
+func (p *yamlPrinter) close() error {
+	return p.encoder.Close()
+}
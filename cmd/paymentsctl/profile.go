@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"github.com/vba270419/payments-backend-go/client"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+)
+
+const (
+	defaultProfile string = "default"
+	defaultServer  string = "http://127.0.0.1:8000"
+)
+
+// A profile is the server and the credentials the commands are sent to and with
+type profile struct {
+	Server   string `yaml:"server"`
+	Token    string `yaml:"token,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+}
+
+// defaultConfigFile is the file named by PAYMENTSCTL_CONFIG or ~/.paymentsctl.yaml
+func defaultConfigFile() string {
+	if file, ok := os.LookupEnv("PAYMENTSCTL_CONFIG"); ok {
+		return file
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".paymentsctl.yaml"
+	}
+	return filepath.Join(home, ".paymentsctl.yaml")
+}
+
+// loadProfile reads the named profile of the file
+func loadProfile(file string, name string) (profile, error) {
+	content, err := os.ReadFile(file)
+	if os.IsNotExist(err) && name == defaultProfile {
+		return profile{Server: defaultServer}, nil
+	}
+	if err != nil {
+		return profile{}, err
+	}
+
+	var profiles map[string]profile
+	if err = yaml.Unmarshal(content, &profiles); err != nil {
+		return profile{}, fmt.Errorf("invalid profiles file %s: %w", file, err)
+	}
+
+	selected, ok := profiles[name]
+	if !ok {
+		return profile{}, fmt.Errorf("profile '%s' is not found in %s", name, file)
+	}
+	if len(selected.Server) == 0 {
+		selected.Server = defaultServer
+	}
+	return selected, nil
+}
+
+func (p profile) newClient() *client.Client {
+	var options []client.Option
+	if len(p.Token) > 0 {
+		options = append(options, client.WithBearerToken(p.Token))
+	} else if len(p.Username) > 0 {
+		options = append(options, client.WithBasicAuth(p.Username, p.Password))
+	}
+	return client.New(p.Server, options...)
+}
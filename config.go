@@ -0,0 +1,393 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/spf13/viper"
+	"io/fs"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	serverHost    string = "server_host"
+	serverPort    string = "server_port"
+	serverTimeout string = "server_timeout"
+	grpcPort      string = "grpc_port"
+	exportTimeout string = "export_timeout"
+
+	mongoDbHost    string = "mongodb_host"
+	mongoDbPort    string = "mongodb_port"
+	mongoDbTimeout string = "mongodb_timeout"
+
+	repositoryType             string = "repository_type"
+	eventStoreSnapshotInterval string = "eventstore_snapshot_interval"
+
+	outboxEnabled         string = "outbox_enabled"
+	webhookTimeout        string = "webhook_timeout"
+	webhookMaxAttempts    string = "webhook_max_attempts"
+	webhookInitialBackoff string = "webhook_initial_backoff"
+	webhookPollInterval   string = "webhook_poll_interval"
+
+	eventPublisherType string = "event_publisher"
+	natsURL            string = "nats_url"
+	natsSubjectPrefix  string = "nats_subject_prefix"
+	kafkaBrokers       string = "kafka_brokers"
+	kafkaTopic         string = "kafka_topic"
+
+	bacsServiceUserNumber string = "bacs_service_user_number"
+	bacsServiceUserName   string = "bacs_service_user_name"
+
+	sepaInitiatingPartyName string = "sepa_initiating_party_name"
+
+	v1DeprecationDate string = "v1_deprecation_date"
+	v1SunsetDate      string = "v1_sunset_date"
+	configDateLayout  string = "2006-01-02"
+
+	openAPIValidation string = "openapi_validation"
+
+	graphqlMaxCostProperty string = "graphql_max_cost"
+
+	tracingExporter     string = "tracing_exporter"
+	tracingOTLPEndpoint string = "tracing_otlp_endpoint"
+
+	logLevel  string = "log_level"
+	logFormat string = "log_format"
+
+	readinessTimeoutProperty  string = "readiness_timeout"
+	readinessCacheTTLProperty string = "readiness_cache_ttl"
+	outboxBacklogLimit        string = "outbox_backlog_limit"
+	shutdownDrainDelay        string = "shutdown_drain_delay"
+
+	crudRepository         string = "crud"
+	eventSourcedRepository string = "event_sourced"
+
+	configurationFileFlag    string = "conf"
+	defaultConfigurationFile string = "./config/server.json"
+	configurationEnvPrefix   string = "PAYMENTS"
+	redactedSecret           string = "xxxxx"
+)
+
+// A configuration holds the effective properties of an application, the durations are in seconds
+type configuration struct {
+	ServerHost    string `json:"server_host"`
+	ServerPort    string `json:"server_port"`
+	ServerTimeout int    `json:"server_timeout"`
+	GRPCPort      string `json:"grpc_port"`
+	ExportTimeout int    `json:"export_timeout"`
+
+	MongoDbHost    string `json:"mongodb_host"`
+	MongoDbPort    string `json:"mongodb_port"`
+	MongoDbTimeout int    `json:"mongodb_timeout"`
+
+	RepositoryType             string `json:"repository_type"`
+	EventStoreSnapshotInterval int    `json:"eventstore_snapshot_interval"`
+
+	OutboxEnabled         bool `json:"outbox_enabled"`
+	WebhookTimeout        int  `json:"webhook_timeout"`
+	WebhookMaxAttempts    int  `json:"webhook_max_attempts"`
+	WebhookInitialBackoff int  `json:"webhook_initial_backoff"`
+	WebhookPollInterval   int  `json:"webhook_poll_interval"`
+
+	EventPublisher    string `json:"event_publisher"`
+	NatsURL           string `json:"nats_url"`
+	NatsSubjectPrefix string `json:"nats_subject_prefix"`
+	KafkaBrokers      string `json:"kafka_brokers"`
+	KafkaTopic        string `json:"kafka_topic"`
+
+	BacsServiceUserNumber string `json:"bacs_service_user_number"`
+	BacsServiceUserName   string `json:"bacs_service_user_name"`
+
+	SepaInitiatingPartyName string `json:"sepa_initiating_party_name"`
+
+	V1DeprecationDate string `json:"v1_deprecation_date"`
+	V1SunsetDate      string `json:"v1_sunset_date"`
+
+	OpenAPIValidation string `json:"openapi_validation"`
+
+	GraphQLMaxCost int `json:"graphql_max_cost"`
+
+	TracingExporter     string `json:"tracing_exporter"`
+	TracingOTLPEndpoint string `json:"tracing_otlp_endpoint"`
+
+	LogLevel  string `json:"log_level"`
+	LogFormat string `json:"log_format"`
+
+	ReadinessTimeout   int   `json:"readiness_timeout"`
+	ReadinessCacheTTL  int   `json:"readiness_cache_ttl"`
+	OutboxBacklogLimit int64 `json:"outbox_backlog_limit"`
+	ShutdownDrainDelay int   `json:"shutdown_drain_delay"`
+}
+
+// A configurationProblem tells why the property can't be used
+type configurationProblem struct {
+	property string
+	message  string
+}
+
+// configurationProperties lists the property names of the configuration fields
+func configurationProperties() (properties []string) {
+	fields := reflect.TypeOf(configuration{})
+	for i := 0; i < fields.NumField(); i++ {
+		properties = append(properties, fields.Field(i).Tag.Get("json"))
+	}
+	return properties
+}
+
+// propertyFlag names the flag overriding the property, e.g. server-port for server_port
+func propertyFlag(property string) string {
+	return strings.ReplaceAll(property, "_", "-")
+}
+
+// propertyEnv names the environment variable overriding the property, e.g. PAYMENTS_SERVER_PORT for server_port
+func propertyEnv(property string) string {
+	return configurationEnvPrefix + "_" + strings.ToUpper(property)
+}
+
+// loadConfiguration reads the configuration file and overrides its properties with the environment and the flags
+func loadConfiguration(flags *flag.FlagSet, args []string) (config configuration, err error) {
+	file := flags.String(configurationFileFlag, defaultConfigurationFile, "Path to configuration file")
+	for _, property := range configurationProperties() {
+		flags.String(propertyFlag(property), "", fmt.Sprintf("Overrides the '%s' property and %s variable", property, propertyEnv(property)))
+	}
+	if err = flags.Parse(args); err != nil {
+		return config, err
+	}
+
+	properties := viper.New()
+	setConfigurationDefaults(properties)
+
+	properties.SetConfigFile(*file)
+	if err = properties.ReadInConfig(); err != nil && (isFlagSet(flags, configurationFileFlag) || !errors.Is(err, fs.ErrNotExist)) {
+		return config, fmt.Errorf("Error reading config file, %s", err)
+	}
+
+	for _, property := range configurationProperties() {
+		_ = properties.BindEnv(property, propertyEnv(property))
+		if override := flags.Lookup(propertyFlag(property)); isFlagSet(flags, override.Name) {
+			properties.Set(property, override.Value.String())
+		}
+	}
+
+	var problems []configurationProblem
+	undecoded := make(map[string]bool)
+	fields := reflect.ValueOf(&config).Elem()
+	for i, property := range configurationProperties() {
+		if value := properties.Get(property); value != nil {
+			if err = decodeProperty(fields.Field(i), value); err != nil {
+				problems = append(problems, configurationProblem{property, fmt.Sprintf("Property '%s' %s", property, err)})
+				undecoded[property] = true
+			}
+		}
+	}
+
+	// The value of a property which can't be decoded is not validated, so the property has a single problem
+	for _, problem := range config.validate() {
+		if !undecoded[problem.property] {
+			problems = append(problems, problem)
+		}
+	}
+
+	if len(problems) > 0 {
+		return config, InvalidConfigurationError{problems}
+	}
+	return config, nil
+}
+
+// decodeProperty sets the field to the value of the property
+func decodeProperty(field reflect.Value, value interface{}) error {
+	text := strings.TrimSpace(fmt.Sprint(value))
+
+	switch field.Kind() {
+	case reflect.Int, reflect.Int64:
+		number, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		field.SetInt(number)
+	case reflect.Bool:
+		enabled, err := strconv.ParseBool(text)
+		if err != nil {
+			return errors.New("must be either true or false")
+		}
+		field.SetBool(enabled)
+	default:
+		field.SetString(fmt.Sprint(value))
+	}
+	return nil
+}
+
+func setConfigurationDefaults(properties *viper.Viper) {
+	properties.SetDefault(exportTimeout, 0)
+	properties.SetDefault(grpcPort, "9000")
+	properties.SetDefault(repositoryType, crudRepository)
+	properties.SetDefault(eventStoreSnapshotInterval, 100)
+	properties.SetDefault(outboxEnabled, false)
+	properties.SetDefault(webhookTimeout, 10)
+	properties.SetDefault(webhookMaxAttempts, 8)
+	properties.SetDefault(webhookInitialBackoff, 5)
+	properties.SetDefault(webhookPollInterval, 1)
+	properties.SetDefault(eventPublisherType, noopPublisherType)
+	properties.SetDefault(natsURL, "nats://127.0.0.1:4222")
+	properties.SetDefault(natsSubjectPrefix, "payments")
+	properties.SetDefault(kafkaBrokers, "127.0.0.1:9092")
+	properties.SetDefault(kafkaTopic, "payments")
+	properties.SetDefault(bacsServiceUserNumber, "000000")
+	properties.SetDefault(bacsServiceUserName, "")
+	properties.SetDefault(sepaInitiatingPartyName, "")
+	properties.SetDefault(v1DeprecationDate, "")
+	properties.SetDefault(v1SunsetDate, "")
+	properties.SetDefault(openAPIValidation, logValidation)
+	properties.SetDefault(graphqlMaxCostProperty, 1000)
+	properties.SetDefault(tracingExporter, noTracingExporter)
+	properties.SetDefault(tracingOTLPEndpoint, "127.0.0.1:4318")
+	properties.SetDefault(logLevel, "info")
+	properties.SetDefault(logFormat, jsonLogFormat)
+	properties.SetDefault(readinessTimeoutProperty, 2)
+	properties.SetDefault(readinessCacheTTLProperty, 5)
+	properties.SetDefault(outboxBacklogLimit, 10000)
+	properties.SetDefault(shutdownDrainDelay, 0)
+}
+
+func isFlagSet(flags *flag.FlagSet, name string) (set bool) {
+	flags.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+// validate checks the values of the properties
+func (c configuration) validate() (problems []configurationProblem) {
+	required := []struct {
+		property   string
+		name       string
+		configured bool
+	}{
+		{serverHost, "Server host", c.ServerHost != ""},
+		{serverPort, "Server port", c.ServerPort != ""},
+		{mongoDbHost, "MongoDB host", c.MongoDbHost != ""},
+		{mongoDbPort, "MongoDB port", c.MongoDbPort != ""},
+	}
+	for _, property := range required {
+		if !property.configured {
+			problems = append(problems, configurationProblem{property.property, property.name + " property is not configured"})
+		}
+	}
+
+	if c.ServerTimeout < 1 {
+		problems = append(problems, configurationProblem{serverTimeout, "Server timeout property must be a positive number of seconds"})
+	}
+
+	if c.MongoDbTimeout < 1 {
+		problems = append(problems, configurationProblem{mongoDbTimeout, "MongoDB timeout property must be a positive number of seconds"})
+	}
+
+	if c.ExportTimeout < 0 {
+		problems = append(problems, configurationProblem{exportTimeout, "Export timeout property must not be negative"})
+	}
+
+	if c.RepositoryType != crudRepository && c.RepositoryType != eventSourcedRepository {
+		problems = append(problems, configurationProblem{repositoryType, fmt.Sprintf("Repository type property must be either '%s' or '%s'", crudRepository, eventSourcedRepository)})
+	}
+
+	if !serviceUserNumberPattern.MatchString(c.BacsServiceUserNumber) {
+		problems = append(problems, configurationProblem{bacsServiceUserNumber, "Bacs service user number property must have 6 digits"})
+	}
+
+	if !sepaCharset.MatchString(c.SepaInitiatingPartyName) {
+		problems = append(problems, configurationProblem{sepaInitiatingPartyName, "SEPA initiating party name property must be written in the SEPA character set"})
+	}
+
+	dates := map[string]string{v1DeprecationDate: c.V1DeprecationDate, v1SunsetDate: c.V1SunsetDate}
+	for _, property := range []string{v1DeprecationDate, v1SunsetDate} {
+		if _, err := time.Parse(configDateLayout, dates[property]); err != nil && len(dates[property]) > 0 {
+			problems = append(problems, configurationProblem{property, fmt.Sprintf("Property '%s' must be a date formatted as YYYY-MM-DD", property)})
+		}
+	}
+
+	if deprecatedAt, sunsetAt := parseConfigDate(c.V1DeprecationDate), parseConfigDate(c.V1SunsetDate); !sunsetAt.IsZero() && sunsetAt.Before(deprecatedAt) {
+		problems = append(problems, configurationProblem{v1SunsetDate, "V1 sunset date property must not be before the deprecation date"})
+	}
+
+	switch c.OpenAPIValidation {
+	case enforceValidation, logValidation, noValidation:
+	default:
+		problems = append(problems, configurationProblem{openAPIValidation, fmt.Sprintf("OpenAPI validation property must be one of '%s', '%s' or '%s'", enforceValidation, logValidation, noValidation)})
+	}
+
+	if c.GraphQLMaxCost < 1 {
+		problems = append(problems, configurationProblem{graphqlMaxCostProperty, "GraphQL max cost property must be positive"})
+	}
+
+	switch c.EventPublisher {
+	case noopPublisherType, natsPublisherType, kafkaPublisherType:
+	default:
+		problems = append(problems, configurationProblem{eventPublisherType, fmt.Sprintf("Event publisher property must be one of '%s', '%s' or '%s'", noopPublisherType, natsPublisherType, kafkaPublisherType)})
+	}
+
+	switch c.TracingExporter {
+	case noTracingExporter, otlpTracingExporter, stdoutTracingExporter:
+	default:
+		problems = append(problems, configurationProblem{tracingExporter, fmt.Sprintf("Tracing exporter property must be one of '%s', '%s' or '%s'", noTracingExporter, otlpTracingExporter, stdoutTracingExporter)})
+	}
+
+	if _, ok := logLevels[c.LogLevel]; !ok {
+		problems = append(problems, configurationProblem{logLevel, "Log level property must be one of 'debug', 'info', 'warn' or 'error'"})
+	}
+
+	if c.LogFormat != jsonLogFormat && c.LogFormat != textLogFormat {
+		problems = append(problems, configurationProblem{logFormat, fmt.Sprintf("Log format property must be either '%s' or '%s'", jsonLogFormat, textLogFormat)})
+	}
+
+	if c.ReadinessTimeout < 1 {
+		problems = append(problems, configurationProblem{readinessTimeoutProperty, "Readiness timeout property must be positive"})
+	}
+
+	if c.ReadinessCacheTTL < 0 {
+		problems = append(problems, configurationProblem{readinessCacheTTLProperty, "Readiness cache TTL property must not be negative"})
+	}
+
+	if c.OutboxBacklogLimit < 0 {
+		problems = append(problems, configurationProblem{outboxBacklogLimit, "Outbox backlog limit property must not be negative"})
+	}
+
+	if c.ShutdownDrainDelay < 0 {
+		problems = append(problems, configurationProblem{shutdownDrainDelay, "Shutdown drain delay property must not be negative"})
+	}
+
+	return problems
+}
+
+// redacted is the copy of the configuration which can be logged or printed, the credentials of the URLs are replaced
+func (c configuration) redacted() configuration {
+	c.NatsURL = redactURL(c.NatsURL)
+	return c
+}
+
+// redactURL replaces the credentials of the URL
+func redactURL(value string) string {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return redactedSecret
+	}
+	if parsed.User == nil {
+		return value
+	}
+
+	if _, ok := parsed.User.Password(); ok {
+		parsed.User = url.UserPassword(parsed.User.Username(), redactedSecret)
+	} else {
+		parsed.User = url.User(redactedSecret)
+	}
+	return parsed.String()
+}
+
+// parseConfigDate reads a date property, an empty property is the zero time
+func parseConfigDate(value string) time.Time {
+	date, _ := time.Parse(configDateLayout, value)
+	return date
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	csvFormat string = "csv"
+
+	allOrNothingImport string = "all_or_nothing"
+	bestEffortImport   string = "best_effort"
+
+	importRowCreated     string = "created"
+	importRowInvalid     string = "invalid"
+	importRowFailed      string = "failed"
+	importRowNotImported string = "not_imported"
+)
+
+// A csvColumn sets the value of a cell on the payment field the column is mapped onto
+type csvColumn func(payment *Payment, value string) error
+
+// The columns of an imported CSV file, named by the json path of the payment field without the attributes prefix
+var csvColumns = newCSVColumns()
+
+// A csvRow is a payment read from a row of the file together with the errors of its cells
+type csvRow struct {
+	row     int
+	payment Payment
+	errors  []FieldError
+}
+
+func newCSVColumns() map[string]csvColumn {
+	columns := map[string]csvColumn{
+		"organisation_id":         csvText(func(p *Payment) *string { return &p.OrganisationID }),
+		"amount":                  csvDecimal(func(p *Payment) *float64 { return &p.Attributes.Amount }),
+		"currency":                csvText(func(p *Payment) *string { return &p.Attributes.Currency }),
+		"end_to_end_reference":    csvText(func(p *Payment) *string { return &p.Attributes.EndToEndReference }),
+		"numeric_reference":       csvInteger(func(p *Payment) *int { return &p.Attributes.NumericReference }),
+		"payment_id":              csvText(func(p *Payment) *string { return &p.Attributes.PaymentID }),
+		"payment_purpose":         csvText(func(p *Payment) *string { return &p.Attributes.PaymentPurpose }),
+		"payment_scheme":          csvText(func(p *Payment) *string { return &p.Attributes.PaymentScheme }),
+		"payment_type":            csvText(func(p *Payment) *string { return &p.Attributes.PaymentType }),
+		"processing_date":         csvText(func(p *Payment) *string { return &p.Attributes.ProcessingDate }),
+		"reference":               csvText(func(p *Payment) *string { return &p.Attributes.Reference }),
+		"scheme_payment_sub_type": csvText(func(p *Payment) *string { return &p.Attributes.SchemePaymentSubType }),
+		"scheme_payment_type":     csvText(func(p *Payment) *string { return &p.Attributes.SchemePaymentType }),
+
+		"charges_information.bearer_code":               csvText(func(p *Payment) *string { return &p.Attributes.ChargesInformation.BearerCode }),
+		"charges_information.receiver_charges_amount":   csvDecimal(func(p *Payment) *float64 { return &p.Attributes.ChargesInformation.Amount }),
+		"charges_information.receiver_charges_currency": csvText(func(p *Payment) *string { return &p.Attributes.ChargesInformation.Currency }),
+
+		"fx.contract_reference": csvText(func(p *Payment) *string { return &p.Attributes.FX.ContractReference }),
+		"fx.exchange_rate":      csvDecimal(func(p *Payment) *float64 { return &p.Attributes.FX.ExchangeRate }),
+		"fx.original_amount":    csvDecimal(func(p *Payment) *float64 { return &p.Attributes.FX.OriginalAmount }),
+		"fx.original_currency":  csvText(func(p *Payment) *string { return &p.Attributes.FX.OriginalCurrency }),
+
+		"beneficiary_party.account_type": csvInteger(func(p *Payment) *int { return &p.Attributes.BeneficiaryParty.AccountType })}
+
+	addCSVPartyColumns(columns, "debtor_party", func(p *Payment) *DebtorParty { return &p.Attributes.DebtorParty })
+	addCSVPartyColumns(columns, "beneficiary_party", func(p *Payment) *DebtorParty {
+		if p.Attributes.BeneficiaryParty.DebtorParty == nil {
+			p.Attributes.BeneficiaryParty.DebtorParty = &DebtorParty{}
+		}
+		return p.Attributes.BeneficiaryParty.DebtorParty
+	})
+	addCSVSponsorColumns(columns, "sponsor_party", func(p *Payment) *SponsorParty { return &p.Attributes.SponsorParty })
+
+	return columns
+}
+
+func addCSVPartyColumns(columns map[string]csvColumn, prefix string, party func(p *Payment) *DebtorParty) {
+	columns[prefix+".name"] = csvText(func(p *Payment) *string { return &party(p).Name })
+	columns[prefix+".account_name"] = csvText(func(p *Payment) *string { return &party(p).AccountName })
+	columns[prefix+".account_number_code"] = csvText(func(p *Payment) *string { return &party(p).AccountNumberCode })
+	columns[prefix+".address"] = csvText(func(p *Payment) *string { return &party(p).Address })
+
+	addCSVSponsorColumns(columns, prefix, func(p *Payment) *SponsorParty {
+		if party(p).SponsorParty == nil {
+			party(p).SponsorParty = &SponsorParty{}
+		}
+		return party(p).SponsorParty
+	})
+}
+
+func addCSVSponsorColumns(columns map[string]csvColumn, prefix string, sponsor func(p *Payment) *SponsorParty) {
+	columns[prefix+".account_number"] = csvText(func(p *Payment) *string { return &sponsor(p).AccountNumber })
+	columns[prefix+".bank_id"] = csvText(func(p *Payment) *string { return &sponsor(p).BankID })
+	columns[prefix+".bank_id_code"] = csvText(func(p *Payment) *string { return &sponsor(p).BankIDCode })
+}
+
+func csvText(field func(p *Payment) *string) csvColumn {
+	return func(payment *Payment, value string) error {
+		*field(payment) = value
+		return nil
+	}
+}
+
+func csvDecimal(field func(p *Payment) *float64) csvColumn {
+	return func(payment *Payment, value string) error {
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("must be a decimal number")
+		}
+		*field(payment) = number
+		return nil
+	}
+}
+
+func csvInteger(field func(p *Payment) *int) csvColumn {
+	return func(payment *Payment, value string) error {
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		*field(payment) = number
+		return nil
+	}
+}
+
+// parsePaymentsCSV reads a payment from every row of the CSV file
+func parsePaymentsCSV(reader io.Reader) (rows []csvRow, err error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return nil, &InvalidDocumentError{csvFormat, "the header row is missing"}
+	}
+	if err != nil {
+		return nil, &InvalidDocumentError{csvFormat, err.Error()}
+	}
+
+	names := make([]string, len(header))
+	columns := make([]csvColumn, len(header))
+	for i, name := range header {
+		names[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+
+		column, ok := csvColumns[names[i]]
+		if !ok {
+			return nil, &InvalidDocumentError{csvFormat, fmt.Sprintf("unknown column '%s'", names[i])}
+		}
+		for _, previous := range names[:i] {
+			if previous == names[i] {
+				return nil, &InvalidDocumentError{csvFormat, fmt.Sprintf("duplicate column '%s'", names[i])}
+			}
+		}
+		columns[i] = column
+	}
+
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, &InvalidDocumentError{csvFormat, err.Error()}
+		}
+
+		row := csvRow{row: len(rows) + 1}
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			if len(value) == 0 {
+				continue
+			}
+			if err := columns[i](&row.payment, value); err != nil {
+				row.errors = append(row.errors, FieldError{Field: names[i], Message: err.Error()})
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil, &InvalidDocumentError{csvFormat, "there are no payment rows"}
+	}
+	return rows, nil
+}
+
+// validateImportedPayment checks the payment of the row with the same rules as the create endpoint
+func validateImportedPayment(payment Payment) []FieldError {
+	if len(payment.OrganisationID) == 0 {
+		return []FieldError{{Field: "organisation_id", Message: "is required"}}
+	}
+
+	switch err := validatePayment(payment, true).(type) {
+	case nil:
+		return nil
+	case *SchemeValidationError:
+		return []FieldError{{Field: err.field, Message: err.reason}}
+	default:
+		return []FieldError{{Message: err.Error()}}
+	}
+}
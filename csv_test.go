@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	. "github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test CSV parsing
+
+func TestParsePaymentsCSVMapsColumns(t *testing.T) {
+	rows, err := parsePaymentsCSV(bytes.NewReader(MockPaymentsCSV(t)))
+	Nil(t, err)
+
+	Equal(t, 4, len(rows))
+	Equal(t, 1, rows[0].row)
+	Empty(t, rows[0].errors)
+
+	attributes := rows[0].payment.Attributes
+	Equal(t, 100.21, attributes.Amount)
+	Equal(t, "FPS", attributes.PaymentScheme)
+	Equal(t, "Emelia Jane Brown", attributes.DebtorParty.Name)
+	Equal(t, "203301", attributes.DebtorParty.SponsorParty.BankID)
+	Equal(t, "31926819", attributes.BeneficiaryParty.DebtorParty.SponsorParty.AccountNumber)
+	Equal(t, "456", rows[1].payment.OrganisationID)
+	Empty(t, rows[1].payment.Attributes.BeneficiaryParty.DebtorParty.SponsorParty.BankID)
+
+	Equal(t, []FieldError{{Field: "amount", Message: "must be a decimal number"}}, rows[2].errors)
+}
+
+func TestParsePaymentsCSVInvalidDocument(t *testing.T) {
+	documents := []string{
+		"",
+		"amount,colour\n10,red\n",
+		"amount,amount\n10,10\n",
+		"amount,currency\n",
+		"amount,currency\n10\n"}
+
+	for _, document := range documents {
+		_, err := parsePaymentsCSV(strings.NewReader(document))
+		IsType(t, &InvalidDocumentError{}, err, document)
+	}
+}
+
+// Test CSV import handler
+
+func TestImportPaymentsCSVAllOrNothing(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful}
+
+	response := ServeCSVImportHTTP("?organisation_id=123", MockPaymentsCSV(t), repository)
+
+	Equal(t, 400, response.Code)
+	Empty(t, repository.payments)
+
+	report := DecodeImportReport(t, response)
+	Equal(t, []string{importRowNotImported, importRowNotImported, importRowInvalid, importRowInvalid}, ImportRowStatuses(report))
+	Equal(t, []FieldError{{Field: "debtor_party.account_number", Message: "must be a valid IBAN"}}, report.Data[3].Errors)
+}
+
+func TestImportPaymentsCSVBestEffort(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful}
+
+	response := ServeCSVImportHTTP("?organisation_id=123&mode=best_effort", MockPaymentsCSV(t), repository)
+
+	Equal(t, 207, response.Code)
+	Equal(t, 2, len(repository.payments))
+	Equal(t, "123", repository.payments[0].OrganisationID)
+	Equal(t, "456", repository.payments[1].OrganisationID)
+
+	report := DecodeImportReport(t, response)
+	Equal(t, []string{importRowCreated, importRowCreated, importRowInvalid, importRowInvalid}, ImportRowStatuses(report))
+	Equal(t, repository.payments[0].ID, report.Data[0].ID)
+	Equal(t, 3, report.Data[2].Row)
+}
+
+func TestImportPaymentsCSVSuccessful(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful}
+	document := strings.Join(strings.Split(string(MockPaymentsCSV(t)), "\n")[:3], "\n")
+
+	response := ServeCSVImportHTTP("?organisation_id=123", []byte(document), repository)
+
+	Equal(t, 201, response.Code)
+	Equal(t, 2, len(repository.payments))
+}
+
+func TestImportPaymentsCSVAllOrNothingRolledBack(t *testing.T) {
+	repository := &FailingInsertRepositoryMock{failAt: 2}
+	document := strings.Join(strings.Split(string(MockPaymentsCSV(t)), "\n")[:3], "\n")
+
+	response := ServeCSVImportHTTP("?organisation_id=123", []byte(document), repository)
+
+	Equal(t, 500, response.Code)
+	Equal(t, 1, repository.transactions)
+	Empty(t, repository.payments)
+
+	report := DecodeImportReport(t, response)
+	Equal(t, []string{importRowNotImported, importRowFailed}, ImportRowStatuses(report))
+	Empty(t, report.Data[0].ID)
+}
+
+func TestImportPaymentsCSVMissingOrganisation(t *testing.T) {
+	response := ServeCSVImportHTTP("?mode=best_effort", MockPaymentsCSV(t), &PaymentRepositoryMock{mode: successful})
+
+	report := DecodeImportReport(t, response)
+	Equal(t, []FieldError{{Field: "organisation_id", Message: "is required"}}, report.Data[0].Errors)
+	Equal(t, importRowCreated, report.Data[1].Status)
+}
+
+func TestImportPaymentsCSVServerFailed(t *testing.T) {
+	response := ServeCSVImportHTTP("?organisation_id=123&mode=best_effort", MockPaymentsCSV(t), &PaymentRepositoryMock{mode: dbFailure})
+
+	Equal(t, 500, response.Code)
+
+	report := DecodeImportReport(t, response)
+	Equal(t, []string{importRowFailed, importRowNotImported, importRowInvalid, importRowInvalid}, ImportRowStatuses(report))
+}
+
+func TestImportPaymentsCSVUnsupportedMode(t *testing.T) {
+	response := ServeCSVImportHTTP("?organisation_id=123&mode=some", MockPaymentsCSV(t), &PaymentRepositoryMock{mode: successful})
+
+	Equal(t, 400, response.Code)
+}
+
+// ---------------------------------------------------- //
+
+func MockPaymentsCSV(t *testing.T) []byte {
+	document, err := ioutil.ReadFile("test_resources/csv/payments.csv")
+	Nil(t, err)
+	return document
+}
+
+func ServeCSVImportHTTP(query string, document []byte, repository PaymentRepository) *httptest.ResponseRecorder {
+	setPaymentRepository(repository)
+
+	var body io.Reader = bytes.NewReader(document)
+	request, _ := http.NewRequest(methodPost, importPaymentsCSVPath+query, body)
+
+	response := httptest.NewRecorder()
+	configureRouter().ServeHTTP(response, request)
+
+	return response
+}
+
+func DecodeImportReport(t *testing.T, response *httptest.ResponseRecorder) (report PaymentImportReport) {
+	Nil(t, json.NewDecoder(response.Body).Decode(&report))
+	return report
+}
+
+func ImportRowStatuses(report PaymentImportReport) (statuses []string) {
+	for _, row := range report.Data {
+		statuses = append(statuses, row.Status)
+	}
+	return statuses
+}
@@ -1,21 +1,43 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"log"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
+const (
+	// The header of a create request which makes the request safe to retry
+	idempotencyKeyHeader string = "Idempotency-Key"
+
+	// The largest page of a payments list
+	maxPaymentsPageSize int = 100
+)
+
+// The namespace of the payment ids derived from idempotency keys
+var idempotentPaymentNamespace = uuid.MustParse("0b6f4c3e-5d0a-4f7e-9c55-2a8e1d3b7f90")
+
 var paymentRepository PaymentRepository
 
 func setPaymentRepository(repository PaymentRepository) {
 	paymentRepository = repository
 }
 
+// contextPaymentRepository returns the payment repository bound to the context, e.g. of the request being served
+func contextPaymentRepository(ctx context.Context) PaymentRepository {
+	return bindPaymentRepository(paymentRepository, ctx)
+}
+
 func writeHeaderLocation(writer http.ResponseWriter, request *http.Request, paymentID string) {
-	location := prepareFullPaymentURL(request.Host, getPaymentPath, paymentID)
+	location := prepareFullPaymentURL(request.Host, requestPaymentPaths(request).payment, paymentID)
 	writer.Header().Set("Location", location)
 }
 
@@ -25,44 +47,112 @@ func prepareSuccessHeader(writer http.ResponseWriter, statusCode int) {
 }
 
 func prepareFailureHeader(writer http.ResponseWriter, request *http.Request, err error) {
-	log.Printf("Request [%s] %s with processed error `%s`", request.Method, request.RequestURI, err.Error())
+	slog.InfoContext(request.Context(), "Request processed with error", "error", err)
 
 	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	writer.WriteHeader(failureStatusCode(err))
+}
 
+// failureStatusCode classifies the error of a request, the errors which are not known are caused by the request itself
+func failureStatusCode(err error) int {
 	switch err.(type) {
 	case *PersistenceError:
-		writer.WriteHeader(http.StatusInternalServerError)
-		return
+		return http.StatusInternalServerError
+	case *InvalidResponseError:
+		return http.StatusInternalServerError
 	case *PaymentNotFoundError:
-		writer.WriteHeader(http.StatusNotFound)
-		return
+		return http.StatusNotFound
+	case *PaymentVersionNotFoundError:
+		return http.StatusNotFound
+	case *WebhookSubscriptionNotFoundError:
+		return http.StatusNotFound
+	case *WebhookDeliveryNotFoundError:
+		return http.StatusNotFound
+	case *PaymentBatchNotFoundError:
+		return http.StatusNotFound
+	case *EmptyPaymentBatchError:
+		return http.StatusNotFound
 	case *PaymentVersionConflictError:
-		writer.WriteHeader(http.StatusConflict)
-		return
+		return http.StatusConflict
+	case *PaymentAlreadyExistsError:
+		return http.StatusConflict
+	case *PaymentAlreadySubmittedError:
+		return http.StatusConflict
+	case *WebhookDeliveryInFlightError:
+		return http.StatusConflict
+	case *NotAcceptableError:
+		return http.StatusNotAcceptable
+	case *UnsupportedMediaTypeError:
+		return http.StatusUnsupportedMediaType
+	case *PreconditionRequiredError:
+		return http.StatusPreconditionRequired
+	case *InvalidPatchError:
+		return http.StatusUnprocessableEntity
+	case *IdempotencyKeyReusedError:
+		return http.StatusUnprocessableEntity
 	case *InvalidPaymentError:
-		writer.WriteHeader(http.StatusBadRequest)
-		return
+		return http.StatusBadRequest
+	case *SchemeValidationError:
+		return http.StatusBadRequest
+	case *InvalidAmountError:
+		return http.StatusBadRequest
+	case *InvalidWebhookSubscriptionError:
+		return http.StatusBadRequest
+	case *InvalidDocumentError:
+		return http.StatusBadRequest
+	case *UnsupportedFormatError:
+		return http.StatusBadRequest
+	case *InvalidRequestError:
+		return http.StatusBadRequest
+	case *InvalidLastEventIDError:
+		return http.StatusBadRequest
+	case *InvalidPaymentOperationError:
+		return http.StatusBadRequest
 	default:
-		writer.WriteHeader(http.StatusBadRequest)
-		return
+		return http.StatusBadRequest
 	}
 }
 
-// At the moment payment validation has very simple rules: OrganisationID is a required field and payment ID should be not empty for an update call
-// here we can add more complex validation rules if needed
 func decodeAndValidatePayment(request *http.Request, create bool) (payment Payment, err error) {
 	err = json.NewDecoder(request.Body).Decode(&payment)
 	if err != nil {
 		return payment, err
 	}
 
+	// The id of a payment updated on its resource path may be left out of the body
+	if paymentID, ok := mux.Vars(request)["id"]; ok && !create {
+		if len(payment.ID) == 0 {
+			payment.ID = paymentID
+		}
+		if payment.ID != paymentID {
+			return payment, fmt.Errorf("payment id '%s' differs from the id of the resource path '%s'", payment.ID, paymentID)
+		}
+	}
+
+	return payment, validatePayment(payment, create)
+}
+
+// A paymentSchemeProfile holds the validation rules of a payment scheme on top of the common ones
+type paymentSchemeProfile struct {
+	validate func(payment Payment) error
+}
+
+var paymentSchemeProfiles = map[string]paymentSchemeProfile{
+	sepaPaymentScheme: {validateSepaPayment}}
+
+// validatePayment checks the required fields of the payment and the rules of the profile of its scheme
+func validatePayment(payment Payment, create bool) error {
 	if len(payment.OrganisationID) == 0 || (!create && len(payment.ID) == 0) {
-		return payment, &InvalidPaymentError{payment}
+		return &InvalidPaymentError{payment}
 	}
 
-	return payment, nil
+	if profile, ok := paymentSchemeProfiles[payment.Attributes.PaymentScheme]; ok {
+		return profile.validate(payment)
+	}
+	return nil
 }
 
+// createPaymentEndpoint stores a new payment, once per Idempotency-Key header
 func createPaymentEndpoint(writer http.ResponseWriter, request *http.Request) {
 	var payment, err = decodeAndValidatePayment(request, true)
 	if err != nil {
@@ -70,18 +160,66 @@ func createPaymentEndpoint(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	newUUID, _ := uuid.NewUUID()
-	payment.ID = newUUID.String()
+	idempotencyKey := request.Header.Get(idempotencyKeyHeader)
+	payment.ID = newPaymentID(idempotencyKey, payment.OrganisationID)
 	payment.Version = 1
 
-	err = paymentRepository.InsertPayment(payment)
+	repository := contextPaymentRepository(request.Context())
+	err = repository.InsertPayment(payment)
+	if _, exists := err.(*PaymentAlreadyExistsError); exists && len(idempotencyKey) > 0 {
+		replayIdempotentCreation(writer, request, repository, payment, idempotencyKey)
+		return
+	}
+
 	if err != nil {
 		prepareFailureHeader(writer, request, err)
 		return
 	}
 
+	notifyPaymentEvent(paymentCreatedEvent, payment)
+
 	writeHeaderLocation(writer, request, payment.ID)
 	prepareSuccessHeader(writer, http.StatusCreated)
+	_ = json.NewEncoder(writer).Encode(payment)
+}
+
+// replayIdempotentCreation answers a retried creation with the payment created by the first request
+func replayIdempotentCreation(writer http.ResponseWriter, request *http.Request, repository PaymentRepository, payment Payment, idempotencyKey string) {
+	created, err := repository.GetPaymentVersion(payment.ID, 1)
+	if _, notFound := err.(*PaymentVersionNotFoundError); notFound {
+		err = &PaymentAlreadyExistsError{payment.ID}
+	}
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	if !samePaymentContent(created, payment) {
+		prepareFailureHeader(writer, request, &IdempotencyKeyReusedError{idempotencyKey})
+		return
+	}
+
+	writeHeaderLocation(writer, request, created.ID)
+	prepareSuccessHeader(writer, http.StatusCreated)
+	_ = json.NewEncoder(writer).Encode(created)
+}
+
+// samePaymentContent compares the payments by their json representation, ignoring the id and the version
+func samePaymentContent(payment Payment, other Payment) bool {
+	payment.ID, payment.Version = "", 0
+	other.ID, other.Version = "", 0
+
+	paymentJSON, _ := json.Marshal(payment)
+	otherJSON, _ := json.Marshal(other)
+	return bytes.Equal(paymentJSON, otherJSON)
+}
+
+func newPaymentID(idempotencyKey string, organisationID string) string {
+	if len(idempotencyKey) == 0 {
+		newUUID, _ := uuid.NewUUID()
+		return newUUID.String()
+	}
+	return uuid.NewSHA1(idempotentPaymentNamespace, []byte(organisationID+"/"+idempotencyKey)).String()
 }
 
 func updatePaymentEndpoint(writer http.ResponseWriter, request *http.Request) {
@@ -91,51 +229,173 @@ func updatePaymentEndpoint(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	err = paymentRepository.UpdatePayment(payment)
+	err = contextPaymentRepository(request.Context()).UpdatePayment(payment)
 	if err != nil {
 		prepareFailureHeader(writer, request, err)
 		return
 	}
 
+	payment.Version = payment.Version + 1
+	notifyPaymentEvent(paymentUpdatedEvent, payment)
+
 	writeHeaderLocation(writer, request, payment.ID)
 	prepareSuccessHeader(writer, http.StatusOK)
 }
 
+// patchPaymentEndpoint applies a JSON Merge Patch or a JSON Patch to the current payment
+func patchPaymentEndpoint(writer http.ResponseWriter, request *http.Request) {
+	paymentID := mux.Vars(request)["id"]
+
+	patch, err := decodePaymentPatch(request.Header.Get("Content-Type"), request.Body)
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	version, err := parsePatchPrecondition(paymentID, request.Header.Get("If-Match"), patch.version)
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	current, err := contextPaymentRepository(request.Context()).GetPayment(paymentID)
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	if current.Version != version {
+		prepareFailureHeader(writer, request, &PaymentVersionConflictError{paymentID, version})
+		return
+	}
+
+	patched, err := patchPayment(current, patch)
+	if err == nil {
+		err = validatePayment(patched, false)
+	}
+	if err == nil {
+		err = contextPaymentRepository(request.Context()).PatchPayment(current, patched)
+	}
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	patched.Version = current.Version + 1
+	notifyPaymentEvent(paymentUpdatedEvent, patched)
+
+	writeHeaderLocation(writer, request, patched.ID)
+	writeHeaderETag(writer, patched.Version)
+	prepareSuccessHeader(writer, http.StatusOK)
+
+	links := newPaymentLinks(request, paymentID)
+
+	_ = json.NewEncoder(writer).Encode(PaymentResult{patched, links})
+}
+
+// parsePatchPrecondition returns the version a patch is based on
+func parsePatchPrecondition(paymentID string, ifMatch string, patchVersion int) (version int, err error) {
+	ifMatch = strings.Trim(strings.TrimPrefix(strings.TrimSpace(ifMatch), "W/"), `"`)
+
+	if len(ifMatch) > 0 && ifMatch != "*" {
+		version, err = strconv.Atoi(ifMatch)
+		if err != nil || version < 1 {
+			return version, fmt.Errorf("If-Match header must be the version of the payment")
+		}
+		if patchVersion > 0 && patchVersion != version {
+			return version, fmt.Errorf("the version of If-Match header and the version of the patch differ")
+		}
+		return version, nil
+	}
+
+	if patchVersion == 0 {
+		return version, &PreconditionRequiredError{paymentID}
+	}
+	return patchVersion, nil
+}
+
+// newPaymentLinks links the actions on the payment, the links follow the API version of the request
+func newPaymentLinks(request *http.Request, paymentID string) Links {
+	paths := requestPaymentPaths(request)
+	return Links{
+		Self:     prepareFullPaymentURL(request.Host, paths.payment, paymentID),
+		Update:   prepareFullPaymentURL(request.Host, paths.update, paymentID),
+		Delete:   prepareFullPaymentURL(request.Host, paths.delete, paymentID),
+		Versions: prepareFullPaymentURL(request.Host, paths.versions, paymentID)}
+}
+
+func writeHeaderETag(writer http.ResponseWriter, version int) {
+	writer.Header().Set("ETag", fmt.Sprintf(`"%d"`, version))
+}
+
 func deletePaymentEndpoint(writer http.ResponseWriter, request *http.Request) {
 	paymentID := mux.Vars(request)["id"]
 
-	err := paymentRepository.DeletePayment(paymentID)
+	// The payment is loaded first, so the deletion event carries the organisation and the last state of the payment
+	payment, err := contextPaymentRepository(request.Context()).GetPayment(paymentID)
 	if err != nil {
 		prepareFailureHeader(writer, request, err)
 		return
 	}
 
+	err = contextPaymentRepository(request.Context()).DeletePayment(paymentID)
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	payment.Version = payment.Version + 1
+	notifyPaymentEvent(paymentDeletedEvent, payment)
+
 	prepareSuccessHeader(writer, http.StatusOK)
 }
 
 func getPaymentEndpoint(writer http.ResponseWriter, request *http.Request) {
 	paymentID := mux.Vars(request)["id"]
 
-	payment, err := paymentRepository.GetPayment(paymentID)
+	payment, err := loadPayment(request, paymentID)
 
 	if err != nil {
 		prepareFailureHeader(writer, request, err)
 		return
 	}
 
+	writeHeaderETag(writer, payment.Version)
 	prepareSuccessHeader(writer, http.StatusOK)
 
-	links := Links{
-		Self:   prepareFullPaymentURL(request.Host, getPaymentPath, paymentID),
-		Update: prepareFullPaymentURL(request.Host, updatePaymentPath, ""),
-		Delete: prepareFullPaymentURL(request.Host, deletePaymentPath, paymentID)}
+	links := newPaymentLinks(request, paymentID)
 
 	result := PaymentResult{payment, links}
 	_ = json.NewEncoder(writer).Encode(result)
 }
 
-func getAllPaymentsEndpoint(writer http.ResponseWriter, request *http.Request) {
-	payments, err := paymentRepository.GetAllPayments()
+// loadPayment returns the current state of a payment or a retained version of it
+func loadPayment(request *http.Request, paymentID string) (payment Payment, err error) {
+	query := request.URL.Query()
+
+	if value := query.Get("version"); len(value) > 0 {
+		version, err := strconv.Atoi(value)
+		if err != nil {
+			return payment, err
+		}
+		return contextPaymentRepository(request.Context()).GetPaymentVersion(paymentID, version)
+	}
+
+	if value := query.Get("as_of"); len(value) > 0 {
+		asOf, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return payment, err
+		}
+		return contextPaymentRepository(request.Context()).GetPaymentAsOf(paymentID, asOf)
+	}
+
+	return contextPaymentRepository(request.Context()).GetPayment(paymentID)
+}
+
+func getPaymentVersionsEndpoint(writer http.ResponseWriter, request *http.Request) {
+	paymentID := mux.Vars(request)["id"]
+
+	versions, err := contextPaymentRepository(request.Context()).GetPaymentVersions(paymentID)
 
 	if err != nil {
 		prepareFailureHeader(writer, request, err)
@@ -145,8 +405,101 @@ func getAllPaymentsEndpoint(writer http.ResponseWriter, request *http.Request) {
 	prepareSuccessHeader(writer, http.StatusOK)
 
 	links := Links{
-		Self: prepareFullPaymentURL(request.Host, getAllPaymentsPath, "")}
+		Self: prepareFullPaymentURL(request.Host, requestPaymentPaths(request).versions, paymentID)}
+
+	result := PaymentVersionListResult{versions, links}
+	_ = json.NewEncoder(writer).Encode(result)
+}
+
+func getPaymentDiffEndpoint(writer http.ResponseWriter, request *http.Request) {
+	paymentID := mux.Vars(request)["id"]
+
+	fromVersion, err := strconv.Atoi(request.URL.Query().Get("from"))
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	toVersion, err := strconv.Atoi(request.URL.Query().Get("to"))
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	from, err := contextPaymentRepository(request.Context()).GetPaymentVersion(paymentID, fromVersion)
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	to, err := contextPaymentRepository(request.Context()).GetPaymentVersion(paymentID, toVersion)
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	prepareSuccessHeader(writer, http.StatusOK)
+
+	links := Links{
+		Self:     fmt.Sprintf("%s?from=%d&to=%d", prepareFullPaymentURL(request.Host, requestPaymentPaths(request).diff, paymentID), fromVersion, toVersion),
+		Versions: prepareFullPaymentURL(request.Host, requestPaymentPaths(request).versions, paymentID)}
+
+	diff := PaymentDiff{paymentID, fromVersion, toVersion, diffPayments(from, to)}
+	result := PaymentDiffResult{diff, links}
+	_ = json.NewEncoder(writer).Encode(result)
+}
+
+// getAllPaymentsEndpoint lists the payments matching the filter, paged when the limit parameter is set
+func getAllPaymentsEndpoint(writer http.ResponseWriter, request *http.Request) {
+	var payments []Payment
+	var err error
+
+	query := request.URL.Query()
+	filter := parsePaymentFilter(request)
+	links := Links{
+		Self: prepareFullPaymentURL(request.Host, requestPaymentPaths(request).payments, "")}
+
+	if value := query.Get("limit"); len(value) > 0 {
+		var limit int
+		var more bool
+
+		limit, err = strconv.Atoi(value)
+		if err == nil && (limit < 1 || limit > maxPaymentsPageSize) {
+			err = fmt.Errorf("limit must be between 1 and %d", maxPaymentsPageSize)
+		}
+		if err == nil {
+			payments, more, err = findPaymentsPage(request.Context(), filter, query.Get("after"), limit)
+		}
+
+		if more {
+			query.Set("after", payments[len(payments)-1].ID)
+			links.Next = links.Self + "?" + query.Encode()
+		}
+	} else if filter != (PaymentFilter{}) {
+		payments, err = contextPaymentRepository(request.Context()).FindPayments(filter)
+	} else {
+		payments, err = contextPaymentRepository(request.Context()).GetAllPayments()
+	}
+
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	prepareSuccessHeader(writer, http.StatusOK)
 
 	result := PaymentListResult{payments, links}
 	_ = json.NewEncoder(writer).Encode(result)
 }
+
+// findPaymentsPage reads the page of the payments matching the filter which follows the payment of the after id
+func findPaymentsPage(ctx context.Context, filter PaymentFilter, after string, size int) (payments []Payment, more bool, err error) {
+	filter.After = after
+	filter.Limit = size + 1
+
+	payments, err = contextPaymentRepository(ctx).FindPayments(filter)
+	if len(payments) > size {
+		return payments[:size], true, err
+	}
+	return payments, false, err
+}
@@ -1,6 +1,10 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 // A PersistenceError is an error type when any query or update to a storage failed due to a technical reason
 type PersistenceError struct {
@@ -29,6 +33,25 @@ func (e PaymentVersionConflictError) Error() string {
 	return fmt.Sprintf("Payment '%s' with version '%d' can not be updated", e.paymentID, e.version)
 }
 
+// A PaymentAlreadyExistsError is an error type when a payment is created with the id of a stored payment
+type PaymentAlreadyExistsError struct {
+	paymentID string
+}
+
+func (e PaymentAlreadyExistsError) Error() string {
+	return fmt.Sprintf("Payment with id '%s' already exists", e.paymentID)
+}
+
+// A PaymentVersionNotFoundError is an error type when the requested version of a Payment is not retained in the storage
+type PaymentVersionNotFoundError struct {
+	paymentID string
+	version   int
+}
+
+func (e PaymentVersionNotFoundError) Error() string {
+	return fmt.Sprintf("Payment '%s' with version '%d' not found", e.paymentID, e.version)
+}
+
 // An InvalidPaymentError is an error type when given Payment object has invalid or inconsistent data
 type InvalidPaymentError struct {
 	payment Payment
@@ -37,3 +60,201 @@ type InvalidPaymentError struct {
 func (e InvalidPaymentError) Error() string {
 	return fmt.Sprintf("Payment has invalid format %+v\n", e.payment)
 }
+
+// An IdempotencyKeyReusedError is an error type when an Idempotency-Key is sent again with another payment
+type IdempotencyKeyReusedError struct {
+	idempotencyKey string
+}
+
+func (e IdempotencyKeyReusedError) Error() string {
+	return fmt.Sprintf("Idempotency key '%s' was used to create another payment", e.idempotencyKey)
+}
+
+// An InvalidPaymentOperationError is an error type when a PaymentOperation is unknown or lacks what it needs
+type InvalidPaymentOperationError struct {
+	reason string
+}
+
+func (e InvalidPaymentOperationError) Error() string {
+	return fmt.Sprintf("Payment operation is invalid: %s", e.reason)
+}
+
+// A WebhookSubscriptionNotFoundError is an error type when WebhookSubscription for a given subscriptionID can not be found in the storage
+type WebhookSubscriptionNotFoundError struct {
+	subscriptionID string
+}
+
+func (e WebhookSubscriptionNotFoundError) Error() string {
+	return fmt.Sprintf("Webhook subscription '%s' not found", e.subscriptionID)
+}
+
+// A WebhookDeliveryNotFoundError is an error type when WebhookDelivery for a given deliveryID can not be found in the storage
+type WebhookDeliveryNotFoundError struct {
+	deliveryID string
+}
+
+func (e WebhookDeliveryNotFoundError) Error() string {
+	return fmt.Sprintf("Webhook delivery '%s' not found", e.deliveryID)
+}
+
+// A WebhookDeliveryInFlightError is an error type when WebhookDelivery for a given deliveryID is being sent and can not be changed
+type WebhookDeliveryInFlightError struct {
+	deliveryID string
+}
+
+func (e WebhookDeliveryInFlightError) Error() string {
+	return fmt.Sprintf("Webhook delivery '%s' is in flight", e.deliveryID)
+}
+
+// An InvalidLastEventIDError is an error type when the Last-Event-ID header of a payment stream is not an event id
+type InvalidLastEventIDError struct {
+	eventID string
+}
+
+func (e InvalidLastEventIDError) Error() string {
+	return fmt.Sprintf("Last event id '%s' is invalid", e.eventID)
+}
+
+// An InvalidWebhookSubscriptionError is an error type when given WebhookSubscription object has invalid or inconsistent data
+type InvalidWebhookSubscriptionError struct {
+	subscription WebhookSubscription
+}
+
+func (e InvalidWebhookSubscriptionError) Error() string {
+	return fmt.Sprintf("Webhook subscription of organisation '%s' with url '%s' is invalid", e.subscription.OrganisationID, e.subscription.URL)
+}
+
+// An InvalidDocumentError is an error type when an imported document can not be parsed into payments
+type InvalidDocumentError struct {
+	format string
+	reason string
+}
+
+func (e InvalidDocumentError) Error() string {
+	return fmt.Sprintf("Document of format '%s' is invalid: %s", e.format, e.reason)
+}
+
+// An UnsupportedFormatError is an error type when payments are imported or exported in a format which is not supported
+type UnsupportedFormatError struct {
+	format string
+}
+
+func (e UnsupportedFormatError) Error() string {
+	return fmt.Sprintf("Format '%s' is not supported", e.format)
+}
+
+// A PaymentBatchNotFoundError is an error type when PaymentBatch for a given batchID can not be found in the storage
+type PaymentBatchNotFoundError struct {
+	batchID string
+}
+
+func (e PaymentBatchNotFoundError) Error() string {
+	return fmt.Sprintf("Payment batch '%s' not found", e.batchID)
+}
+
+// An EmptyPaymentBatchError is an error type when there are no payments left to submit for the given PaymentFilter
+type EmptyPaymentBatchError struct {
+	filter PaymentFilter
+}
+
+func (e EmptyPaymentBatchError) Error() string {
+	return fmt.Sprintf("No %s payments of organisation '%s' to submit %+v", e.filter.PaymentScheme, e.filter.OrganisationID, e.filter)
+}
+
+// A PaymentAlreadySubmittedError is an error type when a payment of a new batch has been submitted by another batch already
+type PaymentAlreadySubmittedError struct {
+	batchID string
+}
+
+func (e PaymentAlreadySubmittedError) Error() string {
+	return fmt.Sprintf("Payments of batch '%s' have been submitted already", e.batchID)
+}
+
+// A SchemeValidationError is an error type when a payment field breaks a rule of the payment scheme
+type SchemeValidationError struct {
+	scheme string
+	field  string
+	reason string
+}
+
+func (e SchemeValidationError) Error() string {
+	return fmt.Sprintf("Payment field '%s' is invalid for %s scheme: %s", e.field, e.scheme, e.reason)
+}
+
+// An InvalidAmountError is an error type when an amount of a payment can't be rendered in the field of a message exactly
+type InvalidAmountError struct {
+	field  string
+	amount float64
+	reason string
+}
+
+func (e InvalidAmountError) Error() string {
+	return fmt.Sprintf("Amount %s of field '%s' can not be rendered: %s", strconv.FormatFloat(e.amount, 'f', -1, 64), e.field, e.reason)
+}
+
+// A NotAcceptableError is an error type when none of the media types accepted by the client can be produced
+type NotAcceptableError struct {
+	accept string
+}
+
+func (e NotAcceptableError) Error() string {
+	return fmt.Sprintf("None of the media types '%s' is supported", e.accept)
+}
+
+// An UnsupportedMediaTypeError is an error type when the body of a request is of a media type which is not supported
+type UnsupportedMediaTypeError struct {
+	contentType string
+}
+
+func (e UnsupportedMediaTypeError) Error() string {
+	return fmt.Sprintf("Media type '%s' is not supported", e.contentType)
+}
+
+// A PreconditionRequiredError is an error type when a conditional request doesn't carry the version it is based on
+type PreconditionRequiredError struct {
+	paymentID string
+}
+
+func (e PreconditionRequiredError) Error() string {
+	return fmt.Sprintf("Version of payment '%s' is required in If-Match header or in the patch", e.paymentID)
+}
+
+// An InvalidPatchError is an error type when a well-formed patch can't be applied to a payment
+type InvalidPatchError struct {
+	reason string
+}
+
+func (e InvalidPatchError) Error() string {
+	return fmt.Sprintf("Patch can't be applied: %s", e.reason)
+}
+
+// An InvalidRequestError is an error type when a request breaks the OpenAPI specification of the API
+type InvalidRequestError struct {
+	reason string
+}
+
+func (e InvalidRequestError) Error() string {
+	return fmt.Sprintf("Request breaks the OpenAPI specification: %s", e.reason)
+}
+
+// An InvalidResponseError is an error type when a response breaks the OpenAPI specification of the API
+type InvalidResponseError struct {
+	reason string
+}
+
+func (e InvalidResponseError) Error() string {
+	return fmt.Sprintf("Response breaks the OpenAPI specification: %s", e.reason)
+}
+
+// An InvalidConfigurationError is an error type when the configuration of an application has missing or invalid properties
+type InvalidConfigurationError struct {
+	problems []configurationProblem
+}
+
+func (e InvalidConfigurationError) Error() string {
+	messages := make([]string, len(e.problems))
+	for i, problem := range e.problems {
+		messages[i] = problem.message
+	}
+	return "Configuration is invalid:\n - " + strings.Join(messages, "\n - ")
+}
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	paymentEventsCollectionName      string = "payment_events"
+	paymentProjectionsCollectionName string = "payment_projections"
+	paymentSnapshotsCollectionName   string = "payment_snapshots"
+
+	paymentCreatedEvent string = "PaymentCreated"
+	paymentUpdatedEvent string = "PaymentUpdated"
+	paymentDeletedEvent string = "PaymentDeleted"
+
+	duplicateKeyErrorCode int = 11000
+
+	// maxDeleteAttempts limits the retries of a delete conflicting with concurrent changes of the payment
+	maxDeleteAttempts int = 3
+)
+
+// A paymentEvent is a single entry of the append-only event stream of a payment
+type paymentEvent struct {
+	ID         string               `bson:"_id"`
+	PaymentID  string               `bson:"payment_id"`
+	Position   int                  `bson:"position"`
+	Type       string               `bson:"type"`
+	RecordedAt time.Time            `bson:"recorded_at"`
+	Payment    *Payment             `bson:"payment,omitempty"`
+	Changes    []paymentFieldChange `bson:"changes,omitempty"`
+}
+
+// A paymentFieldChange is a single field change of an update event
+type paymentFieldChange struct {
+	Path  string `bson:"path"`
+	Value string `bson:"value,omitempty"`
+}
+
+// A paymentSnapshot is the state of a payment at a given stream position
+type paymentSnapshot struct {
+	PaymentID string  `bson:"_id"`
+	Position  int     `bson:"position"`
+	Payment   Payment `bson:"payment"`
+}
+
+// A paymentStreamState is the state of a payment after replaying its event stream
+type paymentStreamState struct {
+	payment    Payment
+	position   int
+	recordedAt time.Time
+	deleted    bool
+}
+
+// eventStore is an event-sourced PaymentRepository
+type eventStore struct {
+	client           *mongo.Client
+	snapshotInterval int
+	outbox           bool
+	transaction      mongo.SessionContext
+	ctx              context.Context
+}
+
+func newEventStore(client *mongo.Client, snapshotInterval int, outbox bool) *eventStore {
+	return &eventStore{client: client, snapshotInterval: snapshotInterval, outbox: outbox}
+}
+
+func (s *eventStore) WithContext(ctx context.Context) PaymentRepository {
+	store := newEventStore(s.client, s.snapshotInterval, s.outbox)
+	store.transaction = s.transaction
+	store.ctx = ctx
+	return store
+}
+
+func (s *eventStore) RunInTransaction(changes func(repository PaymentRepository) error) (err error) {
+	return runInTransaction(s.parentContext(), s.client, func(sessionContext mongo.SessionContext) error {
+		store := newEventStore(s.client, s.snapshotInterval, s.outbox)
+		store.transaction = sessionContext
+		store.ctx = s.ctx
+		return changes(store)
+	})
+}
+
+func (s *eventStore) InsertPayment(payment Payment) (err error) {
+	event := paymentEvent{Type: paymentCreatedEvent, PaymentID: payment.ID, Position: payment.Version, Payment: &payment}
+
+	return s.runChange(func(ctx context.Context) (PaymentEvent, error) {
+		err := s.appendEvent(ctx, event)
+		if _, conflict := err.(*PaymentVersionConflictError); conflict {
+			return PaymentEvent{}, &PaymentAlreadyExistsError{payment.ID}
+		}
+		if err != nil {
+			return PaymentEvent{}, err
+		}
+
+		_, err = s.projections().InsertOne(ctx, payment)
+		if err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while projecting", "error", err)
+			return PaymentEvent{}, &PersistenceError{}
+		}
+		return newPaymentEvent(paymentCreatedEvent, payment), nil
+	})
+}
+
+func (s *eventStore) UpdatePayment(payment Payment) (err error) {
+	state, err := s.loadStream(payment.ID, 0, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	currentVersion := payment.Version
+	if state.position != currentVersion {
+		return &PaymentVersionConflictError{payment.ID, currentVersion}
+	}
+
+	payment.Version = currentVersion + 1
+	event := paymentEvent{
+		Type:      paymentUpdatedEvent,
+		PaymentID: payment.ID,
+		Position:  payment.Version,
+		Changes:   newPaymentFieldChanges(diffPayments(state.payment, payment))}
+
+	err = s.runChange(func(ctx context.Context) (PaymentEvent, error) {
+		// A concurrent update appending the same position is rejected by the unique event id, which keeps
+		// the optimistic locking semantics of the CRUD repository
+		err := s.appendEvent(ctx, event)
+		if err != nil {
+			return PaymentEvent{}, err
+		}
+
+		filter := bson.M{"_id": payment.ID, "version": currentVersion}
+		result, err := s.projections().ReplaceOne(ctx, filter, payment)
+		if err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while projecting", "error", err)
+			return PaymentEvent{}, &PersistenceError{}
+		}
+
+		if result.MatchedCount == 0 {
+			slog.ErrorContext(ctx, "Projection doesn't follow the payment stream", "payment_id", payment.ID,
+				"version", currentVersion)
+			return PaymentEvent{}, &PersistenceError{}
+		}
+		return newPaymentEvent(paymentUpdatedEvent, payment), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.snapshotIfNeeded(payment)
+	return nil
+}
+
+// PatchPayment appends the changes of the patched payment like UpdatePayment
+func (s *eventStore) PatchPayment(current Payment, patched Payment) (err error) {
+	patched.ID = current.ID
+	patched.Version = current.Version
+	return s.UpdatePayment(patched)
+}
+
+// DeletePayment appends a delete event to the latest state of the payment
+func (s *eventStore) DeletePayment(paymentID string) (err error) {
+	for attempt := 1; ; attempt++ {
+		err = s.deletePayment(paymentID)
+
+		// within a transaction the conflict has aborted the transaction already so it is reported instead
+		_, conflict := err.(*PaymentVersionConflictError)
+		if !conflict || s.transaction != nil || attempt == maxDeleteAttempts {
+			return err
+		}
+	}
+}
+
+func (s *eventStore) deletePayment(paymentID string) (err error) {
+	state, err := s.loadStream(paymentID, 0, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	deleted := state.payment
+	deleted.Version = state.position + 1
+	event := paymentEvent{Type: paymentDeletedEvent, PaymentID: paymentID, Position: deleted.Version}
+
+	return s.runChange(func(ctx context.Context) (PaymentEvent, error) {
+		err := s.appendEvent(ctx, event)
+		if err != nil {
+			return PaymentEvent{}, err
+		}
+
+		_, err = s.projections().DeleteOne(ctx, bson.M{"_id": paymentID})
+		if err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while projecting", "error", err)
+			return PaymentEvent{}, &PersistenceError{}
+		}
+		return newPaymentEvent(paymentDeletedEvent, deleted), nil
+	})
+}
+
+func (s *eventStore) GetPayment(paymentID string) (payment Payment, err error) {
+	filter := bson.M{"_id": paymentID}
+	err = s.projections().FindOne(s.context(), filter).Decode(&payment)
+
+	if err == mongo.ErrNoDocuments {
+		return payment, &PaymentNotFoundError{paymentID}
+	}
+
+	if err != nil {
+		slog.ErrorContext(s.parentContext(), "Unexpected error while loading", "error", err)
+		return payment, &PersistenceError{}
+	}
+	return payment, nil
+}
+
+func (s *eventStore) GetPayments(paymentIDs []string) (payments []Payment, err error) {
+	return getPayments(getContextWithTimeoutFrom(s.parentContext()), s.projections(), paymentIDs)
+}
+
+func (s *eventStore) GetAllPayments() (payments []Payment, err error) {
+	ctx := getContextWithTimeoutFrom(s.parentContext())
+
+	cursor, err := s.projections().Find(ctx, bson.M{})
+	if err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while loading", "error", err)
+		return payments, &PersistenceError{}
+	}
+
+	for cursor.Next(ctx) {
+		var payment Payment
+		err = cursor.Decode(&payment)
+		if err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while loading", "error", err)
+			break
+		}
+		payments = append(payments, payment)
+	}
+
+	_ = cursor.Close(ctx)
+	return payments, err
+}
+
+func (s *eventStore) FindPayments(filter PaymentFilter) (payments []Payment, err error) {
+	return findPayments(getContextWithTimeoutFrom(s.parentContext()), s.projections(), filter)
+}
+
+func (s *eventStore) StreamPayments(ctx context.Context, filter PaymentFilter, each func(payment Payment) error) (err error) {
+	return streamPayments(ctx, s.projections(), filter, each)
+}
+
+func (s *eventStore) GetPaymentVersions(paymentID string) (versions []PaymentVersion, err error) {
+	events, err := s.loadEvents(paymentID, 0)
+	if err != nil {
+		return versions, err
+	}
+
+	state := paymentStreamState{}
+	for _, event := range events {
+		state, err = applyPaymentEvent(state, event)
+		if err != nil {
+			return versions, err
+		}
+		versions = append(versions, PaymentVersion{
+			PaymentID:  paymentID,
+			Version:    state.position,
+			RecordedAt: state.recordedAt,
+			Deleted:    state.deleted,
+			Payment:    state.payment})
+	}
+
+	if len(versions) == 0 {
+		return versions, &PaymentNotFoundError{paymentID}
+	}
+	return versions, nil
+}
+
+func (s *eventStore) GetPaymentVersion(paymentID string, version int) (payment Payment, err error) {
+	state, err := s.loadStream(paymentID, version, time.Time{})
+	if _, notFound := err.(*PaymentNotFoundError); notFound {
+		return payment, &PaymentVersionNotFoundError{paymentID, version}
+	}
+	if err != nil {
+		return payment, err
+	}
+
+	if state.position != version {
+		return payment, &PaymentVersionNotFoundError{paymentID, version}
+	}
+	return state.payment, nil
+}
+
+func (s *eventStore) GetPaymentAsOf(paymentID string, asOf time.Time) (payment Payment, err error) {
+	state, err := s.loadStream(paymentID, 0, asOf)
+	if err != nil {
+		return payment, err
+	}
+	return state.payment, nil
+}
+
+// RebuildProjection drops the projection and materialises it again from scratch by replaying every payment stream
+func (s *eventStore) RebuildProjection() (err error) {
+	slog.Info("Rebuilding payment projection ...")
+
+	ctx := getContextWithTimeout()
+	paymentIDs, err := s.events().Distinct(ctx, "payment_id", bson.M{})
+	if err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while rebuilding projection", "error", err)
+		return &PersistenceError{}
+	}
+
+	err = s.projections().Drop(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while rebuilding projection", "error", err)
+		return &PersistenceError{}
+	}
+
+	for _, paymentID := range paymentIDs {
+		state, err := s.loadStream(fmt.Sprint(paymentID), 0, time.Time{})
+		if _, deleted := err.(*PaymentNotFoundError); deleted {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = s.projections().InsertOne(getContextWithTimeout(), state.payment)
+		if err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while rebuilding projection", "error", err)
+			return &PersistenceError{}
+		}
+	}
+
+	slog.Info("Payment projection rebuilt", "streams", len(paymentIDs))
+	return nil
+}
+
+// loadStream replays the payment stream up to the given position and time
+func (s *eventStore) loadStream(paymentID string, position int, asOf time.Time) (state paymentStreamState, err error) {
+	if position == 0 && asOf.IsZero() {
+		state, err = s.loadSnapshot(paymentID)
+		if err != nil {
+			return state, err
+		}
+	}
+
+	events, err := s.loadEvents(paymentID, state.position)
+	if err != nil {
+		return state, err
+	}
+
+	for _, event := range events {
+		if (position > 0 && event.Position > position) || (!asOf.IsZero() && event.RecordedAt.After(asOf)) {
+			break
+		}
+		state, err = applyPaymentEvent(state, event)
+		if err != nil {
+			return state, err
+		}
+	}
+
+	if state.position == 0 || state.deleted {
+		return state, &PaymentNotFoundError{paymentID}
+	}
+	return state, nil
+}
+
+func (s *eventStore) loadEvents(paymentID string, afterPosition int) (events []paymentEvent, err error) {
+	ctx := s.context()
+
+	filter := bson.M{"payment_id": paymentID, "position": bson.M{"$gt": afterPosition}}
+	cursor, err := s.events().Find(ctx, filter, options.Find().SetSort(bson.M{"position": 1}))
+	if err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while loading events", "error", err)
+		return events, &PersistenceError{}
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+
+	for cursor.Next(ctx) {
+		var event paymentEvent
+		err = cursor.Decode(&event)
+		if err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while loading events", "error", err)
+			return events, &PersistenceError{}
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (s *eventStore) loadSnapshot(paymentID string) (state paymentStreamState, err error) {
+	var snapshot paymentSnapshot
+	err = s.snapshots().FindOne(s.context(), bson.M{"_id": paymentID}).Decode(&snapshot)
+
+	if err == mongo.ErrNoDocuments {
+		return state, nil
+	}
+
+	if err != nil {
+		slog.ErrorContext(s.parentContext(), "Unexpected error while loading snapshot", "error", err)
+		return state, &PersistenceError{}
+	}
+	return paymentStreamState{payment: snapshot.Payment, position: snapshot.Position}, nil
+}
+
+// snapshotIfNeeded stores the state of the payment every snapshotInterval events
+func (s *eventStore) snapshotIfNeeded(payment Payment) {
+	if s.snapshotInterval <= 0 || payment.Version%s.snapshotInterval != 0 || s.transaction != nil {
+		return
+	}
+
+	snapshot := paymentSnapshot{PaymentID: payment.ID, Position: payment.Version, Payment: payment}
+	filter := bson.M{"_id": payment.ID, "position": bson.M{"$lt": payment.Version}}
+	_, err := s.snapshots().ReplaceOne(getContextWithTimeoutFrom(s.parentContext()), filter, snapshot, options.Replace().SetUpsert(true))
+	if err != nil && !isDuplicateKeyError(err) {
+		slog.ErrorContext(s.parentContext(), "Unexpected error while storing snapshot", "error", err)
+	}
+}
+
+func (s *eventStore) appendEvent(ctx context.Context, event paymentEvent) (err error) {
+	event.ID = fmt.Sprintf("%s:%d", event.PaymentID, event.Position)
+	event.RecordedAt = time.Now().UTC()
+
+	_, err = s.events().InsertOne(ctx, event)
+	if isDuplicateKeyError(err) {
+		return &PaymentVersionConflictError{event.PaymentID, event.Position - 1}
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while appending event", "error", err)
+		return &PersistenceError{}
+	}
+	return nil
+}
+
+// runChange appends an event and writes the projection in a single transaction, the transaction the store is bound to or a new one
+func (s *eventStore) runChange(change func(ctx context.Context) (PaymentEvent, error)) error {
+	if s.transaction != nil {
+		return runWithOutbox(s.parentContext(), s.client, s.outbox, s.transaction, change)
+	}
+	return runInTransaction(s.parentContext(), s.client, func(sessionContext mongo.SessionContext) error {
+		return runWithOutbox(s.parentContext(), s.client, s.outbox, sessionContext, change)
+	})
+}
+
+// context returns the context of the transaction the store is bound to or a new context with the database timeout
+func (s *eventStore) context() context.Context {
+	if s.transaction != nil {
+		return s.transaction
+	}
+	return getContextWithTimeoutFrom(s.parentContext())
+}
+
+// parentContext returns the context the store is bound to, or the background context when it is not bound
+func (s *eventStore) parentContext() context.Context {
+	if s.ctx != nil {
+		return s.ctx
+	}
+	return context.Background()
+}
+
+func (s *eventStore) events() *mongo.Collection {
+	return s.client.Database(databaseName).Collection(paymentEventsCollectionName)
+}
+
+func (s *eventStore) projections() *mongo.Collection {
+	return s.client.Database(databaseName).Collection(paymentProjectionsCollectionName)
+}
+
+func (s *eventStore) snapshots() *mongo.Collection {
+	return s.client.Database(databaseName).Collection(paymentSnapshotsCollectionName)
+}
+
+// applyPaymentEvent returns the state of the payment stream after the given event
+func applyPaymentEvent(state paymentStreamState, event paymentEvent) (paymentStreamState, error) {
+	if event.Position != state.position+1 {
+		slog.Error("Unexpected event position", "payment_id", event.PaymentID, "event_position", event.Position, "stream_position", state.position)
+		return state, &PersistenceError{}
+	}
+
+	switch event.Type {
+	case paymentCreatedEvent:
+		if event.Payment != nil {
+			state.payment = *event.Payment
+		}
+	case paymentUpdatedEvent:
+		payment, err := applyPaymentFieldChanges(state.payment, event.Changes)
+		if err != nil {
+			slog.Error("Unexpected error while applying event", "event_id", event.ID, "error", err)
+			return state, &PersistenceError{}
+		}
+		state.payment = payment
+	case paymentDeletedEvent:
+		state.deleted = true
+	}
+
+	state.position = event.Position
+	state.recordedAt = event.RecordedAt
+	return state, nil
+}
+
+func newPaymentFieldChanges(changes []FieldChange) (fieldChanges []paymentFieldChange) {
+	for _, change := range changes {
+		fieldChange := paymentFieldChange{Path: change.Path}
+		if change.To != nil {
+			value, _ := json.Marshal(change.To)
+			fieldChange.Value = string(value)
+		}
+		fieldChanges = append(fieldChanges, fieldChange)
+	}
+	return fieldChanges
+}
+
+// applyPaymentFieldChanges sets the changed fields on the flattened payment, a change without a value removes the field
+func applyPaymentFieldChanges(payment Payment, changes []paymentFieldChange) (Payment, error) {
+	fields := flattenPayment(payment)
+
+	for _, change := range changes {
+		if len(change.Value) == 0 {
+			delete(fields, change.Path)
+			continue
+		}
+
+		var value interface{}
+		err := json.Unmarshal([]byte(change.Value), &value)
+		if err != nil {
+			return payment, err
+		}
+		fields[change.Path] = value
+	}
+
+	document := make(map[string]interface{})
+	for path, value := range fields {
+		current := document
+		keys := strings.Split(path, ".")
+		for _, key := range keys[:len(keys)-1] {
+			nested, ok := current[key].(map[string]interface{})
+			if !ok {
+				nested = make(map[string]interface{})
+				current[key] = nested
+			}
+			current = nested
+		}
+		current[keys[len(keys)-1]] = value
+	}
+
+	var result Payment
+	data, _ := json.Marshal(document)
+	err := json.Unmarshal(data, &result)
+	return result, err
+}
+
+func isDuplicateKeyError(err error) bool {
+	switch exception := err.(type) {
+	case mongo.WriteException:
+		for _, writeError := range exception.WriteErrors {
+			if writeError.Code == duplicateKeyErrorCode {
+				return true
+			}
+		}
+	case mongo.BulkWriteException:
+		for _, writeError := range exception.WriteErrors {
+			if writeError.Code == duplicateKeyErrorCode {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	. "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestApplyPaymentEventsReplaysStream(t *testing.T) {
+	created := Payment{ID: "1", Version: 1, OrganisationID: "123"}
+	created.Attributes.Amount = 100.21
+	created.Attributes.Currency = "GBP"
+	created.Attributes.ChargesInformation.SenderCharges = []SenderCharges{{Amount: 5, Currency: "GBP"}}
+
+	updated := created
+	updated.Version = 2
+	updated.Attributes.Amount = 150
+	updated.Attributes.Currency = ""
+	updated.Attributes.ChargesInformation.SenderCharges = []SenderCharges{{Amount: 5, Currency: "GBP"}, {Amount: 10, Currency: "USD"}}
+
+	events := []paymentEvent{
+		{ID: "1:1", PaymentID: "1", Position: 1, Type: paymentCreatedEvent, Payment: &created},
+		{ID: "1:2", PaymentID: "1", Position: 2, Type: paymentUpdatedEvent, Changes: newPaymentFieldChanges(diffPayments(created, updated))},
+	}
+
+	state := paymentStreamState{}
+	var err error
+	for _, event := range events {
+		state, err = applyPaymentEvent(state, event)
+		Nil(t, err)
+	}
+
+	Equal(t, 2, state.position)
+	False(t, state.deleted)
+	Equal(t, updated, state.payment)
+}
+
+func TestApplyPaymentEventsDeleted(t *testing.T) {
+	state := paymentStreamState{payment: Payment{ID: "1", Version: 1}, position: 1}
+
+	state, err := applyPaymentEvent(state, paymentEvent{ID: "1:2", PaymentID: "1", Position: 2, Type: paymentDeletedEvent})
+
+	Nil(t, err)
+	Equal(t, 2, state.position)
+	True(t, state.deleted)
+}
+
+func TestApplyPaymentEventsOutOfOrder(t *testing.T) {
+	state := paymentStreamState{payment: Payment{ID: "1", Version: 1}, position: 1}
+
+	_, err := applyPaymentEvent(state, paymentEvent{ID: "1:3", PaymentID: "1", Position: 3, Type: paymentDeletedEvent})
+
+	IsType(t, &PersistenceError{}, err)
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"io"
+	"net/http"
+	"time"
+)
+
+// A paymentImporter parses a document of a foreign format into payments
+type paymentImporter func(reader io.Reader) (payments []Payment, err error)
+
+// A paymentExporter renders a payment as a document of a foreign format
+type paymentExporter struct {
+	contentType string
+	render      func(payment Payment, createdAt time.Time) ([]byte, error)
+}
+
+var paymentImporters = map[string]paymentImporter{
+	pain001Format: parsePain001,
+	mt103Format:   parseMT103}
+
+var paymentExporters = map[string]paymentExporter{
+	pacs008Format: {"application/xml; charset=UTF-8", renderPacs008},
+	mt103Format:   {"text/plain; charset=US-ASCII", renderMT103}}
+
+// importPaymentsEndpoint creates a payment of the organisation for every transaction of the imported document
+func importPaymentsEndpoint(writer http.ResponseWriter, request *http.Request) {
+	format := mux.Vars(request)["format"]
+
+	importer, ok := paymentImporters[format]
+	if !ok {
+		prepareFailureHeader(writer, request, &UnsupportedFormatError{format})
+		return
+	}
+
+	payments, err := importer(request.Body)
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	organisationID := request.URL.Query().Get("organisation_id")
+	for i := range payments {
+		payments[i].OrganisationID = organisationID
+		if err = validatePayment(payments[i], true); err != nil {
+			prepareFailureHeader(writer, request, err)
+			return
+		}
+		payments[i] = newImportedPayment(payments[i])
+	}
+
+	repository := contextPaymentRepository(request.Context())
+	if transactional, ok := transactionalPaymentRepository(repository); ok {
+		err = transactional.RunInTransaction(func(repository PaymentRepository) error {
+			for _, payment := range payments {
+				if err := repository.InsertPayment(payment); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			prepareFailureHeader(writer, request, err)
+			return
+		}
+
+		for _, payment := range payments {
+			notifyPaymentEvent(paymentCreatedEvent, payment)
+		}
+	} else if report, err := insertImportedPayments(repository, payments); err != nil {
+		prepareFailureHeader(writer, request, err)
+
+		links := Links{
+			Self: prepareFullPaymentURL(request.Host, getAllPaymentsPath, "")}
+		_ = json.NewEncoder(writer).Encode(PaymentImportReport{report, links})
+		return
+	}
+
+	prepareSuccessHeader(writer, http.StatusCreated)
+
+	links := Links{
+		Self: prepareFullPaymentURL(request.Host, getAllPaymentsPath, "")}
+
+	result := PaymentListResult{payments, links}
+	_ = json.NewEncoder(writer).Encode(result)
+}
+
+// insertImportedPayments stores the payments one by one until the first failure
+func insertImportedPayments(repository PaymentRepository, payments []Payment) (report []PaymentImportRow, err error) {
+	report = make([]PaymentImportRow, len(payments))
+	for i := range report {
+		report[i] = PaymentImportRow{Row: i + 1, Status: importRowNotImported}
+	}
+
+	for i, payment := range payments {
+		if err = repository.InsertPayment(payment); err != nil {
+			report[i].Status = importRowFailed
+			report[i].Errors = []FieldError{{Message: err.Error()}}
+			break
+		}
+		notifyPaymentEvent(paymentCreatedEvent, payment)
+		report[i].Status = importRowCreated
+		report[i].ID = payment.ID
+	}
+	return report, err
+}
+
+// newImportedPayment gives the imported payment a new id and the first version
+func newImportedPayment(payment Payment) Payment {
+	newUUID, _ := uuid.NewUUID()
+	payment.ID = newUUID.String()
+	payment.Version = 1
+	return payment
+}
+
+// importPaymentsCSVEndpoint creates a payment for every row of the CSV file
+func importPaymentsCSVEndpoint(writer http.ResponseWriter, request *http.Request) {
+	mode := request.URL.Query().Get("mode")
+	if len(mode) == 0 {
+		mode = allOrNothingImport
+	}
+	if mode != allOrNothingImport && mode != bestEffortImport {
+		prepareFailureHeader(writer, request, fmt.Errorf("mode must be either '%s' or '%s'", allOrNothingImport, bestEffortImport))
+		return
+	}
+
+	rows, err := parsePaymentsCSV(request.Body)
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	organisationID := request.URL.Query().Get("organisation_id")
+	report := make([]PaymentImportRow, len(rows))
+	invalid := false
+
+	for i := range rows {
+		if len(rows[i].payment.OrganisationID) == 0 {
+			rows[i].payment.OrganisationID = organisationID
+		}
+		if len(rows[i].errors) == 0 {
+			rows[i].errors = validateImportedPayment(rows[i].payment)
+		}
+
+		report[i] = PaymentImportRow{Row: rows[i].row, Status: importRowNotImported, Errors: rows[i].errors}
+		if len(rows[i].errors) > 0 {
+			report[i].Status = importRowInvalid
+			invalid = true
+		}
+	}
+
+	created := 0
+	failed := false
+	repository := contextPaymentRepository(request.Context())
+	transactional, atomic := transactionalPaymentRepository(repository)
+	switch {
+	case invalid && mode == allOrNothingImport:
+	case atomic && mode == allOrNothingImport:
+		created, failed = insertCSVRowsAtomically(transactional, rows, report)
+	default:
+		created, failed = insertCSVRows(repository, rows, report)
+	}
+
+	statusCode := http.StatusCreated
+	switch {
+	case failed && created == 0:
+		statusCode = http.StatusInternalServerError
+	case created == 0:
+		statusCode = http.StatusBadRequest
+	case created < len(rows):
+		statusCode = http.StatusMultiStatus
+	}
+
+	prepareSuccessHeader(writer, statusCode)
+
+	links := Links{
+		Self: prepareFullPaymentURL(request.Host, getAllPaymentsPath, "")}
+
+	_ = json.NewEncoder(writer).Encode(PaymentImportReport{report, links})
+}
+
+// insertCSVRows stores the payments of the valid rows one by one until the first failure
+func insertCSVRows(repository PaymentRepository, rows []csvRow, report []PaymentImportRow) (created int, failed bool) {
+	for i := range rows {
+		if report[i].Status == importRowInvalid {
+			continue
+		}
+
+		payment := newImportedPayment(rows[i].payment)
+		if err := repository.InsertPayment(payment); err != nil {
+			report[i].Status = importRowFailed
+			report[i].Errors = []FieldError{{Message: err.Error()}}
+			return created, true
+		}
+
+		notifyPaymentEvent(paymentCreatedEvent, payment)
+		report[i].Status = importRowCreated
+		report[i].ID = payment.ID
+		created++
+	}
+	return created, false
+}
+
+// insertCSVRowsAtomically stores the payments of the rows in a transaction
+func insertCSVRowsAtomically(repository TransactionalPaymentRepository, rows []csvRow, report []PaymentImportRow) (created int, failed bool) {
+	payments := make([]Payment, len(rows))
+	failedRow := -1
+
+	err := repository.RunInTransaction(func(repository PaymentRepository) error {
+		for i := range rows {
+			payments[i] = newImportedPayment(rows[i].payment)
+			if err := repository.InsertPayment(payments[i]); err != nil {
+				failedRow = i
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		// a failed commit is the failure of every row
+		for i := range report {
+			if failedRow < 0 || failedRow == i {
+				report[i].Status = importRowFailed
+				report[i].Errors = []FieldError{{Message: err.Error()}}
+			}
+		}
+		return 0, true
+	}
+
+	for i, payment := range payments {
+		notifyPaymentEvent(paymentCreatedEvent, payment)
+		report[i].Status = importRowCreated
+		report[i].ID = payment.ID
+	}
+	return len(payments), false
+}
+
+// exportPaymentEndpoint renders the payment in the requested format
+func exportPaymentEndpoint(writer http.ResponseWriter, request *http.Request) {
+	paymentID := mux.Vars(request)["id"]
+	format := request.URL.Query().Get("format")
+
+	exporter, ok := paymentExporters[format]
+	if !ok {
+		prepareFailureHeader(writer, request, &UnsupportedFormatError{format})
+		return
+	}
+
+	payment, err := loadPayment(request, paymentID)
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	document, err := exporter.render(payment, time.Now())
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	writer.Header().Set("Content-Type", exporter.contentType)
+	writer.WriteHeader(http.StatusOK)
+	_, _ = writer.Write(document)
+}
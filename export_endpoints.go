@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	csvContentType    string = "text/csv"
+	ndjsonContentType string = "application/x-ndjson"
+
+	// The number of exported payments after which the response is flushed to the client
+	exportFlushInterval int = 100
+)
+
+// The columns of a CSV export when ?columns is not provided
+var defaultExportColumns = []string{"id", "version", "organisation_id", "amount", "currency", "payment_scheme",
+	"payment_type", "processing_date", "reference", "debtor_party.name", "debtor_party.account_number",
+	"debtor_party.bank_id", "beneficiary_party.name", "beneficiary_party.account_number", "beneficiary_party.bank_id"}
+
+var paymentsExportTimeout time.Duration
+
+func setExportTimeout(timeout time.Duration) {
+	paymentsExportTimeout = timeout
+}
+
+// exportPaymentsEndpoint streams the payments matching the filter as CSV or as newline delimited json
+func exportPaymentsEndpoint(writer http.ResponseWriter, request *http.Request) {
+	contentType, ok := negotiateExportContentType(request.Header.Get("Accept"))
+	if !ok {
+		prepareFailureHeader(writer, request, &NotAcceptableError{request.Header.Get("Accept")})
+		return
+	}
+
+	columns, err := parseExportColumns(request.URL.Query().Get("columns"))
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	if paymentsExportTimeout > 0 {
+		_ = http.NewResponseController(writer).SetWriteDeadline(time.Now().Add(paymentsExportTimeout))
+	}
+
+	csvWriter := csv.NewWriter(writer)
+	jsonEncoder := json.NewEncoder(writer)
+	encode := func(payment Payment) error {
+		if contentType == ndjsonContentType {
+			return jsonEncoder.Encode(payment)
+		}
+		return csvWriter.Write(newExportRecord(payment, columns))
+	}
+
+	flush := func() {
+		csvWriter.Flush()
+		if flusher, ok := writer.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+
+	exported := 0
+	writeHeader := func() {
+		writer.Header().Set("Content-Type", contentType+"; charset=UTF-8")
+		writer.WriteHeader(http.StatusOK)
+		if contentType == csvContentType {
+			_ = csvWriter.Write(columns)
+		}
+	}
+
+	err = contextPaymentRepository(request.Context()).StreamPayments(request.Context(), parsePaymentFilter(request), func(payment Payment) error {
+		if exported == 0 {
+			writeHeader()
+		}
+		if err := encode(payment); err != nil {
+			return err
+		}
+
+		exported++
+		if exported%exportFlushInterval == 0 {
+			flush()
+		}
+		return nil
+	})
+
+	if err != nil && exported == 0 {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	// The status has been sent already, so the connection is aborted to let the client know the export is incomplete
+	if err != nil {
+		slog.ErrorContext(request.Context(), "Export aborted", "exported", exported, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+
+	if exported == 0 {
+		writeHeader()
+	}
+	flush()
+}
+
+// negotiateExportContentType picks the first supported media type of the Accept header
+func negotiateExportContentType(accept string) (string, bool) {
+	if len(strings.TrimSpace(accept)) == 0 {
+		return ndjsonContentType, true
+	}
+
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case csvContentType, ndjsonContentType:
+			return mediaType, true
+		case "*/*", "application/*":
+			return ndjsonContentType, true
+		case "text/*":
+			return csvContentType, true
+		}
+	}
+	return "", false
+}
+
+// parseExportColumns splits the comma separated columns of an export
+func parseExportColumns(value string) (columns []string, err error) {
+	if len(value) == 0 {
+		return defaultExportColumns, nil
+	}
+
+	for _, column := range strings.Split(value, ",") {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if _, ok := csvColumns[column]; !ok && column != "id" && column != "version" && column != "type" {
+			return nil, fmt.Errorf("unknown export column '%s'", column)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// newExportRecord formats the flattened fields of the payment in the order of the columns, the missing fields are empty
+func newExportRecord(payment Payment, columns []string) []string {
+	fields := flattenPayment(payment)
+	record := make([]string, len(columns))
+
+	for i, column := range columns {
+		value, ok := fields[column]
+		if !ok {
+			value = fields["attributes."+column]
+		}
+
+		switch value := value.(type) {
+		case nil:
+		case string:
+			record[i] = value
+		case float64:
+			record[i] = strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			data, _ := json.Marshal(value)
+			record[i] = string(data)
+		}
+	}
+	return record
+}
+
+// parsePaymentFilter reads the filters of the payment listings: ?organisation_id, ?payment_scheme and ?processing_date
+func parsePaymentFilter(request *http.Request) PaymentFilter {
+	query := request.URL.Query()
+	return PaymentFilter{
+		OrganisationID: query.Get("organisation_id"),
+		PaymentScheme:  query.Get("payment_scheme"),
+		ProcessingDate: query.Get("processing_date")}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	. "github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test payments export handler
+
+func TestExportPaymentsNDJSON(t *testing.T) {
+	response := ServeExportHTTP("?organisation_id=123&processing_date=2019-05-06", ndjsonContentType, successful, MockBacsPayments(t))
+
+	Equal(t, 200, response.Code)
+	Equal(t, "application/x-ndjson; charset=UTF-8", response.Header().Get("Content-Type"))
+
+	var ids []string
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		var payment Payment
+		Nil(t, json.Unmarshal(scanner.Bytes(), &payment))
+		ids = append(ids, payment.ID)
+	}
+	Equal(t, []string{"b1", "b2", "b3"}, ids)
+}
+
+func TestExportPaymentsCSVDefaultColumns(t *testing.T) {
+	response := ServeExportHTTP("?payment_scheme=Bacs", "text/csv", successful, MockBacsPayments(t))
+
+	Equal(t, 200, response.Code)
+	Equal(t, "text/csv; charset=UTF-8", response.Header().Get("Content-Type"))
+
+	records, err := csv.NewReader(response.Body).ReadAll()
+	Nil(t, err)
+	Equal(t, 5, len(records))
+	Equal(t, defaultExportColumns, records[0])
+	Equal(t, []string{"b1", "1", "123", "100.21", "GBP", "Bacs", "Credit", "2019-05-06", "Piano lessons May",
+		"Emelia Jane Brown", "71268996", "203301", "Wilfred Jeremiah Owens", "31926819", "403000"}, records[1])
+}
+
+func TestExportPaymentsCSVImportedBack(t *testing.T) {
+	columns := "organisation_id,amount,currency,processing_date,debtor_party.name,beneficiary_party.account_number"
+
+	response := ServeExportHTTP("?columns="+columns, "text/csv;q=0.9, application/json", successful, MockBacsPayments(t))
+
+	rows, err := parsePaymentsCSV(response.Body)
+	Nil(t, err)
+	Equal(t, 4, len(rows))
+	for i, payment := range MockBacsPayments(t) {
+		Equal(t, payment.Attributes.Amount, rows[i].payment.Attributes.Amount)
+		Equal(t, payment.Attributes.DebtorParty.Name, rows[i].payment.Attributes.DebtorParty.Name)
+		Equal(t, payment.Attributes.BeneficiaryParty.SponsorParty.AccountNumber, rows[i].payment.Attributes.BeneficiaryParty.SponsorParty.AccountNumber)
+	}
+}
+
+func TestExportPaymentsFlushedInChunks(t *testing.T) {
+	var payments []Payment
+	for i := 0; i < 250; i++ {
+		payments = append(payments, Payment{ID: fmt.Sprint(i), OrganisationID: "123"})
+	}
+
+	response := ServeExportHTTP("", ndjsonContentType, successful, payments)
+
+	True(t, response.Flushed)
+	Equal(t, 250, strings.Count(response.Body.String(), "\n"))
+}
+
+func TestExportPaymentsEmpty(t *testing.T) {
+	response := ServeExportHTTP("?organisation_id=456", "text/csv", successful, MockBacsPayments(t))
+
+	Equal(t, 200, response.Code)
+	Equal(t, strings.Join(defaultExportColumns, ",")+"\n", response.Body.String())
+}
+
+func TestExportPaymentsNotAcceptable(t *testing.T) {
+	response := ServeExportHTTP("", "application/xml", successful, MockBacsPayments(t))
+
+	Equal(t, 406, response.Code)
+}
+
+func TestExportPaymentsUnknownColumn(t *testing.T) {
+	response := ServeExportHTTP("?columns=id,colour", "text/csv", successful, MockBacsPayments(t))
+
+	Equal(t, 400, response.Code)
+}
+
+func TestExportPaymentsServerFailed(t *testing.T) {
+	response := ServeExportHTTP("", "text/csv", dbFailure, MockBacsPayments(t))
+
+	Equal(t, 500, response.Code)
+}
+
+func TestGetAllPaymentsFiltered(t *testing.T) {
+	setPaymentRepository(&PaymentRepositoryMock{mode: successful, payments: MockBacsPayments(t)})
+	request, _ := http.NewRequest(methodGet, getAllPaymentsPath+"?processing_date=2019-06-06", http.NoBody)
+
+	response := httptest.NewRecorder()
+	configureRouter().ServeHTTP(response, request)
+
+	var result PaymentListResult
+	_ = json.NewDecoder(response.Body).Decode(&result)
+
+	Equal(t, 200, response.Code)
+	Equal(t, 1, len(result.Data))
+	Equal(t, "b4", result.Data[0].ID)
+}
+
+// ---------------------------------------------------- //
+
+func ServeExportHTTP(query string, accept string, mode string, payments []Payment) *httptest.ResponseRecorder {
+	setPaymentRepository(&PaymentRepositoryMock{mode: mode, payments: payments})
+
+	request, _ := http.NewRequest(methodGet, exportPaymentsPath+query, bytes.NewReader(nil))
+	request.Header.Set("Accept", accept)
+
+	response := httptest.NewRecorder()
+	configureRouter().ServeHTTP(response, request)
+
+	return response
+}
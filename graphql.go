@@ -0,0 +1,526 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// The number of payments of a page when the query doesn't ask for another one
+	graphqlDefaultPageSize int = 20
+)
+
+// The largest cost of a query which is executed, it is configured by the server
+var graphqlMaxCost = 1000
+
+func setGraphQLMaxCost(cost int) {
+	graphqlMaxCost = cost
+}
+
+// The schema of the GraphQL API, its payment types are generated from the models
+var graphqlSchema graphql.Schema
+
+func setGraphQLSchema(schema graphql.Schema) {
+	graphqlSchema = schema
+}
+
+// A graphqlRequest is a structure which represents the body of a GraphQL request
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// graphqlEndpoint executes the GraphQL query of the request
+func graphqlEndpoint(writer http.ResponseWriter, request *http.Request) {
+	var body graphqlRequest
+	if err := json.NewDecoder(request.Body).Decode(&body); err != nil {
+		writeGraphQLResult(writer, request, http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	document, err := parser.Parse(parser.ParseParams{Source: body.Query})
+	if err != nil {
+		writeGraphQLResult(writer, request, http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	if validation := graphql.ValidateDocument(&graphqlSchema, document, nil); !validation.IsValid {
+		writeGraphQLResult(writer, request, http.StatusBadRequest, &graphql.Result{Errors: validation.Errors})
+		return
+	}
+
+	if cost := graphqlQueryCost(document, body.OperationName, body.Variables); cost > graphqlMaxCost {
+		err = fmt.Errorf("query cost %d exceeds the limit of %d", cost, graphqlMaxCost)
+		writeGraphQLResult(writer, request, http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	loader := newPaymentLoader(contextPaymentRepository(request.Context()))
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        graphqlSchema,
+		AST:           document,
+		OperationName: body.OperationName,
+		Args:          body.Variables,
+		Context:       context.WithValue(request.Context(), paymentLoaderKey{}, loader)})
+
+	writeGraphQLResult(writer, request, http.StatusOK, result)
+}
+
+func writeGraphQLResult(writer http.ResponseWriter, request *http.Request, statusCode int, result *graphql.Result) {
+	for _, err := range result.Errors {
+		slog.InfoContext(request.Context(), "Request processed with error", "error", err)
+	}
+
+	prepareSuccessHeader(writer, statusCode)
+	_ = json.NewEncoder(writer).Encode(result)
+}
+
+// A graphqlError is the error of a resolver carrying its http status code
+type graphqlError struct {
+	err error
+}
+
+func (e graphqlError) Error() string {
+	return e.err.Error()
+}
+
+func (e graphqlError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"status": failureStatusCode(e.err)}
+}
+
+// newGraphQLSchema generates the payment types from the models and builds the queries and mutations on top of them
+func newGraphQLSchema() (graphql.Schema, error) {
+	types := newGraphQLTypes()
+
+	payment, err := types.object(reflect.TypeOf(Payment{}))
+	if err != nil {
+		return graphql.Schema{}, err
+	}
+	paymentInput, err := types.input(reflect.TypeOf(Payment{}))
+	if err != nil {
+		return graphql.Schema{}, err
+	}
+
+	pageInfo := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"end_cursor":    &graphql.Field{Type: graphql.String},
+			"has_next_page": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)}}})
+
+	paymentConnection := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PaymentConnection",
+		Fields: graphql.Fields{
+			"nodes":     &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(payment)))},
+			"page_info": &graphql.Field{Type: graphql.NewNonNull(pageInfo)}}})
+
+	paymentFilter := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "PaymentFilter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"organisation_id":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"payment_scheme":      &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"processing_date":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"payment_type":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"scheme_payment_type": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"currency":            &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"min_amount":          &graphql.InputObjectFieldConfig{Type: graphql.Float},
+			"max_amount":          &graphql.InputObjectFieldConfig{Type: graphql.Float}}})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"payment": &graphql.Field{
+				Type:    payment,
+				Args:    graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: resolvePayment},
+			"payments": &graphql.Field{
+				Type: graphql.NewNonNull(paymentConnection),
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: paymentFilter},
+					"first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: graphqlDefaultPageSize},
+					"after":  &graphql.ArgumentConfig{Type: graphql.String}},
+				Resolve: resolvePayments}}})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createPayment": &graphql.Field{
+				Type:    payment,
+				Args:    graphql.FieldConfigArgument{"payment": &graphql.ArgumentConfig{Type: graphql.NewNonNull(paymentInput)}},
+				Resolve: resolvePaymentOperation(createOperation)},
+			"updatePayment": &graphql.Field{
+				Type:    payment,
+				Args:    graphql.FieldConfigArgument{"payment": &graphql.ArgumentConfig{Type: graphql.NewNonNull(paymentInput)}},
+				Resolve: resolvePaymentOperation(updateOperation)},
+			"deletePayment": &graphql.Field{
+				Type:    payment,
+				Args:    graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: resolvePaymentOperation(deleteOperation)}}})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+// graphqlTypes generates the object and input types of the models, every model gets a single type of each kind
+type graphqlTypes struct {
+	objects map[reflect.Type]*graphql.Object
+	inputs  map[reflect.Type]*graphql.InputObject
+}
+
+func newGraphQLTypes() *graphqlTypes {
+	return &graphqlTypes{objects: make(map[reflect.Type]*graphql.Object), inputs: make(map[reflect.Type]*graphql.InputObject)}
+}
+
+// A graphqlModelField is a json field of a model, a number written as a string in json is a string field
+type graphqlModelField struct {
+	name     string
+	kind     reflect.Type
+	asString bool
+}
+
+// graphqlModelFields lists the json fields of the model
+func graphqlModelFields(model reflect.Type) (fields []graphqlModelField) {
+	for i := 0; i < model.NumField(); i++ {
+		field := model.Field(i)
+
+		if field.Anonymous {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			fields = append(fields, graphqlModelFields(embedded)...)
+			continue
+		}
+
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		if tag[0] == "-" || !field.IsExported() {
+			continue
+		}
+
+		name := tag[0]
+		if len(name) == 0 {
+			name = field.Name
+		}
+		fields = append(fields, graphqlModelField{name, field.Type, len(tag) > 1 && tag[1] == "string"})
+	}
+	return fields
+}
+
+func (t *graphqlTypes) object(model reflect.Type) (*graphql.Object, error) {
+	if object, ok := t.objects[model]; ok {
+		return object, nil
+	}
+
+	fields := graphql.Fields{}
+	for _, field := range graphqlModelFields(model) {
+		fieldType, err := t.outputType(field.kind, field.asString)
+		if err != nil {
+			return nil, err
+		}
+		fields[field.name] = &graphql.Field{Type: fieldType}
+	}
+
+	t.objects[model] = graphql.NewObject(graphql.ObjectConfig{Name: model.Name(), Fields: fields})
+	return t.objects[model], nil
+}
+
+func (t *graphqlTypes) input(model reflect.Type) (*graphql.InputObject, error) {
+	if input, ok := t.inputs[model]; ok {
+		return input, nil
+	}
+
+	fields := graphql.InputObjectConfigFieldMap{}
+	for _, field := range graphqlModelFields(model) {
+		fieldType, err := t.inputType(field.kind, field.asString)
+		if err != nil {
+			return nil, err
+		}
+		fields[field.name] = &graphql.InputObjectFieldConfig{Type: fieldType}
+	}
+
+	t.inputs[model] = graphql.NewInputObject(graphql.InputObjectConfig{Name: model.Name() + "Input", Fields: fields})
+	return t.inputs[model], nil
+}
+
+func (t *graphqlTypes) outputType(kind reflect.Type, asString bool) (graphql.Output, error) {
+	switch kind.Kind() {
+	case reflect.Slice:
+		element, err := t.outputType(kind.Elem(), false)
+		if err != nil {
+			return nil, err
+		}
+		return graphql.NewList(element), nil
+	case reflect.Struct:
+		return t.object(kind)
+	default:
+		return graphqlScalar(kind, asString)
+	}
+}
+
+func (t *graphqlTypes) inputType(kind reflect.Type, asString bool) (graphql.Input, error) {
+	switch kind.Kind() {
+	case reflect.Slice:
+		element, err := t.inputType(kind.Elem(), false)
+		if err != nil {
+			return nil, err
+		}
+		return graphql.NewList(element), nil
+	case reflect.Struct:
+		return t.input(kind)
+	default:
+		return graphqlScalar(kind, asString)
+	}
+}
+
+func graphqlScalar(kind reflect.Type, asString bool) (*graphql.Scalar, error) {
+	if asString {
+		return graphql.String, nil
+	}
+
+	switch kind.Kind() {
+	case reflect.String:
+		return graphql.String, nil
+	case reflect.Int:
+		return graphql.Int, nil
+	case reflect.Float64:
+		return graphql.Float, nil
+	case reflect.Bool:
+		return graphql.Boolean, nil
+	default:
+		return nil, fmt.Errorf("field type %s has no GraphQL type", kind.String())
+	}
+}
+
+// graphqlPaymentSource converts the payment to its json document
+func graphqlPaymentSource(payment Payment) (source map[string]interface{}, err error) {
+	document, err := json.Marshal(payment)
+	if err != nil {
+		return nil, err
+	}
+	return source, json.Unmarshal(document, &source)
+}
+
+// graphqlPaymentArgument decodes the payment argument of a mutation
+func graphqlPaymentArgument(arguments map[string]interface{}) (payment Payment, err error) {
+	document, err := json.Marshal(arguments["payment"])
+	if err != nil {
+		return payment, err
+	}
+	return payment, json.Unmarshal(document, &payment)
+}
+
+func resolvePayment(params graphql.ResolveParams) (interface{}, error) {
+	loader := params.Context.Value(paymentLoaderKey{}).(*paymentLoader)
+	paymentID, _ := params.Args["id"].(string)
+	return loader.load(paymentID), nil
+}
+
+// resolvePayments reads a page of the payments matching the filter
+func resolvePayments(params graphql.ResolveParams) (interface{}, error) {
+	first, _ := params.Args["first"].(int)
+	if first < 1 || first > maxPaymentsPageSize {
+		return nil, graphqlError{fmt.Errorf("first must be between 1 and %d", maxPaymentsPageSize)}
+	}
+	after, _ := params.Args["after"].(string)
+	payments, more, err := findPaymentsPage(params.Context, graphqlPaymentFilter(params.Args), after, first)
+	if err != nil {
+		return nil, graphqlError{err}
+	}
+
+	nodes := []interface{}{}
+	pageInfo := map[string]interface{}{"has_next_page": more}
+
+	for _, payment := range payments {
+		source, err := graphqlPaymentSource(payment)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, source)
+		pageInfo["end_cursor"] = payment.ID
+	}
+	return map[string]interface{}{"nodes": nodes, "page_info": pageInfo}, nil
+}
+
+// graphqlPaymentFilter reads the filter argument into the filter of the repository
+func graphqlPaymentFilter(arguments map[string]interface{}) PaymentFilter {
+	criteria, _ := arguments["filter"].(map[string]interface{})
+	text := func(name string) string {
+		value, _ := criteria[name].(string)
+		return value
+	}
+	amount := func(name string) *float64 {
+		if value, ok := criteria[name].(float64); ok {
+			return &value
+		}
+		return nil
+	}
+
+	return PaymentFilter{
+		OrganisationID:    text("organisation_id"),
+		PaymentScheme:     text("payment_scheme"),
+		ProcessingDate:    text("processing_date"),
+		PaymentType:       text("payment_type"),
+		SchemePaymentType: text("scheme_payment_type"),
+		Currency:          text("currency"),
+		MinAmount:         amount("min_amount"),
+		MaxAmount:         amount("max_amount")}
+}
+
+// resolvePaymentOperation resolves a mutation with the operation of a batch
+func resolvePaymentOperation(op string) graphql.FieldResolveFn {
+	return func(params graphql.ResolveParams) (interface{}, error) {
+		operation := PaymentOperation{Op: op}
+
+		if op == deleteOperation {
+			operation.ID, _ = params.Args["id"].(string)
+		} else {
+			payment, err := graphqlPaymentArgument(params.Args)
+			if err != nil {
+				return nil, graphqlError{err}
+			}
+			operation.Payment = &payment
+		}
+
+		payment, err := applySinglePaymentOperation(contextPaymentRepository(params.Context), operation)
+		if err != nil {
+			return nil, graphqlError{err}
+		}
+		return graphqlPaymentSource(payment)
+	}
+}
+
+// The context key of the payment loader of a query
+type paymentLoaderKey struct{}
+
+// A paymentLoader batches the payment reads of a query
+type paymentLoader struct {
+	repository PaymentRepository
+	pending    []string
+	loaded     map[string]bool
+	payments   map[string]Payment
+	failures   map[string]error
+}
+
+func newPaymentLoader(repository PaymentRepository) *paymentLoader {
+	return &paymentLoader{repository: repository, loaded: make(map[string]bool), payments: make(map[string]Payment), failures: make(map[string]error)}
+}
+
+// load queues the payment for the next read and returns the thunk completing the field with it
+func (l *paymentLoader) load(paymentID string) func() (interface{}, error) {
+	if !l.loaded[paymentID] {
+		l.loaded[paymentID] = true
+		l.pending = append(l.pending, paymentID)
+	}
+
+	return func() (interface{}, error) {
+		l.flush()
+
+		if err, failed := l.failures[paymentID]; failed {
+			return nil, graphqlError{err}
+		}
+		payment, found := l.payments[paymentID]
+		if !found {
+			return nil, nil
+		}
+		return graphqlPaymentSource(payment)
+	}
+}
+
+func (l *paymentLoader) flush() {
+	if len(l.pending) == 0 {
+		return
+	}
+	paymentIDs := l.pending
+	l.pending = nil
+
+	payments, err := l.repository.GetPayments(paymentIDs)
+	if err != nil {
+		for _, paymentID := range paymentIDs {
+			l.failures[paymentID] = err
+		}
+		return
+	}
+
+	for _, payment := range payments {
+		l.payments[payment.ID] = payment
+	}
+}
+
+// graphqlQueryCost estimates the cost of the operation before it is executed
+func graphqlQueryCost(document *ast.Document, operationName string, variables map[string]interface{}) int {
+	var operation *ast.OperationDefinition
+	fragments := make(map[string]*ast.FragmentDefinition)
+
+	for _, definition := range document.Definitions {
+		switch definition := definition.(type) {
+		case *ast.OperationDefinition:
+			if operation == nil && (len(operationName) == 0 || (definition.Name != nil && definition.Name.Value == operationName)) {
+				operation = definition
+			}
+		case *ast.FragmentDefinition:
+			fragments[definition.Name.Value] = definition
+		}
+	}
+
+	if operation == nil {
+		return 0
+	}
+	return graphqlSelectionCost(operation.SelectionSet, 1, fragments, variables)
+}
+
+func graphqlSelectionCost(selectionSet *ast.SelectionSet, multiplier int, fragments map[string]*ast.FragmentDefinition, variables map[string]interface{}) (cost int) {
+	if selectionSet == nil {
+		return 0
+	}
+
+	for _, selection := range selectionSet.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			cost += multiplier
+			if selection.Name.Value == "payments" {
+				cost += graphqlSelectionCost(selection.SelectionSet, multiplier*graphqlPageSize(selection, variables), fragments, variables)
+			} else {
+				cost += graphqlSelectionCost(selection.SelectionSet, multiplier, fragments, variables)
+			}
+		case *ast.InlineFragment:
+			cost += graphqlSelectionCost(selection.SelectionSet, multiplier, fragments, variables)
+		case *ast.FragmentSpread:
+			if fragment, ok := fragments[selection.Name.Value]; ok {
+				cost += graphqlSelectionCost(fragment.SelectionSet, multiplier, fragments, variables)
+			}
+		}
+	}
+	return cost
+}
+
+// graphqlPageSize reads the page size of the payments field clamped to the sizes a page can have
+func graphqlPageSize(field *ast.Field, variables map[string]interface{}) int {
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "first" {
+			continue
+		}
+
+		first := maxPaymentsPageSize
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if size, err := strconv.Atoi(value.Value); err == nil {
+				first = size
+			}
+		case *ast.Variable:
+			if size, ok := variables[value.Name.Value].(float64); ok {
+				first = int(size)
+			}
+		}
+		return min(max(first, 1), maxPaymentsPageSize)
+	}
+	return graphqlDefaultPageSize
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/parser"
+	. "github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// Test the schema is generated from the models
+
+func TestGraphQLSchemaFollowsModels(t *testing.T) {
+	schema, err := newGraphQLSchema()
+	Nil(t, err)
+
+	for _, model := range []reflect.Type{
+		reflect.TypeOf(Payment{}), reflect.TypeOf(Attributes{}), reflect.TypeOf(SponsorParty{}), reflect.TypeOf(DebtorParty{}),
+		reflect.TypeOf(BeneficiaryParty{}), reflect.TypeOf(ChargesInformation{}), reflect.TypeOf(SenderCharges{}), reflect.TypeOf(FX{})} {
+
+		object, ok := schema.Type(model.Name()).(*graphql.Object)
+		True(t, ok, model.Name())
+		input, ok := schema.Type(model.Name() + "Input").(*graphql.InputObject)
+		True(t, ok, model.Name())
+
+		properties := SortedKeys(ModelProperties(model))
+		Equal(t, properties, SortedKeys(object.Fields()), model.Name())
+		Equal(t, properties, SortedKeys(input.Fields()), model.Name())
+	}
+
+	attributes := schema.Type("Attributes").(*graphql.Object).Fields()
+	Equal(t, graphql.String, attributes["amount"].Type)
+	Equal(t, graphql.String, attributes["numeric_reference"].Type)
+	Equal(t, "BeneficiaryParty", attributes["beneficiary_party"].Type.Name())
+}
+
+// Test queries
+
+func TestGraphQLGetPayment(t *testing.T) {
+	response, result := ServeGraphQL(&PaymentRepositoryMock{mode: successful},
+		`{ payment(id: "2") { id version organisation_id attributes { amount currency debtor_party { account_number } } } }`, nil)
+
+	Equal(t, 200, response.Code)
+	Empty(t, result.Errors)
+	Equal(t, map[string]interface{}{"payment": map[string]interface{}{
+		"id": "2", "version": 1.0, "organisation_id": "123",
+		"attributes": map[string]interface{}{"amount": "10", "currency": "GBP", "debtor_party": map[string]interface{}{"account_number": nil}}}},
+		result.Data)
+}
+
+func TestGraphQLGetPaymentNotFound(t *testing.T) {
+	response, result := ServeGraphQL(&PaymentRepositoryMock{mode: notFound}, `{ payment(id: "2") { id } }`, nil)
+
+	Equal(t, 200, response.Code)
+	Empty(t, result.Errors)
+	Equal(t, map[string]interface{}{"payment": nil}, result.Data)
+}
+
+func TestGraphQLGetPaymentServerFailed(t *testing.T) {
+	response, result := ServeGraphQL(&PaymentRepositoryMock{mode: dbFailure}, `{ payment(id: "2") { id } }`, nil)
+
+	Equal(t, 200, response.Code)
+	Equal(t, 1, len(result.Errors))
+	Equal(t, map[string]interface{}{"payment": nil}, result.Data)
+}
+
+func TestGraphQLBatchesPaymentReads(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful}
+	_, result := ServeGraphQL(repository, `{
+		first: payment(id: "1") { id }
+		second: payment(id: "2") { id organisation_id }
+		again: payment(id: "1") { version } }`, nil)
+
+	Empty(t, result.Errors)
+	Equal(t, map[string]interface{}{"id": "2", "organisation_id": "123"}, result.Data["second"])
+	Equal(t, map[string]interface{}{"version": 1.0}, result.Data["again"])
+	// The fields of a query are resolved in no particular order
+	Equal(t, 1, len(repository.batches))
+	ElementsMatch(t, []string{"1", "2"}, repository.batches[0])
+}
+
+func TestGraphQLPaymentsPages(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful, payments: []Payment{
+		{ID: "1", OrganisationID: "123"}, {ID: "2", OrganisationID: "456"}, {ID: "3", OrganisationID: "123"}}}
+	query := `query($after: String) { payments(first: 2, after: $after) { nodes { id } page_info { end_cursor has_next_page } } }`
+
+	_, result := ServeGraphQL(repository, query, nil)
+	Empty(t, result.Errors)
+	Equal(t, map[string]interface{}{
+		"nodes":     []interface{}{map[string]interface{}{"id": "1"}, map[string]interface{}{"id": "2"}},
+		"page_info": map[string]interface{}{"end_cursor": "2", "has_next_page": true}},
+		result.Data["payments"])
+
+	_, result = ServeGraphQL(repository, query, map[string]interface{}{"after": "2"})
+	Empty(t, result.Errors)
+	Equal(t, map[string]interface{}{
+		"nodes":     []interface{}{map[string]interface{}{"id": "3"}},
+		"page_info": map[string]interface{}{"end_cursor": "3", "has_next_page": false}},
+		result.Data["payments"])
+}
+
+func TestGraphQLPaymentsFilter(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful, payments: []Payment{
+		{ID: "1", OrganisationID: "123", Attributes: Attributes{Amount: 10, Currency: "GBP"}},
+		{ID: "2", OrganisationID: "456", Attributes: Attributes{Amount: 20, Currency: "GBP"}},
+		{ID: "3", OrganisationID: "123", Attributes: Attributes{Amount: 30, Currency: "GBP"}},
+		{ID: "4", OrganisationID: "123", Attributes: Attributes{Amount: 40, Currency: "EUR"}}}}
+
+	_, result := ServeGraphQL(repository,
+		`{ payments(filter: {organisation_id: "123", currency: "GBP", min_amount: 15}) { nodes { id } } }`, nil)
+
+	Empty(t, result.Errors)
+	Equal(t, map[string]interface{}{"nodes": []interface{}{map[string]interface{}{"id": "3"}}}, result.Data["payments"])
+}
+
+func TestGraphQLPaymentsPageSelectedByRepository(t *testing.T) {
+	repository := &FilterRecordingRepositoryMock{PaymentRepositoryMock: PaymentRepositoryMock{mode: successful}}
+
+	_, result := ServeGraphQL(repository, `{ payments(first: 2, after: "1", filter: {organisation_id: "123"}) { nodes { id } } }`, nil)
+	Empty(t, result.Errors)
+	_, result = ServeGraphQL(repository, `{ payments(first: 2, filter: {currency: "GBP", min_amount: 15}) { nodes { id } } }`, nil)
+	Empty(t, result.Errors)
+
+	minAmount := 15.0
+	Equal(t, []PaymentFilter{{OrganisationID: "123", After: "1", Limit: 3}, {Currency: "GBP", MinAmount: &minAmount, Limit: 3}},
+		repository.filters)
+}
+
+func TestGraphQLPaymentsPageSize(t *testing.T) {
+	response, result := ServeGraphQL(&PaymentRepositoryMock{mode: successful}, `{ payments(first: 0) { nodes { id } } }`, nil)
+
+	Equal(t, 200, response.Code)
+	Equal(t, 1, len(result.Errors))
+	Equal(t, 400.0, result.Errors[0].Extensions["status"])
+}
+
+// Test mutations
+
+func TestGraphQLCreatePayment(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful}
+	_, result := ServeGraphQL(repository,
+		`mutation($payment: PaymentInput!) { createPayment(payment: $payment) { id version attributes { amount } } }`,
+		map[string]interface{}{"payment": map[string]interface{}{
+			"id": "1", "organisation_id": "123", "attributes": map[string]interface{}{"amount": "100.21"}}})
+
+	Empty(t, result.Errors)
+	created := result.Data["createPayment"].(map[string]interface{})
+	NotEqual(t, "1", created["id"])
+	Equal(t, 1.0, created["version"])
+	Equal(t, map[string]interface{}{"amount": "100.21"}, created["attributes"])
+	Equal(t, 1, len(repository.payments))
+	Equal(t, 100.21, repository.payments[0].Attributes.Amount)
+}
+
+func TestGraphQLCreateInvalidPayment(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful}
+
+	_, result := ServeGraphQL(repository, `mutation { createPayment(payment: {type: "Payment"}) { id } }`, nil)
+	Equal(t, 1, len(result.Errors))
+	Equal(t, 400.0, result.Errors[0].Extensions["status"])
+	Equal(t, map[string]interface{}{"createPayment": nil}, result.Data)
+
+	_, result = ServeGraphQL(repository, `mutation { createPayment(payment: {organisation_id: "123", attributes: {amount: "many"}}) { id } }`, nil)
+	Equal(t, 1, len(result.Errors))
+	Empty(t, repository.payments)
+}
+
+func TestGraphQLUpdatePayment(t *testing.T) {
+	_, result := ServeGraphQL(&PaymentRepositoryMock{mode: successful},
+		`mutation { updatePayment(payment: {id: "1", version: 1, organisation_id: "123"}) { id version } }`, nil)
+
+	Empty(t, result.Errors)
+	Equal(t, map[string]interface{}{"id": "1", "version": 2.0}, result.Data["updatePayment"])
+}
+
+func TestGraphQLUpdatePaymentVersionConflict(t *testing.T) {
+	_, result := ServeGraphQL(&PaymentRepositoryMock{mode: versionConflict},
+		`mutation { updatePayment(payment: {id: "1", version: 3, organisation_id: "123"}) { id } }`, nil)
+
+	Equal(t, 1, len(result.Errors))
+	Equal(t, 409.0, result.Errors[0].Extensions["status"])
+}
+
+func TestGraphQLDeletePayment(t *testing.T) {
+	_, result := ServeGraphQL(&PaymentRepositoryMock{mode: successful}, `mutation { deletePayment(id: "2") { id version } }`, nil)
+	Empty(t, result.Errors)
+	Equal(t, map[string]interface{}{"id": "2", "version": 2.0}, result.Data["deletePayment"])
+
+	_, result = ServeGraphQL(&PaymentRepositoryMock{mode: notFound}, `mutation { deletePayment(id: "2") { id } }`, nil)
+	Equal(t, 1, len(result.Errors))
+	Equal(t, 404.0, result.Errors[0].Extensions["status"])
+}
+
+// Test requests which are not executed
+
+func TestGraphQLRejectsInvalidQuery(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful}
+
+	response, result := ServeGraphQL(repository, `{ payment(id: "2") { id unknown } }`, nil)
+	Equal(t, 400, response.Code)
+	Equal(t, 1, len(result.Errors))
+
+	response, _ = ServeGraphQL(repository, `{ payment(id: "2") { id }`, nil)
+	Equal(t, 400, response.Code)
+	Empty(t, repository.batches)
+}
+
+func TestGraphQLRejectsCostlyQuery(t *testing.T) {
+	defer setGraphQLMaxCost(graphqlMaxCost)
+	setGraphQLMaxCost(30)
+	repository := &PaymentRepositoryMock{mode: successful}
+
+	response, result := ServeGraphQL(repository, `{ payments(first: 10) { nodes { id version } } }`, nil)
+	Equal(t, 400, response.Code)
+	Equal(t, 1, len(result.Errors))
+	Contains(t, result.Errors[0].Message, "query cost 31 exceeds the limit of 30")
+
+	response, _ = ServeGraphQL(repository, `{ payments(first: 5) { nodes { id version } } }`, nil)
+	Equal(t, 200, response.Code)
+}
+
+func TestGraphQLQueryCost(t *testing.T) {
+	for query, cost := range map[string]int{
+		`{ payment(id: "1") { id attributes { amount } } }`: 4,
+		`{ payments { nodes { id } } }`:                     1 + 2*graphqlDefaultPageSize,
+		`{ payments(first: 5) { nodes { ...ids } page_info { end_cursor } } } fragment ids on Payment { id version }`:    1 + 5*5,
+		`query($size: Int) { payments(first: $size) { nodes { id } } }`:                                                  1 + 2*maxPaymentsPageSize,
+		`{ a: payments(first: -100000) { nodes { id } } b: payments(first: 1000) { nodes { id } } }`:                     1 + 2 + 1 + 2*maxPaymentsPageSize,
+		`query Named { payments(first: 2) { nodes { ... on Payment { id } } } } query Other { payment(id: "1") { id } }`: 1 + 2*2,
+	} {
+		document, err := parser.Parse(parser.ParseParams{Source: query})
+		Nil(t, err)
+		Equal(t, cost, graphqlQueryCost(document, "", nil), query)
+	}
+
+	document, _ := parser.Parse(parser.ParseParams{Source: `query($size: Int) { payments(first: $size) { nodes { id } } }`})
+	Equal(t, 1+2*3, graphqlQueryCost(document, "", map[string]interface{}{"size": 3.0}))
+}
+
+// ---------------------------------------------------- //
+
+type GraphQLResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func ServeGraphQL(repository PaymentRepository, query string, variables map[string]interface{}) (*httptest.ResponseRecorder, GraphQLResponse) {
+	setPaymentRepository(repository)
+	router := configureRouter()
+
+	body, _ := json.Marshal(graphqlRequest{Query: query, Variables: variables})
+	request, _ := http.NewRequest(methodPost, graphqlPath, bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	var result GraphQLResponse
+	_ = json.Unmarshal(response.Body.Bytes(), &result)
+	return response, result
+}
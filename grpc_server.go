@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/vba270419/payments-backend-go/paymentspb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strconv"
+)
+
+const (
+	// The domain and the reason of the details of a version conflict error
+	paymentsErrorDomain         string = "payments"
+	paymentVersionConflictCause string = "PAYMENT_VERSION_CONFLICT"
+)
+
+// A paymentServiceServer serves the payment operations over gRPC
+type paymentServiceServer struct {
+	paymentspb.UnimplementedPaymentServiceServer
+}
+
+func newGRPCServer() *grpc.Server {
+	server := grpc.NewServer()
+	paymentspb.RegisterPaymentServiceServer(server, &paymentServiceServer{})
+	return server
+}
+
+func (s *paymentServiceServer) CreatePayment(ctx context.Context, request *paymentspb.CreatePaymentRequest) (*paymentspb.Payment, error) {
+	payment := paymentFromProto(request.GetPayment())
+	return applyGRPCPaymentOperation(ctx, PaymentOperation{Op: createOperation, Payment: &payment})
+}
+
+func (s *paymentServiceServer) UpdatePayment(ctx context.Context, request *paymentspb.UpdatePaymentRequest) (*paymentspb.Payment, error) {
+	payment := paymentFromProto(request.GetPayment())
+	return applyGRPCPaymentOperation(ctx, PaymentOperation{Op: updateOperation, Payment: &payment})
+}
+
+func (s *paymentServiceServer) DeletePayment(ctx context.Context, request *paymentspb.DeletePaymentRequest) (*paymentspb.DeletePaymentResponse, error) {
+	if _, err := applyGRPCPaymentOperation(ctx, PaymentOperation{Op: deleteOperation, ID: request.GetId()}); err != nil {
+		return nil, err
+	}
+	return &paymentspb.DeletePaymentResponse{}, nil
+}
+
+func (s *paymentServiceServer) GetPayment(ctx context.Context, request *paymentspb.GetPaymentRequest) (*paymentspb.Payment, error) {
+	payment, err := contextPaymentRepository(ctx).GetPayment(request.GetId())
+	if err != nil {
+		return nil, grpcStatusError(err)
+	}
+	return paymentToProto(payment), nil
+}
+
+// GetAllPayments lists the payments like the get all endpoint, optionally only the ones matching the filter
+func (s *paymentServiceServer) GetAllPayments(ctx context.Context, request *paymentspb.PaymentFilter) (*paymentspb.PaymentList, error) {
+	var payments []Payment
+	var err error
+
+	if filter := paymentFilterFromProto(request); filter != (PaymentFilter{}) {
+		payments, err = contextPaymentRepository(ctx).FindPayments(filter)
+	} else {
+		payments, err = contextPaymentRepository(ctx).GetAllPayments()
+	}
+
+	if err != nil {
+		return nil, grpcStatusError(err)
+	}
+
+	list := &paymentspb.PaymentList{}
+	for _, payment := range payments {
+		list.Payments = append(list.Payments, paymentToProto(payment))
+	}
+	return list, nil
+}
+
+// ListPayments streams the payments matching the filter straight from the repository cursor like the payments export
+func (s *paymentServiceServer) ListPayments(request *paymentspb.PaymentFilter, stream grpc.ServerStreamingServer[paymentspb.Payment]) error {
+	err := contextPaymentRepository(stream.Context()).StreamPayments(stream.Context(), paymentFilterFromProto(request), func(payment Payment) error {
+		return stream.Send(paymentToProto(payment))
+	})
+	if err != nil {
+		return grpcStatusError(err)
+	}
+	return nil
+}
+
+// applyGRPCPaymentOperation validates and applies the operation like a single operation of a batch and notifies its event
+func applyGRPCPaymentOperation(ctx context.Context, operation PaymentOperation) (*paymentspb.Payment, error) {
+	payment, err := applySinglePaymentOperation(contextPaymentRepository(ctx), operation)
+	if err != nil {
+		return nil, grpcStatusError(err)
+	}
+	return paymentToProto(payment), nil
+}
+
+// grpcStatusError converts the error of a call to its gRPC status
+func grpcStatusError(err error) error {
+	switch e := err.(type) {
+	case *PaymentVersionConflictError:
+		conflict := status.New(codes.Aborted, e.Error())
+		detailed, detailsErr := conflict.WithDetails(&errdetails.ErrorInfo{
+			Reason:   paymentVersionConflictCause,
+			Domain:   paymentsErrorDomain,
+			Metadata: map[string]string{"payment_id": e.paymentID, "version": strconv.Itoa(e.version)}})
+		if detailsErr != nil {
+			return conflict.Err()
+		}
+		return detailed.Err()
+	default:
+		return status.Error(grpcStatusCode(err), err.Error())
+	}
+}
+
+func grpcStatusCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	}
+
+	switch err.(type) {
+	case *PersistenceError:
+		return codes.Internal
+	case *PaymentNotFoundError:
+		return codes.NotFound
+	case *PaymentAlreadyExistsError:
+		return codes.AlreadyExists
+	case *PaymentVersionNotFoundError:
+		return codes.NotFound
+	case *PaymentVersionConflictError:
+		return codes.Aborted
+	case *InvalidPaymentError:
+		return codes.InvalidArgument
+	case *SchemeValidationError:
+		return codes.InvalidArgument
+	case *InvalidPaymentOperationError:
+		return codes.InvalidArgument
+	case *InvalidAmountError:
+		return codes.InvalidArgument
+	case *InvalidDocumentError:
+		return codes.InvalidArgument
+	case *InvalidRequestError:
+		return codes.InvalidArgument
+	default:
+		return codes.Internal
+	}
+}
+
+func paymentFilterFromProto(filter *paymentspb.PaymentFilter) PaymentFilter {
+	return PaymentFilter{
+		OrganisationID: filter.GetOrganisationId(),
+		PaymentScheme:  filter.GetPaymentScheme(),
+		ProcessingDate: filter.GetProcessingDate()}
+}
+
+func paymentToProto(payment Payment) *paymentspb.Payment {
+	attributes := payment.Attributes
+
+	var senderCharges []*paymentspb.SenderCharges
+	for _, charges := range attributes.ChargesInformation.SenderCharges {
+		senderCharges = append(senderCharges, &paymentspb.SenderCharges{Amount: charges.Amount, Currency: charges.Currency})
+	}
+
+	debtor := debtorPartyToProto(attributes.DebtorParty)
+	beneficiary := &paymentspb.BeneficiaryParty{AccountType: int32(attributes.BeneficiaryParty.AccountType)}
+	if attributes.BeneficiaryParty.DebtorParty != nil {
+		party := debtorPartyToProto(*attributes.BeneficiaryParty.DebtorParty)
+		beneficiary.AccountNumber = party.AccountNumber
+		beneficiary.BankId = party.BankId
+		beneficiary.BankIdCode = party.BankIdCode
+		beneficiary.AccountName = party.AccountName
+		beneficiary.AccountNumberCode = party.AccountNumberCode
+		beneficiary.Address = party.Address
+		beneficiary.Name = party.Name
+	}
+
+	return &paymentspb.Payment{
+		Type:           payment.Type,
+		Id:             payment.ID,
+		Version:        int32(payment.Version),
+		OrganisationId: payment.OrganisationID,
+		Attributes: &paymentspb.Attributes{
+			Amount:           attributes.Amount,
+			BeneficiaryParty: beneficiary,
+			ChargesInformation: &paymentspb.ChargesInformation{
+				BearerCode:              attributes.ChargesInformation.BearerCode,
+				SenderCharges:           senderCharges,
+				ReceiverChargesAmount:   attributes.ChargesInformation.Amount,
+				ReceiverChargesCurrency: attributes.ChargesInformation.Currency},
+			Currency:          attributes.Currency,
+			DebtorParty:       debtor,
+			EndToEndReference: attributes.EndToEndReference,
+			Fx: &paymentspb.FX{
+				ContractReference: attributes.FX.ContractReference,
+				ExchangeRate:      attributes.FX.ExchangeRate,
+				OriginalAmount:    attributes.FX.OriginalAmount,
+				OriginalCurrency:  attributes.FX.OriginalCurrency},
+			NumericReference:     int64(attributes.NumericReference),
+			PaymentId:            attributes.PaymentID,
+			PaymentPurpose:       attributes.PaymentPurpose,
+			PaymentScheme:        attributes.PaymentScheme,
+			PaymentType:          attributes.PaymentType,
+			ProcessingDate:       attributes.ProcessingDate,
+			Reference:            attributes.Reference,
+			SchemePaymentSubType: attributes.SchemePaymentSubType,
+			SchemePaymentType:    attributes.SchemePaymentType,
+			SponsorParty: &paymentspb.SponsorParty{
+				AccountNumber: attributes.SponsorParty.AccountNumber,
+				BankId:        attributes.SponsorParty.BankID,
+				BankIdCode:    attributes.SponsorParty.BankIDCode}}}
+}
+
+func debtorPartyToProto(party DebtorParty) *paymentspb.DebtorParty {
+	debtor := &paymentspb.DebtorParty{
+		AccountName:       party.AccountName,
+		AccountNumberCode: party.AccountNumberCode,
+		Address:           party.Address,
+		Name:              party.Name}
+	if party.SponsorParty != nil {
+		debtor.AccountNumber = party.AccountNumber
+		debtor.BankId = party.BankID
+		debtor.BankIdCode = party.BankIDCode
+	}
+	return debtor
+}
+
+// paymentFromProto reads the payment of a call
+func paymentFromProto(payment *paymentspb.Payment) Payment {
+	attributes := payment.GetAttributes()
+
+	var senderCharges []SenderCharges
+	for _, charges := range attributes.GetChargesInformation().GetSenderCharges() {
+		senderCharges = append(senderCharges, SenderCharges{Amount: charges.GetAmount(), Currency: charges.GetCurrency()})
+	}
+
+	debtor := attributes.GetDebtorParty()
+	beneficiary := attributes.GetBeneficiaryParty()
+	beneficiaryParty := BeneficiaryParty{AccountType: int(beneficiary.GetAccountType())}
+	if party := debtorPartyFromProto(beneficiary.GetAccountNumber(), beneficiary.GetBankId(), beneficiary.GetBankIdCode(),
+		beneficiary.GetAccountName(), beneficiary.GetAccountNumberCode(), beneficiary.GetAddress(), beneficiary.GetName()); party != (DebtorParty{}) {
+		beneficiaryParty.DebtorParty = &party
+	}
+
+	return Payment{
+		Type:           payment.GetType(),
+		ID:             payment.GetId(),
+		Version:        int(payment.GetVersion()),
+		OrganisationID: payment.GetOrganisationId(),
+		Attributes: Attributes{
+			Amount:           attributes.GetAmount(),
+			BeneficiaryParty: beneficiaryParty,
+			ChargesInformation: ChargesInformation{
+				BearerCode:    attributes.GetChargesInformation().GetBearerCode(),
+				SenderCharges: senderCharges,
+				Amount:        attributes.GetChargesInformation().GetReceiverChargesAmount(),
+				Currency:      attributes.GetChargesInformation().GetReceiverChargesCurrency()},
+			Currency: attributes.GetCurrency(),
+			DebtorParty: debtorPartyFromProto(debtor.GetAccountNumber(), debtor.GetBankId(), debtor.GetBankIdCode(),
+				debtor.GetAccountName(), debtor.GetAccountNumberCode(), debtor.GetAddress(), debtor.GetName()),
+			EndToEndReference: attributes.GetEndToEndReference(),
+			FX: FX{
+				ContractReference: attributes.GetFx().GetContractReference(),
+				ExchangeRate:      attributes.GetFx().GetExchangeRate(),
+				OriginalAmount:    attributes.GetFx().GetOriginalAmount(),
+				OriginalCurrency:  attributes.GetFx().GetOriginalCurrency()},
+			NumericReference:     int(attributes.GetNumericReference()),
+			PaymentID:            attributes.GetPaymentId(),
+			PaymentPurpose:       attributes.GetPaymentPurpose(),
+			PaymentScheme:        attributes.GetPaymentScheme(),
+			PaymentType:          attributes.GetPaymentType(),
+			ProcessingDate:       attributes.GetProcessingDate(),
+			Reference:            attributes.GetReference(),
+			SchemePaymentSubType: attributes.GetSchemePaymentSubType(),
+			SchemePaymentType:    attributes.GetSchemePaymentType(),
+			SponsorParty: SponsorParty{
+				AccountNumber: attributes.GetSponsorParty().GetAccountNumber(),
+				BankID:        attributes.GetSponsorParty().GetBankId(),
+				BankIDCode:    attributes.GetSponsorParty().GetBankIdCode()}}}
+}
+
+func debtorPartyFromProto(accountNumber, bankID, bankIDCode, accountName, accountNumberCode, address, name string) DebtorParty {
+	party := DebtorParty{AccountName: accountName, AccountNumberCode: accountNumberCode, Address: address, Name: name}
+	if sponsor := (SponsorParty{AccountNumber: accountNumber, BankID: bankID, BankIDCode: bankIDCode}); sponsor != (SponsorParty{}) {
+		party.SponsorParty = &sponsor
+	}
+	return party
+}
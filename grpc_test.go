@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	. "github.com/stretchr/testify/assert"
+	"github.com/vba270419/payments-backend-go/paymentspb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	"testing"
+)
+
+// Test payment conversions
+
+func TestPaymentProtoRoundTrip(t *testing.T) {
+	payment := Payment{ID: "1", Version: 2, OrganisationID: "123", Type: "Payment", Attributes: Attributes{
+		Amount:             100.21,
+		Currency:           "GBP",
+		NumericReference:   1002001,
+		DebtorParty:        DebtorParty{Name: "Emelia", SponsorParty: &SponsorParty{AccountNumber: "71268996", BankID: "203301", BankIDCode: "GBDSC"}},
+		BeneficiaryParty:   BeneficiaryParty{AccountType: 1, DebtorParty: &DebtorParty{Name: "Wilfred", SponsorParty: &SponsorParty{AccountNumber: "31926819"}}},
+		ChargesInformation: ChargesInformation{BearerCode: "SHAR", SenderCharges: []SenderCharges{{Amount: 5, Currency: "GBP"}}, Amount: 1, Currency: "USD"},
+		FX:                 FX{ContractReference: "FX123", ExchangeRate: 2, OriginalAmount: 200.42, OriginalCurrency: "USD"},
+		SponsorParty:       SponsorParty{AccountNumber: "56781234", BankID: "123123", BankIDCode: "GBDSC"}}}
+
+	Equal(t, payment, paymentFromProto(paymentToProto(payment)))
+	Equal(t, Payment{OrganisationID: "123"}, paymentFromProto(paymentToProto(Payment{OrganisationID: "123"})))
+}
+
+// Test gRPC payment service
+
+func TestGRPCCreatePayment(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful}
+	client := GRPCClient(t, repository)
+
+	payment, err := client.CreatePayment(context.Background(), &paymentspb.CreatePaymentRequest{
+		Payment: &paymentspb.Payment{Id: "1", Version: 3, OrganisationId: "123"}})
+
+	Nil(t, err)
+	NotEqual(t, "1", payment.GetId())
+	Equal(t, int32(1), payment.GetVersion())
+	Equal(t, 1, len(repository.payments))
+}
+
+func TestGRPCCreateInvalidPayment(t *testing.T) {
+	_, err := GRPCClient(t, &PaymentRepositoryMock{mode: successful}).CreatePayment(context.Background(), &paymentspb.CreatePaymentRequest{})
+
+	Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCUpdatePayment(t *testing.T) {
+	payment, err := GRPCClient(t, &PaymentRepositoryMock{mode: successful}).UpdatePayment(context.Background(), &paymentspb.UpdatePaymentRequest{
+		Payment: &paymentspb.Payment{Id: "1", Version: 1, OrganisationId: "123"}})
+
+	Nil(t, err)
+	Equal(t, int32(2), payment.GetVersion())
+}
+
+func TestGRPCUpdatePaymentVersionConflict(t *testing.T) {
+	_, err := GRPCClient(t, &PaymentRepositoryMock{mode: versionConflict}).UpdatePayment(context.Background(), &paymentspb.UpdatePaymentRequest{
+		Payment: &paymentspb.Payment{Id: "1", Version: 3, OrganisationId: "123"}})
+
+	conflict := status.Convert(err)
+	Equal(t, codes.Aborted, conflict.Code())
+
+	details := conflict.Details()
+	Equal(t, 1, len(details))
+	info, ok := details[0].(*errdetails.ErrorInfo)
+	True(t, ok)
+	Equal(t, paymentVersionConflictCause, info.GetReason())
+	Equal(t, map[string]string{"payment_id": "1", "version": "3"}, info.GetMetadata())
+}
+
+func TestGRPCDeletePayment(t *testing.T) {
+	client := GRPCClient(t, &PaymentRepositoryMock{mode: successful})
+
+	_, err := client.DeletePayment(context.Background(), &paymentspb.DeletePaymentRequest{Id: "1"})
+	Nil(t, err)
+
+	_, err = client.DeletePayment(context.Background(), &paymentspb.DeletePaymentRequest{})
+	Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCGetPayment(t *testing.T) {
+	payment, err := GRPCClient(t, &PaymentRepositoryMock{mode: successful}).GetPayment(context.Background(), &paymentspb.GetPaymentRequest{Id: "2"})
+
+	Nil(t, err)
+	Equal(t, "2", payment.GetId())
+	Equal(t, 10.0, payment.GetAttributes().GetAmount())
+}
+
+func TestGRPCGetPaymentFailures(t *testing.T) {
+	_, err := GRPCClient(t, &PaymentRepositoryMock{mode: notFound}).GetPayment(context.Background(), &paymentspb.GetPaymentRequest{Id: "2"})
+	Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = GRPCClient(t, &PaymentRepositoryMock{mode: dbFailure}).GetPayment(context.Background(), &paymentspb.GetPaymentRequest{Id: "2"})
+	Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestGRPCGetAllPayments(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful, payments: []Payment{{ID: "1", OrganisationID: "123"}, {ID: "2", OrganisationID: "456"}}}
+	client := GRPCClient(t, repository)
+
+	list, err := client.GetAllPayments(context.Background(), &paymentspb.PaymentFilter{})
+	Nil(t, err)
+	Equal(t, 3, len(list.GetPayments()))
+
+	list, err = client.GetAllPayments(context.Background(), &paymentspb.PaymentFilter{OrganisationId: "456"})
+	Nil(t, err)
+	Equal(t, 1, len(list.GetPayments()))
+	Equal(t, "2", list.GetPayments()[0].GetId())
+}
+
+func TestGRPCListPayments(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful, payments: []Payment{
+		{ID: "1", OrganisationID: "123"}, {ID: "2", OrganisationID: "456"}, {ID: "3", OrganisationID: "123"}}}
+
+	stream, err := GRPCClient(t, repository).ListPayments(context.Background(), &paymentspb.PaymentFilter{OrganisationId: "123"})
+	Nil(t, err)
+
+	var ids []string
+	for {
+		payment, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		Nil(t, err)
+		ids = append(ids, payment.GetId())
+	}
+	Equal(t, []string{"1", "3"}, ids)
+}
+
+func TestGRPCListPaymentsServerFailed(t *testing.T) {
+	stream, err := GRPCClient(t, &PaymentRepositoryMock{mode: dbFailure}).ListPayments(context.Background(), &paymentspb.PaymentFilter{})
+	Nil(t, err)
+
+	_, err = stream.Recv()
+	Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestGRPCStatusCodes(t *testing.T) {
+	Equal(t, codes.AlreadyExists, grpcStatusCode(&PaymentAlreadyExistsError{"1"}))
+	Equal(t, codes.Canceled, grpcStatusCode(context.Canceled))
+	Equal(t, codes.DeadlineExceeded, grpcStatusCode(fmt.Errorf("loading payment: %w", context.DeadlineExceeded)))
+	Equal(t, codes.Aborted, grpcStatusCode(&PaymentVersionConflictError{"1", 2}))
+	Equal(t, codes.InvalidArgument, grpcStatusCode(&InvalidPaymentOperationError{"unsupported operation 'move'"}))
+	Equal(t, codes.InvalidArgument, grpcStatusCode(&InvalidDocumentError{mt103Format, "invalid field 32A"}))
+	Equal(t, codes.Internal, grpcStatusCode(fmt.Errorf("unexpected failure")))
+}
+
+// ---------------------------------------------------- //
+
+// GRPCClient serves the payment service over an in-memory connection, which is closed when the test ends
+func GRPCClient(t *testing.T, repository PaymentRepository) paymentspb.PaymentServiceClient {
+	setPaymentRepository(repository)
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := newGRPCServer()
+	go func() { _ = server.Serve(listener) }()
+
+	connection, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	Nil(t, err)
+
+	t.Cleanup(func() {
+		_ = connection.Close()
+		server.Stop()
+	})
+	return paymentspb.NewPaymentServiceClient(connection)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	livenessPath  string = "/healthz"
+	readinessPath string = "/readyz"
+
+	healthStatusOK          string = "ok"
+	healthStatusUnavailable string = "unavailable"
+
+	mongoDbComponent string = "mongodb"
+	outboxComponent  string = "outbox"
+	serverComponent  string = "server"
+)
+
+// The checks of the dependencies the application can't serve requests without
+var (
+	readinessChecks   []*readinessCheck
+	readinessTimeout  = 2 * time.Second
+	readinessCacheTTL = 5 * time.Second
+)
+
+// shuttingDown makes the application unready as soon as the shutdown begins
+var shuttingDown atomic.Bool
+
+func setReadinessChecks(timeout time.Duration, cacheTTL time.Duration, checks ...*readinessCheck) {
+	readinessTimeout = timeout
+	readinessCacheTTL = cacheTTL
+	readinessChecks = checks
+}
+
+// beginShutdown reports the application unready from now on
+func beginShutdown() {
+	shuttingDown.Store(true)
+}
+
+// A readinessCheck checks a single component and remembers its last result
+type readinessCheck struct {
+	component string
+	check     func(ctx context.Context) error
+
+	lock      sync.Mutex
+	checkedAt time.Time
+	health    ComponentHealth
+}
+
+func newReadinessCheck(component string, check func(ctx context.Context) error) *readinessCheck {
+	return &readinessCheck{component: component, check: check}
+}
+
+// run checks the component unless it has been checked within the cache TTL
+func (c *readinessCheck) run(now time.Time) ComponentHealth {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.checkedAt.IsZero() && now.Sub(c.checkedAt) < readinessCacheTTL {
+		return c.health
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), readinessTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.check(ctx)
+	c.health = ComponentHealth{Status: healthStatusOK, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		c.health.Status = healthStatusUnavailable
+		c.health.Error = err.Error()
+	}
+	c.checkedAt = now
+	return c.health
+}
+
+// livenessEndpoint tells the application is alive as long as it serves requests
+func livenessEndpoint(writer http.ResponseWriter, _ *http.Request) {
+	writeHealthReport(writer, HealthReport{Status: healthStatusOK})
+}
+
+// readinessEndpoint checks the components concurrently and answers 503 code when any of them is unavailable
+func readinessEndpoint(writer http.ResponseWriter, _ *http.Request) {
+	if shuttingDown.Load() {
+		writeHealthReport(writer, HealthReport{Status: healthStatusUnavailable, Components: map[string]ComponentHealth{
+			serverComponent: {Status: healthStatusUnavailable, Error: "server is shutting down"}}})
+		return
+	}
+
+	report := HealthReport{Status: healthStatusOK, Components: make(map[string]ComponentHealth)}
+	results := make([]ComponentHealth, len(readinessChecks))
+	now := time.Now()
+
+	var wait sync.WaitGroup
+	for i, check := range readinessChecks {
+		wait.Add(1)
+		go func(i int, check *readinessCheck) {
+			defer wait.Done()
+			results[i] = check.run(now)
+		}(i, check)
+	}
+	wait.Wait()
+
+	for i, check := range readinessChecks {
+		report.Components[check.component] = results[i]
+		if results[i].Status != healthStatusOK {
+			report.Status = healthStatusUnavailable
+		}
+	}
+	writeHealthReport(writer, report)
+}
+
+func writeHealthReport(writer http.ResponseWriter, report HealthReport) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Header().Set("Cache-Control", "no-store")
+	if report.Status == healthStatusOK {
+		writer.WriteHeader(http.StatusOK)
+	} else {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(writer).Encode(report)
+}
+
+// mongoReadinessCheck pings the primary of MongoDB, which the payments are written to
+func mongoReadinessCheck(client *mongo.Client) *readinessCheck {
+	return newReadinessCheck(mongoDbComponent, func(ctx context.Context) error {
+		return client.Ping(ctx, readpref.Primary())
+	})
+}
+
+// outboxReadinessCheck fails when more than the limit of events wait in the outbox to be dispatched
+func outboxReadinessCheck(client *mongo.Client, limit int64) *readinessCheck {
+	return newReadinessCheck(outboxComponent, func(ctx context.Context) error {
+		collection := client.Database(databaseName).Collection(paymentOutboxCollectionName)
+		countOptions := options.Count().SetLimit(limit + 1)
+		backlog, err := collection.CountDocuments(ctx, bson.M{"dispatched_at": bson.M{"$exists": false}}, countOptions)
+		if err != nil {
+			return err
+		}
+		return checkOutboxBacklog(backlog, limit)
+	})
+}
+
+func checkOutboxBacklog(backlog int64, limit int64) error {
+	if backlog > limit {
+		return fmt.Errorf("more than %d events wait to be dispatched", limit)
+	}
+	return nil
+}
+
+// publisherReadinessChecks checks the message brokers the payment events are published to
+func publisherReadinessChecks(publisher EventPublisher) (checks []*readinessCheck) {
+	publishers, ok := publisher.(eventPublishers)
+	if !ok {
+		publishers = eventPublishers{publisher}
+	}
+
+	for _, publisher := range publishers {
+		switch publisher := publisher.(type) {
+		case *natsPublisher:
+			checks = append(checks, newReadinessCheck(natsPublisherType, publisher.checkConnection))
+		case *kafkaPublisher:
+			checks = append(checks, newReadinessCheck(kafkaPublisherType, publisher.checkConnection))
+		}
+	}
+	return checks
+}
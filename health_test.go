@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	. "github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test liveness
+
+func TestLiveness(t *testing.T) {
+	SetReadinessChecks(t, time.Second, 0, newReadinessCheck(mongoDbComponent, func(ctx context.Context) error {
+		return errors.New("server selection timeout")
+	}))
+
+	response, report := ServeHealthHTTP(livenessPath)
+
+	Equal(t, http.StatusOK, response.Code)
+	Equal(t, HealthReport{Status: healthStatusOK}, report)
+}
+
+// Test readiness
+
+func TestReadinessReportsComponents(t *testing.T) {
+	SetReadinessChecks(t, time.Second, 0,
+		newReadinessCheck(mongoDbComponent, func(ctx context.Context) error { return nil }),
+		newReadinessCheck(natsPublisherType, func(ctx context.Context) error { return errors.New("NATS connection is RECONNECTING") }))
+
+	response, report := ServeHealthHTTP(readinessPath)
+
+	Equal(t, http.StatusServiceUnavailable, response.Code)
+	Equal(t, "no-store", response.Header().Get("Cache-Control"))
+	Equal(t, healthStatusUnavailable, report.Status)
+	Equal(t, healthStatusOK, report.Components[mongoDbComponent].Status)
+	Empty(t, report.Components[mongoDbComponent].Error)
+	Equal(t, healthStatusUnavailable, report.Components[natsPublisherType].Status)
+	Equal(t, "NATS connection is RECONNECTING", report.Components[natsPublisherType].Error)
+}
+
+func TestReadinessReady(t *testing.T) {
+	SetReadinessChecks(t, time.Second, 0, newReadinessCheck(mongoDbComponent, func(ctx context.Context) error { return nil }))
+
+	response, report := ServeHealthHTTP(readinessPath)
+
+	Equal(t, http.StatusOK, response.Code)
+	Equal(t, healthStatusOK, report.Status)
+	GreaterOrEqual(t, report.Components[mongoDbComponent].LatencyMs, float64(0))
+}
+
+func TestReadinessCheckCached(t *testing.T) {
+	checks := 0
+	check := newReadinessCheck(mongoDbComponent, func(ctx context.Context) error {
+		checks++
+		return nil
+	})
+	SetReadinessChecks(t, time.Second, 5*time.Second, check)
+	now := time.Now()
+
+	check.run(now)
+	check.run(now.Add(4 * time.Second))
+	Equal(t, 1, checks)
+
+	check.run(now.Add(5 * time.Second))
+	Equal(t, 2, checks)
+}
+
+func TestReadinessCheckTimeBounded(t *testing.T) {
+	check := newReadinessCheck(mongoDbComponent, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	SetReadinessChecks(t, 10*time.Millisecond, 0, check)
+
+	health := check.run(time.Now())
+
+	Equal(t, healthStatusUnavailable, health.Status)
+	Equal(t, context.DeadlineExceeded.Error(), health.Error)
+	Less(t, health.LatencyMs, float64(1000))
+}
+
+func TestReadinessCheckOutlivesRequest(t *testing.T) {
+	checks := 0
+	SetReadinessChecks(t, time.Second, 5*time.Second, newReadinessCheck(mongoDbComponent, func(ctx context.Context) error {
+		checks++
+		return ctx.Err()
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	request := httptest.NewRequest(methodGet, readinessPath, http.NoBody).WithContext(ctx)
+	configureRouter().ServeHTTP(httptest.NewRecorder(), request)
+	response, report := ServeHealthHTTP(readinessPath)
+
+	Equal(t, http.StatusOK, response.Code)
+	Equal(t, healthStatusOK, report.Components[mongoDbComponent].Status)
+	Equal(t, 1, checks)
+}
+
+func TestReadinessUnavailableOnShutdown(t *testing.T) {
+	SetReadinessChecks(t, time.Second, 0, newReadinessCheck(mongoDbComponent, func(ctx context.Context) error { return nil }))
+	t.Cleanup(func() { shuttingDown.Store(false) })
+
+	beginShutdown()
+	response, report := ServeHealthHTTP(readinessPath)
+
+	Equal(t, http.StatusServiceUnavailable, response.Code)
+	Equal(t, healthStatusUnavailable, report.Components[serverComponent].Status)
+	NotContains(t, report.Components, mongoDbComponent)
+
+	response, _ = ServeHealthHTTP(livenessPath)
+	Equal(t, http.StatusOK, response.Code)
+}
+
+func TestOutboxBacklog(t *testing.T) {
+	Nil(t, checkOutboxBacklog(10, 10))
+	EqualError(t, checkOutboxBacklog(11, 10), "more than 10 events wait to be dispatched")
+}
+
+func TestPublisherReadinessChecks(t *testing.T) {
+	conn := MockNatsConnection(t)
+
+	checks := publisherReadinessChecks(eventPublishers{paymentEvents, newNatsPublisher(conn, "payments"), noopPublisher{}})
+	Len(t, checks, 1)
+	Equal(t, natsPublisherType, checks[0].component)
+	Nil(t, checks[0].check(context.Background()))
+
+	conn.Close()
+	EqualError(t, checks[0].check(context.Background()), "NATS connection is CLOSED")
+
+	Len(t, publisherReadinessChecks(newKafkaPublisher([]string{"127.0.0.1:9092"}, "payments", false)), 1)
+}
+
+// ---------------------------------------------------- //
+
+// SetReadinessChecks replaces the readiness checks for the test
+func SetReadinessChecks(t *testing.T, timeout time.Duration, cacheTTL time.Duration, checks ...*readinessCheck) {
+	previousTimeout, previousTTL, previousChecks := readinessTimeout, readinessCacheTTL, readinessChecks
+	setReadinessChecks(timeout, cacheTTL, checks...)
+	t.Cleanup(func() { setReadinessChecks(previousTimeout, previousTTL, previousChecks...) })
+}
+
+func ServeHealthHTTP(path string) (*httptest.ResponseRecorder, HealthReport) {
+	response := ServeHTTP(methodGet, path, http.NoBody, successful)
+
+	var report HealthReport
+	_ = json.NewDecoder(response.Body).Decode(&report)
+	return response, report
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// diffPayments compares two versions of a payment field by field
+func diffPayments(from Payment, to Payment) (changes []FieldChange) {
+	changes = []FieldChange{}
+
+	fromFields := flattenPayment(from)
+	toFields := flattenPayment(to)
+
+	paths := make(map[string]bool)
+	for path := range fromFields {
+		paths[path] = true
+	}
+	for path := range toFields {
+		paths[path] = true
+	}
+
+	for path := range paths {
+		fromValue, toValue := fromFields[path], toFields[path]
+		if !reflect.DeepEqual(fromValue, toValue) {
+			changes = append(changes, FieldChange{Path: path, From: fromValue, To: toValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// flattenPayment converts a payment into a map of json property paths to values
+func flattenPayment(payment Payment) map[string]interface{} {
+	fields := make(map[string]interface{})
+
+	var document map[string]interface{}
+	data, _ := json.Marshal(payment)
+	_ = json.Unmarshal(data, &document)
+
+	flattenDocument("", document, fields)
+	return fields
+}
+
+func flattenDocument(prefix string, document map[string]interface{}, fields map[string]interface{}) {
+	for key, value := range document {
+		path := key
+		if len(prefix) > 0 {
+			path = prefix + "." + key
+		}
+
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenDocument(path, nested, fields)
+			continue
+		}
+		fields[path] = value
+	}
+}
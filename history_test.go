@@ -0,0 +1,33 @@
+package main
+
+import (
+	. "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDiffPaymentsNestedFields(t *testing.T) {
+	from := Payment{ID: "1", Version: 1, OrganisationID: "123"}
+	from.Attributes.Amount = 100.21
+	from.Attributes.Currency = "GBP"
+	from.Attributes.FX.ExchangeRate = 2
+
+	to := from
+	to.Version = 2
+	to.Attributes.Amount = 150
+	to.Attributes.FX = FX{}
+	to.Attributes.Reference = "Piano lessons"
+
+	changes := diffPayments(from, to)
+
+	Equal(t, 4, len(changes))
+	Equal(t, FieldChange{Path: "attributes.amount", From: "100.21", To: "150"}, changes[0])
+	Equal(t, FieldChange{Path: "attributes.fx.exchange_rate", From: "2"}, changes[1])
+	Equal(t, FieldChange{Path: "attributes.reference", To: "Piano lessons"}, changes[2])
+	Equal(t, FieldChange{Path: "version", From: float64(1), To: float64(2)}, changes[3])
+}
+
+func TestDiffPaymentsNoChanges(t *testing.T) {
+	payment := Payment{ID: "1", Version: 1, OrganisationID: "123"}
+
+	Equal(t, 0, len(diffPayments(payment, payment)))
+}
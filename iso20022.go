@@ -0,0 +1,516 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	pain001Format string = "pain001"
+	pacs008Format string = "pacs008"
+
+	pain001NamespacePrefix string = "urn:iso:std:iso:20022:tech:xsd:pain.001."
+	pacs008Namespace       string = "urn:iso:std:iso:20022:tech:xsd:pacs.008.001.09"
+
+	iso20022DateLayout     string = "2006-01-02"
+	iso20022DateTimeLayout string = "2006-01-02T15:04:05Z"
+	iso20022NotProvided    string = "NOTPROVIDED"
+	iso20022SharedCharges  string = "SHAR"
+	iso20022ClearingMethod string = "CLRG"
+	iso20022AmountDigits   int    = 18
+
+	swiftBankIDCode string = "SWBIC"
+	ibanAccountCode string = "IBAN"
+	bbanAccountCode string = "BBAN"
+)
+
+// The ISO 20022 components shared by the pain.001 and pacs.008 messages. Elements are matched by their local name,
+// so pain.001 documents of the different message versions are parsed with the same structures
+
+type iso20022Amount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type iso20022Code struct {
+	Code        string `xml:"Cd,omitempty"`
+	Proprietary string `xml:"Prtry,omitempty"`
+}
+
+type iso20022PostalAddress struct {
+	AddressLines []string `xml:"AdrLine"`
+}
+
+type iso20022Party struct {
+	Name          string                 `xml:"Nm,omitempty"`
+	PostalAddress *iso20022PostalAddress `xml:"PstlAdr,omitempty"`
+}
+
+type iso20022GenericAccount struct {
+	ID         string        `xml:"Id"`
+	SchemeName *iso20022Code `xml:"SchmeNm,omitempty"`
+}
+
+type iso20022AccountID struct {
+	IBAN  string                  `xml:"IBAN,omitempty"`
+	Other *iso20022GenericAccount `xml:"Othr,omitempty"`
+}
+
+type iso20022Account struct {
+	ID   iso20022AccountID `xml:"Id"`
+	Name string            `xml:"Nm,omitempty"`
+}
+
+type iso20022ClearingSystemMember struct {
+	ClearingSystemID *iso20022Code `xml:"ClrSysId,omitempty"`
+	MemberID         string        `xml:"MmbId"`
+}
+
+type iso20022FinancialInstitutionID struct {
+	BICFI                string                        `xml:"BICFI,omitempty"`
+	BIC                  string                        `xml:"BIC,omitempty"`
+	ClearingSystemMember *iso20022ClearingSystemMember `xml:"ClrSysMmbId,omitempty"`
+	Other                *iso20022GenericAccount       `xml:"Othr,omitempty"`
+}
+
+type iso20022Agent struct {
+	FinancialInstitutionID iso20022FinancialInstitutionID `xml:"FinInstnId"`
+}
+
+type iso20022PaymentID struct {
+	InstructionID string `xml:"InstrId,omitempty"`
+	EndToEndID    string `xml:"EndToEndId"`
+	TransactionID string `xml:"TxId,omitempty"`
+}
+
+type iso20022Remittance struct {
+	Unstructured []string `xml:"Ustrd"`
+}
+
+// A pain.001 customer credit transfer initiation, the requested execution date is a plain date in pain.001.001.03
+// and a date or date time choice in the later versions
+
+type pain001Document struct {
+	XMLName    xml.Name
+	Initiation pain001Initiation `xml:"CstmrCdtTrfInitn"`
+}
+
+type pain001Initiation struct {
+	GroupHeader        pain001GroupHeader          `xml:"GrpHdr"`
+	PaymentInformation []pain001PaymentInformation `xml:"PmtInf"`
+}
+
+type pain001GroupHeader struct {
+	MessageID            string        `xml:"MsgId"`
+	CreationDateTime     string        `xml:"CreDtTm"`
+	NumberOfTransactions string        `xml:"NbOfTxs"`
+	ControlSum           string        `xml:"CtrlSum,omitempty"`
+	InitiatingParty      iso20022Party `xml:"InitgPty"`
+}
+
+type pain001PaymentInformation struct {
+	ID                     string               `xml:"PmtInfId"`
+	Method                 string               `xml:"PmtMtd"`
+	NumberOfTransactions   string               `xml:"NbOfTxs,omitempty"`
+	ControlSum             string               `xml:"CtrlSum,omitempty"`
+	PaymentType            *pain001PaymentType  `xml:"PmtTpInf,omitempty"`
+	RequestedExecutionDate pain001ExecutionDate `xml:"ReqdExctnDt"`
+	Debtor                 iso20022Party        `xml:"Dbtr"`
+	DebtorAccount          iso20022Account      `xml:"DbtrAcct"`
+	DebtorAgent            iso20022Agent        `xml:"DbtrAgt"`
+	ChargeBearer           string               `xml:"ChrgBr,omitempty"`
+	Transactions           []pain001Transaction `xml:"CdtTrfTxInf"`
+}
+
+type pain001PaymentType struct {
+	ServiceLevel iso20022Code `xml:"SvcLvl"`
+}
+
+type pain001ExecutionDate struct {
+	Value    string `xml:",chardata"`
+	Date     string `xml:"Dt,omitempty"`
+	DateTime string `xml:"DtTm,omitempty"`
+}
+
+func (d pain001ExecutionDate) date() string {
+	switch {
+	case len(d.Date) > 0:
+		return d.Date
+	case len(d.DateTime) >= len(iso20022DateLayout):
+		return d.DateTime[:len(iso20022DateLayout)]
+	default:
+		return strings.TrimSpace(d.Value)
+	}
+}
+
+type pain001Transaction struct {
+	PaymentID             iso20022PaymentID   `xml:"PmtId"`
+	Amount                pain001Amount       `xml:"Amt"`
+	ChargeBearer          string              `xml:"ChrgBr,omitempty"`
+	CreditorAgent         *iso20022Agent      `xml:"CdtrAgt,omitempty"`
+	Creditor              *iso20022Party      `xml:"Cdtr,omitempty"`
+	CreditorAccount       *iso20022Account    `xml:"CdtrAcct,omitempty"`
+	RemittanceInformation *iso20022Remittance `xml:"RmtInf,omitempty"`
+}
+
+type pain001Amount struct {
+	InstructedAmount iso20022Amount `xml:"InstdAmt"`
+}
+
+// A pacs.008.001.09 FI to FI customer credit transfer carrying a single payment
+
+type pacs008Document struct {
+	XMLName  xml.Name        `xml:"urn:iso:std:iso:20022:tech:xsd:pacs.008.001.09 Document"`
+	Transfer pacs008Transfer `xml:"FIToFICstmrCdtTrf"`
+}
+
+type pacs008Transfer struct {
+	GroupHeader  pacs008GroupHeader   `xml:"GrpHdr"`
+	Transactions []pacs008Transaction `xml:"CdtTrfTxInf"`
+}
+
+type pacs008GroupHeader struct {
+	MessageID            string            `xml:"MsgId"`
+	CreationDateTime     string            `xml:"CreDtTm"`
+	NumberOfTransactions int               `xml:"NbOfTxs"`
+	Settlement           pacs008Settlement `xml:"SttlmInf"`
+}
+
+type pacs008Settlement struct {
+	Method string `xml:"SttlmMtd"`
+}
+
+type pacs008Transaction struct {
+	PaymentID             iso20022PaymentID   `xml:"PmtId"`
+	InterbankAmount       iso20022Amount      `xml:"IntrBkSttlmAmt"`
+	InterbankDate         string              `xml:"IntrBkSttlmDt,omitempty"`
+	InstructedAmount      *iso20022Amount     `xml:"InstdAmt,omitempty"`
+	ExchangeRate          string              `xml:"XchgRate,omitempty"`
+	ChargeBearer          string              `xml:"ChrgBr"`
+	ChargesInformation    []pacs008Charges    `xml:"ChrgsInf"`
+	Debtor                iso20022Party       `xml:"Dbtr"`
+	DebtorAccount         *iso20022Account    `xml:"DbtrAcct,omitempty"`
+	DebtorAgent           iso20022Agent       `xml:"DbtrAgt"`
+	CreditorAgent         iso20022Agent       `xml:"CdtrAgt"`
+	Creditor              iso20022Party       `xml:"Cdtr"`
+	CreditorAccount       *iso20022Account    `xml:"CdtrAcct,omitempty"`
+	RemittanceInformation *iso20022Remittance `xml:"RmtInf,omitempty"`
+}
+
+type pacs008Charges struct {
+	Amount iso20022Amount `xml:"Amt"`
+	Agent  iso20022Agent  `xml:"Agt"`
+}
+
+// parsePain001 maps every credit transfer transaction of a pain.001 document into a payment
+func parsePain001(reader io.Reader) (payments []Payment, err error) {
+	var document pain001Document
+	if err = xml.NewDecoder(reader).Decode(&document); err != nil {
+		return nil, &InvalidDocumentError{pain001Format, err.Error()}
+	}
+
+	if document.XMLName.Local != "Document" || !strings.HasPrefix(document.XMLName.Space, pain001NamespacePrefix) {
+		return nil, &InvalidDocumentError{pain001Format, fmt.Sprintf("unexpected document namespace '%s'", document.XMLName.Space)}
+	}
+
+	for _, information := range document.Initiation.PaymentInformation {
+		for _, transaction := range information.Transactions {
+			payment, err := newPaymentFromPain001(information, transaction)
+			if err != nil {
+				return nil, err
+			}
+			payments = append(payments, payment)
+		}
+	}
+
+	if len(payments) == 0 {
+		return nil, &InvalidDocumentError{pain001Format, "no credit transfer transactions"}
+	}
+	return payments, nil
+}
+
+func newPaymentFromPain001(information pain001PaymentInformation, transaction pain001Transaction) (payment Payment, err error) {
+	instructedAmount := transaction.Amount.InstructedAmount
+	amount, err := strconv.ParseFloat(strings.TrimSpace(instructedAmount.Value), 64)
+	if err != nil || amount <= 0 {
+		return payment, &InvalidDocumentError{pain001Format,
+			fmt.Sprintf("invalid instructed amount '%s' of transaction '%s'", instructedAmount.Value, transaction.PaymentID.EndToEndID)}
+	}
+
+	debtorParty := newISO20022DebtorParty(information.Debtor, &information.DebtorAccount, &information.DebtorAgent)
+	beneficiaryParty := BeneficiaryParty{DebtorParty: &DebtorParty{}}
+	if transaction.Creditor != nil || transaction.CreditorAccount != nil || transaction.CreditorAgent != nil {
+		var creditor iso20022Party
+		if transaction.Creditor != nil {
+			creditor = *transaction.Creditor
+		}
+		beneficiary := newISO20022DebtorParty(creditor, transaction.CreditorAccount, transaction.CreditorAgent)
+		beneficiaryParty.DebtorParty = &beneficiary
+	}
+
+	bearerCode := transaction.ChargeBearer
+	if len(bearerCode) == 0 {
+		bearerCode = information.ChargeBearer
+	}
+
+	endToEndReference := transaction.PaymentID.EndToEndID
+	if endToEndReference == iso20022NotProvided {
+		endToEndReference = ""
+	}
+
+	var reference string
+	if transaction.RemittanceInformation != nil {
+		reference = strings.Join(transaction.RemittanceInformation.Unstructured, " ")
+	}
+
+	payment = Payment{
+		Type: "Payment",
+		Attributes: Attributes{
+			Amount:             amount,
+			BeneficiaryParty:   beneficiaryParty,
+			ChargesInformation: ChargesInformation{BearerCode: bearerCode},
+			Currency:           instructedAmount.Currency,
+			DebtorParty:        debtorParty,
+			EndToEndReference:  endToEndReference,
+			PaymentID:          transaction.PaymentID.InstructionID,
+			PaymentType:        "Credit",
+			ProcessingDate:     information.RequestedExecutionDate.date(),
+			Reference:          reference}}
+
+	return payment, nil
+}
+
+func newISO20022DebtorParty(party iso20022Party, account *iso20022Account, agent *iso20022Agent) DebtorParty {
+	debtorParty := DebtorParty{SponsorParty: &SponsorParty{}, Name: party.Name}
+
+	if party.PostalAddress != nil {
+		debtorParty.Address = strings.Join(party.PostalAddress.AddressLines, " ")
+	}
+
+	if account != nil {
+		debtorParty.AccountName = account.Name
+		switch {
+		case len(account.ID.IBAN) > 0:
+			debtorParty.AccountNumber = account.ID.IBAN
+			debtorParty.AccountNumberCode = ibanAccountCode
+		case account.ID.Other != nil:
+			debtorParty.AccountNumber = account.ID.Other.ID
+			debtorParty.AccountNumberCode = bbanAccountCode
+		}
+	}
+
+	if agent != nil {
+		institution := agent.FinancialInstitutionID
+		switch {
+		case len(institution.BICFI) > 0 || len(institution.BIC) > 0:
+			debtorParty.BankID = institution.BICFI + institution.BIC
+			debtorParty.BankIDCode = swiftBankIDCode
+		case institution.ClearingSystemMember != nil:
+			debtorParty.BankID = institution.ClearingSystemMember.MemberID
+			if system := institution.ClearingSystemMember.ClearingSystemID; system != nil {
+				debtorParty.BankIDCode = system.Code + system.Proprietary
+			}
+		}
+	}
+
+	return debtorParty
+}
+
+// renderPacs008 renders the payment as a pacs.008.001.09 message
+func renderPacs008(payment Payment, createdAt time.Time) ([]byte, error) {
+	attributes := payment.Attributes
+	if len(attributes.Currency) != 3 || attributes.Amount < 0 {
+		return nil, &InvalidPaymentError{payment}
+	}
+
+	interbankAmount, err := newISO20022Amount("IntrBkSttlmAmt", attributes.Amount, attributes.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	debtor := attributes.DebtorParty
+	beneficiary := attributes.BeneficiaryParty.debtorParty()
+
+	transaction := pacs008Transaction{
+		PaymentID: iso20022PaymentID{
+			InstructionID: truncateText(attributes.PaymentID, 35),
+			EndToEndID:    truncateText(defaultText(attributes.EndToEndReference, iso20022NotProvided), 35),
+			TransactionID: iso20022MessageID(payment)},
+		InterbankAmount:       interbankAmount,
+		InterbankDate:         iso20022Date(attributes.ProcessingDate),
+		ChargeBearer:          iso20022ChargeBearer(attributes.ChargesInformation.BearerCode),
+		Debtor:                newISO20022Party(debtor.Name, debtor.Address),
+		DebtorAccount:         newISO20022Account(debtor.sponsorParty().AccountNumber, debtor.AccountNumberCode, debtor.AccountName),
+		DebtorAgent:           newISO20022Agent(debtor.sponsorParty()),
+		CreditorAgent:         newISO20022Agent(beneficiary.sponsorParty()),
+		Creditor:              newISO20022Party(beneficiary.Name, beneficiary.Address),
+		CreditorAccount:       newISO20022Account(beneficiary.sponsorParty().AccountNumber, beneficiary.AccountNumberCode, beneficiary.AccountName),
+		RemittanceInformation: newISO20022Remittance(attributes.Reference)}
+
+	if fx := attributes.FX; fx.OriginalAmount > 0 && len(fx.OriginalCurrency) == 3 {
+		instructedAmount, err := newISO20022Amount("InstdAmt", fx.OriginalAmount, fx.OriginalCurrency)
+		if err != nil {
+			return nil, err
+		}
+		transaction.InstructedAmount = &instructedAmount
+		if fx.ExchangeRate > 0 {
+			transaction.ExchangeRate = strconv.FormatFloat(fx.ExchangeRate, 'f', -1, 64)
+		}
+	}
+
+	charges := attributes.ChargesInformation
+	for _, senderCharges := range charges.SenderCharges {
+		amount, err := newISO20022Amount("ChrgsInf/Amt", senderCharges.Amount, senderCharges.Currency)
+		if err != nil {
+			return nil, err
+		}
+		transaction.ChargesInformation = append(transaction.ChargesInformation, pacs008Charges{Amount: amount, Agent: transaction.DebtorAgent})
+	}
+	if charges.Amount > 0 && len(charges.Currency) == 3 {
+		amount, err := newISO20022Amount("ChrgsInf/Amt", charges.Amount, charges.Currency)
+		if err != nil {
+			return nil, err
+		}
+		transaction.ChargesInformation = append(transaction.ChargesInformation, pacs008Charges{Amount: amount, Agent: transaction.CreditorAgent})
+	}
+
+	document := pacs008Document{
+		Transfer: pacs008Transfer{
+			GroupHeader: pacs008GroupHeader{
+				MessageID:            iso20022MessageID(payment),
+				CreationDateTime:     createdAt.UTC().Format(iso20022DateTimeLayout),
+				NumberOfTransactions: 1,
+				Settlement:           pacs008Settlement{Method: iso20022ClearingMethod}},
+			Transactions: []pacs008Transaction{transaction}}}
+
+	return marshalISO20022(document)
+}
+
+func marshalISO20022(document interface{}) ([]byte, error) {
+	data, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// The message identification is limited to 35 characters, so the dashes are removed from the payment UUID
+func iso20022MessageID(payment Payment) string {
+	return truncateText(strings.ReplaceAll(payment.ID, "-", ""), 35)
+}
+
+// newISO20022Amount renders the amount of the element with at most the minor units of its currency
+func newISO20022Amount(element string, amount float64, currency string) (iso20022Amount, error) {
+	formatted, err := formatDecimal(element, amount, currencyDecimals(currency))
+	if err != nil {
+		return iso20022Amount{}, err
+	}
+
+	if digits := len(strings.Trim(strings.Replace(formatted, ".", "", 1), "-")); digits > iso20022AmountDigits {
+		return iso20022Amount{}, &InvalidAmountError{element, amount, fmt.Sprintf("has more than %d digits", iso20022AmountDigits)}
+	}
+	return iso20022Amount{Currency: currency, Value: formatted}, nil
+}
+
+func newISO20022Party(name string, address string) iso20022Party {
+	party := iso20022Party{Name: truncateText(name, 140)}
+	if len(address) > 0 {
+		party.PostalAddress = &iso20022PostalAddress{AddressLines: []string{truncateText(address, 70)}}
+	}
+	return party
+}
+
+// Accounts are identified by IBAN, any other account number is reported with its scheme name code (e.g. BBAN)
+func newISO20022Account(number string, code string, name string) *iso20022Account {
+	if len(number) == 0 {
+		return nil
+	}
+
+	account := &iso20022Account{Name: truncateText(name, 70)}
+	if code == ibanAccountCode {
+		account.ID.IBAN = number
+		return account
+	}
+
+	account.ID.Other = &iso20022GenericAccount{ID: truncateText(number, 34)}
+	if len(code) > 0 {
+		account.ID.Other.SchemeName = &iso20022Code{Code: truncateText(code, 4)}
+	}
+	return account
+}
+
+// Agents are identified by BIC, any other bank id is reported as a member of the clearing system named by the bank id code (e.g. GBDSC)
+func newISO20022Agent(sponsor SponsorParty) iso20022Agent {
+	var agent iso20022Agent
+	switch {
+	case len(sponsor.BankID) == 0:
+	case sponsor.BankIDCode == swiftBankIDCode:
+		agent.FinancialInstitutionID.BICFI = sponsor.BankID
+	default:
+		member := &iso20022ClearingSystemMember{MemberID: truncateText(sponsor.BankID, 35)}
+		if len(sponsor.BankIDCode) > 5 {
+			member.ClearingSystemID = &iso20022Code{Proprietary: truncateText(sponsor.BankIDCode, 35)}
+		} else if len(sponsor.BankIDCode) > 0 {
+			member.ClearingSystemID = &iso20022Code{Code: sponsor.BankIDCode}
+		}
+		agent.FinancialInstitutionID.ClearingSystemMember = member
+	}
+	return agent
+}
+
+func newISO20022Remittance(reference string) *iso20022Remittance {
+	if len(reference) == 0 {
+		return nil
+	}
+	return &iso20022Remittance{Unstructured: []string{truncateText(reference, 140)}}
+}
+
+func iso20022Date(date string) string {
+	if _, err := time.Parse(iso20022DateLayout, date); err != nil {
+		return ""
+	}
+	return date
+}
+
+func iso20022ChargeBearer(bearerCode string) string {
+	switch bearerCode {
+	case "DEBT", "CRED", "SHAR", "SLEV":
+		return bearerCode
+	default:
+		return iso20022SharedCharges
+	}
+}
+
+func (p DebtorParty) sponsorParty() SponsorParty {
+	if p.SponsorParty == nil {
+		return SponsorParty{}
+	}
+	return *p.SponsorParty
+}
+
+func (p BeneficiaryParty) debtorParty() DebtorParty {
+	if p.DebtorParty == nil {
+		return DebtorParty{}
+	}
+	return *p.DebtorParty
+}
+
+func defaultText(text string, defaultValue string) string {
+	if len(text) == 0 {
+		return defaultValue
+	}
+	return text
+}
+
+// truncateText cuts the text to the maximum number of characters allowed by a message field
+func truncateText(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length])
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	. "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files of the message tests")
+
+// FailingInsertRepositoryMock fails the insert of the payment number failAt, counting from 1
+type FailingInsertRepositoryMock struct {
+	TransactionalRepositoryMock
+	inserts int
+	failAt  int
+}
+
+func (m *FailingInsertRepositoryMock) InsertPayment(payment Payment) (err error) {
+	if m.inserts++; m.inserts == m.failAt {
+		return &PersistenceError{}
+	}
+	return m.TransactionalRepositoryMock.InsertPayment(payment)
+}
+
+func (m *FailingInsertRepositoryMock) RunInTransaction(changes func(repository PaymentRepository) error) (err error) {
+	return m.TransactionalRepositoryMock.RunInTransaction(func(PaymentRepository) error { return changes(m) })
+}
+
+// Test pain.001 import
+
+func TestParsePain001MatchesGolden(t *testing.T) {
+	document, err := ioutil.ReadFile("test_resources/iso20022/pain.001.001.10.xml")
+	Nil(t, err)
+	ValidateXMLSchema(t, document, "test_resources/iso20022/pain.001.001.10.xsd")
+
+	payments, err := parsePain001(strings.NewReader(string(document)))
+	Nil(t, err)
+	Equal(t, 2, len(payments))
+
+	Equal(t, float64(100.21), payments[0].Attributes.Amount)
+	Equal(t, "GBP", payments[0].Attributes.Currency)
+	Equal(t, "Emelia Jane Brown", payments[0].Attributes.DebtorParty.Name)
+	Equal(t, "GB29XABC10161234567801", payments[0].Attributes.DebtorParty.AccountNumber)
+	Equal(t, "IBAN", payments[0].Attributes.DebtorParty.AccountNumberCode)
+	Equal(t, "31926819", payments[0].Attributes.BeneficiaryParty.AccountNumber)
+	Equal(t, "GBDSC", payments[0].Attributes.BeneficiaryParty.BankIDCode)
+	Equal(t, "Wil piano Jan", payments[0].Attributes.EndToEndReference)
+	Equal(t, "Payment for Em's piano lessons", payments[0].Attributes.Reference)
+	Equal(t, "2019-05-06", payments[0].Attributes.ProcessingDate)
+
+	Equal(t, "DEUTDEFF", payments[1].Attributes.BeneficiaryParty.BankID)
+	Equal(t, "SWBIC", payments[1].Attributes.BeneficiaryParty.BankIDCode)
+	Equal(t, "DEBT", payments[1].Attributes.ChargesInformation.BearerCode)
+	Empty(t, payments[1].Attributes.EndToEndReference)
+
+	actual, _ := json.MarshalIndent(payments, "", "  ")
+	AssertGolden(t, "test_resources/iso20022/pain.001.001.10.golden.json", actual)
+}
+
+func TestParsePain001Version03(t *testing.T) {
+	document := `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"><CstmrCdtTrfInitn>
+		<GrpHdr><MsgId>1</MsgId><CreDtTm>2019-05-05T11:00:00</CreDtTm><NbOfTxs>1</NbOfTxs><InitgPty/></GrpHdr>
+		<PmtInf><PmtInfId>1</PmtInfId><PmtMtd>TRF</PmtMtd><ReqdExctnDt>2019-05-06</ReqdExctnDt>
+			<Dbtr><Nm>Emelia Jane Brown</Nm></Dbtr>
+			<DbtrAcct><Id><IBAN>GB29XABC10161234567801</IBAN></Id></DbtrAcct>
+			<DbtrAgt><FinInstnId><BIC>XABCGB2L</BIC></FinInstnId></DbtrAgt>
+			<CdtTrfTxInf><PmtId><EndToEndId>E2E</EndToEndId></PmtId><Amt><InstdAmt Ccy="EUR">1.5</InstdAmt></Amt></CdtTrfTxInf>
+		</PmtInf></CstmrCdtTrfInitn></Document>`
+
+	payments, err := parsePain001(strings.NewReader(document))
+	Nil(t, err)
+	Equal(t, 1, len(payments))
+	Equal(t, "2019-05-06", payments[0].Attributes.ProcessingDate)
+	Equal(t, "XABCGB2L", payments[0].Attributes.DebtorParty.BankID)
+	Equal(t, "SWBIC", payments[0].Attributes.DebtorParty.BankIDCode)
+}
+
+func TestParsePain001InvalidDocuments(t *testing.T) {
+	documents := []string{
+		"not xml",
+		`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pacs.008.001.09"/>`,
+		`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.10"><CstmrCdtTrfInitn/></Document>`,
+		`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.10"><CstmrCdtTrfInitn><PmtInf>
+			<CdtTrfTxInf><PmtId><EndToEndId>E2E</EndToEndId></PmtId><Amt><InstdAmt Ccy="EUR">-1</InstdAmt></Amt></CdtTrfTxInf>
+		</PmtInf></CstmrCdtTrfInitn></Document>`}
+
+	for _, document := range documents {
+		_, err := parsePain001(strings.NewReader(document))
+		IsType(t, &InvalidDocumentError{}, err)
+	}
+}
+
+// Test pacs.008 export
+
+func TestRenderPacs008MatchesGolden(t *testing.T) {
+	file, err := ioutil.ReadFile("test_resources/single_payment.json")
+	Nil(t, err)
+
+	var payment Payment
+	Nil(t, json.Unmarshal(file, &payment))
+
+	document, err := renderPacs008(payment, time.Date(2019, 5, 5, 11, 0, 0, 0, time.UTC))
+	Nil(t, err)
+
+	Contains(t, string(document), pacs008Namespace)
+	ValidateXMLSchema(t, document, "test_resources/iso20022/pacs.008.001.09.xsd")
+	AssertGolden(t, "test_resources/iso20022/pacs.008.001.09.golden.xml", document)
+}
+
+func TestRenderPacs008MinimalPayment(t *testing.T) {
+	payment := Payment{ID: "1", OrganisationID: "123", Attributes: Attributes{Amount: 10, Currency: "GBP"}}
+
+	document, err := renderPacs008(payment, time.Now())
+	Nil(t, err)
+
+	ValidateXMLSchema(t, document, "test_resources/iso20022/pacs.008.001.09.xsd")
+	Contains(t, string(document), "<EndToEndId>NOTPROVIDED</EndToEndId>")
+	Contains(t, string(document), "<ChrgBr>SHAR</ChrgBr>")
+}
+
+func TestRenderPacs008InvalidAmount(t *testing.T) {
+	payment := Payment{ID: "1", Attributes: Attributes{Amount: 100.005, Currency: "GBP"}}
+	_, err := renderPacs008(payment, time.Now())
+	EqualError(t, err, "Amount 100.005 of field 'IntrBkSttlmAmt' can not be rendered: has more than 2 decimals")
+
+	payment.Attributes.Amount = 1500.5
+	payment.Attributes.Currency = "JPY"
+	_, err = renderPacs008(payment, time.Now())
+	IsType(t, &InvalidAmountError{}, err)
+
+	payment.Attributes.Amount = 1e18
+	payment.Attributes.Currency = "GBP"
+	_, err = renderPacs008(payment, time.Now())
+	EqualError(t, err, "Amount 1000000000000000000 of field 'IntrBkSttlmAmt' can not be rendered: has more than 18 digits")
+}
+
+func TestRenderPacs008WithoutCurrency(t *testing.T) {
+	_, err := renderPacs008(Payment{ID: "1", Attributes: Attributes{Amount: 10}}, time.Now())
+
+	IsType(t, &InvalidPaymentError{}, err)
+}
+
+// Test import and export handlers
+
+func TestImportPain001Successful(t *testing.T) {
+	file, _ := os.Open("test_resources/iso20022/pain.001.001.10.xml")
+	defer file.Close()
+
+	response := ServeHTTP(methodPost, preparePaymentImportURL(pain001Format)+"?organisation_id=123", file, successful)
+
+	var result PaymentListResult
+	_ = json.NewDecoder(response.Body).Decode(&result)
+
+	Equal(t, 201, response.Code)
+	Equal(t, 2, len(result.Data))
+	NotEmpty(t, result.Data[0].ID)
+	Equal(t, "123", result.Data[1].OrganisationID)
+	Equal(t, 1, result.Data[1].Version)
+}
+
+func TestImportPain001WithoutOrganisation(t *testing.T) {
+	file, _ := os.Open("test_resources/iso20022/pain.001.001.10.xml")
+	defer file.Close()
+
+	response := ServeHTTP(methodPost, preparePaymentImportURL(pain001Format), file, successful)
+
+	Equal(t, 400, response.Code)
+}
+
+func TestImportPain001ServerFailed(t *testing.T) {
+	file, _ := os.Open("test_resources/iso20022/pain.001.001.10.xml")
+	defer file.Close()
+
+	response := ServeHTTP(methodPost, preparePaymentImportURL(pain001Format)+"?organisation_id=123", file, dbFailure)
+
+	Equal(t, 500, response.Code)
+}
+
+func TestImportPain001RolledBack(t *testing.T) {
+	repository := &FailingInsertRepositoryMock{failAt: 2}
+
+	response := ServePain001ImportHTTP(t, repository)
+
+	Equal(t, 500, response.Code)
+	Equal(t, 1, repository.transactions)
+	Empty(t, repository.payments)
+}
+
+func TestImportPain001ReportedWithoutTransactions(t *testing.T) {
+	repository := &FailingInsertRepositoryMock{failAt: 2}
+
+	response := ServePain001ImportHTTP(t, struct{ PaymentRepository }{repository})
+
+	Equal(t, 500, response.Code)
+	Len(t, repository.payments, 1)
+
+	report := DecodeImportReport(t, response)
+	Equal(t, []string{importRowCreated, importRowFailed}, ImportRowStatuses(report))
+	Equal(t, repository.payments[0].ID, report.Data[0].ID)
+}
+
+func TestImportUnsupportedFormat(t *testing.T) {
+	response := ServeHTTP(methodPost, preparePaymentImportURL("camt053")+"?organisation_id=123", http.NoBody, successful)
+
+	Equal(t, 400, response.Code)
+}
+
+func TestExportPacs008Successful(t *testing.T) {
+	response := ServeHTTP(methodGet, preparePaymentURL(exportPaymentPath, "2")+"?format=pacs008", http.NoBody, successful)
+
+	Equal(t, 200, response.Code)
+	Equal(t, "application/xml; charset=UTF-8", response.Header().Get("Content-Type"))
+	ValidateXMLSchema(t, response.Body.Bytes(), "test_resources/iso20022/pacs.008.001.09.xsd")
+}
+
+func TestExportPaymentUnsupportedFormat(t *testing.T) {
+	response := ServeHTTP(methodGet, preparePaymentURL(exportPaymentPath, "2")+"?format=csv", http.NoBody, successful)
+
+	Equal(t, 400, response.Code)
+}
+
+func TestExportPaymentNotFound(t *testing.T) {
+	response := ServeHTTP(methodGet, preparePaymentURL(exportPaymentPath, "2")+"?format=pacs008", http.NoBody, notFound)
+
+	Equal(t, 404, response.Code)
+}
+
+// ---------------------------------------------------- //
+
+func preparePaymentImportURL(format string) string {
+	return strings.ReplaceAll(importPaymentsPath, "{format}", format)
+}
+
+func ServePain001ImportHTTP(t *testing.T, repository PaymentRepository) *httptest.ResponseRecorder {
+	document, err := ioutil.ReadFile("test_resources/iso20022/pain.001.001.10.xml")
+	Nil(t, err)
+	setPaymentRepository(repository)
+
+	request, _ := http.NewRequest(methodPost, preparePaymentImportURL(pain001Format)+"?organisation_id=123", bytes.NewReader(document))
+	response := httptest.NewRecorder()
+	configureRouter().ServeHTTP(response, request)
+
+	return response
+}
+
+// ValidateXMLSchema validates the document against the XSD with xmllint in the xsd subtest, which is skipped when
+// xmllint is not installed, so the rest of the test runs either way
+func ValidateXMLSchema(t *testing.T, document []byte, schema string) {
+	t.Run("xsd", func(t *testing.T) {
+		xmllint, err := exec.LookPath("xmllint")
+		if err != nil {
+			t.Skip("xmllint is not installed, the XSD validation is skipped")
+		}
+
+		path := filepath.Join(t.TempDir(), "document.xml")
+		Nil(t, ioutil.WriteFile(path, document, 0644))
+
+		output, err := exec.Command(xmllint, "--noout", "--schema", schema, path).CombinedOutput()
+		Nil(t, err, string(output))
+	})
+}
+
+// AssertGolden compares the actual output with the golden file, the golden files are rewritten with -update
+func AssertGolden(t *testing.T, golden string, actual []byte) {
+	if *updateGolden {
+		Nil(t, ioutil.WriteFile(golden, actual, 0644))
+	}
+
+	expected, err := ioutil.ReadFile(golden)
+	Nil(t, err)
+	Equal(t, string(expected), string(actual))
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A lifecycle shuts the application down in phases
+type lifecycle struct {
+	timeout    time.Duration
+	drainDelay time.Duration
+
+	servers []lifecycleServer
+	stop    chan struct{}
+	workers sync.WaitGroup
+	closers []func()
+}
+
+type lifecycleServer struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+func newLifecycle(timeout time.Duration, drainDelay time.Duration) *lifecycle {
+	return &lifecycle{timeout: timeout, drainDelay: drainDelay, stop: make(chan struct{})}
+}
+
+// addServer registers the server which stops accepting requests and drains the ones in flight with the stop function
+func (l *lifecycle) addServer(name string, stop func(ctx context.Context) error) {
+	l.servers = append(l.servers, lifecycleServer{name, stop})
+}
+
+// runWorker runs the background worker until the stop channel is closed, the shutdown waits for it to return
+func (l *lifecycle) runWorker(run func(stop <-chan struct{})) {
+	l.workers.Add(1)
+	go func() {
+		defer l.workers.Done()
+		run(l.stop)
+	}()
+}
+
+// addCloser registers the function closing a resource, the resources are closed in the reverse order of registration
+func (l *lifecycle) addCloser(close func()) {
+	l.closers = append(l.closers, close)
+}
+
+// shutdownSignals notifies the signals the application is stopped with
+func shutdownSignals() chan os.Signal {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	return signals
+}
+
+// shutdown runs the phases of the shutdown
+func (l *lifecycle) shutdown() error {
+	start := time.Now()
+
+	slog.Info("Shutdown phase 1/4: reporting the application unready", "drain_delay", l.drainDelay.String())
+	beginShutdown()
+	time.Sleep(l.drainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+
+	slog.Info("Shutdown phase 2/4: stopping servers and draining in-flight requests", "timeout", l.timeout.String())
+	errs := make([]error, len(l.servers)+1)
+	var wait sync.WaitGroup
+	for i, server := range l.servers {
+		wait.Add(1)
+		go func(i int, server lifecycleServer) {
+			defer wait.Done()
+			if errs[i] = server.stop(ctx); errs[i] != nil {
+				slog.Warn("Server did not stop gracefully", "server", server.name, "error", errs[i])
+				return
+			}
+			slog.Info("Server stopped", "server", server.name)
+		}(i, server)
+	}
+	wait.Wait()
+
+	slog.Info("Shutdown phase 3/4: stopping background workers")
+	close(l.stop)
+	stopped := make(chan struct{})
+	go func() {
+		l.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		slog.Info("Background workers stopped")
+	case <-ctx.Done():
+		errs[len(l.servers)] = errors.New("background workers did not stop within the graceful timeout")
+		slog.Warn("Background workers did not stop within the graceful timeout")
+	}
+
+	slog.Info("Shutdown phase 4/4: closing resources")
+	for i := len(l.closers) - 1; i >= 0; i-- {
+		l.closers[i]()
+	}
+
+	err := errors.Join(errs...)
+	slog.Info("Shutdown complete", "duration", time.Since(start).String(), "graceful", err == nil)
+	return err
+}
+
+// stopHTTPServer stops the server from accepting requests and waits for the requests in flight
+func stopHTTPServer(server *http.Server) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		err := server.Shutdown(ctx)
+		if err != nil {
+			_ = server.Close()
+		}
+		return err
+	}
+}
+
+// stopGRPCServer stops the server from accepting calls and waits for the calls in flight
+func stopGRPCServer(server *grpc.Server) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			server.Stop()
+			return ctx.Err()
+		}
+	}
+}
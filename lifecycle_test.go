@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	. "github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// BlockingRepositoryMock holds the inserts until they are released
+type BlockingRepositoryMock struct {
+	*PaymentRepositoryMock
+	started  chan struct{}
+	release  chan struct{}
+	recorder *PhaseRecorder
+}
+
+func (m *BlockingRepositoryMock) InsertPayment(payment Payment) (err error) {
+	close(m.started)
+	<-m.release
+	m.recorder.record("payment inserted")
+	return m.PaymentRepositoryMock.InsertPayment(payment)
+}
+
+// PhaseRecorder records the order the phases of a test happen in
+type PhaseRecorder struct {
+	lock   sync.Mutex
+	phases []string
+}
+
+func (r *PhaseRecorder) record(phase string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.phases = append(r.phases, phase)
+}
+
+func (r *PhaseRecorder) recorded() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.phases...)
+}
+
+// Test shutdown
+
+func TestInFlightCreateCompletesDuringShutdown(t *testing.T) {
+	recorder := &PhaseRecorder{}
+	repository := &BlockingRepositoryMock{&PaymentRepositoryMock{}, make(chan struct{}), make(chan struct{}), recorder}
+	setPaymentRepository(repository)
+	t.Cleanup(func() { shuttingDown.Store(false) })
+
+	server := &http.Server{Handler: configureRouter()}
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	go func() { _ = server.Serve(listener) }()
+	url := "http://" + listener.Addr().String()
+
+	life := newLifecycle(5*time.Second, 0)
+	life.addServer("http", stopHTTPServer(server))
+	life.runWorker(func(stop <-chan struct{}) {
+		<-stop
+		recorder.record("worker stopped")
+	})
+	life.addCloser(func() { recorder.record("repository closed") })
+	life.addCloser(func() { recorder.record("publisher closed") })
+
+	responses := make(chan *http.Response, 1)
+	go func() {
+		response, err := http.Post(url+createPaymentPath, "application/json", MockPayment("", "123"))
+		Nil(t, err)
+		responses <- response
+	}()
+	<-repository.started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- life.shutdown() }()
+
+	Eventually(t, func() bool {
+		_, err := http.Get(url + livenessPath)
+		return err != nil
+	}, time.Second, 10*time.Millisecond, "new requests are still accepted")
+	Empty(t, recorder.recorded())
+
+	close(repository.release)
+
+	response := <-responses
+	Equal(t, http.StatusCreated, response.StatusCode)
+	Nil(t, <-shutdown)
+	Equal(t, []string{"payment inserted", "worker stopped", "publisher closed", "repository closed"}, recorder.recorded())
+	Len(t, repository.payments, 1)
+}
+
+func TestShutdownTimesOut(t *testing.T) {
+	recorder := &PhaseRecorder{}
+	t.Cleanup(func() { shuttingDown.Store(false) })
+
+	life := newLifecycle(20*time.Millisecond, 0)
+	life.addServer("grpc", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	life.runWorker(func(stop <-chan struct{}) { time.Sleep(time.Second) })
+	life.addCloser(func() { recorder.record("repository closed") })
+
+	err := life.shutdown()
+
+	ErrorIs(t, err, context.DeadlineExceeded)
+	ErrorContains(t, err, "background workers did not stop within the graceful timeout")
+	Equal(t, []string{"repository closed"}, recorder.recorded())
+	True(t, shuttingDown.Load())
+}
+
+func TestShutdownSignals(t *testing.T) {
+	signals := shutdownSignals()
+	t.Cleanup(func() { signal.Stop(signals) })
+
+	Nil(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+
+	select {
+	case received := <-signals:
+		Equal(t, syscall.SIGTERM, received)
+	case <-time.After(time.Second):
+		Fail(t, "SIGTERM is not received")
+	}
+}
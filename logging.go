@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+)
+
+const (
+	jsonLogFormat string = "json"
+	textLogFormat string = "text"
+
+	requestIDHeader string = "X-Request-ID"
+)
+
+// The request ids taken from X-Request-ID header, other ids are replaced, so a caller can't forge the log lines
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// The log levels by their names in the configuration
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// initializeLogging makes the logger of the configured level and format the default one
+func initializeLogging(level string, format string) {
+	slog.SetDefault(newLogger(os.Stderr, logLevels[level], format))
+}
+
+// newLogger creates the logger writing the lines of the level and above to the writer
+func newLogger(writer io.Writer, level slog.Level, format string) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if format == textLogFormat {
+		handler = slog.NewTextHandler(writer, options)
+	} else {
+		handler = slog.NewJSONHandler(writer, options)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+// A contextHandler adds the request id and the trace of the context to the log lines
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := requestID(ctx); len(id) > 0 {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// The context key of the request id
+type requestIDKey struct{}
+
+// requestID returns the id of the request the context belongs to, or an empty id outside of a request
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestIDMiddleware identifies the request by the id of X-Request-ID header or by a new id
+func requestIDMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		id := request.Header.Get(requestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = uuid.New().String()
+		}
+
+		writer.Header().Set(requestIDHeader, id)
+		handler.ServeHTTP(writer, request.WithContext(context.WithValue(request.Context(), requestIDKey{}, id)))
+	})
+}
+
+// accessLogMiddleware logs the request once it is served
+func accessLogMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: writer, statusCode: http.StatusOK}
+
+		handler.ServeHTTP(recorder, request)
+
+		level := slog.LevelInfo
+		if recorder.statusCode >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(request.Context(), level, "Request served",
+			slog.String("method", request.Method),
+			slog.String("route", requestRoute(request)),
+			slog.String("path", request.URL.Path),
+			slog.Int("status", recorder.statusCode),
+			slog.Int("bytes", recorder.bytes),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("remote_addr", request.RemoteAddr))
+	})
+}
+
+// fatal logs the error and exits the application, like log.Fatal does
+func fatal(message string, args ...any) {
+	slog.Error(message, args...)
+	os.Exit(1)
+}
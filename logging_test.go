@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	. "github.com/stretchr/testify/assert"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test request ids
+
+func TestRequestIDGenerated(t *testing.T) {
+	response := ServeHTTP(methodGet, preparePaymentURL(getPaymentPath, "1"), http.NoBody, successful)
+
+	Len(t, response.Header().Get(requestIDHeader), 36)
+}
+
+func TestRequestIDTakenFromHeader(t *testing.T) {
+	response := ServeRequestIDHTTP("4f1c2a-retry.2")
+	Equal(t, "4f1c2a-retry.2", response.Header().Get(requestIDHeader))
+
+	response = ServeRequestIDHTTP("id\nlevel=ERROR")
+	Len(t, response.Header().Get(requestIDHeader), 36)
+}
+
+// Test log lines
+
+func TestAccessLog(t *testing.T) {
+	logs := CaptureLogs(t)
+
+	ServeRequestIDHTTP("access-1")
+
+	line := FindLogLine(t, logs, "Request served")
+	Equal(t, "INFO", line["level"])
+	Equal(t, "access-1", line["request_id"])
+	Equal(t, methodGet, line["method"])
+	Equal(t, getPaymentPath, line["route"])
+	Equal(t, "/v1/payments/get/1", line["path"])
+	Equal(t, float64(http.StatusOK), line["status"])
+	Greater(t, line["bytes"], float64(0))
+	Contains(t, line, "duration_ms")
+}
+
+func TestRequestErrorLogged(t *testing.T) {
+	logs := CaptureLogs(t)
+
+	response := ServeHTTP(methodGet, preparePaymentURL(getPaymentPath, "1"), http.NoBody, dbFailure)
+
+	line := FindLogLine(t, logs, "Request processed with error")
+	Equal(t, response.Header().Get(requestIDHeader), line["request_id"])
+	Equal(t, (&PersistenceError{}).Error(), line["error"])
+	Equal(t, "ERROR", FindLogLine(t, logs, "Request served")["level"])
+}
+
+func TestLoggerLevelAndFormat(t *testing.T) {
+	var output bytes.Buffer
+	logger := newLogger(&output, slog.LevelWarn, textLogFormat)
+
+	logger.Info("Skipped")
+	logger.WarnContext(context.WithValue(context.Background(), requestIDKey{}, "text-1"), "Written", "attempts", 3)
+
+	Equal(t, 1, strings.Count(output.String(), "\n"))
+	Contains(t, output.String(), `level=WARN msg=Written attempts=3 request_id=text-1`)
+}
+
+func TestLogLineCarriesTrace(t *testing.T) {
+	TraceSpans(t)
+	logs := CaptureLogs(t)
+	ctx, span := tracer().Start(context.Background(), "call")
+	defer span.End()
+
+	slog.InfoContext(ctx, "Traced")
+
+	line := FindLogLine(t, logs, "Traced")
+	Equal(t, span.SpanContext().TraceID().String(), line["trace_id"])
+	Equal(t, span.SpanContext().SpanID().String(), line["span_id"])
+	NotContains(t, line, "request_id")
+}
+
+// ---------------------------------------------------- //
+
+func ServeRequestIDHTTP(id string) *httptest.ResponseRecorder {
+	router := MockRouter(successful)
+	request, _ := http.NewRequest(methodGet, preparePaymentURL(getPaymentPath, "1"), http.NoBody)
+	request.Header.Set(requestIDHeader, id)
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	return response
+}
+
+// CaptureLogs writes the log lines of the test as json to the returned buffer
+func CaptureLogs(t *testing.T) *bytes.Buffer {
+	var logs bytes.Buffer
+	logger := slog.Default()
+
+	slog.SetDefault(newLogger(&logs, slog.LevelDebug, jsonLogFormat))
+	t.Cleanup(func() { slog.SetDefault(logger) })
+	return &logs
+}
+
+// FindLogLine decodes the last log line with the message
+func FindLogLine(t *testing.T, logs *bytes.Buffer, message string) (line map[string]interface{}) {
+	for _, text := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var decoded map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &decoded); err == nil && decoded["msg"] == message {
+			line = decoded
+		}
+	}
+	if line == nil {
+		t.Fatalf("log line '%s' is not found in %s", message, logs.String())
+	}
+	return line
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	metricsPath      string = "/metrics"
+	metricsNamespace string = "payments"
+
+	otherLabelValue string = "other"
+)
+
+// The currencies and the payment schemes which label the business metrics, the others are labelled as other
+var (
+	metricCurrencies = map[string]bool{"AUD": true, "CAD": true, "CHF": true, "CNY": true, "DKK": true, "EUR": true,
+		"GBP": true, "HKD": true, "JPY": true, "NOK": true, "NZD": true, "PLN": true, "SEK": true, "SGD": true, "USD": true}
+
+	metricPaymentSchemes = map[string]bool{bacsPaymentScheme: true, "FPS": true, sepaPaymentScheme: true, swiftPaymentScheme: true}
+)
+
+// The registry of the metrics served at /metrics
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "Number of the http requests by method, route template and status code."},
+		[]string{"method", "route", "code"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of the http requests by method, route template and status code.",
+		Buckets:   prometheus.DefBuckets},
+		[]string{"method", "route", "code"})
+
+	repositoryCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "repository_call_duration_seconds",
+		Help:      "Duration of the payment repository calls by operation.",
+		Buckets:   prometheus.DefBuckets},
+		[]string{"operation"})
+
+	repositoryErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "repository_errors_total",
+		Help:      "Number of the failed payment repository calls by operation and error type."},
+		[]string{"operation", "error"})
+
+	versionConflictsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "version_conflicts_total",
+		Help:      "Number of the payment changes rejected because of a version conflict by operation."},
+		[]string{"operation"})
+
+	mongoCommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "mongodb_command_duration_seconds",
+		Help:      "Duration of the MongoDB commands by command and outcome.",
+		Buckets:   prometheus.DefBuckets},
+		[]string{"command", "outcome"})
+
+	mongoCommandsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "mongodb_commands_in_flight",
+		Help:      "Number of the MongoDB commands sent and not answered yet."})
+
+	mongoPoolConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "mongodb_pool_connections",
+		Help:      "Number of the MongoDB connections created by the pool and not closed yet."})
+
+	mongoPoolConnectionsInUse = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "mongodb_pool_connections_in_use",
+		Help:      "Number of the pooled MongoDB connections checked out of the pool."})
+
+	mongoPoolConnectionsIdle = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "mongodb_pool_connections_idle",
+		Help:      "Number of the pooled MongoDB connections waiting in the pool."})
+
+	paymentsCreatedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "created_total",
+		Help:      "Number of the created payments by currency and payment scheme."},
+		[]string{"currency", "scheme"})
+
+	paymentsCreatedAmount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "created_amount_total",
+		Help:      "Total amount of the created payments by currency."},
+		[]string{"currency"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestsTotal,
+		httpRequestDuration,
+		repositoryCallDuration,
+		repositoryErrorsTotal,
+		versionConflictsTotal,
+		mongoCommandDuration,
+		mongoCommandsInFlight,
+		mongoPoolConnections,
+		mongoPoolConnectionsInUse,
+		mongoPoolConnectionsIdle,
+		paymentsCreatedTotal,
+		paymentsCreatedAmount)
+}
+
+func metricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// metricsMiddleware counts the requests and observes their duration labelled by the route template
+func metricsMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		route := requestRoute(request)
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: writer, statusCode: http.StatusOK}
+		defer func() {
+			code := strconv.Itoa(recorder.statusCode)
+			httpRequestsTotal.WithLabelValues(request.Method, route, code).Inc()
+			httpRequestDuration.WithLabelValues(request.Method, route, code).Observe(time.Since(start).Seconds())
+		}()
+
+		handler.ServeHTTP(recorder, request)
+	})
+}
+
+// requestRoute returns the template of the route matching the request, or the path when no route matches it
+func requestRoute(request *http.Request) string {
+	if current := mux.CurrentRoute(request); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return request.URL.Path
+}
+
+// A statusRecorder records the status code and the size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	bytes       int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(statusCode int) {
+	if !r.wroteHeader {
+		r.statusCode = statusCode
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	written, err := r.ResponseWriter.Write(data)
+	r.bytes += written
+	return written, err
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		r.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// countCreatedPayment counts the payment into the business metrics of the created payments
+func countCreatedPayment(payment Payment) {
+	currency := knownLabelValue(payment.Attributes.Currency, metricCurrencies)
+	paymentScheme := knownLabelValue(payment.Attributes.PaymentScheme, metricPaymentSchemes)
+
+	paymentsCreatedTotal.WithLabelValues(currency, paymentScheme).Inc()
+	if payment.Attributes.Amount > 0 {
+		paymentsCreatedAmount.WithLabelValues(currency).Add(payment.Attributes.Amount)
+	}
+}
+
+// knownLabelValue keeps the number of the label values bounded by replacing the unknown values with other
+func knownLabelValue(value string, known map[string]bool) string {
+	if known[value] {
+		return value
+	}
+	return otherLabelValue
+}
+
+// newMongoCommandMonitor observes the duration of the MongoDB commands and the commands in flight
+func newMongoCommandMonitor() *event.CommandMonitor {
+	var lock sync.Mutex
+	requests := make(map[int64]bool)
+
+	finished := func(finished event.CommandFinishedEvent, outcome string) {
+		mongoCommandDuration.WithLabelValues(finished.CommandName, outcome).Observe(time.Duration(finished.DurationNanos).Seconds())
+
+		lock.Lock()
+		defer lock.Unlock()
+		delete(requests, finished.RequestID)
+		mongoCommandsInFlight.Set(float64(len(requests)))
+	}
+
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, started *event.CommandStartedEvent) {
+			lock.Lock()
+			defer lock.Unlock()
+			requests[started.RequestID] = true
+			mongoCommandsInFlight.Set(float64(len(requests)))
+		},
+		Succeeded: func(_ context.Context, succeeded *event.CommandSucceededEvent) {
+			finished(succeeded.CommandFinishedEvent, "success")
+		},
+		Failed: func(_ context.Context, failed *event.CommandFailedEvent) {
+			finished(failed.CommandFinishedEvent, "failure")
+		},
+	}
+}
+
+// newMongoPoolMonitor observes the connections of the MongoDB connection pools
+func newMongoPoolMonitor() *event.PoolMonitor {
+	type connectionKey struct {
+		address string
+		id      uint64
+	}
+
+	var lock sync.Mutex
+	connections := make(map[connectionKey]bool)
+	checkedOut := 0
+
+	return &event.PoolMonitor{
+		Event: func(poolEvent *event.PoolEvent) {
+			key := connectionKey{poolEvent.Address, poolEvent.ConnectionID}
+
+			lock.Lock()
+			defer lock.Unlock()
+			switch poolEvent.Type {
+			case event.ConnectionCreated:
+				connections[key] = false
+			case event.ConnectionClosed:
+				if connections[key] {
+					checkedOut--
+				}
+				delete(connections, key)
+			case event.GetSucceeded:
+				if inUse, ok := connections[key]; ok && !inUse {
+					connections[key] = true
+					checkedOut++
+				}
+			case event.ConnectionReturned:
+				if connections[key] {
+					connections[key] = false
+					checkedOut--
+				}
+			default:
+				return
+			}
+
+			mongoPoolConnections.Set(float64(len(connections)))
+			mongoPoolConnectionsInUse.Set(float64(checkedOut))
+			mongoPoolConnectionsIdle.Set(float64(len(connections) - checkedOut))
+		},
+	}
+}
+
+// instrumentPaymentRepository wraps the repository with the one observing and tracing its calls
+func instrumentPaymentRepository(repository PaymentRepository) PaymentRepository {
+	instrumented := &instrumentedPaymentRepository{repository: repository}
+	if transactional, ok := repository.(TransactionalPaymentRepository); ok {
+		return &instrumentedTransactionalRepository{instrumented, transactional}
+	}
+	return instrumented
+}
+
+// An instrumentedPaymentRepository observes and traces the calls of the repository it wraps
+type instrumentedPaymentRepository struct {
+	repository PaymentRepository
+	ctx        context.Context
+}
+
+func (r *instrumentedPaymentRepository) WithContext(ctx context.Context) PaymentRepository {
+	return &instrumentedPaymentRepository{repository: r.repository, ctx: ctx}
+}
+
+// start starts the span of the call of the operation, the returned function ends it and observes the call
+func (r *instrumentedPaymentRepository) start(ctx context.Context, operation string) (context.Context, func(err error)) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	start := time.Now()
+	ctx, span := tracer().Start(ctx, "PaymentRepository."+operation,
+		trace.WithAttributes(attribute.String("payment.repository.operation", operation)))
+
+	return ctx, func(err error) {
+		observeRepositoryCall(operation, start, err)
+		endSpan(span, err)
+	}
+}
+
+// observeRepositoryCall records the duration of the call of the operation which started at the start time and its error
+func observeRepositoryCall(operation string, start time.Time, err error) {
+	repositoryCallDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err == nil {
+		return
+	}
+
+	repositoryErrorsTotal.WithLabelValues(operation, errorTypeName(err)).Inc()
+	if _, ok := err.(*PaymentVersionConflictError); ok {
+		versionConflictsTotal.WithLabelValues(operation).Inc()
+	}
+}
+
+// errorTypeName names the type of the error, e.g. PaymentNotFoundError
+func errorTypeName(err error) string {
+	errorType := reflect.TypeOf(err)
+	for errorType.Kind() == reflect.Ptr {
+		errorType = errorType.Elem()
+	}
+	if len(errorType.Name()) == 0 {
+		return errorType.String()
+	}
+	return errorType.Name()
+}
+
+func (r *instrumentedPaymentRepository) InsertPayment(payment Payment) (err error) {
+	ctx, done := r.start(r.ctx, "InsertPayment")
+	defer func() { done(err) }()
+	return bindPaymentRepository(r.repository, ctx).InsertPayment(payment)
+}
+
+func (r *instrumentedPaymentRepository) UpdatePayment(payment Payment) (err error) {
+	ctx, done := r.start(r.ctx, "UpdatePayment")
+	defer func() { done(err) }()
+	return bindPaymentRepository(r.repository, ctx).UpdatePayment(payment)
+}
+
+func (r *instrumentedPaymentRepository) PatchPayment(current Payment, patched Payment) (err error) {
+	ctx, done := r.start(r.ctx, "PatchPayment")
+	defer func() { done(err) }()
+	return bindPaymentRepository(r.repository, ctx).PatchPayment(current, patched)
+}
+
+func (r *instrumentedPaymentRepository) DeletePayment(paymentID string) (err error) {
+	ctx, done := r.start(r.ctx, "DeletePayment")
+	defer func() { done(err) }()
+	return bindPaymentRepository(r.repository, ctx).DeletePayment(paymentID)
+}
+
+func (r *instrumentedPaymentRepository) GetPayment(paymentID string) (payment Payment, err error) {
+	ctx, done := r.start(r.ctx, "GetPayment")
+	defer func() { done(err) }()
+	return bindPaymentRepository(r.repository, ctx).GetPayment(paymentID)
+}
+
+func (r *instrumentedPaymentRepository) GetPayments(paymentIDs []string) (payments []Payment, err error) {
+	ctx, done := r.start(r.ctx, "GetPayments")
+	defer func() { done(err) }()
+	return bindPaymentRepository(r.repository, ctx).GetPayments(paymentIDs)
+}
+
+func (r *instrumentedPaymentRepository) GetAllPayments() (payments []Payment, err error) {
+	ctx, done := r.start(r.ctx, "GetAllPayments")
+	defer func() { done(err) }()
+	return bindPaymentRepository(r.repository, ctx).GetAllPayments()
+}
+
+func (r *instrumentedPaymentRepository) FindPayments(filter PaymentFilter) (payments []Payment, err error) {
+	ctx, done := r.start(r.ctx, "FindPayments")
+	defer func() { done(err) }()
+	return bindPaymentRepository(r.repository, ctx).FindPayments(filter)
+}
+
+// StreamPayments observes the whole stream, including the time the payments spend in the function of the caller
+func (r *instrumentedPaymentRepository) StreamPayments(ctx context.Context, filter PaymentFilter, each func(payment Payment) error) (err error) {
+	ctx, done := r.start(ctx, "StreamPayments")
+	defer func() { done(err) }()
+	return bindPaymentRepository(r.repository, ctx).StreamPayments(ctx, filter, each)
+}
+
+func (r *instrumentedPaymentRepository) GetPaymentVersions(paymentID string) (versions []PaymentVersion, err error) {
+	ctx, done := r.start(r.ctx, "GetPaymentVersions")
+	defer func() { done(err) }()
+	return bindPaymentRepository(r.repository, ctx).GetPaymentVersions(paymentID)
+}
+
+func (r *instrumentedPaymentRepository) GetPaymentVersion(paymentID string, version int) (payment Payment, err error) {
+	ctx, done := r.start(r.ctx, "GetPaymentVersion")
+	defer func() { done(err) }()
+	return bindPaymentRepository(r.repository, ctx).GetPaymentVersion(paymentID, version)
+}
+
+func (r *instrumentedPaymentRepository) GetPaymentAsOf(paymentID string, asOf time.Time) (payment Payment, err error) {
+	ctx, done := r.start(r.ctx, "GetPaymentAsOf")
+	defer func() { done(err) }()
+	return bindPaymentRepository(r.repository, ctx).GetPaymentAsOf(paymentID, asOf)
+}
+
+type instrumentedTransactionalRepository struct {
+	*instrumentedPaymentRepository
+	transactional TransactionalPaymentRepository
+}
+
+func (r *instrumentedTransactionalRepository) WithContext(ctx context.Context) PaymentRepository {
+	return &instrumentedTransactionalRepository{&instrumentedPaymentRepository{repository: r.repository, ctx: ctx}, r.transactional}
+}
+
+// RunInTransaction traces the changes of the transaction as the children of the span of the transaction
+func (r *instrumentedTransactionalRepository) RunInTransaction(changes func(repository PaymentRepository) error) (err error) {
+	ctx, done := r.start(r.ctx, "RunInTransaction")
+	defer func() { done(err) }()
+
+	transactional := r.transactional
+	if contextual, ok := r.transactional.(ContextualPaymentRepository); ok {
+		if bound, ok := contextual.WithContext(ctx).(TransactionalPaymentRepository); ok {
+			transactional = bound
+		}
+	}
+	return transactional.RunInTransaction(func(repository PaymentRepository) error {
+		return changes(&instrumentedPaymentRepository{repository: repository, ctx: ctx})
+	})
+}
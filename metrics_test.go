@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/event"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test http metrics
+
+func TestRequestsCountedByRouteTemplate(t *testing.T) {
+	requests := httpRequestsTotal.WithLabelValues(methodGet, getPaymentPath, "200")
+	missing := httpRequestsTotal.WithLabelValues(methodGet, getPaymentPath, "404")
+	before, beforeMissing := testutil.ToFloat64(requests), testutil.ToFloat64(missing)
+
+	ServeHTTP(methodGet, preparePaymentURL(getPaymentPath, "4ee3a8d8"), http.NoBody, successful)
+	ServeHTTP(methodGet, preparePaymentURL(getPaymentPath, "4ee3a8d8"), http.NoBody, notFound)
+
+	Equal(t, before+1, testutil.ToFloat64(requests))
+	Equal(t, beforeMissing+1, testutil.ToFloat64(missing))
+
+	response := ServeHTTP(methodGet, metricsPath, http.NoBody, successful)
+
+	Equal(t, 200, response.Code)
+	Contains(t, response.Body.String(), `payments_http_request_duration_seconds_bucket{code="200",method="GET",route="/v1/payments/get/{id}"`)
+	NotContains(t, response.Body.String(), "4ee3a8d8")
+}
+
+func TestStatusRecorderFlushesAndUnwraps(t *testing.T) {
+	response := httptest.NewRecorder()
+	recorder := &statusRecorder{ResponseWriter: response, statusCode: http.StatusOK}
+
+	Nil(t, http.NewResponseController(recorder).Flush())
+	recorder.WriteHeader(http.StatusCreated)
+
+	True(t, response.Flushed)
+	Equal(t, http.StatusOK, recorder.statusCode)
+	Equal(t, response, recorder.Unwrap())
+}
+
+func TestCreatedPaymentsCounted(t *testing.T) {
+	created := paymentsCreatedTotal.WithLabelValues("EUR", "SEPA")
+	amount := paymentsCreatedAmount.WithLabelValues("EUR")
+	before, beforeAmount := testutil.ToFloat64(created), testutil.ToFloat64(amount)
+
+	notifyPaymentEvent(paymentCreatedEvent, Payment{ID: "1", Attributes: Attributes{Amount: 12.5, Currency: "EUR", PaymentScheme: "SEPA"}})
+	notifyPaymentEvent(paymentUpdatedEvent, Payment{ID: "1", Attributes: Attributes{Amount: 12.5, Currency: "EUR", PaymentScheme: "SEPA"}})
+
+	Equal(t, before+1, testutil.ToFloat64(created))
+	Equal(t, beforeAmount+12.5, testutil.ToFloat64(amount))
+}
+
+func TestCreatedPaymentsWithUnknownLabelsCountedAsOther(t *testing.T) {
+	created := paymentsCreatedTotal.WithLabelValues(otherLabelValue, otherLabelValue)
+	before := testutil.ToFloat64(created)
+
+	notifyPaymentEvent(paymentCreatedEvent, Payment{ID: "1", Attributes: Attributes{Currency: "XYZ", PaymentScheme: "a\nb"}})
+
+	Equal(t, before+1, testutil.ToFloat64(created))
+}
+
+// Test repository metrics
+
+func TestRepositoryErrorsCounted(t *testing.T) {
+	errors := repositoryErrorsTotal.WithLabelValues("UpdatePayment", "PaymentVersionConflictError")
+	conflicts := versionConflictsTotal.WithLabelValues("UpdatePayment")
+	before, beforeConflicts := testutil.ToFloat64(errors), testutil.ToFloat64(conflicts)
+
+	repository := instrumentPaymentRepository(&PaymentRepositoryMock{mode: versionConflict})
+	err := repository.UpdatePayment(Payment{ID: "1", Version: 2})
+
+	Equal(t, &PaymentVersionConflictError{"1", 2}, err)
+	Equal(t, before+1, testutil.ToFloat64(errors))
+	Equal(t, beforeConflicts+1, testutil.ToFloat64(conflicts))
+}
+
+func TestInstrumentedRepositoryStaysTransactional(t *testing.T) {
+	mock := NewTransactionalRepositoryMock()
+
+	repository := instrumentPaymentRepository(mock)
+	transactional, ok := repository.(TransactionalPaymentRepository)
+	True(t, ok)
+
+	err := transactional.RunInTransaction(func(repository PaymentRepository) error {
+		_, instrumented := repository.(*instrumentedPaymentRepository)
+		True(t, instrumented)
+		return repository.UpdatePayment(Payment{ID: "p1", Version: 1})
+	})
+
+	Nil(t, err)
+	Equal(t, 1, mock.transactions)
+	Equal(t, 2, mock.payments[0].Version)
+
+	_, ok = instrumentPaymentRepository(&PaymentRepositoryMock{}).(TransactionalPaymentRepository)
+	False(t, ok)
+}
+
+// Test MongoDB metrics
+
+func TestMongoCommandMonitor(t *testing.T) {
+	monitor := newMongoCommandMonitor()
+	ctx := context.Background()
+
+	monitor.Started(ctx, &event.CommandStartedEvent{CommandName: "find", RequestID: 1, ConnectionID: "c1"})
+	monitor.Started(ctx, &event.CommandStartedEvent{CommandName: "find", RequestID: 2, ConnectionID: "c1"})
+	monitor.Started(ctx, &event.CommandStartedEvent{CommandName: "insert", RequestID: 3, ConnectionID: "c2"})
+
+	Equal(t, float64(3), testutil.ToFloat64(mongoCommandsInFlight))
+
+	monitor.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", RequestID: 1}})
+	monitor.Failed(ctx, &event.CommandFailedEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "insert", RequestID: 3}})
+
+	Equal(t, float64(1), testutil.ToFloat64(mongoCommandsInFlight))
+}
+
+func TestMongoPoolMonitor(t *testing.T) {
+	monitor := newMongoPoolMonitor()
+	poolEvent := func(eventType string, address string, connectionID uint64) {
+		monitor.Event(&event.PoolEvent{Type: eventType, Address: address, ConnectionID: connectionID})
+	}
+
+	poolEvent(event.ConnectionCreated, "mongo1:27017", 1)
+	poolEvent(event.ConnectionCreated, "mongo1:27017", 2)
+	poolEvent(event.ConnectionCreated, "mongo2:27017", 1)
+	poolEvent(event.GetSucceeded, "mongo1:27017", 1)
+	poolEvent(event.GetSucceeded, "mongo2:27017", 1)
+
+	Equal(t, float64(3), testutil.ToFloat64(mongoPoolConnections))
+	Equal(t, float64(2), testutil.ToFloat64(mongoPoolConnectionsInUse))
+	Equal(t, float64(1), testutil.ToFloat64(mongoPoolConnectionsIdle))
+
+	poolEvent(event.ConnectionReturned, "mongo1:27017", 1)
+	poolEvent(event.ConnectionClosed, "mongo2:27017", 1)
+	poolEvent(event.ConnectionReturned, "mongo2:27017", 1)
+
+	Equal(t, float64(2), testutil.ToFloat64(mongoPoolConnections))
+	Equal(t, float64(0), testutil.ToFloat64(mongoPoolConnectionsInUse))
+	Equal(t, float64(2), testutil.ToFloat64(mongoPoolConnectionsIdle))
+}
@@ -1,5 +1,7 @@
 package main
 
+import "time"
+
 // A PaymentListResult is a structure used by endpoints to return a list of payments
 type PaymentListResult struct {
 	Data  []Payment `json:"data,omitempty"`
@@ -12,11 +14,62 @@ type PaymentResult struct {
 	Links Links   `json:"links,omitempty"`
 }
 
+// A PaymentVersionListResult is a structure used by endpoints to return all retained versions of a payment
+type PaymentVersionListResult struct {
+	Data  []PaymentVersion `json:"data,omitempty"`
+	Links Links            `json:"links,omitempty"`
+}
+
+// A PaymentDiffResult is a structure used by endpoints to return the difference between two versions of a payment
+type PaymentDiffResult struct {
+	Data  PaymentDiff `json:"data,omitempty"`
+	Links Links       `json:"links,omitempty"`
+}
+
+// A WebhookSubscriptionListResult is a structure used by endpoints to return a list of webhook subscriptions
+type WebhookSubscriptionListResult struct {
+	Data  []WebhookSubscription `json:"data,omitempty"`
+	Links Links                 `json:"links,omitempty"`
+}
+
+// A WebhookDeliveryListResult is a structure used by endpoints to return a list of webhook deliveries
+type WebhookDeliveryListResult struct {
+	Data  []WebhookDelivery `json:"data,omitempty"`
+	Links Links             `json:"links,omitempty"`
+}
+
+// A PaymentImportReport is a structure used by endpoints to return the result of every row of an imported file
+type PaymentImportReport struct {
+	Data  []PaymentImportRow `json:"data,omitempty"`
+	Links Links              `json:"links,omitempty"`
+}
+
+// A PaymentOperationsReport is a structure used by endpoints to return the result of every operation of a batch
+type PaymentOperationsReport struct {
+	Data  []PaymentOperationResult `json:"data,omitempty"`
+	Links Links                    `json:"links,omitempty"`
+}
+
+// A HealthReport is a structure used by the health endpoints to return the status of the application
+type HealthReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentHealth `json:"components,omitempty"`
+}
+
+// A ComponentHealth is a structure used by the readiness endpoint to return the result of a component check
+type ComponentHealth struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
 // A Links is a structure used by endpoints to return URLs to possible actions depending on the response context
 type Links struct {
-	Self   string `json:"self,omitempty"`
-	Update string `json:"update,omitempty"`
-	Delete string `json:"delete,omitempty"`
+	Self     string `json:"self,omitempty"`
+	Update   string `json:"update,omitempty"`
+	Delete   string `json:"delete,omitempty"`
+	Versions string `json:"versions,omitempty"`
+	Next     string `json:"next,omitempty"`
 }
 
 // A Payment is a structure which represents the data for a single payment
@@ -92,3 +145,119 @@ type FX struct {
 	OriginalAmount    float64 `json:"original_amount,string,omitempty" bson:"original_amount,omitempty"`
 	OriginalCurrency  string  `json:"original_currency,omitempty" bson:"original_currency,omitempty"`
 }
+
+// A PaymentImportRow is a structure which represents the result of a single imported row, the first row after the header is row 1
+type PaymentImportRow struct {
+	Row    int          `json:"row"`
+	Status string       `json:"status"`
+	ID     string       `json:"id,omitempty"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// A PaymentOperationBatch is a structure which represents the operations submitted to the batch endpoint
+type PaymentOperationBatch struct {
+	Operations []PaymentOperation `json:"operations"`
+}
+
+// A PaymentOperation is a structure which represents a single create, update or delete of a batch
+type PaymentOperation struct {
+	Op      string   `json:"op"`
+	ID      string   `json:"id,omitempty"`
+	Payment *Payment `json:"payment,omitempty"`
+}
+
+// A PaymentOperationResult is a structure which represents the result of a single operation of a batch
+type PaymentOperationResult struct {
+	Index   int    `json:"index"`
+	Op      string `json:"op"`
+	Status  int    `json:"status"`
+	ID      string `json:"id,omitempty"`
+	Version int    `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// A FieldError is a structure which represents a single invalid field of a payment
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// A PaymentVersion is a structure which represents a single retained version of a payment and the time it was recorded
+type PaymentVersion struct {
+	PaymentID  string    `json:"payment_id" bson:"payment_id"`
+	Version    int       `json:"version" bson:"version"`
+	RecordedAt time.Time `json:"recorded_at" bson:"recorded_at"`
+	Deleted    bool      `json:"deleted,omitempty" bson:"deleted,omitempty"`
+	Payment    Payment   `json:"payment,omitempty" bson:"payment,omitempty"`
+}
+
+// A PaymentDiff is a structure which represents the list of field changes between two versions of a payment
+type PaymentDiff struct {
+	PaymentID   string        `json:"payment_id"`
+	FromVersion int           `json:"from_version"`
+	ToVersion   int           `json:"to_version"`
+	Changes     []FieldChange `json:"changes"`
+}
+
+// A FieldChange is a structure which represents a change of a single payment field, the path is a dot separated list of json property names
+type FieldChange struct {
+	Path string      `json:"path"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// A PaymentEvent is a structure which represents a change of a payment delivered to downstream systems
+type PaymentEvent struct {
+	ID             string    `json:"id" bson:"_id"`
+	Type           string    `json:"type" bson:"type"`
+	PaymentID      string    `json:"payment_id" bson:"payment_id"`
+	OrganisationID string    `json:"organisation_id" bson:"organisation_id"`
+	Version        int       `json:"version" bson:"version"`
+	OccurredAt     time.Time `json:"occurred_at" bson:"occurred_at"`
+	Payment        *Payment  `json:"payment,omitempty" bson:"payment,omitempty"`
+}
+
+// A WebhookSubscription is a structure which represents the URL the payment events of an organisation are delivered to
+type WebhookSubscription struct {
+	ID             string `json:"id,omitempty" bson:"_id"`
+	OrganisationID string `json:"organisation_id,omitempty" bson:"organisation_id"`
+	URL            string `json:"url,omitempty" bson:"url"`
+	Secret         string `json:"secret,omitempty" bson:"secret"`
+}
+
+// A WebhookDelivery is a structure which represents the delivery of a single payment event to a single subscription
+type WebhookDelivery struct {
+	ID             string       `json:"id" bson:"_id"`
+	SubscriptionID string       `json:"subscription_id" bson:"subscription_id"`
+	Event          PaymentEvent `json:"event" bson:"event"`
+	Status         string       `json:"status" bson:"status"`
+	Attempts       int          `json:"attempts" bson:"attempts"`
+	NextAttemptAt  time.Time    `json:"next_attempt_at" bson:"next_attempt_at"`
+	LeaseExpiresAt time.Time    `json:"-" bson:"lease_expires_at,omitempty"`
+	LastError      string       `json:"last_error,omitempty" bson:"last_error,omitempty"`
+}
+
+// A PaymentFilter is a structure which represents the criteria payments are selected by, an empty criterion matches all payments
+type PaymentFilter struct {
+	OrganisationID    string
+	PaymentScheme     string
+	ProcessingDate    string
+	PaymentType       string
+	SchemePaymentType string
+	Currency          string
+	MinAmount         *float64
+	MaxAmount         *float64
+	After             string
+	Limit             int
+}
+
+// A PaymentBatch is a structure which represents a file of payments submitted to a payment scheme, a payment is submitted at most once
+type PaymentBatch struct {
+	ID             string    `json:"id" bson:"_id"`
+	OrganisationID string    `json:"organisation_id" bson:"organisation_id"`
+	PaymentScheme  string    `json:"payment_scheme" bson:"payment_scheme"`
+	ProcessingDate string    `json:"processing_date" bson:"processing_date"`
+	PaymentIDs     []string  `json:"payment_ids" bson:"payment_ids"`
+	SubmittedAt    time.Time `json:"submitted_at" bson:"submitted_at"`
+	File           string    `json:"-" bson:"file"`
+}
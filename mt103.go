@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	mt103Format string = "mt103"
+
+	swiftPaymentScheme   string = "SWIFT"
+	swiftDateLayout      string = "060102"
+	swiftLineLength      int    = 35
+	swiftPartyLines      int    = 4
+	swiftNarrativeLines  int    = 4
+	swiftReferenceLength int    = 16
+	swiftAccountLength   int    = 34
+	swiftAmountLength    int    = 15
+	swiftRateLength      int    = 12
+	swiftLineSeparator   string = "\r\n"
+)
+
+var (
+	// The minor units of the currencies which don't have two decimals (ISO 4217)
+	currencyMinorUnits = map[string]int{"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+		"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+		"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3}
+
+	// The characters of the SWIFT X character set
+	swiftCharset = regexp.MustCompile(`[^a-zA-Z0-9/\-?:().,'+ \n]`)
+
+	swiftField = regexp.MustCompile(`^:([0-9]{2}[A-Z]?):(.*)$`)
+	iban       = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]+$`)
+
+	// The SWIFT codes of field 71A and the charge bearer codes of a payment
+	swiftChargeCodes = map[string]string{"SHAR": "SHA", "SLEV": "SHA", "DEBT": "OUR", "CRED": "BEN"}
+	swiftBearerCodes = map[string]string{"SHA": "SHAR", "OUR": "DEBT", "BEN": "CRED"}
+
+	latinTransliterations = newLatinTransliterations(map[string]string{
+		"àáâãäåāą": "a", "ÀÁÂÃÄÅĀĄ": "A", "æ": "ae", "Æ": "AE", "çćč": "c", "ÇĆČ": "C", "ďđ": "d", "ĎĐ": "D",
+		"èéêëēęě": "e", "ÈÉÊËĒĘĚ": "E", "ìíîïī": "i", "ÌÍÎÏĪ": "I", "ł": "l", "Ł": "L", "ñńň": "n", "ÑŃŇ": "N",
+		"òóôõöøō": "o", "ÒÓÔÕÖØŌ": "O", "řŕ": "r", "ŘŔ": "R", "śšş": "s", "ŚŠŞ": "S", "ß": "ss", "ťţ": "t", "ŤŢ": "T",
+		"ùúûüūů": "u", "ÙÚÛÜŪŮ": "U", "ýÿ": "y", "ÝŸ": "Y", "źżž": "z", "ŹŻŽ": "Z"})
+
+	// The symbols outside of the SWIFT X character set which have a replacement other than a dot
+	swiftSymbols = strings.NewReplacer("&", "+", "\t", " ")
+)
+
+func newLatinTransliterations(groups map[string]string) map[rune]string {
+	transliterations := make(map[rune]string)
+	for characters, replacement := range groups {
+		for _, character := range characters {
+			transliterations[character] = replacement
+		}
+	}
+	return transliterations
+}
+
+// normalizeSwiftText converts the text to the SWIFT X character set
+func normalizeSwiftText(text string) string {
+	text = swiftSymbols.Replace(transliterate(strings.ReplaceAll(text, "\r", "")))
+
+	lines := strings.Split(swiftCharset.ReplaceAllString(text, "."), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, ":") || strings.HasPrefix(line, "-") {
+			line = "." + line[1:]
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// transliterate replaces the accented letters with their closest latin letters
+func transliterate(text string) string {
+	var builder strings.Builder
+	for _, character := range text {
+		if replacement, ok := latinTransliterations[character]; ok {
+			builder.WriteString(replacement)
+		} else {
+			builder.WriteRune(character)
+		}
+	}
+	return builder.String()
+}
+
+// wrapSwiftText normalises the text and wraps it at the word boundaries into at most maxLines lines of 35 characters
+func wrapSwiftText(text string, maxLines int) (lines []string) {
+	var line string
+	for _, word := range strings.Fields(normalizeSwiftText(text)) {
+		for len(word) > 0 {
+			switch {
+			case len(line) == 0 && len(word) > swiftLineLength:
+				lines = append(lines, word[:swiftLineLength])
+				word = word[swiftLineLength:]
+			case len(line) == 0:
+				line, word = word, ""
+			case len(line)+1+len(word) <= swiftLineLength:
+				line, word = line+" "+word, ""
+			default:
+				lines = append(lines, line)
+				line = ""
+			}
+		}
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+
+	if len(lines) > maxLines {
+		return lines[:maxLines]
+	}
+	return lines
+}
+
+// renderMT103 renders the payment as a MT103 single customer credit transfer
+func renderMT103(payment Payment, createdAt time.Time) ([]byte, error) {
+	attributes := payment.Attributes
+	debtor := attributes.DebtorParty
+	beneficiary := attributes.BeneficiaryParty.debtorParty()
+
+	if len(attributes.Currency) != 3 || attributes.Amount <= 0 ||
+		debtor.sponsorParty().BankIDCode != swiftBankIDCode || beneficiary.sponsorParty().BankIDCode != swiftBankIDCode {
+		return nil, &InvalidPaymentError{payment}
+	}
+
+	valueDate := createdAt
+	if date, err := time.Parse(iso20022DateLayout, attributes.ProcessingDate); err == nil {
+		valueDate = date
+	}
+
+	reference := defaultText(attributes.PaymentID, strings.ReplaceAll(payment.ID, "-", ""))
+	chargeCode := defaultText(swiftChargeCodes[attributes.ChargesInformation.BearerCode], "SHA")
+
+	var fields []string
+	addField := func(tag string, lines ...string) {
+		fields = append(fields, ":"+tag+":"+strings.Join(lines, swiftLineSeparator))
+	}
+	// The amounts are never rounded or truncated, the first amount which can't be rendered fails the message
+	var amountErr error
+	swiftAmount := func(tag string, currency string, amount float64) string {
+		formatted, err := formatSwiftAmount(tag, currency, amount)
+		if amountErr == nil {
+			amountErr = err
+		}
+		return formatted
+	}
+
+	addField("20", swiftReference(reference))
+	addField("23B", "CRED")
+	addField("32A", valueDate.Format(swiftDateLayout)+attributes.Currency+swiftAmount("32A", attributes.Currency, attributes.Amount))
+	if fx := attributes.FX; fx.OriginalAmount > 0 && len(fx.OriginalCurrency) == 3 {
+		addField("33B", fx.OriginalCurrency+swiftAmount("33B", fx.OriginalCurrency, fx.OriginalAmount))
+		if fx.ExchangeRate > 0 && fx.OriginalCurrency != attributes.Currency {
+			rate, err := formatSwiftDecimal("36", fx.ExchangeRate, -1, swiftRateLength)
+			if amountErr == nil {
+				amountErr = err
+			}
+			addField("36", rate)
+		}
+	}
+	addField("50K", swiftParty(debtor)...)
+	addField("59", swiftParty(beneficiary)...)
+	if narrative := wrapSwiftText(attributes.Reference, swiftNarrativeLines); len(narrative) > 0 {
+		addField("70", narrative...)
+	}
+	addField("71A", chargeCode)
+
+	charges := attributes.ChargesInformation
+	switch chargeCode {
+	case "OUR":
+		if charges.Amount > 0 && len(charges.Currency) == 3 {
+			addField("71G", charges.Currency+swiftAmount("71G", charges.Currency, charges.Amount))
+		}
+	default:
+		for _, senderCharges := range charges.SenderCharges {
+			addField("71F", senderCharges.Currency+swiftAmount("71F", senderCharges.Currency, senderCharges.Amount))
+		}
+		// The beneficiary bears the charges, so at least one sender's charge is required
+		if chargeCode == "BEN" && len(charges.SenderCharges) == 0 {
+			addField("71F", attributes.Currency+swiftAmount("71F", attributes.Currency, 0))
+		}
+	}
+
+	if amountErr != nil {
+		return nil, amountErr
+	}
+
+	message := fmt.Sprintf("{1:F01%s0000000000}{2:I103%sN}{4:%s%s%s-}",
+		swiftLogicalTerminal(debtor.BankID, "A"), swiftLogicalTerminal(beneficiary.BankID, "X"),
+		swiftLineSeparator, strings.Join(fields, swiftLineSeparator), swiftLineSeparator)
+
+	return []byte(message + swiftLineSeparator), nil
+}
+
+// The sender's reference must not start or end with a slash or contain two consecutive slashes
+func swiftReference(reference string) string {
+	reference = strings.ReplaceAll(normalizeSwiftText(reference), "\n", "")
+	for strings.Contains(reference, "//") {
+		reference = strings.ReplaceAll(reference, "//", "/")
+	}
+	return strings.Trim(truncateText(strings.Trim(reference, "/"), swiftReferenceLength), "/")
+}
+
+// swiftParty renders the account line followed by the name and as many address lines as the field allows
+func swiftParty(party DebtorParty) (lines []string) {
+	if accountNumber := party.sponsorParty().AccountNumber; len(accountNumber) > 0 {
+		lines = append(lines, "/"+truncateText(strings.ReplaceAll(normalizeSwiftText(accountNumber), "\n", ""), swiftAccountLength))
+	}
+	name := wrapSwiftText(party.Name, 1)
+	lines = append(lines, name...)
+	return append(lines, wrapSwiftText(party.Address, swiftPartyLines-len(name))...)
+}
+
+// The logical terminal address is the BIC extended by the terminal code and the branch code (XXX for the head office)
+func swiftLogicalTerminal(bic string, terminalCode string) string {
+	bic = strings.ToUpper(bic)
+	if len(bic) < 8 {
+		return fmt.Sprintf("%-12s", bic)
+	}
+	branch := "XXX"
+	if len(bic) == 11 {
+		branch = bic[8:]
+	}
+	return bic[:8] + terminalCode + branch
+}
+
+func swiftBIC(logicalTerminal string) string {
+	if len(logicalTerminal) < 12 {
+		return strings.TrimSpace(logicalTerminal)
+	}
+	if branch := logicalTerminal[9:12]; branch != "XXX" {
+		return logicalTerminal[:8] + branch
+	}
+	return logicalTerminal[:8]
+}
+
+// formatSwiftAmount renders the amount of the field with at most the minor units of its currency
+func formatSwiftAmount(tag string, currency string, amount float64) (string, error) {
+	return formatSwiftDecimal(tag, amount, currencyDecimals(currency), swiftAmountLength)
+}
+
+// formatSwiftDecimal renders the value of the field with a decimal comma
+func formatSwiftDecimal(tag string, value float64, decimals int, length int) (string, error) {
+	formatted, err := formatDecimal(tag, value, decimals)
+	if err != nil {
+		return "", err
+	}
+
+	formatted = strings.Replace(formatted, ".", ",", 1)
+	if !strings.Contains(formatted, ",") {
+		formatted += ","
+	}
+
+	if len(formatted) > length {
+		return "", &InvalidAmountError{tag, value, fmt.Sprintf("is longer than %d characters", length)}
+	}
+	return formatted, nil
+}
+
+// currencyDecimals returns the minor units of the currency, two when the currency is not known to have others
+func currencyDecimals(currency string) int {
+	if units, ok := currencyMinorUnits[currency]; ok {
+		return units
+	}
+	return 2
+}
+
+// formatDecimal renders the value of the field with at most the decimals, -1 allows any
+func formatDecimal(field string, value float64, decimals int) (string, error) {
+	formatted := strconv.FormatFloat(value, 'f', decimals, 64)
+	if exact, _ := strconv.ParseFloat(formatted, 64); exact != value {
+		return "", &InvalidAmountError{field, value, fmt.Sprintf("has more than %d decimals", decimals)}
+	}
+
+	if strings.Contains(formatted, ".") {
+		formatted = strings.TrimRight(strings.TrimRight(formatted, "0"), ".")
+	}
+	return formatted, nil
+}
+
+// parseSwiftCurrencyAmount parses the currency and the amount of the field, e.g. 33B or 71F
+func parseSwiftCurrencyAmount(tag string, value string) (currency string, amount float64, err error) {
+	if len(value) <= 3 {
+		return "", 0, &InvalidDocumentError{mt103Format, fmt.Sprintf("invalid field %s '%s'", tag, value)}
+	}
+
+	amount, err = parseSwiftAmount(value[3:])
+	if err != nil || amount < 0 {
+		return "", 0, &InvalidDocumentError{mt103Format, fmt.Sprintf("invalid amount '%s' of field %s", value[3:], tag)}
+	}
+	return value[:3], amount, nil
+}
+
+func parseSwiftAmount(amount string) (float64, error) {
+	return strconv.ParseFloat(strings.Replace(strings.TrimSpace(amount), ",", ".", 1), 64)
+}
+
+// parseMT103 parses every MT103 message of the document into a payment
+func parseMT103(reader io.Reader) (payments []Payment, err error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	document := strings.TrimSpace(strings.ReplaceAll(string(data), "\r\n", "\n"))
+	messages := strings.Split(document, "{1:")
+	if len(messages) > 1 {
+		messages = messages[1:]
+	}
+
+	for _, message := range messages {
+		payment, err := parseMT103Message(message)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	return payments, nil
+}
+
+func parseMT103Message(message string) (payment Payment, err error) {
+	text := message
+	var sender, receiver string
+	if start := strings.Index(message, "{4:"); start >= 0 {
+		sender = swiftBlockValue(message[:start], "F01")
+		receiver = swiftBlockValue(message[:start], "{2:I103")
+		text = message[start+len("{4:"):]
+		if end := strings.Index(text, "\n-}"); end >= 0 {
+			text = text[:end]
+		}
+	}
+
+	fields, err := parseSwiftFields(text)
+	if err != nil {
+		return payment, err
+	}
+
+	for _, tag := range []string{"20", "32A", "50K", "59", "71A"} {
+		if len(fields[tag]) == 0 {
+			return payment, &InvalidDocumentError{mt103Format, fmt.Sprintf("mandatory field %s is missing", tag)}
+		}
+	}
+
+	field32A := fields["32A"][0]
+	if len(field32A) < 10 {
+		return payment, &InvalidDocumentError{mt103Format, fmt.Sprintf("invalid field 32A '%s'", field32A)}
+	}
+	valueDate, err := time.Parse(swiftDateLayout, field32A[:6])
+	if err != nil {
+		return payment, &InvalidDocumentError{mt103Format, fmt.Sprintf("invalid value date '%s'", field32A[:6])}
+	}
+	amount, err := parseSwiftAmount(field32A[9:])
+	if err != nil || amount <= 0 {
+		return payment, &InvalidDocumentError{mt103Format, fmt.Sprintf("invalid amount '%s'", field32A[9:])}
+	}
+
+	chargeCode := strings.TrimSpace(fields["71A"][0])
+	bearerCode, ok := swiftBearerCodes[chargeCode]
+	if !ok {
+		return payment, &InvalidDocumentError{mt103Format, fmt.Sprintf("invalid details of charges '%s'", chargeCode)}
+	}
+
+	debtorParty := newSwiftParty(fields["50K"][0], sender)
+	beneficiaryParty := newSwiftParty(fields["59"][0], receiver)
+
+	attributes := Attributes{
+		Amount:             amount,
+		BeneficiaryParty:   BeneficiaryParty{DebtorParty: &beneficiaryParty},
+		ChargesInformation: ChargesInformation{BearerCode: bearerCode},
+		Currency:           field32A[6:9],
+		DebtorParty:        debtorParty,
+		PaymentID:          swiftReference(fields["20"][0]),
+		PaymentScheme:      swiftPaymentScheme,
+		PaymentType:        "Credit",
+		ProcessingDate:     valueDate.Format(iso20022DateLayout)}
+
+	if narrative, ok := fields["70"]; ok {
+		attributes.Reference = strings.Join(wrapSwiftText(strings.ReplaceAll(narrative[0], "\n", " "), swiftNarrativeLines), " ")
+	}
+
+	if instructedAmount, ok := fields["33B"]; ok {
+		attributes.FX.OriginalCurrency, attributes.FX.OriginalAmount, err = parseSwiftCurrencyAmount("33B", instructedAmount[0])
+		if err != nil {
+			return payment, err
+		}
+	}
+	if exchangeRate, ok := fields["36"]; ok {
+		attributes.FX.ExchangeRate, err = parseSwiftAmount(exchangeRate[0])
+		if err != nil || attributes.FX.ExchangeRate <= 0 {
+			return payment, &InvalidDocumentError{mt103Format, fmt.Sprintf("invalid exchange rate '%s' of field 36", exchangeRate[0])}
+		}
+	}
+
+	for _, senderCharges := range fields["71F"] {
+		currency, chargeAmount, err := parseSwiftCurrencyAmount("71F", senderCharges)
+		if err != nil {
+			return payment, err
+		}
+		attributes.ChargesInformation.SenderCharges = append(attributes.ChargesInformation.SenderCharges,
+			SenderCharges{Amount: chargeAmount, Currency: currency})
+	}
+	if receiverCharges, ok := fields["71G"]; ok {
+		attributes.ChargesInformation.Currency, attributes.ChargesInformation.Amount, err = parseSwiftCurrencyAmount("71G", receiverCharges[0])
+		if err != nil {
+			return payment, err
+		}
+	}
+
+	return Payment{Type: "Payment", Attributes: attributes}, nil
+}
+
+// parseSwiftFields returns the values of the text block fields by their tags, the lines of a multiline field are separated by a new line
+func parseSwiftFields(text string) (fields map[string][]string, err error) {
+	fields = make(map[string][]string)
+
+	var tag string
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " ")
+		if len(strings.TrimSpace(line)) == 0 || line == "-" || line == "-}" {
+			continue
+		}
+
+		if match := swiftField.FindStringSubmatch(line); match != nil {
+			tag = match[1]
+			fields[tag] = append(fields[tag], match[2])
+			continue
+		}
+
+		if len(tag) == 0 {
+			return nil, &InvalidDocumentError{mt103Format, fmt.Sprintf("unexpected line '%s'", line)}
+		}
+		values := fields[tag]
+		values[len(values)-1] += "\n" + line
+	}
+
+	return fields, scanner.Err()
+}
+
+// The value of a basic or application header block is the logical terminal address following the prefix
+func swiftBlockValue(headers string, prefix string) string {
+	start := strings.Index(headers, prefix)
+	if start < 0 || len(headers) < start+len(prefix)+12 {
+		return ""
+	}
+	return headers[start+len(prefix) : start+len(prefix)+12]
+}
+
+// newSwiftParty maps a party field, an optional account line starting with a slash followed by the name and the address lines
+func newSwiftParty(value string, logicalTerminal string) DebtorParty {
+	party := DebtorParty{SponsorParty: &SponsorParty{}}
+
+	lines := strings.Split(normalizeSwiftText(value), "\n")
+	if strings.HasPrefix(lines[0], "/") {
+		party.AccountNumber = truncateText(strings.TrimPrefix(lines[0], "/"), swiftAccountLength)
+		party.AccountNumberCode = bbanAccountCode
+		if iban.MatchString(party.AccountNumber) {
+			party.AccountNumberCode = ibanAccountCode
+		}
+		lines = lines[1:]
+	}
+
+	if len(lines) > swiftPartyLines {
+		lines = lines[:swiftPartyLines]
+	}
+	if len(lines) > 0 {
+		party.Name = truncateText(lines[0], swiftLineLength)
+		party.Address = strings.Join(lines[1:], " ")
+	}
+
+	if bic := swiftBIC(logicalTerminal); len(bic) > 0 {
+		party.BankID = bic
+		party.BankIDCode = swiftBankIDCode
+	}
+	return party
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	. "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test MT103 rendering
+
+func TestRenderMT103MatchesGolden(t *testing.T) {
+	document, err := renderMT103(MockSwiftPayment(t), time.Date(2019, 5, 5, 11, 0, 0, 0, time.UTC))
+	Nil(t, err)
+
+	AssertGolden(t, "test_resources/swift/mt103.golden.txt", document)
+}
+
+func TestRenderMT103ProducesSwiftText(t *testing.T) {
+	document, err := renderMT103(MockSwiftPayment(t), time.Now())
+	Nil(t, err)
+
+	message := string(document)
+	True(t, strings.HasPrefix(message, "{1:F01XABCGB2LAXXX0000000000}{2:I103DEUTDEFFX500N}{4:\r\n"))
+	True(t, strings.HasSuffix(message, "\r\n-}\r\n"))
+	Contains(t, message, ":20:INV-2019/042-000\r\n")
+	Contains(t, message, ":32A:190506EUR1250,5\r\n")
+	Contains(t, message, ":33B:GBP1078,02\r\n")
+	Contains(t, message, ":36:1,16\r\n")
+	Contains(t, message, ":71A:SHA\r\n")
+
+	fields, err := parseSwiftFields(message[strings.Index(message, "{4:")+len("{4:"):])
+	Nil(t, err)
+	for _, values := range fields {
+		for _, line := range strings.Split(values[0], "\n") {
+			LessOrEqual(t, len(line), swiftLineLength)
+			Regexp(t, `^[a-zA-Z0-9/\-?:().,'+ ]*$`, line)
+		}
+	}
+	Equal(t, 4, len(strings.Split(fields["59"][0], "\n")))
+	Equal(t, 4, len(strings.Split(fields["70"][0], "\n")))
+}
+
+func TestRenderMT103ChargeCodes(t *testing.T) {
+	payment := MockSwiftPayment(t)
+
+	payment.Attributes.ChargesInformation = ChargesInformation{BearerCode: "DEBT", Amount: 10, Currency: "EUR"}
+	document, _ := renderMT103(payment, time.Now())
+	Contains(t, string(document), ":71A:OUR\r\n:71G:EUR10,\r\n")
+
+	payment.Attributes.ChargesInformation = ChargesInformation{BearerCode: "CRED"}
+	document, _ = renderMT103(payment, time.Now())
+	Contains(t, string(document), ":71A:BEN\r\n:71F:EUR0,\r\n")
+}
+
+func TestRenderMT103WithoutBIC(t *testing.T) {
+	payment := MockSwiftPayment(t)
+	payment.Attributes.DebtorParty.BankIDCode = "GBDSC"
+
+	_, err := renderMT103(payment, time.Now())
+
+	IsType(t, &InvalidPaymentError{}, err)
+}
+
+func TestRenderMT103AmountsNotRoundedOrTruncated(t *testing.T) {
+	payment := MockSwiftPayment(t)
+
+	payment.Attributes.Amount = 1234567890123.45
+	_, err := renderMT103(payment, time.Now())
+	EqualError(t, err, "Amount 1234567890123.45 of field '32A' can not be rendered: is longer than 15 characters")
+
+	payment.Attributes.Amount = 100.005
+	_, err = renderMT103(payment, time.Now())
+	EqualError(t, err, "Amount 100.005 of field '32A' can not be rendered: has more than 2 decimals")
+}
+
+func TestFormatSwiftAmountWithMinorUnits(t *testing.T) {
+	for currency, expected := range map[string]string{"JPY": "1500,", "KWD": "1,125", "EUR": "1250,5"} {
+		amount := map[string]float64{"JPY": 1500, "KWD": 1.125, "EUR": 1250.50}[currency]
+		formatted, err := formatSwiftAmount("32A", currency, amount)
+		Nil(t, err)
+		Equal(t, expected, formatted)
+	}
+
+	_, err := formatSwiftAmount("32A", "JPY", 1500.5)
+	IsType(t, &InvalidAmountError{}, err)
+}
+
+// Test MT103 parsing
+
+func TestParseMT103RoundTrip(t *testing.T) {
+	document, _ := ioutil.ReadFile("test_resources/swift/mt103.golden.txt")
+
+	payments, err := parseMT103(strings.NewReader(string(document)))
+	Nil(t, err)
+	Equal(t, 1, len(payments))
+
+	attributes := payments[0].Attributes
+	Equal(t, float64(1250.5), attributes.Amount)
+	Equal(t, "EUR", attributes.Currency)
+	Equal(t, "2019-05-06", attributes.ProcessingDate)
+	Equal(t, "INV-2019/042-000", attributes.PaymentID)
+	Equal(t, swiftPaymentScheme, attributes.PaymentScheme)
+	Equal(t, "SHAR", attributes.ChargesInformation.BearerCode)
+	Equal(t, []SenderCharges{{Amount: 5, Currency: "GBP"}}, attributes.ChargesInformation.SenderCharges)
+	Equal(t, FX{ExchangeRate: 1.16, OriginalAmount: 1078.02, OriginalCurrency: "GBP"}, attributes.FX)
+
+	Equal(t, "Emelia Jane Brown", attributes.DebtorParty.Name)
+	Equal(t, "GB29XABC10161234567801", attributes.DebtorParty.AccountNumber)
+	Equal(t, "IBAN", attributes.DebtorParty.AccountNumberCode)
+	Equal(t, "XABCGB2L", attributes.DebtorParty.BankID)
+
+	Equal(t, "Jurgen Muller + Sohne GmbH", attributes.BeneficiaryParty.Name)
+	Equal(t, "DEUTDEFF500", attributes.BeneficiaryParty.BankID)
+	Equal(t, "SWBIC", attributes.BeneficiaryParty.BankIDCode)
+	True(t, strings.HasPrefix(attributes.Reference, "Invoice 2019/042 for the delivery of piano parts. 50. deposit"))
+}
+
+func TestParseMT103TextBlock(t *testing.T) {
+	document := ":20:REF-1\r\n:23B:CRED\r\n:32A:190506GBP100,21\r\n:50K:/31926819\r\nWilfred Owens\r\n:59:Émilie Brown\r\n10 Debtor Crescent\r\n:70:Piano lessons\r\nMay\r\n:71A:OUR\r\n:71G:GBP1,\r\n-"
+
+	payments, err := parseMT103(strings.NewReader(document))
+	Nil(t, err)
+	Equal(t, 1, len(payments))
+
+	attributes := payments[0].Attributes
+	Equal(t, "REF-1", attributes.PaymentID)
+	Equal(t, float64(100.21), attributes.Amount)
+	Equal(t, "31926819", attributes.DebtorParty.AccountNumber)
+	Equal(t, "BBAN", attributes.DebtorParty.AccountNumberCode)
+	Equal(t, "Emilie Brown", attributes.BeneficiaryParty.Name)
+	Equal(t, "10 Debtor Crescent", attributes.BeneficiaryParty.Address)
+	Equal(t, "Piano lessons May", attributes.Reference)
+	Equal(t, "DEBT", attributes.ChargesInformation.BearerCode)
+	Equal(t, float64(1), attributes.ChargesInformation.Amount)
+}
+
+func TestParseMT103InvalidDocuments(t *testing.T) {
+	documents := []string{
+		"",
+		"unexpected text",
+		":20:REF-1\n:32A:190506GBP100,21\n:50K:Wilfred Owens\n:59:Emilie Brown",
+		":20:REF-1\n:32A:1905GBP100\n:50K:Wilfred Owens\n:59:Emilie Brown\n:71A:SHA",
+		":20:REF-1\n:32A:190506GBP100,21\n:50K:Wilfred Owens\n:59:Emilie Brown\n:71A:ALL",
+		":20:REF-1\n:32A:190506GBP100,21\n:50K:Wilfred Owens\n:59:Emilie Brown\n:71A:SHA\n:33B:USDabc",
+		":20:REF-1\n:32A:190506GBP100,21\n:50K:Wilfred Owens\n:59:Emilie Brown\n:71A:SHA\n:36:0,",
+		":20:REF-1\n:32A:190506GBP100,21\n:50K:Wilfred Owens\n:59:Emilie Brown\n:71A:SHA\n:71F:GBP",
+		":20:REF-1\n:32A:190506GBP100,21\n:50K:Wilfred Owens\n:59:Emilie Brown\n:71A:OUR\n:71G:GBP1,,5"}
+
+	for _, document := range documents {
+		_, err := parseMT103(strings.NewReader(document))
+		IsType(t, &InvalidDocumentError{}, err)
+	}
+
+	_, err := parseMT103(strings.NewReader(":20:REF-1\n:32A:190506GBP100,21\n:50K:Wilfred Owens\n:59:Emilie Brown\n:71A:SHA\n:71F:GBPx"))
+	EqualError(t, err, "Document of format 'mt103' is invalid: invalid amount 'x' of field 71F")
+}
+
+// Test MT103 import and export handlers
+
+func TestImportMT103Successful(t *testing.T) {
+	document, _ := ioutil.ReadFile("test_resources/swift/mt103.golden.txt")
+	body := strings.NewReader(string(document) + string(document))
+
+	response := ServeHTTP(methodPost, preparePaymentImportURL(mt103Format)+"?organisation_id=123", body, successful)
+
+	var result PaymentListResult
+	_ = json.NewDecoder(response.Body).Decode(&result)
+
+	Equal(t, 201, response.Code)
+	Equal(t, 2, len(result.Data))
+	Equal(t, swiftPaymentScheme, result.Data[0].Attributes.PaymentScheme)
+}
+
+func TestExportMT103InvalidPayment(t *testing.T) {
+	response := ServeHTTP(methodGet, preparePaymentURL(exportPaymentPath, "2")+"?format=mt103", http.NoBody, successful)
+
+	Equal(t, 400, response.Code)
+}
+
+// ---------------------------------------------------- //
+
+func MockSwiftPayment(t *testing.T) (payment Payment) {
+	file, err := ioutil.ReadFile("test_resources/swift/swift_payment.json")
+	Nil(t, err)
+	Nil(t, json.Unmarshal(file, &payment))
+	return payment
+}
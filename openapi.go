@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gorilla/mux"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+)
+
+const (
+	openAPIPath        string = "/openapi.json"
+	swaggerUIPath      string = "/docs"
+	swaggerUIAssetPath string = swaggerUIPath + "/{asset}"
+
+	enforceValidation string = "enforce"
+	logValidation     string = "log"
+	noValidation      string = "off"
+)
+
+// The OpenAPI specification of the API, it must describe every route of the routes table and every field of the models
+//
+//go:embed api/openapi.json
+var openAPIDocument []byte
+
+// The Swagger UI page rendering the specification
+//
+//go:embed api/swagger-ui/index.html
+var swaggerUIPage []byte
+
+// The assets of swagger-ui-dist the Swagger UI page loads, they are served by the application rather than a CDN
+//
+//go:embed api/swagger-ui/swagger-ui.css api/swagger-ui/swagger-ui-bundle.js
+var swaggerUIAssets embed.FS
+
+// The validation of the requests and responses against the specification, it is off until the server configures it
+var openAPIValidationMode = noValidation
+
+func setOpenAPIValidation(mode string) {
+	openAPIValidationMode = mode
+}
+
+func init() {
+	// The media types of the request bodies the default decoders of the validation don't read
+	openapi3filter.RegisterBodyDecoder(mergePatchContentType, openapi3filter.JSONBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("application/xml", textBodyDecoder)
+}
+
+func textBodyDecoder(body io.Reader, _ http.Header, _ *openapi3.SchemaRef, _ openapi3filter.EncodingFn) (interface{}, error) {
+	data, err := io.ReadAll(body)
+	return string(data), err
+}
+
+// loadOpenAPISpec parses and validates the embedded specification
+func loadOpenAPISpec() (*openapi3.T, error) {
+	spec, err := openapi3.NewLoader().LoadFromData(openAPIDocument)
+	if err != nil {
+		return nil, err
+	}
+	return spec, spec.Validate(context.Background())
+}
+
+func openAPIEndpoint(writer http.ResponseWriter, _ *http.Request) {
+	prepareSuccessHeader(writer, http.StatusOK)
+	_, _ = writer.Write(openAPIDocument)
+}
+
+func swaggerUIEndpoint(writer http.ResponseWriter, _ *http.Request) {
+	writer.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	writer.WriteHeader(http.StatusOK)
+	_, _ = writer.Write(swaggerUIPage)
+}
+
+func swaggerUIAssetEndpoint(writer http.ResponseWriter, request *http.Request) {
+	assets, _ := fs.Sub(swaggerUIAssets, "api/swagger-ui")
+	http.ServeFileFS(writer, request, assets, mux.Vars(request)["asset"])
+}
+
+// openAPIValidationMiddleware validates the requests and the responses against the specification
+func openAPIValidationMiddleware(mode string) (func(handler http.Handler) http.Handler, error) {
+	spec, err := loadOpenAPISpec()
+	if err != nil {
+		return nil, err
+	}
+
+	specRouter, err := gorillamux.NewRouter(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			route, pathParams, err := specRouter.FindRoute(request)
+			if err != nil {
+				if err != routers.ErrPathNotFound {
+					slog.WarnContext(request.Context(), "Request is not described by the OpenAPI specification", "error", err)
+				}
+				handler.ServeHTTP(writer, request)
+				return
+			}
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    request,
+				PathParams: pathParams,
+				Route:      route,
+				Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc}}
+
+			if err = openapi3filter.ValidateRequest(request.Context(), input); err != nil {
+				if mode == enforceValidation {
+					prepareFailureHeader(writer, request, &InvalidRequestError{err.Error()})
+					return
+				}
+				slog.WarnContext(request.Context(), "Request breaks the OpenAPI specification", "error", err)
+			}
+
+			if !hasJSONResponses(route.Operation) {
+				handler.ServeHTTP(writer, request)
+				return
+			}
+
+			response := newBufferedResponseWriter()
+			handler.ServeHTTP(response, request)
+
+			responseInput := &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: input,
+				Status:                 response.statusCode,
+				Header:                 response.header,
+				Options:                input.Options}
+			responseInput.SetBodyBytes(response.body.Bytes())
+
+			if err = openapi3filter.ValidateResponse(request.Context(), responseInput); err != nil {
+				if mode == enforceValidation {
+					prepareFailureHeader(writer, request, &InvalidResponseError{err.Error()})
+					return
+				}
+				slog.WarnContext(request.Context(), "Response breaks the OpenAPI specification", "error", err)
+			}
+
+			response.writeTo(writer)
+		})
+	}, nil
+}
+
+// hasJSONResponses tells whether every response of the operation which has a body is a json document
+func hasJSONResponses(operation *openapi3.Operation) bool {
+	for _, response := range operation.Responses.Map() {
+		for contentType := range response.Value.Content {
+			if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "application/json" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// A bufferedResponseWriter holds a response back until it is validated
+type bufferedResponseWriter struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{header: make(http.Header), statusCode: http.StatusOK}
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+}
+
+func (w *bufferedResponseWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedResponseWriter) writeTo(writer http.ResponseWriter) {
+	for name, values := range w.header {
+		writer.Header()[name] = values
+	}
+	writer.WriteHeader(w.statusCode)
+	_, _ = writer.Write(w.body.Bytes())
+}
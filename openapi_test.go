@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	. "github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The components of the specification and the models they describe
+var openAPIModels = map[string]reflect.Type{
+	"Payment":                       reflect.TypeOf(Payment{}),
+	"Attributes":                    reflect.TypeOf(Attributes{}),
+	"SponsorParty":                  reflect.TypeOf(SponsorParty{}),
+	"DebtorParty":                   reflect.TypeOf(DebtorParty{}),
+	"BeneficiaryParty":              reflect.TypeOf(BeneficiaryParty{}),
+	"ChargesInformation":            reflect.TypeOf(ChargesInformation{}),
+	"SenderCharges":                 reflect.TypeOf(SenderCharges{}),
+	"FX":                            reflect.TypeOf(FX{}),
+	"Links":                         reflect.TypeOf(Links{}),
+	"PaymentResult":                 reflect.TypeOf(PaymentResult{}),
+	"PaymentListResult":             reflect.TypeOf(PaymentListResult{}),
+	"PaymentVersion":                reflect.TypeOf(PaymentVersion{}),
+	"PaymentVersionListResult":      reflect.TypeOf(PaymentVersionListResult{}),
+	"FieldChange":                   reflect.TypeOf(FieldChange{}),
+	"PaymentDiff":                   reflect.TypeOf(PaymentDiff{}),
+	"PaymentDiffResult":             reflect.TypeOf(PaymentDiffResult{}),
+	"PaymentEvent":                  reflect.TypeOf(PaymentEvent{}),
+	"WebhookSubscription":           reflect.TypeOf(WebhookSubscription{}),
+	"WebhookSubscriptionListResult": reflect.TypeOf(WebhookSubscriptionListResult{}),
+	"WebhookDelivery":               reflect.TypeOf(WebhookDelivery{}),
+	"WebhookDeliveryListResult":     reflect.TypeOf(WebhookDeliveryListResult{}),
+	"FieldError":                    reflect.TypeOf(FieldError{}),
+	"PaymentImportRow":              reflect.TypeOf(PaymentImportRow{}),
+	"PaymentImportReport":           reflect.TypeOf(PaymentImportReport{}),
+	"PaymentOperationBatch":         reflect.TypeOf(PaymentOperationBatch{}),
+	"PaymentOperation":              reflect.TypeOf(PaymentOperation{}),
+	"PaymentOperationResult":        reflect.TypeOf(PaymentOperationResult{}),
+	"PaymentOperationsReport":       reflect.TypeOf(PaymentOperationsReport{}),
+	"JSONPatchOperation":            reflect.TypeOf(jsonPatchOperation{}),
+}
+
+// Test the specification is in line with the routes and the models
+
+func TestOpenAPISpecIsValid(t *testing.T) {
+	_, err := loadOpenAPISpec()
+
+	Nil(t, err)
+}
+
+func TestOpenAPISpecDescribesRoutes(t *testing.T) {
+	spec := LoadOpenAPISpec(t)
+
+	routes = nil
+	initializeRoutes()
+
+	registered := make(map[string]bool)
+	for _, route := range routes {
+		registered[route.Method+" "+route.Path] = true
+	}
+
+	described := make(map[string]bool)
+	for path, item := range spec.Paths.Map() {
+		for method := range item.Operations() {
+			described[method+" "+path] = true
+		}
+	}
+
+	Equal(t, SortedKeys(registered), SortedKeys(described))
+}
+
+func TestOpenAPISpecDescribesModels(t *testing.T) {
+	spec := LoadOpenAPISpec(t)
+
+	Equal(t, SortedKeys(openAPIModels), SortedKeys(spec.Components.Schemas))
+
+	for name, model := range openAPIModels {
+		schema, ok := spec.Components.Schemas[name]
+		if !ok {
+			continue
+		}
+		Equal(t, ModelProperties(model), SchemaProperties(schema.Value), name)
+	}
+}
+
+// Test specification endpoints
+
+func TestGetOpenAPISpec(t *testing.T) {
+	response := ServeHTTP(methodGet, openAPIPath, http.NoBody, successful)
+
+	Equal(t, 200, response.Code)
+	Equal(t, "application/json; charset=UTF-8", response.Header().Get("Content-Type"))
+	Equal(t, openAPIDocument, response.Body.Bytes())
+}
+
+func TestGetSwaggerUI(t *testing.T) {
+	response := ServeHTTP(methodGet, swaggerUIPath, http.NoBody, successful)
+
+	Equal(t, 200, response.Code)
+	Contains(t, response.Body.String(), openAPIPath)
+	NotContains(t, response.Body.String(), "https://")
+
+	response = ServeHTTP(methodGet, swaggerUIPath+"/swagger-ui-bundle.js", http.NoBody, successful)
+	Equal(t, 200, response.Code)
+	Contains(t, response.Header().Get("Content-Type"), "javascript")
+	Contains(t, response.Body.String(), "SwaggerUIBundle")
+
+	response = ServeHTTP(methodGet, swaggerUIPath+"/swagger-ui.css", http.NoBody, successful)
+	Equal(t, 200, response.Code)
+	Contains(t, response.Header().Get("Content-Type"), "text/css")
+
+	Equal(t, 404, ServeHTTP(methodGet, swaggerUIPath+"/swagger-ui.js", http.NoBody, successful).Code)
+}
+
+// Test validation middleware
+
+func TestOpenAPIValidationAcceptsValidExchanges(t *testing.T) {
+	defer setOpenAPIValidation(noValidation)
+	setOpenAPIValidation(enforceValidation)
+
+	Equal(t, 201, ServeJSONHTTP(methodPost, paymentsV2Path, MockPayment("", "123"), successful).Code)
+	Equal(t, 200, ServeJSONHTTP(methodPut, preparePaymentURL(paymentV2Path, "1"), MockPayment("1", "123"), successful).Code)
+	Equal(t, 200, ServeHTTP(methodGet, preparePaymentURL(paymentV2Path, "2"), http.NoBody, successful).Code)
+	Equal(t, 200, ServeHTTP(methodGet, paymentsV2Path, http.NoBody, successful).Code)
+	Equal(t, 200, ServeHTTP(methodGet, preparePaymentURL(getPaymentVersionsPath, "2"), http.NoBody, successful).Code)
+	Equal(t, 200, ServeHTTP(methodGet, preparePaymentURL(getPaymentDiffPath, "2")+"?from=1&to=2", http.NoBody, successful).Code)
+	Equal(t, 404, ServeHTTP(methodGet, preparePaymentURL(paymentV2Path, "2"), http.NoBody, notFound).Code)
+}
+
+func TestOpenAPIValidationRejectsInvalidRequest(t *testing.T) {
+	defer setOpenAPIValidation(noValidation)
+	setOpenAPIValidation(enforceValidation)
+
+	Equal(t, 400, ServeJSONHTTP(methodPost, paymentsV2Path, strings.NewReader(`{"version":"1"}`), successful).Code)
+	Equal(t, 400, ServeHTTP(methodPost, paymentsV2Path, MockPayment("", "123"), successful).Code)
+	Equal(t, 400, ServeHTTP(methodGet, preparePaymentURL(paymentV2Path, "2")+"?version=last", http.NoBody, successful).Code)
+	Equal(t, 400, ServeHTTP(methodGet, preparePaymentURL(paymentDiffV2Path, "2")+"?from=1", http.NoBody, successful).Code)
+}
+
+func TestOpenAPIValidationLogsInvalidRequest(t *testing.T) {
+	defer setOpenAPIValidation(noValidation)
+	setOpenAPIValidation(logValidation)
+
+	// The request without Content-Type header breaks the specification, but the endpoint reads it anyway
+	Equal(t, 201, ServeHTTP(methodPost, paymentsV2Path, MockPayment("", "123"), successful).Code)
+}
+
+func TestOpenAPIValidationOfResponse(t *testing.T) {
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		prepareSuccessHeader(writer, http.StatusOK)
+		_, _ = writer.Write([]byte(`{"data":{"id":"2","version":"2"}}`))
+	})
+
+	enforced, err := openAPIValidationMiddleware(enforceValidation)
+	Nil(t, err)
+	response := ServeMiddlewareHTTP(enforced(handler), preparePaymentURL(paymentV2Path, "2"))
+	Equal(t, 500, response.Code)
+	Empty(t, response.Body.String())
+
+	logged, err := openAPIValidationMiddleware(logValidation)
+	Nil(t, err)
+	response = ServeMiddlewareHTTP(logged(handler), preparePaymentURL(paymentV2Path, "2"))
+	Equal(t, 200, response.Code)
+	Equal(t, `{"data":{"id":"2","version":"2"}}`, response.Body.String())
+}
+
+func TestOpenAPIValidationPassesStreamedResponses(t *testing.T) {
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, flushable := writer.(http.Flusher)
+		writer.Header().Set("Content-Type", "text/event-stream")
+		writer.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(writer, "flushable: %t", flushable)
+	})
+
+	enforced, _ := openAPIValidationMiddleware(enforceValidation)
+	response := ServeMiddlewareHTTP(enforced(handler), streamPaymentsPath)
+
+	Equal(t, 200, response.Code)
+	Equal(t, "flushable: true", response.Body.String())
+}
+
+// ---------------------------------------------------- //
+
+func LoadOpenAPISpec(t *testing.T) *openapi3.T {
+	spec, err := loadOpenAPISpec()
+	Nil(t, err)
+	return spec
+}
+
+func ServeJSONHTTP(method string, url string, body io.Reader, mode string) *httptest.ResponseRecorder {
+	router := MockRouter(mode)
+	request, _ := http.NewRequest(method, url, body)
+	request.Header.Set("Content-Type", "application/json")
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	return response
+}
+
+func ServeMiddlewareHTTP(handler http.Handler, url string) *httptest.ResponseRecorder {
+	request, _ := http.NewRequest(methodGet, url, http.NoBody)
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	return response
+}
+
+// ModelProperties describes the json properties of the model like SchemaProperties describes the ones of a schema,
+// the fields of the embedded structures are the properties of the model
+func ModelProperties(model reflect.Type) map[string]string {
+	properties := make(map[string]string)
+
+	for i := 0; i < model.NumField(); i++ {
+		field := model.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")
+
+		if field.Anonymous {
+			for name, kind := range ModelProperties(field.Type.Elem()) {
+				properties[name] = kind
+			}
+			continue
+		}
+		if tag[0] == "-" || !field.IsExported() {
+			continue
+		}
+
+		name := tag[0]
+		if len(name) == 0 {
+			name = field.Name
+		}
+		if len(tag) > 1 && tag[1] == "string" {
+			properties[name] = "string"
+			continue
+		}
+		properties[name] = TypeDescription(field.Type)
+	}
+	return properties
+}
+
+func TypeDescription(kind reflect.Type) string {
+	switch {
+	case kind == reflect.TypeOf(time.Time{}):
+		return "string:date-time"
+	case kind == reflect.TypeOf(json.RawMessage{}):
+		return "any"
+	}
+
+	switch kind.Kind() {
+	case reflect.Ptr:
+		return TypeDescription(kind.Elem())
+	case reflect.String:
+		return "string"
+	case reflect.Int:
+		return "integer"
+	case reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Interface:
+		return "any"
+	case reflect.Slice:
+		return "array:" + TypeDescription(kind.Elem())
+	case reflect.Struct:
+		for name, model := range openAPIModels {
+			if model == kind {
+				return "ref:" + name
+			}
+		}
+	}
+	return "unknown:" + kind.String()
+}
+
+func SchemaProperties(schema *openapi3.Schema) map[string]string {
+	properties := make(map[string]string)
+	for name, property := range schema.Properties {
+		properties[name] = SchemaDescription(property)
+	}
+	return properties
+}
+
+func SchemaDescription(schema *openapi3.SchemaRef) string {
+	if len(schema.Ref) > 0 {
+		return "ref:" + strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+	}
+	if schema.Value.Type == nil || len(*schema.Value.Type) == 0 {
+		return "any"
+	}
+
+	kind := (*schema.Value.Type)[0]
+	switch {
+	case kind == "array":
+		return "array:" + SchemaDescription(schema.Value.Items)
+	case kind == "string" && schema.Value.Format == "date-time":
+		return "string:date-time"
+	}
+	return kind
+}
+
+func SortedKeys[V any](values map[string]V) (keys []string) {
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
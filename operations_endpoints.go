@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+)
+
+const (
+	createOperation string = "create"
+	updateOperation string = "update"
+	deleteOperation string = "delete"
+
+	atomicBatch    string = "atomic"
+	nonAtomicBatch string = "non_atomic"
+
+	// The largest number of operations accepted in a single batch
+	maxBatchOperations int = 1000
+)
+
+// batchPaymentsEndpoint applies a list of create, update and delete operations and reports the result of every one
+func batchPaymentsEndpoint(writer http.ResponseWriter, request *http.Request) {
+	mode := request.URL.Query().Get("mode")
+	if len(mode) == 0 {
+		mode = nonAtomicBatch
+	}
+	if mode != atomicBatch && mode != nonAtomicBatch {
+		prepareFailureHeader(writer, request, fmt.Errorf("mode must be either '%s' or '%s'", atomicBatch, nonAtomicBatch))
+		return
+	}
+
+	repository := contextPaymentRepository(request.Context())
+	transactional, ok := transactionalPaymentRepository(repository)
+	if mode == atomicBatch && !ok {
+		prepareFailureHeader(writer, request, fmt.Errorf("atomic batches are not supported by the payment repository"))
+		return
+	}
+
+	var batch PaymentOperationBatch
+	err := json.NewDecoder(request.Body).Decode(&batch)
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	if len(batch.Operations) == 0 || len(batch.Operations) > maxBatchOperations {
+		prepareFailureHeader(writer, request, fmt.Errorf("a batch must have from 1 to %d operations", maxBatchOperations))
+		return
+	}
+
+	results := make([]PaymentOperationResult, len(batch.Operations))
+	var invalid error
+	for i, operation := range batch.Operations {
+		results[i] = PaymentOperationResult{Index: i, Op: operation.Op}
+		if err := validatePaymentOperation(operation); err != nil {
+			setOperationFailure(&results[i], err)
+			if invalid == nil {
+				invalid = err
+			}
+		}
+	}
+
+	var statusCode int
+	switch {
+	case mode == nonAtomicBatch:
+		statusCode = applyPaymentOperations(repository, batch.Operations, results)
+	case invalid != nil:
+		skipPaymentOperations(results, "not applied, an operation of the batch is invalid")
+		statusCode = failureStatusCode(invalid)
+	default:
+		statusCode = applyPaymentOperationsAtomically(transactional, batch.Operations, results)
+	}
+
+	prepareSuccessHeader(writer, statusCode)
+
+	links := Links{
+		Self: prepareFullPaymentURL(request.Host, getAllPaymentsPath, "")}
+
+	_ = json.NewEncoder(writer).Encode(PaymentOperationsReport{results, links})
+}
+
+// applyPaymentOperations applies the valid operations one by one
+func applyPaymentOperations(repository PaymentRepository, operations []PaymentOperation, results []PaymentOperationResult) int {
+	statusCode := http.StatusOK
+
+	for i, operation := range operations {
+		if results[i].Status != 0 {
+			statusCode = http.StatusMultiStatus
+			continue
+		}
+
+		payment, eventType, err := applyPaymentOperation(repository, operation)
+		if err != nil {
+			setOperationFailure(&results[i], err)
+			statusCode = http.StatusMultiStatus
+			continue
+		}
+
+		notifyPaymentEvent(eventType, payment)
+		setOperationSuccess(&results[i], payment)
+	}
+	return statusCode
+}
+
+// applyPaymentOperationsAtomically applies the operations in a transaction which stops at the first failed one
+func applyPaymentOperationsAtomically(repository TransactionalPaymentRepository, operations []PaymentOperation, results []PaymentOperationResult) int {
+	var notifications []func()
+	failed := -1
+
+	err := repository.RunInTransaction(func(repository PaymentRepository) error {
+		for i, operation := range operations {
+			payment, eventType, err := applyPaymentOperation(repository, operation)
+			if err != nil {
+				failed = i
+				return err
+			}
+
+			setOperationSuccess(&results[i], payment)
+			notifications = append(notifications, func() { notifyPaymentEvent(eventType, payment) })
+		}
+		return nil
+	})
+
+	if err != nil {
+		// a failed commit is the failure of every operation
+		if failed < 0 {
+			for i := range results {
+				setOperationFailure(&results[i], err)
+			}
+			return failureStatusCode(err)
+		}
+
+		setOperationFailure(&results[failed], err)
+		skipPaymentOperations(results, "not applied, the batch has been rolled back")
+		return failureStatusCode(err)
+	}
+
+	for _, notify := range notifications {
+		notify()
+	}
+	return http.StatusOK
+}
+
+// applyPaymentOperation applies the operation with the repository the same way the endpoint of the operation does
+func applyPaymentOperation(repository PaymentRepository, operation PaymentOperation) (payment Payment, eventType string, err error) {
+	switch operation.Op {
+	case createOperation:
+		payment = *operation.Payment
+		newUUID, _ := uuid.NewUUID()
+		payment.ID = newUUID.String()
+		payment.Version = 1
+		return payment, paymentCreatedEvent, repository.InsertPayment(payment)
+	case updateOperation:
+		payment = *operation.Payment
+		err = repository.UpdatePayment(payment)
+		payment.Version = payment.Version + 1
+		return payment, paymentUpdatedEvent, err
+	default:
+		// The payment is loaded first, so the deletion event carries the organisation and the last state of the payment
+		payment, err = repository.GetPayment(operation.ID)
+		if err != nil {
+			return payment, paymentDeletedEvent, err
+		}
+		err = repository.DeletePayment(operation.ID)
+		payment.Version = payment.Version + 1
+		return payment, paymentDeletedEvent, err
+	}
+}
+
+// validatePaymentOperation checks the operation is known and carries what it needs
+func validatePaymentOperation(operation PaymentOperation) error {
+	switch operation.Op {
+	case createOperation, updateOperation:
+		if operation.Payment == nil {
+			return &InvalidPaymentOperationError{fmt.Sprintf("the %s operation requires a payment", operation.Op)}
+		}
+		return validatePayment(*operation.Payment, operation.Op == createOperation)
+	case deleteOperation:
+		if len(operation.ID) == 0 {
+			return &InvalidPaymentOperationError{"the delete operation requires a payment id"}
+		}
+		return nil
+	default:
+		return &InvalidPaymentOperationError{fmt.Sprintf("unsupported operation '%s'", operation.Op)}
+	}
+}
+
+// applySinglePaymentOperation validates, applies and notifies an operation made on its own
+func applySinglePaymentOperation(repository PaymentRepository, operation PaymentOperation) (payment Payment, err error) {
+	if err = validatePaymentOperation(operation); err != nil {
+		return payment, err
+	}
+
+	payment, eventType, err := applyPaymentOperation(repository, operation)
+	if err != nil {
+		return payment, err
+	}
+
+	notifyPaymentEvent(eventType, payment)
+	return payment, nil
+}
+
+func setOperationSuccess(result *PaymentOperationResult, payment Payment) {
+	result.Status = http.StatusOK
+	if result.Op == createOperation {
+		result.Status = http.StatusCreated
+	}
+	result.ID = payment.ID
+	result.Version = payment.Version
+	result.Error = ""
+}
+
+func setOperationFailure(result *PaymentOperationResult, err error) {
+	result.Status = failureStatusCode(err)
+	result.ID = ""
+	result.Version = 0
+	result.Error = strings.TrimSpace(err.Error())
+}
+
+// skipPaymentOperations reports the operations which have not failed themselves as not applied (424 Failed Dependency)
+func skipPaymentOperations(results []PaymentOperationResult, reason string) {
+	for i := range results {
+		if len(results[i].Error) == 0 {
+			results[i] = PaymentOperationResult{Index: i, Op: results[i].Op, Status: http.StatusFailedDependency, Error: reason}
+		}
+	}
+}
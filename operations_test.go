@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	. "github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TransactionalRepositoryMock keeps the payments in memory and restores them when a transaction fails
+type TransactionalRepositoryMock struct {
+	PaymentRepositoryMock
+	transactions int
+}
+
+func NewTransactionalRepositoryMock() *TransactionalRepositoryMock {
+	repository := &TransactionalRepositoryMock{}
+	repository.payments = []Payment{
+		{ID: "p1", OrganisationID: "123", Version: 1},
+		{ID: "p2", OrganisationID: "123", Version: 1}}
+	return repository
+}
+
+func (m *TransactionalRepositoryMock) RunInTransaction(changes func(repository PaymentRepository) error) (err error) {
+	m.transactions++
+	payments := append([]Payment(nil), m.payments...)
+
+	err = changes(m)
+	if err != nil {
+		m.payments = payments
+	}
+	return err
+}
+
+func (m *TransactionalRepositoryMock) UpdatePayment(payment Payment) (err error) {
+	for i := range m.payments {
+		if m.payments[i].ID != payment.ID {
+			continue
+		}
+		if m.payments[i].Version != payment.Version {
+			return &PaymentVersionConflictError{payment.ID, payment.Version}
+		}
+		payment.Version = payment.Version + 1
+		m.payments[i] = payment
+		return nil
+	}
+	return &PaymentNotFoundError{payment.ID}
+}
+
+func (m *TransactionalRepositoryMock) DeletePayment(paymentID string) (err error) {
+	for i := range m.payments {
+		if m.payments[i].ID == paymentID {
+			m.payments = append(m.payments[:i:i], m.payments[i+1:]...)
+			return nil
+		}
+	}
+	return &PaymentNotFoundError{paymentID}
+}
+
+func (m *TransactionalRepositoryMock) GetPayment(paymentID string) (payment Payment, err error) {
+	for _, payment := range m.payments {
+		if payment.ID == paymentID {
+			return payment, nil
+		}
+	}
+	return payment, &PaymentNotFoundError{paymentID}
+}
+
+// Test batch operations handler
+
+func TestBatchPaymentsNonAtomic(t *testing.T) {
+	repository := NewTransactionalRepositoryMock()
+	operations := []PaymentOperation{
+		{Op: createOperation, Payment: &Payment{OrganisationID: "123"}},
+		{Op: updateOperation, Payment: &Payment{ID: "p1", OrganisationID: "456", Version: 1}},
+		{Op: updateOperation, Payment: &Payment{ID: "p2", OrganisationID: "123", Version: 3}},
+		{Op: deleteOperation, ID: "p3"},
+		{Op: createOperation, Payment: &Payment{}},
+		{Op: "move", ID: "p1"}}
+
+	response := ServeBatchOperationsHTTP("", operations, repository)
+
+	Equal(t, 207, response.Code)
+
+	report := DecodeOperationsReport(t, response)
+	Equal(t, []int{201, 200, 409, 404, 400, 400}, OperationStatuses(report))
+	Equal(t, 2, report.Data[1].Version)
+	Equal(t, "Payment operation is invalid: unsupported operation 'move'", report.Data[5].Error)
+	Equal(t, 0, repository.transactions)
+
+	Equal(t, 3, len(repository.payments))
+	Equal(t, "456", repository.payments[0].OrganisationID)
+	Equal(t, report.Data[0].ID, repository.payments[2].ID)
+}
+
+func TestBatchPaymentsNonAtomicSuccessful(t *testing.T) {
+	repository := NewTransactionalRepositoryMock()
+	operations := []PaymentOperation{
+		{Op: createOperation, Payment: &Payment{OrganisationID: "123"}},
+		{Op: deleteOperation, ID: "p2"}}
+
+	response := ServeBatchOperationsHTTP("?mode=non_atomic", operations, repository)
+
+	Equal(t, 200, response.Code)
+	Equal(t, []int{201, 200}, OperationStatuses(DecodeOperationsReport(t, response)))
+}
+
+func TestBatchPaymentsAtomicSuccessful(t *testing.T) {
+	repository := NewTransactionalRepositoryMock()
+	operations := []PaymentOperation{
+		{Op: createOperation, Payment: &Payment{OrganisationID: "123"}},
+		{Op: updateOperation, Payment: &Payment{ID: "p1", OrganisationID: "123", Version: 1}},
+		{Op: updateOperation, Payment: &Payment{ID: "p1", OrganisationID: "456", Version: 2}},
+		{Op: deleteOperation, ID: "p2"}}
+
+	response := ServeBatchOperationsHTTP("?mode=atomic", operations, repository)
+
+	Equal(t, 200, response.Code)
+
+	report := DecodeOperationsReport(t, response)
+	Equal(t, []int{201, 200, 200, 200}, OperationStatuses(report))
+	Equal(t, 3, report.Data[2].Version)
+	Equal(t, 1, repository.transactions)
+	Equal(t, 2, len(repository.payments))
+}
+
+func TestBatchPaymentsAtomicRolledBack(t *testing.T) {
+	repository := NewTransactionalRepositoryMock()
+	operations := []PaymentOperation{
+		{Op: createOperation, Payment: &Payment{OrganisationID: "123"}},
+		{Op: updateOperation, Payment: &Payment{ID: "p1", OrganisationID: "456", Version: 1}},
+		{Op: updateOperation, Payment: &Payment{ID: "p2", OrganisationID: "123", Version: 3}},
+		{Op: deleteOperation, ID: "p1"}}
+
+	response := ServeBatchOperationsHTTP("?mode=atomic", operations, repository)
+
+	Equal(t, 409, response.Code)
+
+	report := DecodeOperationsReport(t, response)
+	Equal(t, []int{424, 424, 409, 424}, OperationStatuses(report))
+	Empty(t, report.Data[0].ID)
+	Equal(t, NewTransactionalRepositoryMock().payments, repository.payments)
+}
+
+func TestBatchPaymentsAtomicNotFound(t *testing.T) {
+	repository := NewTransactionalRepositoryMock()
+	operations := []PaymentOperation{
+		{Op: deleteOperation, ID: "p1"},
+		{Op: deleteOperation, ID: "p1"}}
+
+	response := ServeBatchOperationsHTTP("?mode=atomic", operations, repository)
+
+	Equal(t, 404, response.Code)
+	Equal(t, []int{424, 404}, OperationStatuses(DecodeOperationsReport(t, response)))
+	Equal(t, 2, len(repository.payments))
+}
+
+func TestBatchPaymentsAtomicInvalid(t *testing.T) {
+	repository := NewTransactionalRepositoryMock()
+	operations := []PaymentOperation{
+		{Op: deleteOperation, ID: "p1"},
+		{Op: updateOperation, Payment: &Payment{OrganisationID: "123"}}}
+
+	response := ServeBatchOperationsHTTP("?mode=atomic", operations, repository)
+
+	Equal(t, 400, response.Code)
+	Equal(t, []int{424, 400}, OperationStatuses(DecodeOperationsReport(t, response)))
+	Equal(t, 0, repository.transactions)
+}
+
+func TestBatchPaymentsServerFailed(t *testing.T) {
+	operations := []PaymentOperation{{Op: createOperation, Payment: &Payment{OrganisationID: "123"}}}
+
+	response := ServeBatchOperationsHTTP("", operations, &PaymentRepositoryMock{mode: dbFailure})
+
+	Equal(t, 207, response.Code)
+	Equal(t, []int{500}, OperationStatuses(DecodeOperationsReport(t, response)))
+}
+
+func TestBatchPaymentsAtomicNotSupported(t *testing.T) {
+	operations := []PaymentOperation{{Op: deleteOperation, ID: "p1"}}
+
+	response := ServeBatchOperationsHTTP("?mode=atomic", operations, &PaymentRepositoryMock{mode: successful})
+
+	Equal(t, 400, response.Code)
+}
+
+func TestBatchPaymentsAtomicWithoutTransactions(t *testing.T) {
+	setPaymentTransactionsSupported(false)
+	defer setPaymentTransactionsSupported(true)
+	repository := NewTransactionalRepositoryMock()
+	operations := []PaymentOperation{{Op: deleteOperation, ID: "p1"}}
+
+	response := ServeBatchOperationsHTTP("?mode=atomic", operations, repository)
+
+	Equal(t, 400, response.Code)
+	Equal(t, 0, repository.transactions)
+}
+
+func TestBatchPaymentsInvalidRequest(t *testing.T) {
+	Equal(t, 400, ServeBatchOperationsHTTP("", nil, NewTransactionalRepositoryMock()).Code)
+	Equal(t, 400, ServeBatchOperationsHTTP("?mode=some", []PaymentOperation{{Op: deleteOperation, ID: "p1"}}, NewTransactionalRepositoryMock()).Code)
+	Equal(t, 400, ServeBatchOperationsHTTP("", make([]PaymentOperation, maxBatchOperations+1), NewTransactionalRepositoryMock()).Code)
+}
+
+// ---------------------------------------------------- //
+
+func ServeBatchOperationsHTTP(query string, operations []PaymentOperation, repository PaymentRepository) *httptest.ResponseRecorder {
+	setPaymentRepository(repository)
+
+	body, _ := json.Marshal(PaymentOperationBatch{operations})
+	request, _ := http.NewRequest(methodPost, batchPaymentsPath+query, bytes.NewReader(body))
+
+	response := httptest.NewRecorder()
+	configureRouter().ServeHTTP(response, request)
+
+	return response
+}
+
+func DecodeOperationsReport(t *testing.T, response *httptest.ResponseRecorder) (report PaymentOperationsReport) {
+	Nil(t, json.NewDecoder(response.Body).Decode(&report))
+	return report
+}
+
+func OperationStatuses(report PaymentOperationsReport) (statuses []int) {
+	for _, result := range report.Data {
+		statuses = append(statuses, result.Status)
+	}
+	return statuses
+}
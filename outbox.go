@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log/slog"
+	"time"
+)
+
+// mongoEventLog is a PaymentEventLog backed by the outbox, which keeps every payment event in the order of event ids
+type mongoEventLog struct {
+	client *mongo.Client
+}
+
+func (m *mongoEventLog) GetPaymentEventsAfter(eventID string, limit int) (events []PaymentEvent, err error) {
+	ctx := getContextWithTimeout()
+
+	filter := bson.M{"_id": bson.M{"$gt": eventID}}
+	findOptions := options.Find().SetSort(bson.M{"_id": 1}).SetLimit(int64(limit))
+
+	cursor, err := getOutboxCollection(m.client).Find(ctx, filter, findOptions)
+	if err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while loading outbox", "error", err)
+		return events, &PersistenceError{}
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+
+	for cursor.Next(ctx) {
+		var event PaymentEvent
+		if err = cursor.Decode(&event); err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while loading outbox", "error", err)
+			return events, &PersistenceError{}
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+const (
+	paymentOutboxCollectionName string = "payment_outbox"
+
+	dispatchedEventRetention time.Duration = 7 * 24 * time.Hour
+
+	changeStreamHistoryLostErrorCode int32 = 286
+)
+
+// runWithOutbox runs the change of a payment and writes its event to the outbox in the same transaction
+func runWithOutbox(ctx context.Context, client *mongo.Client, enabled bool, transaction mongo.SessionContext, change func(ctx context.Context) (PaymentEvent, error)) error {
+	changeWithOutbox := func(ctx context.Context) error {
+		event, err := change(ctx)
+		if err != nil || !enabled {
+			return err
+		}
+
+		_, err = getOutboxCollection(client).InsertOne(ctx, event)
+		if err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while writing to outbox", "error", err)
+			return &PersistenceError{}
+		}
+		return nil
+	}
+
+	if transaction != nil {
+		return changeWithOutbox(transaction)
+	}
+
+	if !enabled {
+		return changeWithOutbox(getContextWithTimeoutFrom(ctx))
+	}
+
+	return runInTransaction(ctx, client, func(sessionContext mongo.SessionContext) error {
+		return changeWithOutbox(sessionContext)
+	})
+}
+
+// runInTransaction runs the changes in a multi-document transaction
+func runInTransaction(parent context.Context, client *mongo.Client, changes func(sessionContext mongo.SessionContext) error) error {
+	ctx := getContextWithTimeoutFrom(parent)
+
+	session, err := client.StartSession()
+	if err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while starting session", "error", err)
+		return &PersistenceError{}
+	}
+	defer session.EndSession(ctx)
+
+	err = session.StartTransaction()
+	if err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while starting transaction", "error", err)
+		return &PersistenceError{}
+	}
+
+	return mongo.WithSession(ctx, session, func(sessionContext mongo.SessionContext) error {
+		err := changes(sessionContext)
+		if err != nil {
+			_ = session.AbortTransaction(sessionContext)
+			return err
+		}
+
+		err = session.CommitTransaction(sessionContext)
+		if err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while committing transaction", "error", err)
+			return &PersistenceError{}
+		}
+		return nil
+	})
+}
+
+// newPaymentEvent creates an event of the given type for the payment
+func newPaymentEvent(eventType string, payment Payment) PaymentEvent {
+	return PaymentEvent{
+		ID:             primitive.NewObjectID().Hex(),
+		Type:           eventType,
+		PaymentID:      payment.ID,
+		OrganisationID: payment.OrganisationID,
+		Version:        payment.Version,
+		OccurredAt:     time.Now().UTC(),
+		Payment:        &payment}
+}
+
+// watchPaymentOutbox publishes the events inserted into the outbox to the payment streams
+func watchPaymentOutbox(client *mongo.Client, hub *paymentEventHub, stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	pipeline := []bson.M{{"$match": bson.M{"operationType": "insert"}}}
+	var resumeToken bson.Raw
+
+	for ctx.Err() == nil {
+		watchOptions := options.ChangeStream()
+		if resumeToken != nil {
+			watchOptions.SetResumeAfter(resumeToken)
+		}
+
+		changeStream, err := getOutboxCollection(client).Watch(ctx, pipeline, watchOptions)
+		if isChangeStreamHistoryLostError(err) {
+			slog.WarnContext(ctx, "Outbox changes can't be resumed, the events inserted since are not published", "error", err)
+			resumeToken = nil
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while watching outbox", "error", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		for changeStream.Next(ctx) {
+			var change struct {
+				ID           bson.Raw     `bson:"_id"`
+				FullDocument PaymentEvent `bson:"fullDocument"`
+			}
+			if err = changeStream.Decode(&change); err != nil {
+				slog.ErrorContext(ctx, "Unexpected error while watching outbox", "error", err)
+				continue
+			}
+			resumeToken = change.ID
+			_ = hub.Publish(change.FullDocument)
+		}
+		_ = changeStream.Close(context.Background())
+	}
+}
+
+// isChangeStreamHistoryLostError tells whether a change stream can't be resumed
+func isChangeStreamHistoryLostError(err error) bool {
+	commandError, ok := err.(mongo.CommandError)
+	return ok && commandError.Code == changeStreamHistoryLostErrorCode
+}
+
+func getOutboxCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database(databaseName).Collection(paymentOutboxCollectionName)
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	mergePatchContentType string = "application/merge-patch+json"
+	jsonPatchContentType  string = "application/json-patch+json"
+
+	mergePatchFormat string = "merge-patch"
+	jsonPatchFormat  string = "json-patch"
+)
+
+// A paymentPatch is a patch document of one of the supported media types
+type paymentPatch struct {
+	apply   func(document interface{}) (interface{}, error)
+	version int
+}
+
+// A jsonPatchOperation is a single operation of a JSON Patch document
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+var jsonPointerEscapes = strings.NewReplacer("~1", "/", "~0", "~")
+
+// decodePaymentPatch reads a JSON Merge Patch or a JSON Patch document depending on the content type
+func decodePaymentPatch(contentType string, body io.Reader) (patch paymentPatch, err error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch mediaType {
+	case mergePatchContentType:
+		var document map[string]interface{}
+		if err = json.NewDecoder(body).Decode(&document); err != nil || document == nil {
+			return patch, &InvalidDocumentError{mergePatchFormat, "the patch of a payment must be an object"}
+		}
+
+		if value, ok := document["version"]; ok {
+			patch.version, err = parsePatchVersion(mergePatchFormat, value)
+		}
+		patch.apply = func(target interface{}) (interface{}, error) {
+			return mergePatch(target, document), nil
+		}
+		return patch, err
+
+	case jsonPatchContentType:
+		var operations []jsonPatchOperation
+		if err = json.NewDecoder(body).Decode(&operations); err != nil {
+			return patch, &InvalidDocumentError{jsonPatchFormat, err.Error()}
+		}
+
+		for _, operation := range operations {
+			if operation.Path == "/version" && (operation.Op == "test" || operation.Op == "replace" || operation.Op == "add") {
+				var value interface{}
+				_ = json.Unmarshal(operation.Value, &value)
+				if patch.version, err = parsePatchVersion(jsonPatchFormat, value); err != nil {
+					return patch, err
+				}
+			}
+		}
+		patch.apply = func(target interface{}) (interface{}, error) {
+			return applyJSONPatch(target, operations)
+		}
+		return patch, nil
+
+	default:
+		return patch, &UnsupportedMediaTypeError{contentType}
+	}
+}
+
+func parsePatchVersion(format string, value interface{}) (int, error) {
+	version, ok := value.(float64)
+	if !ok || version < 1 || version != float64(int(version)) {
+		return 0, &InvalidDocumentError{format, "the version must be a positive integer"}
+	}
+	return int(version), nil
+}
+
+// patchPayment applies the patch to the json document of the payment
+func patchPayment(payment Payment, patch paymentPatch) (patched Payment, err error) {
+	var document interface{}
+	data, _ := json.Marshal(payment)
+	_ = json.Unmarshal(data, &document)
+
+	document, err = patch.apply(document)
+	if err != nil {
+		return patched, err
+	}
+
+	object, ok := document.(map[string]interface{})
+	if !ok {
+		return patched, &InvalidPatchError{"the patched payment must be an object"}
+	}
+	if id, _ := object["id"].(string); id != payment.ID {
+		return patched, &InvalidPatchError{"the id of a payment can't be changed"}
+	}
+	object["version"] = payment.Version
+
+	data, _ = json.Marshal(object)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&patched); err != nil {
+		return patched, &InvalidPatchError{err.Error()}
+	}
+	return patched, nil
+}
+
+// mergePatch applies a JSON Merge Patch to the target
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+	return targetObject
+}
+
+// applyJSONPatch applies the operations of a JSON Patch one after another
+func applyJSONPatch(document interface{}, operations []jsonPatchOperation) (interface{}, error) {
+	for i, operation := range operations {
+		var err error
+		document, err = applyJSONPatchOperation(document, operation)
+		if err != nil {
+			return document, &InvalidPatchError{fmt.Sprintf("operation %d (%s %s) %s", i, operation.Op, operation.Path, err.Error())}
+		}
+	}
+	return document, nil
+}
+
+func applyJSONPatchOperation(document interface{}, operation jsonPatchOperation) (interface{}, error) {
+	path, err := parseJSONPointer(operation.Path)
+	if err != nil {
+		return document, err
+	}
+
+	var value interface{}
+	switch operation.Op {
+	case "add", "replace", "test":
+		if len(operation.Value) == 0 {
+			return document, fmt.Errorf("requires a value")
+		}
+		_ = json.Unmarshal(operation.Value, &value)
+	case "move", "copy":
+		from, err := parseJSONPointer(operation.From)
+		if err != nil {
+			return document, err
+		}
+		if value, err = getJSONValue(document, from); err != nil {
+			return document, err
+		}
+		if operation.Op == "copy" {
+			value = copyJSONValue(value)
+			break
+		}
+		if strings.HasPrefix(operation.Path+"/", operation.From+"/") {
+			return document, fmt.Errorf("can't move a value into itself")
+		}
+		if document, err = removeJSONValue(document, from); err != nil {
+			return document, err
+		}
+	}
+
+	switch operation.Op {
+	case "add", "move", "copy":
+		return addJSONValue(document, path, value)
+	case "remove":
+		return removeJSONValue(document, path)
+	case "replace":
+		if len(path) == 0 {
+			return value, nil
+		}
+		if document, err = removeJSONValue(document, path); err != nil {
+			return document, err
+		}
+		return addJSONValue(document, path, value)
+	case "test":
+		current, err := getJSONValue(document, path)
+		if err != nil {
+			return document, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return document, fmt.Errorf("failed, the value is %s", formatJSONValue(current))
+		}
+		return document, nil
+	default:
+		return document, fmt.Errorf("is not supported")
+	}
+}
+
+// parseJSONPointer splits a JSON Pointer (RFC 6901) into its reference tokens, the empty pointer refers to the whole document
+func parseJSONPointer(pointer string) (tokens []string, err error) {
+	if len(pointer) == 0 {
+		return tokens, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return tokens, fmt.Errorf("has an invalid pointer '%s'", pointer)
+	}
+
+	for _, token := range strings.Split(pointer[1:], "/") {
+		tokens = append(tokens, jsonPointerEscapes.Replace(token))
+	}
+	return tokens, nil
+}
+
+func getJSONValue(document interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := document.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("refers to a missing member '%s'", token)
+			}
+			document = value
+		case []interface{}:
+			index, err := parseJSONArrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			document = node[index]
+		default:
+			return nil, fmt.Errorf("refers to a member '%s' of a value which is not a container", token)
+		}
+	}
+	return document, nil
+}
+
+func addJSONValue(document interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return changeJSONContainer(document, path, func(container interface{}, token string) (interface{}, error) {
+		switch node := container.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			index := len(node)
+			if token != "-" {
+				var err error
+				if index, err = parseJSONArrayIndex(token, len(node)); err != nil {
+					return node, err
+				}
+			}
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		default:
+			return container, fmt.Errorf("refers to a member '%s' of a value which is not a container", token)
+		}
+	})
+}
+
+func removeJSONValue(document interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return document, fmt.Errorf("can't remove the whole document")
+	}
+
+	return changeJSONContainer(document, path, func(container interface{}, token string) (interface{}, error) {
+		switch node := container.(type) {
+		case map[string]interface{}:
+			if _, ok := node[token]; !ok {
+				return node, fmt.Errorf("refers to a missing member '%s'", token)
+			}
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			index, err := parseJSONArrayIndex(token, len(node)-1)
+			if err != nil {
+				return node, err
+			}
+			return append(node[:index], node[index+1:]...), nil
+		default:
+			return container, fmt.Errorf("refers to a member '%s' of a value which is not a container", token)
+		}
+	})
+}
+
+// changeJSONContainer replaces the container of the last token of the path by the result of the change
+func changeJSONContainer(document interface{}, path []string, change func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return change(document, path[0])
+	}
+
+	switch node := document.(type) {
+	case map[string]interface{}:
+		child, ok := node[path[0]]
+		if !ok {
+			return document, fmt.Errorf("refers to a missing member '%s'", path[0])
+		}
+		child, err := changeJSONContainer(child, path[1:], change)
+		if err != nil {
+			return document, err
+		}
+		node[path[0]] = child
+		return node, nil
+	case []interface{}:
+		index, err := parseJSONArrayIndex(path[0], len(node)-1)
+		if err != nil {
+			return document, err
+		}
+		child, err := changeJSONContainer(node[index], path[1:], change)
+		if err != nil {
+			return document, err
+		}
+		node[index] = child
+		return node, nil
+	default:
+		return document, fmt.Errorf("refers to a member '%s' of a value which is not a container", path[0])
+	}
+}
+
+func parseJSONArrayIndex(token string, maxIndex int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > maxIndex || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("refers to an invalid array index '%s'", token)
+	}
+	return index, nil
+}
+
+func copyJSONValue(value interface{}) (copied interface{}) {
+	data, _ := json.Marshal(value)
+	_ = json.Unmarshal(data, &copied)
+	return copied
+}
+
+func formatJSONValue(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
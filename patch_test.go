@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	. "github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test patch documents
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct{ target, patch, result string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, test := range tests {
+		result := mergePatch(DecodeJSONValue(t, test.target), DecodeJSONValue(t, test.patch))
+		Equal(t, DecodeJSONValue(t, test.result), result, test.patch)
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	tests := []struct{ document, patch, result string }{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc"]}]`, `{"foo":["bar",["abc"]]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":null}]`, `{"baz":null,"foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"foo":{"bar":1}}`, `[{"op":"copy","from":"/foo","path":"/baz"},{"op":"replace","path":"/baz/bar","value":2}]`, `{"foo":{"bar":1},"baz":{"bar":2}}`},
+		{`{"a/b":1,"m~n":2}`, `[{"op":"test","path":"/a~1b","value":1},{"op":"remove","path":"/m~0n"}]`, `{"a/b":1}`},
+		{`{"foo":1}`, `[{"op":"replace","path":"","value":{"bar":2}}]`, `{"bar":2}`},
+	}
+
+	for _, test := range tests {
+		var operations []jsonPatchOperation
+		Nil(t, json.Unmarshal([]byte(test.patch), &operations))
+
+		result, err := applyJSONPatch(DecodeJSONValue(t, test.document), operations)
+
+		Nil(t, err, test.patch)
+		Equal(t, DecodeJSONValue(t, test.result), result, test.patch)
+	}
+}
+
+func TestApplyJSONPatchFailures(t *testing.T) {
+	patches := []string{
+		`[{"op":"test","path":"/foo","value":"baz"}]`,
+		`[{"op":"remove","path":"/qux"}]`,
+		`[{"op":"replace","path":"/qux","value":1}]`,
+		`[{"op":"add","path":"/bar/5","value":1}]`,
+		`[{"op":"add","path":"/bar/01","value":1}]`,
+		`[{"op":"add","path":"/qux/foo","value":1}]`,
+		`[{"op":"add","path":"/baz"}]`,
+		`[{"op":"move","from":"/bar","path":"/bar/0"}]`,
+		`[{"op":"remove","path":""}]`,
+		`[{"op":"add","path":"foo","value":1}]`,
+		`[{"op":"merge","path":"/foo","value":1}]`,
+	}
+
+	for _, patch := range patches {
+		var operations []jsonPatchOperation
+		Nil(t, json.Unmarshal([]byte(patch), &operations))
+
+		_, err := applyJSONPatch(DecodeJSONValue(t, `{"foo":"bar","bar":[1]}`), operations)
+
+		IsType(t, &InvalidPatchError{}, err, patch)
+	}
+}
+
+func TestDecodePaymentPatchVersion(t *testing.T) {
+	patch, err := decodePaymentPatch(mergePatchContentType, strings.NewReader(`{"version":3,"attributes":{"reference":null}}`))
+	Nil(t, err)
+	Equal(t, 3, patch.version)
+
+	patch, err = decodePaymentPatch(jsonPatchContentType+"; charset=UTF-8", strings.NewReader(`[{"op":"test","path":"/version","value":2}]`))
+	Nil(t, err)
+	Equal(t, 2, patch.version)
+
+	_, err = decodePaymentPatch(mergePatchContentType, strings.NewReader(`{"version":"3"}`))
+	IsType(t, &InvalidDocumentError{}, err)
+
+	_, err = decodePaymentPatch(mergePatchContentType, strings.NewReader(`[]`))
+	IsType(t, &InvalidDocumentError{}, err)
+
+	_, err = decodePaymentPatch("application/json", strings.NewReader(`{}`))
+	IsType(t, &UnsupportedMediaTypeError{}, err)
+}
+
+func TestPatchPaymentKeepsIDAndVersion(t *testing.T) {
+	payment := Payment{ID: "1", Version: 2, OrganisationID: "123"}
+
+	patch, _ := decodePaymentPatch(mergePatchContentType, strings.NewReader(`{"version":2,"organisation_id":"456"}`))
+	patched, err := patchPayment(payment, patch)
+	Nil(t, err)
+	Equal(t, Payment{ID: "1", Version: 2, OrganisationID: "456"}, patched)
+
+	patch, _ = decodePaymentPatch(mergePatchContentType, strings.NewReader(`{"id":"2"}`))
+	_, err = patchPayment(payment, patch)
+	IsType(t, &InvalidPatchError{}, err)
+
+	patch, _ = decodePaymentPatch(mergePatchContentType, strings.NewReader(`{"colour":"red"}`))
+	_, err = patchPayment(payment, patch)
+	IsType(t, &InvalidPatchError{}, err)
+}
+
+// Test stored document changes
+
+func TestDiffPaymentDocuments(t *testing.T) {
+	current := Payment{ID: "1", Version: 1, OrganisationID: "123", Attributes: Attributes{
+		Amount:      10,
+		Reference:   "Rent",
+		DebtorParty: DebtorParty{Name: "Emelia", SponsorParty: &SponsorParty{AccountNumber: "71268996", BankID: "203301"}}}}
+
+	patched := current
+	patched.Version = 2
+	patched.Attributes.Amount = 20.5
+	patched.Attributes.Reference = ""
+	patched.Attributes.DebtorParty.SponsorParty = &SponsorParty{AccountNumber: "31926819", BankID: "203301"}
+
+	set, unset, err := diffPaymentDocuments(current, patched)
+
+	Nil(t, err)
+	Equal(t, bson.M{"version": int32(2), "attributes.amount": 20.5, "attributes.debtor_party.sponsorparty.account_number": "31926819"}, set)
+	Equal(t, bson.M{"attributes.reference": ""}, unset)
+}
+
+// Test payment patch handler
+
+func TestPatchPaymentMerge(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful}
+
+	response := ServePatchHTTP("1", mergePatchContentType, "", `{"version":1,"attributes":{"currency":"EUR","amount":null}}`, repository)
+
+	Equal(t, 200, response.Code)
+	Equal(t, `"2"`, response.Header().Get("ETag"))
+	Contains(t, response.Header().Get("Location"), "/v1/payments/get/1")
+
+	Equal(t, 1, len(repository.payments))
+	Equal(t, Payment{ID: "1", OrganisationID: "123", Version: 1, Attributes: Attributes{Currency: "EUR"}}, repository.payments[0])
+
+	var result PaymentResult
+	Nil(t, json.NewDecoder(response.Body).Decode(&result))
+	Equal(t, 2, result.Data.Version)
+}
+
+func TestPatchPaymentJSONPatchWithIfMatch(t *testing.T) {
+	repository := &PaymentRepositoryMock{mode: successful}
+	patch := `[{"op":"replace","path":"/attributes/currency","value":"EUR"},{"op":"add","path":"/attributes/reference","value":"Rent"}]`
+
+	response := ServePatchHTTP("1", jsonPatchContentType, `W/"1"`, patch, repository)
+
+	Equal(t, 200, response.Code)
+	Equal(t, "EUR", repository.payments[0].Attributes.Currency)
+	Equal(t, "Rent", repository.payments[0].Attributes.Reference)
+	Equal(t, 10.0, repository.payments[0].Attributes.Amount)
+}
+
+func TestPatchPaymentVersionRequired(t *testing.T) {
+	response := ServePatchHTTP("1", mergePatchContentType, "", `{"attributes":{"currency":"EUR"}}`, &PaymentRepositoryMock{mode: successful})
+
+	Equal(t, 428, response.Code)
+}
+
+func TestPatchPaymentVersionConflict(t *testing.T) {
+	Equal(t, 409, ServePatchHTTP("1", mergePatchContentType, `"2"`, `{}`, &PaymentRepositoryMock{mode: successful}).Code)
+	Equal(t, 409, ServePatchHTTP("1", mergePatchContentType, `"1"`, `{}`, &PaymentRepositoryMock{mode: versionConflict}).Code)
+	Equal(t, 400, ServePatchHTTP("1", mergePatchContentType, `"1"`, `{"version":2}`, &PaymentRepositoryMock{mode: successful}).Code)
+}
+
+func TestPatchPaymentTestFailed(t *testing.T) {
+	patch := `[{"op":"test","path":"/attributes/currency","value":"EUR"},{"op":"remove","path":"/attributes/amount"}]`
+
+	response := ServePatchHTTP("1", jsonPatchContentType, `"1"`, patch, &PaymentRepositoryMock{mode: successful})
+
+	Equal(t, 422, response.Code)
+}
+
+func TestPatchPaymentInvalidResult(t *testing.T) {
+	response := ServePatchHTTP("1", mergePatchContentType, `"1"`, `{"organisation_id":null}`, &PaymentRepositoryMock{mode: successful})
+
+	Equal(t, 400, response.Code)
+}
+
+func TestPatchPaymentUnsupportedMediaType(t *testing.T) {
+	response := ServePatchHTTP("1", "application/json", `"1"`, `{}`, &PaymentRepositoryMock{mode: successful})
+
+	Equal(t, 415, response.Code)
+}
+
+func TestPatchPaymentNotFound(t *testing.T) {
+	response := ServePatchHTTP("1", mergePatchContentType, `"1"`, `{}`, &PaymentRepositoryMock{mode: notFound})
+
+	Equal(t, 404, response.Code)
+}
+
+func TestGetPaymentETag(t *testing.T) {
+	response := ServeHTTP(methodGet, preparePaymentURL(getPaymentPath, "1"), http.NoBody, successful)
+
+	Equal(t, `"1"`, response.Header().Get("ETag"))
+}
+
+// ---------------------------------------------------- //
+
+func ServePatchHTTP(paymentID string, contentType string, ifMatch string, patch string, repository PaymentRepository) *httptest.ResponseRecorder {
+	setPaymentRepository(repository)
+
+	request, _ := http.NewRequest(methodPatch, preparePaymentURL(patchPaymentPath, paymentID), strings.NewReader(patch))
+	request.Header.Set("Content-Type", contentType)
+	if len(ifMatch) > 0 {
+		request.Header.Set("If-Match", ifMatch)
+	}
+
+	response := httptest.NewRecorder()
+	configureRouter().ServeHTTP(response, request)
+
+	return response
+}
+
+func DecodeJSONValue(t *testing.T, document string) (value interface{}) {
+	Nil(t, json.Unmarshal([]byte(document), &value))
+	return value
+}
@@ -0,0 +1,1207 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: payments.proto
+
+package paymentspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Payment struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Type           string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Id             string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Version        int32                  `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	OrganisationId string                 `protobuf:"bytes,4,opt,name=organisation_id,json=organisationId,proto3" json:"organisation_id,omitempty"`
+	Attributes     *Attributes            `protobuf:"bytes,5,opt,name=attributes,proto3" json:"attributes,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Payment) Reset() {
+	*x = Payment{}
+	mi := &file_payments_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Payment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Payment) ProtoMessage() {}
+
+func (x *Payment) ProtoReflect() protoreflect.Message {
+	mi := &file_payments_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Payment.ProtoReflect.Descriptor instead.
+func (*Payment) Descriptor() ([]byte, []int) {
+	return file_payments_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Payment) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Payment) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Payment) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Payment) GetOrganisationId() string {
+	if x != nil {
+		return x.OrganisationId
+	}
+	return ""
+}
+
+func (x *Payment) GetAttributes() *Attributes {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+type Attributes struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	Amount               float64                `protobuf:"fixed64,1,opt,name=amount,proto3" json:"amount,omitempty"`
+	BeneficiaryParty     *BeneficiaryParty      `protobuf:"bytes,2,opt,name=beneficiary_party,json=beneficiaryParty,proto3" json:"beneficiary_party,omitempty"`
+	ChargesInformation   *ChargesInformation    `protobuf:"bytes,3,opt,name=charges_information,json=chargesInformation,proto3" json:"charges_information,omitempty"`
+	Currency             string                 `protobuf:"bytes,4,opt,name=currency,proto3" json:"currency,omitempty"`
+	DebtorParty          *DebtorParty           `protobuf:"bytes,5,opt,name=debtor_party,json=debtorParty,proto3" json:"debtor_party,omitempty"`
+	EndToEndReference    string                 `protobuf:"bytes,6,opt,name=end_to_end_reference,json=endToEndReference,proto3" json:"end_to_end_reference,omitempty"`
+	Fx                   *FX                    `protobuf:"bytes,7,opt,name=fx,proto3" json:"fx,omitempty"`
+	NumericReference     int64                  `protobuf:"varint,8,opt,name=numeric_reference,json=numericReference,proto3" json:"numeric_reference,omitempty"`
+	PaymentId            string                 `protobuf:"bytes,9,opt,name=payment_id,json=paymentId,proto3" json:"payment_id,omitempty"`
+	PaymentPurpose       string                 `protobuf:"bytes,10,opt,name=payment_purpose,json=paymentPurpose,proto3" json:"payment_purpose,omitempty"`
+	PaymentScheme        string                 `protobuf:"bytes,11,opt,name=payment_scheme,json=paymentScheme,proto3" json:"payment_scheme,omitempty"`
+	PaymentType          string                 `protobuf:"bytes,12,opt,name=payment_type,json=paymentType,proto3" json:"payment_type,omitempty"`
+	ProcessingDate       string                 `protobuf:"bytes,13,opt,name=processing_date,json=processingDate,proto3" json:"processing_date,omitempty"`
+	Reference            string                 `protobuf:"bytes,14,opt,name=reference,proto3" json:"reference,omitempty"`
+	SchemePaymentSubType string                 `protobuf:"bytes,15,opt,name=scheme_payment_sub_type,json=schemePaymentSubType,proto3" json:"scheme_payment_sub_type,omitempty"`
+	SchemePaymentType    string                 `protobuf:"bytes,16,opt,name=scheme_payment_type,json=schemePaymentType,proto3" json:"scheme_payment_type,omitempty"`
+	SponsorParty         *SponsorParty          `protobuf:"bytes,17,opt,name=sponsor_party,json=sponsorParty,proto3" json:"sponsor_party,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *Attributes) Reset() {
+	*x = Attributes{}
+	mi := &file_payments_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Attributes) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Attributes) ProtoMessage() {}
+
+func (x *Attributes) ProtoReflect() protoreflect.Message {
+	mi := &file_payments_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Attributes.ProtoReflect.Descriptor instead.
+func (*Attributes) Descriptor() ([]byte, []int) {
+	return file_payments_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Attributes) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *Attributes) GetBeneficiaryParty() *BeneficiaryParty {
+	if x != nil {
+		return x.BeneficiaryParty
+	}
+	return nil
+}
+
+func (x *Attributes) GetChargesInformation() *ChargesInformation {
+	if x != nil {
+		return x.ChargesInformation
+	}
+	return nil
+}
+
+func (x *Attributes) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *Attributes) GetDebtorParty() *DebtorParty {
+	if x != nil {
+		return x.DebtorParty
+	}
+	return nil
+}
+
+func (x *Attributes) GetEndToEndReference() string {
+	if x != nil {
+		return x.EndToEndReference
+	}
+	return ""
+}
+
+func (x *Attributes) GetFx() *FX {
+	if x != nil {
+		return x.Fx
+	}
+	return nil
+}
+
+func (x *Attributes) GetNumericReference() int64 {
+	if x != nil {
+		return x.NumericReference
+	}
+	return 0
+}
+
+func (x *Attributes) GetPaymentId() string {
+	if x != nil {
+		return x.PaymentId
+	}
+	return ""
+}
+
+func (x *Attributes) GetPaymentPurpose() string {
+	if x != nil {
+		return x.PaymentPurpose
+	}
+	return ""
+}
+
+func (x *Attributes) GetPaymentScheme() string {
+	if x != nil {
+		return x.PaymentScheme
+	}
+	return ""
+}
+
+func (x *Attributes) GetPaymentType() string {
+	if x != nil {
+		return x.PaymentType
+	}
+	return ""
+}
+
+func (x *Attributes) GetProcessingDate() string {
+	if x != nil {
+		return x.ProcessingDate
+	}
+	return ""
+}
+
+func (x *Attributes) GetReference() string {
+	if x != nil {
+		return x.Reference
+	}
+	return ""
+}
+
+func (x *Attributes) GetSchemePaymentSubType() string {
+	if x != nil {
+		return x.SchemePaymentSubType
+	}
+	return ""
+}
+
+func (x *Attributes) GetSchemePaymentType() string {
+	if x != nil {
+		return x.SchemePaymentType
+	}
+	return ""
+}
+
+func (x *Attributes) GetSponsorParty() *SponsorParty {
+	if x != nil {
+		return x.SponsorParty
+	}
+	return nil
+}
+
+type SponsorParty struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AccountNumber string                 `protobuf:"bytes,1,opt,name=account_number,json=accountNumber,proto3" json:"account_number,omitempty"`
+	BankId        string                 `protobuf:"bytes,2,opt,name=bank_id,json=bankId,proto3" json:"bank_id,omitempty"`
+	BankIdCode    string                 `protobuf:"bytes,3,opt,name=bank_id_code,json=bankIdCode,proto3" json:"bank_id_code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SponsorParty) Reset() {
+	*x = SponsorParty{}
+	mi := &file_payments_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SponsorParty) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SponsorParty) ProtoMessage() {}
+
+func (x *SponsorParty) ProtoReflect() protoreflect.Message {
+	mi := &file_payments_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SponsorParty.ProtoReflect.Descriptor instead.
+func (*SponsorParty) Descriptor() ([]byte, []int) {
+	return file_payments_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SponsorParty) GetAccountNumber() string {
+	if x != nil {
+		return x.AccountNumber
+	}
+	return ""
+}
+
+func (x *SponsorParty) GetBankId() string {
+	if x != nil {
+		return x.BankId
+	}
+	return ""
+}
+
+func (x *SponsorParty) GetBankIdCode() string {
+	if x != nil {
+		return x.BankIdCode
+	}
+	return ""
+}
+
+type DebtorParty struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	AccountNumber     string                 `protobuf:"bytes,1,opt,name=account_number,json=accountNumber,proto3" json:"account_number,omitempty"`
+	BankId            string                 `protobuf:"bytes,2,opt,name=bank_id,json=bankId,proto3" json:"bank_id,omitempty"`
+	BankIdCode        string                 `protobuf:"bytes,3,opt,name=bank_id_code,json=bankIdCode,proto3" json:"bank_id_code,omitempty"`
+	AccountName       string                 `protobuf:"bytes,4,opt,name=account_name,json=accountName,proto3" json:"account_name,omitempty"`
+	AccountNumberCode string                 `protobuf:"bytes,5,opt,name=account_number_code,json=accountNumberCode,proto3" json:"account_number_code,omitempty"`
+	Address           string                 `protobuf:"bytes,6,opt,name=address,proto3" json:"address,omitempty"`
+	Name              string                 `protobuf:"bytes,7,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *DebtorParty) Reset() {
+	*x = DebtorParty{}
+	mi := &file_payments_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DebtorParty) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DebtorParty) ProtoMessage() {}
+
+func (x *DebtorParty) ProtoReflect() protoreflect.Message {
+	mi := &file_payments_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DebtorParty.ProtoReflect.Descriptor instead.
+func (*DebtorParty) Descriptor() ([]byte, []int) {
+	return file_payments_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *DebtorParty) GetAccountNumber() string {
+	if x != nil {
+		return x.AccountNumber
+	}
+	return ""
+}
+
+func (x *DebtorParty) GetBankId() string {
+	if x != nil {
+		return x.BankId
+	}
+	return ""
+}
+
+func (x *DebtorParty) GetBankIdCode() string {
+	if x != nil {
+		return x.BankIdCode
+	}
+	return ""
+}
+
+func (x *DebtorParty) GetAccountName() string {
+	if x != nil {
+		return x.AccountName
+	}
+	return ""
+}
+
+func (x *DebtorParty) GetAccountNumberCode() string {
+	if x != nil {
+		return x.AccountNumberCode
+	}
+	return ""
+}
+
+func (x *DebtorParty) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *DebtorParty) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type BeneficiaryParty struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	AccountNumber     string                 `protobuf:"bytes,1,opt,name=account_number,json=accountNumber,proto3" json:"account_number,omitempty"`
+	BankId            string                 `protobuf:"bytes,2,opt,name=bank_id,json=bankId,proto3" json:"bank_id,omitempty"`
+	BankIdCode        string                 `protobuf:"bytes,3,opt,name=bank_id_code,json=bankIdCode,proto3" json:"bank_id_code,omitempty"`
+	AccountName       string                 `protobuf:"bytes,4,opt,name=account_name,json=accountName,proto3" json:"account_name,omitempty"`
+	AccountNumberCode string                 `protobuf:"bytes,5,opt,name=account_number_code,json=accountNumberCode,proto3" json:"account_number_code,omitempty"`
+	Address           string                 `protobuf:"bytes,6,opt,name=address,proto3" json:"address,omitempty"`
+	Name              string                 `protobuf:"bytes,7,opt,name=name,proto3" json:"name,omitempty"`
+	AccountType       int32                  `protobuf:"varint,8,opt,name=account_type,json=accountType,proto3" json:"account_type,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *BeneficiaryParty) Reset() {
+	*x = BeneficiaryParty{}
+	mi := &file_payments_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BeneficiaryParty) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BeneficiaryParty) ProtoMessage() {}
+
+func (x *BeneficiaryParty) ProtoReflect() protoreflect.Message {
+	mi := &file_payments_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BeneficiaryParty.ProtoReflect.Descriptor instead.
+func (*BeneficiaryParty) Descriptor() ([]byte, []int) {
+	return file_payments_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BeneficiaryParty) GetAccountNumber() string {
+	if x != nil {
+		return x.AccountNumber
+	}
+	return ""
+}
+
+func (x *BeneficiaryParty) GetBankId() string {
+	if x != nil {
+		return x.BankId
+	}
+	return ""
+}
+
+func (x *BeneficiaryParty) GetBankIdCode() string {
+	if x != nil {
+		return x.BankIdCode
+	}
+	return ""
+}
+
+func (x *BeneficiaryParty) GetAccountName() string {
+	if x != nil {
+		return x.AccountName
+	}
+	return ""
+}
+
+func (x *BeneficiaryParty) GetAccountNumberCode() string {
+	if x != nil {
+		return x.AccountNumberCode
+	}
+	return ""
+}
+
+func (x *BeneficiaryParty) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *BeneficiaryParty) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *BeneficiaryParty) GetAccountType() int32 {
+	if x != nil {
+		return x.AccountType
+	}
+	return 0
+}
+
+type ChargesInformation struct {
+	state                   protoimpl.MessageState `protogen:"open.v1"`
+	BearerCode              string                 `protobuf:"bytes,1,opt,name=bearer_code,json=bearerCode,proto3" json:"bearer_code,omitempty"`
+	SenderCharges           []*SenderCharges       `protobuf:"bytes,2,rep,name=sender_charges,json=senderCharges,proto3" json:"sender_charges,omitempty"`
+	ReceiverChargesAmount   float64                `protobuf:"fixed64,3,opt,name=receiver_charges_amount,json=receiverChargesAmount,proto3" json:"receiver_charges_amount,omitempty"`
+	ReceiverChargesCurrency string                 `protobuf:"bytes,4,opt,name=receiver_charges_currency,json=receiverChargesCurrency,proto3" json:"receiver_charges_currency,omitempty"`
+	unknownFields           protoimpl.UnknownFields
+	sizeCache               protoimpl.SizeCache
+}
+
+func (x *ChargesInformation) Reset() {
+	*x = ChargesInformation{}
+	mi := &file_payments_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChargesInformation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChargesInformation) ProtoMessage() {}
+
+func (x *ChargesInformation) ProtoReflect() protoreflect.Message {
+	mi := &file_payments_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChargesInformation.ProtoReflect.Descriptor instead.
+func (*ChargesInformation) Descriptor() ([]byte, []int) {
+	return file_payments_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ChargesInformation) GetBearerCode() string {
+	if x != nil {
+		return x.BearerCode
+	}
+	return ""
+}
+
+func (x *ChargesInformation) GetSenderCharges() []*SenderCharges {
+	if x != nil {
+		return x.SenderCharges
+	}
+	return nil
+}
+
+func (x *ChargesInformation) GetReceiverChargesAmount() float64 {
+	if x != nil {
+		return x.ReceiverChargesAmount
+	}
+	return 0
+}
+
+func (x *ChargesInformation) GetReceiverChargesCurrency() string {
+	if x != nil {
+		return x.ReceiverChargesCurrency
+	}
+	return ""
+}
+
+type SenderCharges struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Amount        float64                `protobuf:"fixed64,1,opt,name=amount,proto3" json:"amount,omitempty"`
+	Currency      string                 `protobuf:"bytes,2,opt,name=currency,proto3" json:"currency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SenderCharges) Reset() {
+	*x = SenderCharges{}
+	mi := &file_payments_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SenderCharges) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SenderCharges) ProtoMessage() {}
+
+func (x *SenderCharges) ProtoReflect() protoreflect.Message {
+	mi := &file_payments_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SenderCharges.ProtoReflect.Descriptor instead.
+func (*SenderCharges) Descriptor() ([]byte, []int) {
+	return file_payments_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SenderCharges) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *SenderCharges) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+type FX struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	ContractReference string                 `protobuf:"bytes,1,opt,name=contract_reference,json=contractReference,proto3" json:"contract_reference,omitempty"`
+	ExchangeRate      float64                `protobuf:"fixed64,2,opt,name=exchange_rate,json=exchangeRate,proto3" json:"exchange_rate,omitempty"`
+	OriginalAmount    float64                `protobuf:"fixed64,3,opt,name=original_amount,json=originalAmount,proto3" json:"original_amount,omitempty"`
+	OriginalCurrency  string                 `protobuf:"bytes,4,opt,name=original_currency,json=originalCurrency,proto3" json:"original_currency,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *FX) Reset() {
+	*x = FX{}
+	mi := &file_payments_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FX) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FX) ProtoMessage() {}
+
+func (x *FX) ProtoReflect() protoreflect.Message {
+	mi := &file_payments_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FX.ProtoReflect.Descriptor instead.
+func (*FX) Descriptor() ([]byte, []int) {
+	return file_payments_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *FX) GetContractReference() string {
+	if x != nil {
+		return x.ContractReference
+	}
+	return ""
+}
+
+func (x *FX) GetExchangeRate() float64 {
+	if x != nil {
+		return x.ExchangeRate
+	}
+	return 0
+}
+
+func (x *FX) GetOriginalAmount() float64 {
+	if x != nil {
+		return x.OriginalAmount
+	}
+	return 0
+}
+
+func (x *FX) GetOriginalCurrency() string {
+	if x != nil {
+		return x.OriginalCurrency
+	}
+	return ""
+}
+
+type CreatePaymentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Payment       *Payment               `protobuf:"bytes,1,opt,name=payment,proto3" json:"payment,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreatePaymentRequest) Reset() {
+	*x = CreatePaymentRequest{}
+	mi := &file_payments_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreatePaymentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreatePaymentRequest) ProtoMessage() {}
+
+func (x *CreatePaymentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_payments_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreatePaymentRequest.ProtoReflect.Descriptor instead.
+func (*CreatePaymentRequest) Descriptor() ([]byte, []int) {
+	return file_payments_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *CreatePaymentRequest) GetPayment() *Payment {
+	if x != nil {
+		return x.Payment
+	}
+	return nil
+}
+
+type UpdatePaymentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Payment       *Payment               `protobuf:"bytes,1,opt,name=payment,proto3" json:"payment,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdatePaymentRequest) Reset() {
+	*x = UpdatePaymentRequest{}
+	mi := &file_payments_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdatePaymentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdatePaymentRequest) ProtoMessage() {}
+
+func (x *UpdatePaymentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_payments_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdatePaymentRequest.ProtoReflect.Descriptor instead.
+func (*UpdatePaymentRequest) Descriptor() ([]byte, []int) {
+	return file_payments_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *UpdatePaymentRequest) GetPayment() *Payment {
+	if x != nil {
+		return x.Payment
+	}
+	return nil
+}
+
+type DeletePaymentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeletePaymentRequest) Reset() {
+	*x = DeletePaymentRequest{}
+	mi := &file_payments_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeletePaymentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletePaymentRequest) ProtoMessage() {}
+
+func (x *DeletePaymentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_payments_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletePaymentRequest.ProtoReflect.Descriptor instead.
+func (*DeletePaymentRequest) Descriptor() ([]byte, []int) {
+	return file_payments_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DeletePaymentRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type DeletePaymentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeletePaymentResponse) Reset() {
+	*x = DeletePaymentResponse{}
+	mi := &file_payments_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeletePaymentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletePaymentResponse) ProtoMessage() {}
+
+func (x *DeletePaymentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_payments_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletePaymentResponse.ProtoReflect.Descriptor instead.
+func (*DeletePaymentResponse) Descriptor() ([]byte, []int) {
+	return file_payments_proto_rawDescGZIP(), []int{11}
+}
+
+type GetPaymentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPaymentRequest) Reset() {
+	*x = GetPaymentRequest{}
+	mi := &file_payments_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPaymentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPaymentRequest) ProtoMessage() {}
+
+func (x *GetPaymentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_payments_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPaymentRequest.ProtoReflect.Descriptor instead.
+func (*GetPaymentRequest) Descriptor() ([]byte, []int) {
+	return file_payments_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *GetPaymentRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+// PaymentFilter selects the payments by the criteria of the payment listing, an empty criterion matches all payments
+type PaymentFilter struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	OrganisationId string                 `protobuf:"bytes,1,opt,name=organisation_id,json=organisationId,proto3" json:"organisation_id,omitempty"`
+	PaymentScheme  string                 `protobuf:"bytes,2,opt,name=payment_scheme,json=paymentScheme,proto3" json:"payment_scheme,omitempty"`
+	ProcessingDate string                 `protobuf:"bytes,3,opt,name=processing_date,json=processingDate,proto3" json:"processing_date,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *PaymentFilter) Reset() {
+	*x = PaymentFilter{}
+	mi := &file_payments_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PaymentFilter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaymentFilter) ProtoMessage() {}
+
+func (x *PaymentFilter) ProtoReflect() protoreflect.Message {
+	mi := &file_payments_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaymentFilter.ProtoReflect.Descriptor instead.
+func (*PaymentFilter) Descriptor() ([]byte, []int) {
+	return file_payments_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *PaymentFilter) GetOrganisationId() string {
+	if x != nil {
+		return x.OrganisationId
+	}
+	return ""
+}
+
+func (x *PaymentFilter) GetPaymentScheme() string {
+	if x != nil {
+		return x.PaymentScheme
+	}
+	return ""
+}
+
+func (x *PaymentFilter) GetProcessingDate() string {
+	if x != nil {
+		return x.ProcessingDate
+	}
+	return ""
+}
+
+type PaymentList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Payments      []*Payment             `protobuf:"bytes,1,rep,name=payments,proto3" json:"payments,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PaymentList) Reset() {
+	*x = PaymentList{}
+	mi := &file_payments_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PaymentList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaymentList) ProtoMessage() {}
+
+func (x *PaymentList) ProtoReflect() protoreflect.Message {
+	mi := &file_payments_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaymentList.ProtoReflect.Descriptor instead.
+func (*PaymentList) Descriptor() ([]byte, []int) {
+	return file_payments_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *PaymentList) GetPayments() []*Payment {
+	if x != nil {
+		return x.Payments
+	}
+	return nil
+}
+
+var File_payments_proto protoreflect.FileDescriptor
+
+const file_payments_proto_rawDesc = "" +
+	"\n" +
+	"\x0epayments.proto\x12\vpayments.v1\"\xa9\x01\n" +
+	"\aPayment\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\x05R\aversion\x12'\n" +
+	"\x0forganisation_id\x18\x04 \x01(\tR\x0eorganisationId\x127\n" +
+	"\n" +
+	"attributes\x18\x05 \x01(\v2\x17.payments.v1.AttributesR\n" +
+	"attributes\"\x9a\x06\n" +
+	"\n" +
+	"Attributes\x12\x16\n" +
+	"\x06amount\x18\x01 \x01(\x01R\x06amount\x12J\n" +
+	"\x11beneficiary_party\x18\x02 \x01(\v2\x1d.payments.v1.BeneficiaryPartyR\x10beneficiaryParty\x12P\n" +
+	"\x13charges_information\x18\x03 \x01(\v2\x1f.payments.v1.ChargesInformationR\x12chargesInformation\x12\x1a\n" +
+	"\bcurrency\x18\x04 \x01(\tR\bcurrency\x12;\n" +
+	"\fdebtor_party\x18\x05 \x01(\v2\x18.payments.v1.DebtorPartyR\vdebtorParty\x12/\n" +
+	"\x14end_to_end_reference\x18\x06 \x01(\tR\x11endToEndReference\x12\x1f\n" +
+	"\x02fx\x18\a \x01(\v2\x0f.payments.v1.FXR\x02fx\x12+\n" +
+	"\x11numeric_reference\x18\b \x01(\x03R\x10numericReference\x12\x1d\n" +
+	"\n" +
+	"payment_id\x18\t \x01(\tR\tpaymentId\x12'\n" +
+	"\x0fpayment_purpose\x18\n" +
+	" \x01(\tR\x0epaymentPurpose\x12%\n" +
+	"\x0epayment_scheme\x18\v \x01(\tR\rpaymentScheme\x12!\n" +
+	"\fpayment_type\x18\f \x01(\tR\vpaymentType\x12'\n" +
+	"\x0fprocessing_date\x18\r \x01(\tR\x0eprocessingDate\x12\x1c\n" +
+	"\treference\x18\x0e \x01(\tR\treference\x125\n" +
+	"\x17scheme_payment_sub_type\x18\x0f \x01(\tR\x14schemePaymentSubType\x12.\n" +
+	"\x13scheme_payment_type\x18\x10 \x01(\tR\x11schemePaymentType\x12>\n" +
+	"\rsponsor_party\x18\x11 \x01(\v2\x19.payments.v1.SponsorPartyR\fsponsorParty\"p\n" +
+	"\fSponsorParty\x12%\n" +
+	"\x0eaccount_number\x18\x01 \x01(\tR\raccountNumber\x12\x17\n" +
+	"\abank_id\x18\x02 \x01(\tR\x06bankId\x12 \n" +
+	"\fbank_id_code\x18\x03 \x01(\tR\n" +
+	"bankIdCode\"\xf0\x01\n" +
+	"\vDebtorParty\x12%\n" +
+	"\x0eaccount_number\x18\x01 \x01(\tR\raccountNumber\x12\x17\n" +
+	"\abank_id\x18\x02 \x01(\tR\x06bankId\x12 \n" +
+	"\fbank_id_code\x18\x03 \x01(\tR\n" +
+	"bankIdCode\x12!\n" +
+	"\faccount_name\x18\x04 \x01(\tR\vaccountName\x12.\n" +
+	"\x13account_number_code\x18\x05 \x01(\tR\x11accountNumberCode\x12\x18\n" +
+	"\aaddress\x18\x06 \x01(\tR\aaddress\x12\x12\n" +
+	"\x04name\x18\a \x01(\tR\x04name\"\x98\x02\n" +
+	"\x10BeneficiaryParty\x12%\n" +
+	"\x0eaccount_number\x18\x01 \x01(\tR\raccountNumber\x12\x17\n" +
+	"\abank_id\x18\x02 \x01(\tR\x06bankId\x12 \n" +
+	"\fbank_id_code\x18\x03 \x01(\tR\n" +
+	"bankIdCode\x12!\n" +
+	"\faccount_name\x18\x04 \x01(\tR\vaccountName\x12.\n" +
+	"\x13account_number_code\x18\x05 \x01(\tR\x11accountNumberCode\x12\x18\n" +
+	"\aaddress\x18\x06 \x01(\tR\aaddress\x12\x12\n" +
+	"\x04name\x18\a \x01(\tR\x04name\x12!\n" +
+	"\faccount_type\x18\b \x01(\x05R\vaccountType\"\xec\x01\n" +
+	"\x12ChargesInformation\x12\x1f\n" +
+	"\vbearer_code\x18\x01 \x01(\tR\n" +
+	"bearerCode\x12A\n" +
+	"\x0esender_charges\x18\x02 \x03(\v2\x1a.payments.v1.SenderChargesR\rsenderCharges\x126\n" +
+	"\x17receiver_charges_amount\x18\x03 \x01(\x01R\x15receiverChargesAmount\x12:\n" +
+	"\x19receiver_charges_currency\x18\x04 \x01(\tR\x17receiverChargesCurrency\"C\n" +
+	"\rSenderCharges\x12\x16\n" +
+	"\x06amount\x18\x01 \x01(\x01R\x06amount\x12\x1a\n" +
+	"\bcurrency\x18\x02 \x01(\tR\bcurrency\"\xae\x01\n" +
+	"\x02FX\x12-\n" +
+	"\x12contract_reference\x18\x01 \x01(\tR\x11contractReference\x12#\n" +
+	"\rexchange_rate\x18\x02 \x01(\x01R\fexchangeRate\x12'\n" +
+	"\x0foriginal_amount\x18\x03 \x01(\x01R\x0eoriginalAmount\x12+\n" +
+	"\x11original_currency\x18\x04 \x01(\tR\x10originalCurrency\"F\n" +
+	"\x14CreatePaymentRequest\x12.\n" +
+	"\apayment\x18\x01 \x01(\v2\x14.payments.v1.PaymentR\apayment\"F\n" +
+	"\x14UpdatePaymentRequest\x12.\n" +
+	"\apayment\x18\x01 \x01(\v2\x14.payments.v1.PaymentR\apayment\"&\n" +
+	"\x14DeletePaymentRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x17\n" +
+	"\x15DeletePaymentResponse\"#\n" +
+	"\x11GetPaymentRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x88\x01\n" +
+	"\rPaymentFilter\x12'\n" +
+	"\x0forganisation_id\x18\x01 \x01(\tR\x0eorganisationId\x12%\n" +
+	"\x0epayment_scheme\x18\x02 \x01(\tR\rpaymentScheme\x12'\n" +
+	"\x0fprocessing_date\x18\x03 \x01(\tR\x0eprocessingDate\"?\n" +
+	"\vPaymentList\x120\n" +
+	"\bpayments\x18\x01 \x03(\v2\x14.payments.v1.PaymentR\bpayments2\xcc\x03\n" +
+	"\x0ePaymentService\x12H\n" +
+	"\rCreatePayment\x12!.payments.v1.CreatePaymentRequest\x1a\x14.payments.v1.Payment\x12H\n" +
+	"\rUpdatePayment\x12!.payments.v1.UpdatePaymentRequest\x1a\x14.payments.v1.Payment\x12V\n" +
+	"\rDeletePayment\x12!.payments.v1.DeletePaymentRequest\x1a\".payments.v1.DeletePaymentResponse\x12B\n" +
+	"\n" +
+	"GetPayment\x12\x1e.payments.v1.GetPaymentRequest\x1a\x14.payments.v1.Payment\x12F\n" +
+	"\x0eGetAllPayments\x12\x1a.payments.v1.PaymentFilter\x1a\x18.payments.v1.PaymentList\x12B\n" +
+	"\fListPayments\x12\x1a.payments.v1.PaymentFilter\x1a\x14.payments.v1.Payment0\x01B5Z3github.com/vba270419/payments-backend-go/paymentspbb\x06proto3"
+
+var (
+	file_payments_proto_rawDescOnce sync.Once
+	file_payments_proto_rawDescData []byte
+)
+
+func file_payments_proto_rawDescGZIP() []byte {
+	file_payments_proto_rawDescOnce.Do(func() {
+		file_payments_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_payments_proto_rawDesc), len(file_payments_proto_rawDesc)))
+	})
+	return file_payments_proto_rawDescData
+}
+
+var file_payments_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_payments_proto_goTypes = []any{
+	(*Payment)(nil),               // 0: payments.v1.Payment
+	(*Attributes)(nil),            // 1: payments.v1.Attributes
+	(*SponsorParty)(nil),          // 2: payments.v1.SponsorParty
+	(*DebtorParty)(nil),           // 3: payments.v1.DebtorParty
+	(*BeneficiaryParty)(nil),      // 4: payments.v1.BeneficiaryParty
+	(*ChargesInformation)(nil),    // 5: payments.v1.ChargesInformation
+	(*SenderCharges)(nil),         // 6: payments.v1.SenderCharges
+	(*FX)(nil),                    // 7: payments.v1.FX
+	(*CreatePaymentRequest)(nil),  // 8: payments.v1.CreatePaymentRequest
+	(*UpdatePaymentRequest)(nil),  // 9: payments.v1.UpdatePaymentRequest
+	(*DeletePaymentRequest)(nil),  // 10: payments.v1.DeletePaymentRequest
+	(*DeletePaymentResponse)(nil), // 11: payments.v1.DeletePaymentResponse
+	(*GetPaymentRequest)(nil),     // 12: payments.v1.GetPaymentRequest
+	(*PaymentFilter)(nil),         // 13: payments.v1.PaymentFilter
+	(*PaymentList)(nil),           // 14: payments.v1.PaymentList
+}
+var file_payments_proto_depIdxs = []int32{
+	1,  // 0: payments.v1.Payment.attributes:type_name -> payments.v1.Attributes
+	4,  // 1: payments.v1.Attributes.beneficiary_party:type_name -> payments.v1.BeneficiaryParty
+	5,  // 2: payments.v1.Attributes.charges_information:type_name -> payments.v1.ChargesInformation
+	3,  // 3: payments.v1.Attributes.debtor_party:type_name -> payments.v1.DebtorParty
+	7,  // 4: payments.v1.Attributes.fx:type_name -> payments.v1.FX
+	2,  // 5: payments.v1.Attributes.sponsor_party:type_name -> payments.v1.SponsorParty
+	6,  // 6: payments.v1.ChargesInformation.sender_charges:type_name -> payments.v1.SenderCharges
+	0,  // 7: payments.v1.CreatePaymentRequest.payment:type_name -> payments.v1.Payment
+	0,  // 8: payments.v1.UpdatePaymentRequest.payment:type_name -> payments.v1.Payment
+	0,  // 9: payments.v1.PaymentList.payments:type_name -> payments.v1.Payment
+	8,  // 10: payments.v1.PaymentService.CreatePayment:input_type -> payments.v1.CreatePaymentRequest
+	9,  // 11: payments.v1.PaymentService.UpdatePayment:input_type -> payments.v1.UpdatePaymentRequest
+	10, // 12: payments.v1.PaymentService.DeletePayment:input_type -> payments.v1.DeletePaymentRequest
+	12, // 13: payments.v1.PaymentService.GetPayment:input_type -> payments.v1.GetPaymentRequest
+	13, // 14: payments.v1.PaymentService.GetAllPayments:input_type -> payments.v1.PaymentFilter
+	13, // 15: payments.v1.PaymentService.ListPayments:input_type -> payments.v1.PaymentFilter
+	0,  // 16: payments.v1.PaymentService.CreatePayment:output_type -> payments.v1.Payment
+	0,  // 17: payments.v1.PaymentService.UpdatePayment:output_type -> payments.v1.Payment
+	11, // 18: payments.v1.PaymentService.DeletePayment:output_type -> payments.v1.DeletePaymentResponse
+	0,  // 19: payments.v1.PaymentService.GetPayment:output_type -> payments.v1.Payment
+	14, // 20: payments.v1.PaymentService.GetAllPayments:output_type -> payments.v1.PaymentList
+	0,  // 21: payments.v1.PaymentService.ListPayments:output_type -> payments.v1.Payment
+	16, // [16:22] is the sub-list for method output_type
+	10, // [10:16] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_payments_proto_init() }
+func file_payments_proto_init() {
+	if File_payments_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_payments_proto_rawDesc), len(file_payments_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   15,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_payments_proto_goTypes,
+		DependencyIndexes: file_payments_proto_depIdxs,
+		MessageInfos:      file_payments_proto_msgTypes,
+	}.Build()
+	File_payments_proto = out.File
+	file_payments_proto_goTypes = nil
+	file_payments_proto_depIdxs = nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	paymentEventSchemaVersion string = "1.0"
+	paymentEventSource        string = "payments-backend"
+
+	noopPublisherType  string = "none"
+	natsPublisherType  string = "nats"
+	kafkaPublisherType string = "kafka"
+
+	kafkaPublishTimeout time.Duration = 5 * time.Second
+	kafkaBatchTimeout   time.Duration = 10 * time.Millisecond
+)
+
+// EventPublisher is an interface which defines the methods must be implemented by a specific publisher of payment events
+type EventPublisher interface {
+	Publish(event PaymentEvent) (err error)
+
+	Close() (err error)
+}
+
+// A PaymentEventEnvelope is a structure in which payment events are published to a message broker
+type PaymentEventEnvelope struct {
+	SchemaVersion string       `json:"schema_version"`
+	ID            string       `json:"id"`
+	Type          string       `json:"type"`
+	Source        string       `json:"source"`
+	Time          time.Time    `json:"time"`
+	Data          PaymentEvent `json:"data"`
+}
+
+var eventPublisher EventPublisher = paymentEvents
+
+func setEventPublisher(publisher EventPublisher) {
+	eventPublisher = publisher
+}
+
+func newPaymentEventEnvelope(event PaymentEvent) PaymentEventEnvelope {
+	return PaymentEventEnvelope{
+		SchemaVersion: paymentEventSchemaVersion,
+		ID:            event.ID,
+		Type:          event.Type,
+		Source:        paymentEventSource,
+		Time:          event.OccurredAt,
+		Data:          event}
+}
+
+// eventPublishers publishes every event with all the publishers, a failure of one publisher does not stop the others
+type eventPublishers []EventPublisher
+
+func (p eventPublishers) Publish(event PaymentEvent) (err error) {
+	for _, publisher := range p {
+		if publishErr := publisher.Publish(event); publishErr != nil {
+			err = publishErr
+		}
+	}
+	return err
+}
+
+func (p eventPublishers) Close() (err error) {
+	for i := len(p) - 1; i >= 0; i-- {
+		if closeErr := p[i].Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+type noopPublisher struct {
+}
+
+func (p noopPublisher) Publish(event PaymentEvent) (err error) {
+	return nil
+}
+
+func (p noopPublisher) Close() (err error) {
+	return nil
+}
+
+// natsPublisher publishes payment events to the '<prefix>.<event type>' subjects of a NATS server
+type natsPublisher struct {
+	conn          *nats.Conn
+	subjectPrefix string
+}
+
+func newNatsPublisher(conn *nats.Conn, subjectPrefix string) *natsPublisher {
+	return &natsPublisher{conn: conn, subjectPrefix: subjectPrefix}
+}
+
+func (p *natsPublisher) Publish(event PaymentEvent) (err error) {
+	data, err := json.Marshal(newPaymentEventEnvelope(event))
+	if err != nil {
+		return err
+	}
+	return p.conn.Publish(p.subjectPrefix+"."+event.Type, data)
+}
+
+func (p *natsPublisher) Close() (err error) {
+	return p.conn.Drain()
+}
+
+// checkConnection fails unless the connection is established, the client reconnects on its own
+func (p *natsPublisher) checkConnection(_ context.Context) error {
+	if status := p.conn.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("NATS connection is %s", status.String())
+	}
+	return nil
+}
+
+// kafkaPublisher publishes payment events to a Kafka topic keyed by payment id
+type kafkaPublisher struct {
+	writer  *kafka.Writer
+	brokers []string
+}
+
+// newKafkaPublisher creates the publisher, an asynchronous publisher returns before the brokers acknowledge a message
+func newKafkaPublisher(brokers []string, topic string, async bool) *kafkaPublisher {
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: kafkaBatchTimeout,
+		RequiredAcks: kafka.RequireAll,
+		Async:        async}
+	if async {
+		writer.Completion = logKafkaFailure
+	}
+	return &kafkaPublisher{writer: writer, brokers: brokers}
+}
+
+func logKafkaFailure(messages []kafka.Message, err error) {
+	if err != nil {
+		slog.Error("Unexpected error while publishing events", "events", len(messages), "error", err)
+	}
+}
+
+func (p *kafkaPublisher) Publish(event PaymentEvent) (err error) {
+	message, err := newKafkaMessage(event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaPublishTimeout)
+	defer cancel()
+	return p.writer.WriteMessages(ctx, message)
+}
+
+func (p *kafkaPublisher) Close() (err error) {
+	return p.writer.Close()
+}
+
+// checkConnection fails unless one of the brokers accepts a connection
+func (p *kafkaPublisher) checkConnection(ctx context.Context) (err error) {
+	for _, broker := range p.brokers {
+		var conn *kafka.Conn
+		if conn, err = kafka.DialContext(ctx, "tcp", broker); err == nil {
+			return conn.Close()
+		}
+	}
+	return err
+}
+
+func newKafkaMessage(event PaymentEvent) (message kafka.Message, err error) {
+	data, err := json.Marshal(newPaymentEventEnvelope(event))
+	if err != nil {
+		return message, err
+	}
+
+	return kafka.Message{
+		Key:   []byte(event.PaymentID),
+		Value: data,
+		Headers: []kafka.Header{
+			{Key: "schema_version", Value: []byte(paymentEventSchemaVersion)},
+			{Key: "type", Value: []byte(event.Type)}}}, nil
+}
+
+// initializeEventPublisher creates the publisher configured by event_publisher property
+func initializeEventPublisher(config configuration, inProcess bool) EventPublisher {
+	var publishers eventPublishers
+	if inProcess {
+		publishers = append(publishers, paymentEvents)
+	}
+
+	switch config.EventPublisher {
+	case natsPublisherType:
+		url := redactURL(config.NatsURL)
+		slog.Info("Connecting to NATS ...", "url", url)
+		conn, err := nats.Connect(config.NatsURL, nats.Name(paymentEventSource))
+		if err != nil {
+			fatal("Failed to establish connection to NATS", "url", url, "error", err)
+		}
+		publishers = append(publishers, newNatsPublisher(conn, config.NatsSubjectPrefix))
+		slog.Info("Connection to NATS - OK", "url", url)
+	case kafkaPublisherType:
+		brokers := strings.Split(config.KafkaBrokers, ",")
+		slog.Info("Publishing payment events to Kafka", "brokers", brokers)
+		publishers = append(publishers, newKafkaPublisher(brokers, config.KafkaTopic, inProcess))
+	default:
+		publishers = append(publishers, noopPublisher{})
+	}
+
+	return publishers
+}
+
+func shutdownEventPublisher(publisher EventPublisher) {
+	slog.Info("Closing event publisher ...")
+	if err := publisher.Close(); err != nil {
+		slog.Error("Unexpected error while closing event publisher", "error", err)
+	}
+	slog.Info("Event publisher closed")
+}
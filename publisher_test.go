@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	. "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestNatsPublisherPublishesEnvelope(t *testing.T) {
+	conn := MockNatsConnection(t)
+
+	messages := make(chan *nats.Msg, 1)
+	subscription, err := conn.ChanSubscribe("payments.>", messages)
+	Nil(t, err)
+	defer func() { _ = subscription.Unsubscribe() }()
+
+	publisher := newNatsPublisher(conn, "payments")
+	event := newPaymentEvent(paymentCreatedEvent, Payment{ID: "1", OrganisationID: "123", Version: 1})
+	Nil(t, publisher.Publish(event))
+
+	select {
+	case message := <-messages:
+		var envelope PaymentEventEnvelope
+		Nil(t, json.Unmarshal(message.Data, &envelope))
+
+		Equal(t, "payments.PaymentCreated", message.Subject)
+		Equal(t, paymentEventSchemaVersion, envelope.SchemaVersion)
+		Equal(t, event.ID, envelope.ID)
+		Equal(t, "1", envelope.Data.PaymentID)
+	case <-time.After(time.Second):
+		Fail(t, "payment event not published")
+	}
+}
+
+func TestKafkaMessageKeyedByPayment(t *testing.T) {
+	event := newPaymentEvent(paymentUpdatedEvent, Payment{ID: "1", OrganisationID: "123", Version: 2})
+
+	message, err := newKafkaMessage(event)
+	Nil(t, err)
+
+	var envelope PaymentEventEnvelope
+	Nil(t, json.Unmarshal(message.Value, &envelope))
+
+	Equal(t, "1", string(message.Key))
+	Equal(t, paymentUpdatedEvent, envelope.Type)
+	Equal(t, 2, envelope.Data.Version)
+}
+
+func TestKafkaPublisherDoesNotWaitForBatch(t *testing.T) {
+	publisher := newKafkaPublisher([]string{"127.0.0.1:9092"}, "payments", true)
+
+	Equal(t, kafkaBatchTimeout, publisher.writer.BatchTimeout)
+	Less(t, publisher.writer.BatchTimeout, 100*time.Millisecond)
+	True(t, publisher.writer.Async)
+	False(t, newKafkaPublisher([]string{"127.0.0.1:9092"}, "payments", false).writer.Async)
+}
+
+func TestEventPublishersPublishToAll(t *testing.T) {
+	events := paymentEvents.subscribe()
+	defer paymentEvents.unsubscribe(events)
+
+	conn := MockNatsConnection(t)
+	messages := make(chan *nats.Msg, 1)
+	_, err := conn.ChanSubscribe("payments.>", messages)
+	Nil(t, err)
+
+	publisher := eventPublishers{paymentEvents, newNatsPublisher(conn, "payments")}
+	Nil(t, publisher.Publish(newPaymentEvent(paymentDeletedEvent, Payment{ID: "1", OrganisationID: "123", Version: 2})))
+
+	Equal(t, paymentDeletedEvent, (<-events).Type)
+	Equal(t, "payments.PaymentDeleted", (<-messages).Subject)
+}
+
+func TestPaymentEventEnvelopeMatchesSchema(t *testing.T) {
+	file, err := ioutil.ReadFile("schema/payment_event.v1.json")
+	Nil(t, err)
+
+	var schema struct {
+		Required   []string `json:"required"`
+		Properties struct {
+			Data struct {
+				Required []string `json:"required"`
+			} `json:"data"`
+		} `json:"properties"`
+	}
+	Nil(t, json.Unmarshal(file, &schema))
+
+	data, _ := json.Marshal(newPaymentEventEnvelope(newPaymentEvent(paymentCreatedEvent, Payment{ID: "1", Version: 1})))
+	var envelope map[string]interface{}
+	Nil(t, json.Unmarshal(data, &envelope))
+
+	for _, property := range schema.Required {
+		Contains(t, envelope, property)
+	}
+	for _, property := range schema.Properties.Data.Required {
+		Contains(t, envelope["data"], property)
+	}
+}
+
+// ---------------------------------------------------- //
+
+// MockNatsConnection starts an embedded NATS server which does not listen on any port and connects to it in process
+func MockNatsConnection(t *testing.T) *nats.Conn {
+	natsServer, err := server.NewServer(&server.Options{DontListen: true})
+	Nil(t, err)
+
+	go natsServer.Start()
+	True(t, natsServer.ReadyForConnections(5*time.Second))
+	t.Cleanup(natsServer.Shutdown)
+
+	conn, err := nats.Connect("", nats.InProcessServer(natsServer))
+	Nil(t, err)
+	t.Cleanup(conn.Close)
+	return conn
+}
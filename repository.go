@@ -2,41 +2,112 @@ package main
 
 import (
 	"context"
-	"github.com/spf13/viper"
+	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"log"
+	"log/slog"
+	"reflect"
 	"time"
 )
 
+const (
+	databaseName                  string = "account_book"
+	paymentsCollectionName        string = "payments"
+	paymentVersionsCollectionName string = "payment_versions"
+)
+
 // PaymentRepository is an interface which defines the methods must be implemented by a specific repository that persist payments to storage
 type PaymentRepository interface {
 	InsertPayment(payment Payment) (err error)
 
 	UpdatePayment(payment Payment) (err error)
 
+	PatchPayment(current Payment, patched Payment) (err error)
+
 	DeletePayment(paymentID string) (err error)
 
 	GetPayment(paymentID string) (payment Payment, err error)
 
+	GetPayments(paymentIDs []string) (payments []Payment, err error)
+
 	GetAllPayments() (payments []Payment, err error)
+
+	FindPayments(filter PaymentFilter) (payments []Payment, err error)
+
+	StreamPayments(ctx context.Context, filter PaymentFilter, each func(payment Payment) error) (err error)
+
+	GetPaymentVersions(paymentID string) (versions []PaymentVersion, err error)
+
+	GetPaymentVersion(paymentID string, version int) (payment Payment, err error)
+
+	GetPaymentAsOf(paymentID string, asOf time.Time) (payment Payment, err error)
+}
+
+// TransactionalPaymentRepository is implemented by the repositories which can run several changes in a single transaction
+type TransactionalPaymentRepository interface {
+	RunInTransaction(changes func(repository PaymentRepository) error) (err error)
+}
+
+// paymentTransactionsSupported tells whether the MongoDB deployment supports multi-document transactions
+var paymentTransactionsSupported = true
+
+func setPaymentTransactionsSupported(supported bool) {
+	paymentTransactionsSupported = supported
+}
+
+// transactionalPaymentRepository returns the repository as a TransactionalPaymentRepository when it can run transactions
+func transactionalPaymentRepository(repository PaymentRepository) (TransactionalPaymentRepository, bool) {
+	transactional, ok := repository.(TransactionalPaymentRepository)
+	return transactional, ok && paymentTransactionsSupported
+}
+
+// ContextualPaymentRepository is implemented by the repositories which can be bound to the context of a request
+type ContextualPaymentRepository interface {
+	WithContext(ctx context.Context) PaymentRepository
+}
+
+// bindPaymentRepository binds the repository to the context when the repository can be bound
+func bindPaymentRepository(repository PaymentRepository, ctx context.Context) PaymentRepository {
+	if contextual, ok := repository.(ContextualPaymentRepository); ok {
+		return contextual.WithContext(ctx)
+	}
+	return repository
 }
 
 type mongoClient struct {
-	client *mongo.Client
+	client      *mongo.Client
+	outbox      bool
+	transaction mongo.SessionContext
+	ctx         context.Context
+}
+
+func (m *mongoClient) WithContext(ctx context.Context) PaymentRepository {
+	return &mongoClient{client: m.client, outbox: m.outbox, transaction: m.transaction, ctx: ctx}
+}
+
+func (m *mongoClient) RunInTransaction(changes func(repository PaymentRepository) error) (err error) {
+	return runInTransaction(m.parentContext(), m.client, func(sessionContext mongo.SessionContext) error {
+		return changes(&mongoClient{client: m.client, outbox: m.outbox, transaction: sessionContext, ctx: m.ctx})
+	})
 }
 
 func (m *mongoClient) InsertPayment(payment Payment) (err error) {
 	collection := getCollection(m.client)
 
-	_, err = collection.InsertOne(getContextWithTimeout(), payment)
-	if err != nil {
-		log.Printf("Unexpected error while inserting: %s", err.Error())
-		return &PersistenceError{}
-	}
+	return m.runChange(func(ctx context.Context) (PaymentEvent, error) {
+		_, err := collection.InsertOne(ctx, payment)
+		if isDuplicateKeyError(err) {
+			return PaymentEvent{}, &PaymentAlreadyExistsError{payment.ID}
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while inserting", "error", err)
+			return PaymentEvent{}, &PersistenceError{}
+		}
 
-	return err
+		err = m.recordPaymentVersion(ctx, PaymentVersion{PaymentID: payment.ID, Version: payment.Version, Payment: payment})
+		return newPaymentEvent(paymentCreatedEvent, payment), err
+	})
 }
 
 func (m *mongoClient) UpdatePayment(payment Payment) (err error) {
@@ -49,13 +120,22 @@ func (m *mongoClient) UpdatePayment(payment Payment) (err error) {
 	filter := bson.M{"_id": payment.ID, "version": currentVersion}
 	update := bson.M{"$set": payment}
 
-	result, err := collection.UpdateOne(getContextWithTimeout(), filter, update)
-	if err != nil {
-		log.Printf("Unexpected error while updating: %s", err.Error())
-		return &PersistenceError{}
-	}
+	err = m.runChange(func(ctx context.Context) (PaymentEvent, error) {
+		result, err := collection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while updating", "error", err)
+			return PaymentEvent{}, &PersistenceError{}
+		}
 
-	if result.MatchedCount == 0 {
+		if result.MatchedCount == 0 {
+			return PaymentEvent{}, &PaymentVersionConflictError{payment.ID, currentVersion}
+		}
+
+		err = m.recordPaymentVersion(ctx, PaymentVersion{PaymentID: payment.ID, Version: payment.Version, Payment: payment})
+		return newPaymentEvent(paymentUpdatedEvent, payment), err
+	})
+
+	if _, conflict := err.(*PaymentVersionConflictError); conflict {
 		_, err = m.GetPayment(payment.ID)
 		if err != nil {
 			return err
@@ -65,49 +145,103 @@ func (m *mongoClient) UpdatePayment(payment Payment) (err error) {
 	return err
 }
 
-func (m *mongoClient) DeletePayment(paymentID string) (err error) {
+// PatchPayment sets and unsets the fields of the patched payment which differ from the current payment
+func (m *mongoClient) PatchPayment(current Payment, patched Payment) (err error) {
 	collection := getCollection(m.client)
 
-	filter := bson.M{"_id": paymentID}
-
-	result, err := collection.DeleteOne(getContextWithTimeout(), filter)
+	patched.ID = current.ID
+	patched.Version = current.Version + 1
 
+	set, unset, err := diffPaymentDocuments(current, patched)
 	if err != nil {
-		log.Printf("Unexpected error while deleting: %s", err.Error())
+		slog.ErrorContext(m.parentContext(), "Unexpected error while patching", "error", err)
 		return &PersistenceError{}
 	}
 
-	if result.DeletedCount == 0 {
-		return &PaymentNotFoundError{paymentID}
+	filter := bson.M{"_id": current.ID, "version": current.Version}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
 	}
 
+	err = m.runChange(func(ctx context.Context) (PaymentEvent, error) {
+		result, err := collection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while patching", "error", err)
+			return PaymentEvent{}, &PersistenceError{}
+		}
+
+		if result.MatchedCount == 0 {
+			return PaymentEvent{}, &PaymentVersionConflictError{current.ID, current.Version}
+		}
+
+		err = m.recordPaymentVersion(ctx, PaymentVersion{PaymentID: patched.ID, Version: patched.Version, Payment: patched})
+		return newPaymentEvent(paymentUpdatedEvent, patched), err
+	})
+
+	if _, conflict := err.(*PaymentVersionConflictError); conflict {
+		_, err = m.GetPayment(current.ID)
+		if err != nil {
+			return err
+		}
+		return &PaymentVersionConflictError{current.ID, current.Version}
+	}
 	return err
 }
 
+func (m *mongoClient) DeletePayment(paymentID string) (err error) {
+	collection := getCollection(m.client)
+
+	filter := bson.M{"_id": paymentID}
+
+	return m.runChange(func(ctx context.Context) (PaymentEvent, error) {
+		var payment Payment
+		err := collection.FindOneAndDelete(ctx, filter).Decode(&payment)
+
+		if err == mongo.ErrNoDocuments {
+			return PaymentEvent{}, &PaymentNotFoundError{paymentID}
+		}
+
+		if err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while deleting", "error", err)
+			return PaymentEvent{}, &PersistenceError{}
+		}
+
+		// The deletion is retained as a tombstone version, so the history shows when the payment stopped existing
+		payment.Version = payment.Version + 1
+		err = m.recordPaymentVersion(ctx, PaymentVersion{PaymentID: paymentID, Version: payment.Version, Deleted: true})
+		return newPaymentEvent(paymentDeletedEvent, payment), err
+	})
+}
+
 func (m *mongoClient) GetPayment(paymentID string) (payment Payment, err error) {
 	collection := getCollection(m.client)
 
 	filter := bson.M{"_id": paymentID}
-	err = collection.FindOne(getContextWithTimeout(), filter).Decode(&payment)
+	err = collection.FindOne(m.context(), filter).Decode(&payment)
 
 	if err != nil {
 		if err.Error() == "mongo: no documents in result" {
 			return payment, &PaymentNotFoundError{paymentID}
 		}
-		log.Printf("Unexpected error while loading: %s", err.Error())
+		slog.ErrorContext(m.parentContext(), "Unexpected error while loading", "error", err)
 	}
 	return payment, err
 }
 
+func (m *mongoClient) GetPayments(paymentIDs []string) (payments []Payment, err error) {
+	return getPayments(getContextWithTimeoutFrom(m.parentContext()), getCollection(m.client), paymentIDs)
+}
+
 func (m *mongoClient) GetAllPayments() (payments []Payment, err error) {
-	ctx := getContextWithTimeout()
+	ctx := getContextWithTimeoutFrom(m.parentContext())
 	collection := getCollection(m.client)
 
 	filter := bson.M{}
 	cursor, err := collection.Find(ctx, filter)
 
 	if err != nil {
-		log.Printf("Unexpected error while loading: %s", err.Error())
+		slog.ErrorContext(ctx, "Unexpected error while loading", "error", err)
 		return payments, &PersistenceError{}
 	}
 
@@ -115,7 +249,7 @@ func (m *mongoClient) GetAllPayments() (payments []Payment, err error) {
 		var payment Payment
 		err = cursor.Decode(&payment)
 		if err != nil {
-			log.Printf("Unexpected error while loading: %s", err.Error())
+			slog.ErrorContext(ctx, "Unexpected error while loading", "error", err)
 			break
 		}
 		payments = append(payments, payment)
@@ -125,39 +259,392 @@ func (m *mongoClient) GetAllPayments() (payments []Payment, err error) {
 	return payments, err
 }
 
+func (m *mongoClient) FindPayments(filter PaymentFilter) (payments []Payment, err error) {
+	return findPayments(getContextWithTimeoutFrom(m.parentContext()), getCollection(m.client), filter)
+}
+
+func (m *mongoClient) StreamPayments(ctx context.Context, filter PaymentFilter, each func(payment Payment) error) (err error) {
+	return streamPayments(ctx, getCollection(m.client), filter, each)
+}
+
+func (m *mongoClient) GetPaymentVersions(paymentID string) (versions []PaymentVersion, err error) {
+	ctx := getContextWithTimeoutFrom(m.parentContext())
+	collection := getVersionsCollection(m.client)
+
+	filter := bson.M{"payment_id": paymentID}
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"version": 1}))
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while loading versions", "error", err)
+		return versions, &PersistenceError{}
+	}
+
+	for cursor.Next(ctx) {
+		var version PaymentVersion
+		err = cursor.Decode(&version)
+		if err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while loading versions", "error", err)
+			_ = cursor.Close(ctx)
+			return versions, &PersistenceError{}
+		}
+		versions = append(versions, version)
+	}
+	_ = cursor.Close(ctx)
+
+	if len(versions) == 0 {
+		return versions, &PaymentNotFoundError{paymentID}
+	}
+	return versions, nil
+}
+
+func (m *mongoClient) GetPaymentVersion(paymentID string, version int) (payment Payment, err error) {
+	collection := getVersionsCollection(m.client)
+
+	var paymentVersion PaymentVersion
+	filter := bson.M{"payment_id": paymentID, "version": version, "deleted": bson.M{"$ne": true}}
+	err = collection.FindOne(getContextWithTimeoutFrom(m.parentContext()), filter).Decode(&paymentVersion)
+
+	if err == mongo.ErrNoDocuments {
+		return payment, &PaymentVersionNotFoundError{paymentID, version}
+	}
+
+	if err != nil {
+		slog.ErrorContext(m.parentContext(), "Unexpected error while loading version", "error", err)
+		return payment, &PersistenceError{}
+	}
+	return paymentVersion.Payment, nil
+}
+
+func (m *mongoClient) GetPaymentAsOf(paymentID string, asOf time.Time) (payment Payment, err error) {
+	collection := getVersionsCollection(m.client)
+
+	// The latest version recorded at or before the given time is the state the payment had at that time
+	var paymentVersion PaymentVersion
+	filter := bson.M{"payment_id": paymentID, "recorded_at": bson.M{"$lte": asOf}}
+	findOptions := options.FindOne().SetSort(bson.M{"version": -1})
+	err = collection.FindOne(getContextWithTimeoutFrom(m.parentContext()), filter, findOptions).Decode(&paymentVersion)
+
+	if err == mongo.ErrNoDocuments || (err == nil && paymentVersion.Deleted) {
+		return payment, &PaymentNotFoundError{paymentID}
+	}
+
+	if err != nil {
+		slog.ErrorContext(m.parentContext(), "Unexpected error while loading version", "error", err)
+		return payment, &PersistenceError{}
+	}
+	return paymentVersion.Payment, nil
+}
+
+// recordPaymentVersion records the version of a changed payment
+func (m *mongoClient) recordPaymentVersion(ctx context.Context, version PaymentVersion) (err error) {
+	collection := getVersionsCollection(m.client)
+
+	version.RecordedAt = time.Now().UTC()
+
+	_, err = collection.InsertOne(ctx, version)
+	if isDuplicateKeyError(err) {
+		return &PaymentAlreadyExistsError{version.PaymentID}
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while recording version", "error", err)
+		return &PersistenceError{}
+	}
+	return nil
+}
+
+// runChange writes the change of a payment together with its version in a transaction when MongoDB supports transactions
+func (m *mongoClient) runChange(change func(ctx context.Context) (PaymentEvent, error)) error {
+	if m.transaction != nil || m.outbox || !paymentTransactionsSupported {
+		return runWithOutbox(m.parentContext(), m.client, m.outbox, m.transaction, change)
+	}
+	return runInTransaction(m.parentContext(), m.client, func(sessionContext mongo.SessionContext) error {
+		return runWithOutbox(m.parentContext(), m.client, m.outbox, sessionContext, change)
+	})
+}
+
+// diffPaymentDocuments returns the fields which are set and unset to change the first payment into the second
+func diffPaymentDocuments(from Payment, to Payment) (set bson.M, unset bson.M, err error) {
+	fromFields, err := flattenPaymentDocument(from)
+	if err != nil {
+		return set, unset, err
+	}
+	toFields, err := flattenPaymentDocument(to)
+	if err != nil {
+		return set, unset, err
+	}
+
+	set, unset = bson.M{}, bson.M{}
+	for path, value := range toFields {
+		if !reflect.DeepEqual(fromFields[path], value) {
+			set[path] = value
+		}
+	}
+	for path := range fromFields {
+		if _, ok := toFields[path]; !ok {
+			unset[path] = ""
+		}
+	}
+	return set, unset, nil
+}
+
+func flattenPaymentDocument(payment Payment) (fields map[string]interface{}, err error) {
+	data, err := bson.Marshal(payment)
+	if err != nil {
+		return fields, err
+	}
+
+	var document bson.M
+	if err = bson.Unmarshal(data, &document); err != nil {
+		return fields, err
+	}
+
+	fields = make(map[string]interface{})
+	flattenBSONDocument("", document, fields)
+	return fields, nil
+}
+
+func flattenBSONDocument(prefix string, document bson.M, fields map[string]interface{}) {
+	for key, value := range document {
+		path := key
+		if len(prefix) > 0 {
+			path = prefix + "." + key
+		}
+
+		if nested, ok := value.(bson.M); ok {
+			flattenBSONDocument(path, nested, fields)
+			continue
+		}
+		fields[path] = value
+	}
+}
+
+// getPayments loads the payments of the ids with a single query, the ids of the payments which don't exist are left out
+func getPayments(ctx context.Context, collection *mongo.Collection, paymentIDs []string) (payments []Payment, err error) {
+	cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$in": paymentIDs}})
+	if err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while loading", "error", err)
+		return payments, &PersistenceError{}
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+
+	for cursor.Next(ctx) {
+		var payment Payment
+		if err = cursor.Decode(&payment); err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while loading", "error", err)
+			return payments, &PersistenceError{}
+		}
+		payments = append(payments, payment)
+	}
+
+	if err = cursor.Err(); err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while loading", "error", err)
+		return payments, &PersistenceError{}
+	}
+	return payments, nil
+}
+
+// findPayments selects the payments of the collection matching the filter, ordered by id
+func findPayments(ctx context.Context, collection *mongo.Collection, filter PaymentFilter) (payments []Payment, err error) {
+	err = streamPayments(ctx, collection, filter, func(payment Payment) error {
+		payments = append(payments, payment)
+		return nil
+	})
+	return payments, err
+}
+
+// paymentFilterQuery translates the criteria of the filter into the query of the payments collection
+func paymentFilterQuery(filter PaymentFilter) bson.M {
+	query := bson.M{}
+	for field, value := range map[string]string{
+		"organisation_id":                filter.OrganisationID,
+		"attributes.payment_scheme":      filter.PaymentScheme,
+		"attributes.processing_date":     filter.ProcessingDate,
+		"attributes.payment_type":        filter.PaymentType,
+		"attributes.scheme_payment_type": filter.SchemePaymentType,
+		"attributes.currency":            filter.Currency,
+	} {
+		if len(value) > 0 {
+			query[field] = value
+		}
+	}
+
+	amount := bson.M{}
+	if filter.MinAmount != nil {
+		amount["$gte"] = *filter.MinAmount
+	}
+	if filter.MaxAmount != nil {
+		amount["$lte"] = *filter.MaxAmount
+	}
+	if len(amount) > 0 {
+		query["attributes.amount"] = amount
+	}
+
+	if len(filter.After) > 0 {
+		query["_id"] = bson.M{"$gt": filter.After}
+	}
+	return query
+}
+
+// streamPayments passes the payments of the collection matching the filter one by one to the each function
+func streamPayments(ctx context.Context, collection *mongo.Collection, filter PaymentFilter, each func(payment Payment) error) (err error) {
+	query := paymentFilterQuery(filter)
+	findOptions := options.Find().SetSort(bson.M{"_id": 1})
+	if filter.Limit > 0 {
+		findOptions.SetLimit(int64(filter.Limit))
+	}
+
+	cursor, err := collection.Find(ctx, query, findOptions)
+	if err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while loading", "error", err)
+		return &PersistenceError{}
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+
+	for cursor.Next(ctx) {
+		var payment Payment
+		if err = cursor.Decode(&payment); err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while loading", "error", err)
+			return &PersistenceError{}
+		}
+		if err = each(payment); err != nil {
+			return err
+		}
+	}
+
+	if err = cursor.Err(); err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while loading", "error", err)
+		return &PersistenceError{}
+	}
+	return nil
+}
+
+// context returns the context of the transaction the repository is bound to or a new context with the database timeout
+func (m *mongoClient) context() context.Context {
+	if m.transaction != nil {
+		return m.transaction
+	}
+	return getContextWithTimeoutFrom(m.parentContext())
+}
+
+// parentContext returns the context the repository is bound to, or the background context when it is not bound
+func (m *mongoClient) parentContext() context.Context {
+	if m.ctx != nil {
+		return m.ctx
+	}
+	return context.Background()
+}
+
+// The timeout of the database operations
+var mongoOperationTimeout = 10 * time.Second
+
+func setMongoDbTimeout(timeout time.Duration) {
+	mongoOperationTimeout = timeout
+}
+
 func getContextWithTimeout() context.Context {
-	duration := time.Duration(viper.GetInt(mongoDbTimeout)) * time.Second
-	ctx, _ := context.WithTimeout(context.Background(), duration)
+	return getContextWithTimeoutFrom(context.Background())
+}
+
+// getContextWithTimeoutFrom derives the context with the timeout of the database operations from the parent context
+func getContextWithTimeoutFrom(parent context.Context) context.Context {
+	ctx, _ := context.WithTimeout(parent, mongoOperationTimeout)
 	return ctx
 }
 
 func getCollection(client *mongo.Client) *mongo.Collection {
-	return client.Database("account_book").Collection("payments")
+	return client.Database(databaseName).Collection(paymentsCollectionName)
+}
+
+func getVersionsCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database(databaseName).Collection(paymentVersionsCollectionName)
 }
 
-func initializeMongoRepository() (PaymentRepository, *mongo.Client) {
-	host := viper.GetString(mongoDbHost)
-	port := viper.GetString(mongoDbPort)
+func initializeMongoRepository(config configuration) (PaymentRepository, *mongo.Client) {
+	host := config.MongoDbHost
+	port := config.MongoDbPort
 
-	log.Printf("Connecting to MongoDB [%s:%s] ... ", host, port)
+	slog.Info("Connecting to MongoDB ...", "host", host, "port", port)
 	ctx := getContextWithTimeout()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://"+host+":"+port))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://"+host+":"+port).SetMonitor(joinCommandMonitors(newMongoCommandMonitor(), newMongoTracingMonitor())).
+		SetPoolMonitor(newMongoPoolMonitor()))
 	if err != nil {
-		log.Fatalf("Failed to establish connection to MongoDB [%s:%s]: %s", host, port, err.Error())
+		fatal("Failed to establish connection to MongoDB", "host", host, "port", port, "error", err)
 	}
 	err = client.Ping(ctx, nil)
 	if err != nil {
-		log.Fatalf("Failed to establish connection to MongoDB [%s:%s]: %s", host, port, err.Error())
+		fatal("Failed to establish connection to MongoDB", "host", host, "port", port, "error", err)
+	}
+
+	slog.Info("Connection to MongoDB - OK", "host", host, "port", port)
+
+	if err = createMongoIndexes(client); err != nil {
+		fatal("Failed to create MongoDB indexes", "error", err)
 	}
 
-	repository := &mongoClient{client: client}
-	log.Printf("Connection to MongoDB [%s:%s] - OK", host, port)
+	if config.RepositoryType == eventSourcedRepository {
+		slog.Info("Using event-sourced payment repository")
+		if !mongoSupportsTransactions(client) {
+			fatal("Event-sourced payment repository requires MongoDB replica set or sharded cluster", "host", host, "port", port)
+		}
+		store := newEventStore(client, config.EventStoreSnapshotInterval, config.OutboxEnabled)
+		if *rebuildProjection {
+			if err := store.RebuildProjection(); err != nil {
+				fatal("Failed to rebuild payment projection", "error", err)
+			}
+		}
+		return store, client
+	}
+
+	repository := &mongoClient{client: client, outbox: config.OutboxEnabled}
 
 	return repository, client
 }
 
+// A mongoIndex is an index of a collection which is created on start
+type mongoIndex struct {
+	collection string
+	model      mongo.IndexModel
+}
+
+// The indexes of the collections, the versions of a payment are unique and the dispatched events expire
+var mongoIndexes = []mongoIndex{
+	{paymentVersionsCollectionName, mongo.IndexModel{
+		Keys:    bson.D{{Key: "payment_id", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true)}},
+	{paymentOutboxCollectionName, mongo.IndexModel{
+		Keys: bson.D{{Key: "dispatched_at", Value: 1}, {Key: "_id", Value: 1}}}},
+	{paymentOutboxCollectionName, mongo.IndexModel{
+		Keys:    bson.D{{Key: "dispatched_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(dispatchedEventRetention.Seconds()))}},
+	{webhookDeliveriesCollectionName, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}}},
+	{webhookDeliveriesCollectionName, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "lease_expires_at", Value: 1}}}},
+}
+
+// createMongoIndexes creates the indexes which don't exist yet
+func createMongoIndexes(client *mongo.Client) error {
+	for _, index := range mongoIndexes {
+		_, err := client.Database(databaseName).Collection(index.collection).Indexes().CreateOne(getContextWithTimeout(), index.model)
+		if err != nil {
+			return fmt.Errorf("index of %s collection: %w", index.collection, err)
+		}
+	}
+	return nil
+}
+
+// mongoSupportsTransactions tells whether the MongoDB deployment is a replica set or a sharded cluster
+func mongoSupportsTransactions(client *mongo.Client) bool {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := client.Database("admin").RunCommand(getContextWithTimeout(), bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	return err == nil && (len(hello.SetName) > 0 || hello.Msg == "isdbgrid")
+}
+
 func shutdownMongoRepository(client *mongo.Client) {
-	log.Println("Disconnecting from to MongoDB ... ")
+	slog.Info("Disconnecting from MongoDB ...")
 	_ = client.Disconnect(getContextWithTimeout())
-	log.Println("Disconnected from to MongoDB")
+	slog.Info("Disconnected from MongoDB")
 }
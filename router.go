@@ -3,9 +3,10 @@ package main
 import (
 	"fmt"
 	"github.com/gorilla/mux"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 )
 
 const (
@@ -13,14 +14,74 @@ const (
 	methodPut    string = "PUT"
 	methodDelete string = "DELETE"
 	methodGet    string = "GET"
+	methodPatch  string = "PATCH"
 
 	createPaymentPath  string = "/v1/payments/create"
 	updatePaymentPath  string = "/v1/payments/update"
 	deletePaymentPath  string = "/v1/payments/delete/{id}"
 	getPaymentPath     string = "/v1/payments/get/{id}"
 	getAllPaymentsPath string = "/v1/payments/all"
+	exportPaymentsPath string = "/v1/payments/export"
+	batchPaymentsPath  string = "/v1/payments/batch"
+
+	patchPaymentPath       string = "/v1/payments/{id}"
+	getPaymentVersionsPath string = "/v1/payments/{id}/versions"
+	getPaymentDiffPath     string = "/v1/payments/{id}/diff"
+	streamPaymentsPath     string = "/v1/payments/stream"
+	importPaymentsCSVPath  string = "/v1/payments/import/csv"
+	importPaymentsPath     string = "/v1/payments/import/{format}"
+	exportPaymentPath      string = "/v1/payments/{id}/export"
+	bacsBatchesPath        string = "/v1/payments/batches/bacs"
+	sepaBatchesPath        string = "/v1/payments/batches/sepa"
+	paymentBatchPath       string = "/v1/payments/batches/{id}"
+
+	webhookSubscriptionsPath string = "/v1/webhooks/subscriptions"
+	webhookSubscriptionPath  string = "/v1/webhooks/subscriptions/{id}"
+	webhookDeadLettersPath   string = "/v1/webhooks/dead-letters"
+	webhookRedeliveryPath    string = "/v1/webhooks/deliveries/{id}/redeliver"
+
+	paymentsV2Path        string = "/v2/payments"
+	paymentV2Path         string = "/v2/payments/{id}"
+	paymentVersionsV2Path string = "/v2/payments/{id}/versions"
+	paymentDiffV2Path     string = "/v2/payments/{id}/diff"
+
+	graphqlPath string = "/graphql"
 )
 
+// The paths of the payment resources of an API version, which the links of the responses are built from
+type paymentResourcePaths struct {
+	payment  string
+	payments string
+	update   string
+	delete   string
+	versions string
+	diff     string
+}
+
+var v1PaymentPaths = paymentResourcePaths{
+	payment:  getPaymentPath,
+	payments: getAllPaymentsPath,
+	update:   updatePaymentPath,
+	delete:   deletePaymentPath,
+	versions: getPaymentVersionsPath,
+	diff:     getPaymentDiffPath}
+
+var v2PaymentPaths = paymentResourcePaths{
+	payment:  paymentV2Path,
+	payments: paymentsV2Path,
+	update:   paymentV2Path,
+	delete:   paymentV2Path,
+	versions: paymentVersionsV2Path,
+	diff:     paymentDiffV2Path}
+
+// The dates the v1 payment routes are deprecated at and will be removed at
+var v1DeprecatedAt, v1SunsetAt time.Time
+
+func setV1Deprecation(deprecatedAt time.Time, sunsetAt time.Time) {
+	v1DeprecatedAt = deprecatedAt
+	v1SunsetAt = sunsetAt
+}
+
 type route struct {
 	Path    string
 	Method  string
@@ -30,41 +91,112 @@ type route struct {
 var routes []route
 
 func initializeRoutes() {
-	addRoute(route{createPaymentPath, methodPost, createPaymentEndpoint})
-	addRoute(route{updatePaymentPath, methodPut, updatePaymentEndpoint})
-	addRoute(route{deletePaymentPath, methodDelete, deletePaymentEndpoint})
-	addRoute(route{getPaymentPath, methodGet, getPaymentEndpoint})
-	addRoute(route{getAllPaymentsPath, methodGet, getAllPaymentsEndpoint})
+	addDeprecatedRoute(route{createPaymentPath, methodPost, createPaymentEndpoint}, paymentsV2Path)
+	addDeprecatedRoute(route{updatePaymentPath, methodPut, updatePaymentEndpoint}, paymentsV2Path)
+	addDeprecatedRoute(route{deletePaymentPath, methodDelete, deletePaymentEndpoint}, paymentV2Path)
+	addDeprecatedRoute(route{getPaymentPath, methodGet, getPaymentEndpoint}, paymentV2Path)
+	addDeprecatedRoute(route{getAllPaymentsPath, methodGet, getAllPaymentsEndpoint}, paymentsV2Path)
+	addRoute(route{exportPaymentsPath, methodGet, exportPaymentsEndpoint})
+	addRoute(route{batchPaymentsPath, methodPost, batchPaymentsEndpoint})
+	addDeprecatedRoute(route{patchPaymentPath, methodPatch, patchPaymentEndpoint}, paymentV2Path)
+	addDeprecatedRoute(route{getPaymentVersionsPath, methodGet, getPaymentVersionsEndpoint}, paymentVersionsV2Path)
+	addDeprecatedRoute(route{getPaymentDiffPath, methodGet, getPaymentDiffEndpoint}, paymentDiffV2Path)
+	addRoute(route{streamPaymentsPath, methodGet, streamPaymentsEndpoint})
+	addRoute(route{importPaymentsCSVPath, methodPost, importPaymentsCSVEndpoint})
+	addRoute(route{importPaymentsPath, methodPost, importPaymentsEndpoint})
+	addRoute(route{exportPaymentPath, methodGet, exportPaymentEndpoint})
+	addRoute(route{bacsBatchesPath, methodPost, createBacsBatchEndpoint})
+	addRoute(route{sepaBatchesPath, methodPost, createSepaBatchEndpoint})
+	addRoute(route{paymentBatchPath, methodGet, getPaymentBatchEndpoint})
+	addRoute(route{webhookSubscriptionsPath, methodPost, createWebhookSubscriptionEndpoint})
+	addRoute(route{webhookSubscriptionsPath, methodGet, getWebhookSubscriptionsEndpoint})
+	addRoute(route{webhookSubscriptionPath, methodDelete, deleteWebhookSubscriptionEndpoint})
+	addRoute(route{webhookDeadLettersPath, methodGet, getWebhookDeadLettersEndpoint})
+	addRoute(route{webhookRedeliveryPath, methodPost, redeliverWebhookEndpoint})
+
+	addRoute(route{paymentsV2Path, methodPost, createPaymentEndpoint})
+	addRoute(route{paymentsV2Path, methodGet, getAllPaymentsEndpoint})
+	addRoute(route{paymentV2Path, methodGet, getPaymentEndpoint})
+	addRoute(route{paymentV2Path, methodPut, updatePaymentEndpoint})
+	addRoute(route{paymentV2Path, methodPatch, patchPaymentEndpoint})
+	addRoute(route{paymentV2Path, methodDelete, deletePaymentEndpoint})
+	addRoute(route{paymentVersionsV2Path, methodGet, getPaymentVersionsEndpoint})
+	addRoute(route{paymentDiffV2Path, methodGet, getPaymentDiffEndpoint})
+
+	addRoute(route{graphqlPath, methodPost, graphqlEndpoint})
 }
 
 func addRoute(route route) {
 	routes = append(routes, route)
 }
 
+// addDeprecatedRoute adds a v1 route which is replaced by a v2 route
+func addDeprecatedRoute(route route, successorPath string) {
+	handler := route.Handler
+	route.Handler = func(writer http.ResponseWriter, request *http.Request) {
+		if !v1DeprecatedAt.IsZero() {
+			writer.Header().Set("Deprecation", fmt.Sprintf("@%d", v1DeprecatedAt.Unix()))
+		}
+		if !v1SunsetAt.IsZero() {
+			writer.Header().Set("Sunset", v1SunsetAt.UTC().Format(http.TimeFormat))
+		}
+		successor := prepareFullPaymentURL(request.Host, successorPath, mux.Vars(request)["id"])
+		writer.Header().Set("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+
+		handler(writer, request)
+	}
+	addRoute(route)
+}
+
+// requestPaymentPaths returns the payment resource paths of the API version of the request
+func requestPaymentPaths(request *http.Request) paymentResourcePaths {
+	if strings.HasPrefix(request.URL.Path, "/v2/") {
+		return v2PaymentPaths
+	}
+	return v1PaymentPaths
+}
+
 func configureRouter() (router *mux.Router) {
-	log.Print("Initializing router...")
+	slog.Info("Initializing router...")
 
 	initializeRoutes()
 
 	router = mux.NewRouter()
-	router.Use(loggingMiddleware)
+	router.Use(requestIDMiddleware)
+	router.Use(metricsMiddleware)
+	router.Use(tracingMiddleware)
+	router.Use(accessLogMiddleware)
+
+	if openAPIValidationMode != noValidation {
+		validationMiddleware, err := openAPIValidationMiddleware(openAPIValidationMode)
+		if err != nil {
+			fatal("OpenAPI specification is invalid", "error", err)
+		}
+		router.Use(validationMiddleware)
+	}
+
+	schema, err := newGraphQLSchema()
+	if err != nil {
+		fatal("GraphQL schema can't be generated", "error", err)
+	}
+	setGraphQLSchema(schema)
+
+	router.HandleFunc(openAPIPath, openAPIEndpoint).Methods(methodGet)
+	router.HandleFunc(swaggerUIPath, swaggerUIEndpoint).Methods(methodGet)
+	router.HandleFunc(swaggerUIAssetPath, swaggerUIAssetEndpoint).Methods(methodGet)
+	router.Handle(metricsPath, metricsHandler()).Methods(methodGet)
+	router.HandleFunc(livenessPath, livenessEndpoint).Methods(methodGet)
+	router.HandleFunc(readinessPath, readinessEndpoint).Methods(methodGet)
 
 	for _, route := range routes {
 		router.HandleFunc(route.Path, route.Handler).Methods(route.Method)
 	}
 
 	router.StrictSlash(true)
-	log.Print("Router - OK")
+	slog.Info("Router - OK")
 	return router
 }
 
-func loggingMiddleware(handler http.Handler) http.Handler {
-	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-		log.Printf("[%s] %s", request.Method, request.RequestURI)
-		handler.ServeHTTP(writer, request)
-	})
-}
-
 func preparePaymentURL(path string, paymentID string) string {
 	return strings.ReplaceAll(path, "{id}", paymentID)
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/gorilla/mux"
 	. "github.com/stretchr/testify/assert"
@@ -10,6 +11,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 const (
@@ -21,7 +23,9 @@ const (
 
 type PaymentRepositoryMock struct {
 	mock.Mock
-	mode string
+	mode     string
+	payments []Payment
+	batches  [][]string
 }
 
 func (m *PaymentRepositoryMock) InsertPayment(payment Payment) (err error) {
@@ -29,6 +33,12 @@ func (m *PaymentRepositoryMock) InsertPayment(payment Payment) (err error) {
 	case dbFailure:
 		return &PersistenceError{}
 	default:
+		for _, stored := range m.payments {
+			if stored.ID == payment.ID {
+				return &PaymentAlreadyExistsError{payment.ID}
+			}
+		}
+		m.payments = append(m.payments, payment)
 		return
 	}
 }
@@ -46,6 +56,14 @@ func (m *PaymentRepositoryMock) UpdatePayment(payment Payment) (err error) {
 	}
 }
 
+func (m *PaymentRepositoryMock) PatchPayment(current Payment, patched Payment) (err error) {
+	err = m.UpdatePayment(current)
+	if err == nil {
+		m.payments = append(m.payments, patched)
+	}
+	return err
+}
+
 func (m *PaymentRepositoryMock) DeletePayment(paymentID string) (err error) {
 	switch m.mode {
 	case notFound:
@@ -58,7 +76,7 @@ func (m *PaymentRepositoryMock) DeletePayment(paymentID string) (err error) {
 }
 
 func (m *PaymentRepositoryMock) GetPayment(paymentID string) (payment Payment, err error) {
-	payment = Payment{ID: paymentID, OrganisationID: "123", Version: 1}
+	payment = Payment{ID: paymentID, OrganisationID: "123", Version: 1, Attributes: Attributes{Amount: 10, Currency: "GBP"}}
 
 	switch m.mode {
 	case notFound:
@@ -70,6 +88,23 @@ func (m *PaymentRepositoryMock) GetPayment(paymentID string) (payment Payment, e
 	}
 }
 
+func (m *PaymentRepositoryMock) GetPayments(paymentIDs []string) (payments []Payment, err error) {
+	m.batches = append(m.batches, paymentIDs)
+
+	switch m.mode {
+	case dbFailure:
+		return payments, &PersistenceError{}
+	case notFound:
+		return payments, nil
+	}
+
+	for _, paymentID := range paymentIDs {
+		payment, _ := m.GetPayment(paymentID)
+		payments = append(payments, payment)
+	}
+	return payments, nil
+}
+
 func (m *PaymentRepositoryMock) GetAllPayments() (payments []Payment, err error) {
 	payments = append(payments, Payment{ID: "1", OrganisationID: "123", Version: 1})
 	payments = append(payments, Payment{ID: "2", OrganisationID: "456", Version: 2})
@@ -83,6 +118,79 @@ func (m *PaymentRepositoryMock) GetAllPayments() (payments []Payment, err error)
 	}
 }
 
+func (m *PaymentRepositoryMock) FindPayments(filter PaymentFilter) (payments []Payment, err error) {
+	if m.mode == dbFailure {
+		return payments, &PersistenceError{}
+	}
+
+	for _, payment := range m.payments {
+		if (len(filter.OrganisationID) == 0 || payment.OrganisationID == filter.OrganisationID) &&
+			(len(filter.PaymentScheme) == 0 || payment.Attributes.PaymentScheme == filter.PaymentScheme) &&
+			(len(filter.ProcessingDate) == 0 || payment.Attributes.ProcessingDate == filter.ProcessingDate) &&
+			(len(filter.PaymentType) == 0 || payment.Attributes.PaymentType == filter.PaymentType) &&
+			(len(filter.SchemePaymentType) == 0 || payment.Attributes.SchemePaymentType == filter.SchemePaymentType) &&
+			(len(filter.Currency) == 0 || payment.Attributes.Currency == filter.Currency) &&
+			(filter.MinAmount == nil || payment.Attributes.Amount >= *filter.MinAmount) &&
+			(filter.MaxAmount == nil || payment.Attributes.Amount <= *filter.MaxAmount) &&
+			payment.ID > filter.After && (filter.Limit <= 0 || len(payments) < filter.Limit) {
+			payments = append(payments, payment)
+		}
+	}
+	return payments, nil
+}
+
+func (m *PaymentRepositoryMock) StreamPayments(ctx context.Context, filter PaymentFilter, each func(payment Payment) error) (err error) {
+	payments, err := m.FindPayments(filter)
+	if err != nil {
+		return err
+	}
+
+	for _, payment := range payments {
+		if err = each(payment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *PaymentRepositoryMock) GetPaymentVersions(paymentID string) (versions []PaymentVersion, err error) {
+	versions = append(versions, PaymentVersion{PaymentID: paymentID, Version: 1, Payment: Payment{ID: paymentID, OrganisationID: "123", Version: 1}})
+	versions = append(versions, PaymentVersion{PaymentID: paymentID, Version: 2, Payment: Payment{ID: paymentID, OrganisationID: "456", Version: 2}})
+
+	switch m.mode {
+	case notFound:
+		return nil, &PaymentNotFoundError{paymentID}
+	case dbFailure:
+		return nil, &PersistenceError{}
+	default:
+		return versions, nil
+	}
+}
+
+func (m *PaymentRepositoryMock) GetPaymentVersion(paymentID string, version int) (payment Payment, err error) {
+	for _, stored := range m.payments {
+		if stored.ID == paymentID && stored.Version == version {
+			return stored, nil
+		}
+	}
+
+	versions, err := m.GetPaymentVersions(paymentID)
+	if err != nil {
+		return payment, err
+	}
+
+	for _, v := range versions {
+		if v.Version == version {
+			return v.Payment, nil
+		}
+	}
+	return payment, &PaymentVersionNotFoundError{paymentID, version}
+}
+
+func (m *PaymentRepositoryMock) GetPaymentAsOf(paymentID string, asOf time.Time) (payment Payment, err error) {
+	return m.GetPaymentVersion(paymentID, 1)
+}
+
 // Test payment creation handler
 
 func TestCreatePaymentSuccessful(t *testing.T) {
@@ -194,6 +302,100 @@ func TestGetPaymentServerFailed(t *testing.T) {
 	Equal(t, 500, response.Code)
 }
 
+func TestGetPaymentVersionSuccessful(t *testing.T) {
+	response := ServeHTTP(methodGet, preparePaymentURL(getPaymentPath, "2")+"?version=2", http.NoBody, successful)
+
+	var paymentResult PaymentResult
+	_ = json.NewDecoder(response.Body).Decode(&paymentResult)
+
+	Equal(t, 200, response.Code)
+	Equal(t, 2, paymentResult.Data.Version)
+	Equal(t, "456", paymentResult.Data.OrganisationID)
+	Contains(t, paymentResult.Links.Versions, "/v1/payments/2/versions")
+}
+
+func TestGetPaymentVersionNotFound(t *testing.T) {
+	response := ServeHTTP(methodGet, preparePaymentURL(getPaymentPath, "2")+"?version=3", http.NoBody, successful)
+
+	Equal(t, 404, response.Code)
+}
+
+func TestGetPaymentVersionInvalid(t *testing.T) {
+	response := ServeHTTP(methodGet, preparePaymentURL(getPaymentPath, "2")+"?version=latest", http.NoBody, successful)
+
+	Equal(t, 400, response.Code)
+}
+
+func TestGetPaymentAsOfSuccessful(t *testing.T) {
+	response := ServeHTTP(methodGet, preparePaymentURL(getPaymentPath, "2")+"?as_of=2019-05-05T11:00:00Z", http.NoBody, successful)
+
+	var paymentResult PaymentResult
+	_ = json.NewDecoder(response.Body).Decode(&paymentResult)
+
+	Equal(t, 200, response.Code)
+	Equal(t, 1, paymentResult.Data.Version)
+}
+
+func TestGetPaymentAsOfInvalid(t *testing.T) {
+	response := ServeHTTP(methodGet, preparePaymentURL(getPaymentPath, "2")+"?as_of=yesterday", http.NoBody, successful)
+
+	Equal(t, 400, response.Code)
+}
+
+// Test payment versions handler
+
+func TestGetPaymentVersionsSuccessful(t *testing.T) {
+	response := ServeHTTP(methodGet, preparePaymentURL(getPaymentVersionsPath, "2"), http.NoBody, successful)
+
+	var result PaymentVersionListResult
+	_ = json.NewDecoder(response.Body).Decode(&result)
+
+	Equal(t, 200, response.Code)
+	Equal(t, 2, len(result.Data))
+	Equal(t, 1, result.Data[0].Version)
+	Equal(t, 2, result.Data[1].Version)
+}
+
+func TestGetPaymentVersionsNotFound(t *testing.T) {
+	response := ServeHTTP(methodGet, preparePaymentURL(getPaymentVersionsPath, "2"), http.NoBody, notFound)
+
+	Equal(t, 404, response.Code)
+}
+
+func TestGetPaymentVersionsServerFailed(t *testing.T) {
+	response := ServeHTTP(methodGet, preparePaymentURL(getPaymentVersionsPath, "2"), http.NoBody, dbFailure)
+
+	Equal(t, 500, response.Code)
+}
+
+// Test payment diff handler
+
+func TestGetPaymentDiffSuccessful(t *testing.T) {
+	response := ServeHTTP(methodGet, preparePaymentURL(getPaymentDiffPath, "2")+"?from=1&to=2", http.NoBody, successful)
+
+	var result PaymentDiffResult
+	_ = json.NewDecoder(response.Body).Decode(&result)
+
+	Equal(t, 200, response.Code)
+	Equal(t, 1, result.Data.FromVersion)
+	Equal(t, 2, result.Data.ToVersion)
+	Equal(t, 2, len(result.Data.Changes))
+	Equal(t, "organisation_id", result.Data.Changes[0].Path)
+	Equal(t, "version", result.Data.Changes[1].Path)
+}
+
+func TestGetPaymentDiffMissingVersion(t *testing.T) {
+	response := ServeHTTP(methodGet, preparePaymentURL(getPaymentDiffPath, "2")+"?from=1", http.NoBody, successful)
+
+	Equal(t, 400, response.Code)
+}
+
+func TestGetPaymentDiffVersionNotFound(t *testing.T) {
+	response := ServeHTTP(methodGet, preparePaymentURL(getPaymentDiffPath, "2")+"?from=1&to=5", http.NoBody, successful)
+
+	Equal(t, 404, response.Code)
+}
+
 // Test get all payments handler
 
 func TestGetAllPaymentsSuccessful(t *testing.T) {
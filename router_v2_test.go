@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	. "github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testHost string = "http://example.com"
+
+// Test v2 payment routes
+
+func TestCreatePaymentV2(t *testing.T) {
+	response := ServeHTTP(methodPost, testHost+paymentsV2Path, MockPayment("", "123"), successful)
+
+	Equal(t, 201, response.Code)
+	True(t, strings.HasPrefix(response.Header().Get("Location"), "http://example.com/v2/payments/"))
+	Empty(t, response.Header().Get("Deprecation"))
+}
+
+func TestGetPaymentV2Links(t *testing.T) {
+	response := ServeHTTP(methodGet, testHost+preparePaymentURL(paymentV2Path, "2"), http.NoBody, successful)
+
+	var result PaymentResult
+	_ = json.NewDecoder(response.Body).Decode(&result)
+
+	Equal(t, 200, response.Code)
+	Equal(t, Links{
+		Self:     "http://example.com/v2/payments/2",
+		Update:   "http://example.com/v2/payments/2",
+		Delete:   "http://example.com/v2/payments/2",
+		Versions: "http://example.com/v2/payments/2/versions"}, result.Links)
+}
+
+func TestGetAllPaymentsV2(t *testing.T) {
+	response := ServeHTTP(methodGet, testHost+paymentsV2Path, http.NoBody, successful)
+
+	var result PaymentListResult
+	_ = json.NewDecoder(response.Body).Decode(&result)
+
+	Equal(t, 200, response.Code)
+	Equal(t, 3, len(result.Data))
+	Equal(t, "http://example.com/v2/payments", result.Links.Self)
+}
+
+func TestUpdatePaymentV2(t *testing.T) {
+	response := ServeHTTP(methodPut, testHost+preparePaymentURL(paymentV2Path, "1"), MockPayment("", "123"), successful)
+
+	Equal(t, 200, response.Code)
+	Equal(t, "http://example.com/v2/payments/1", response.Header().Get("Location"))
+}
+
+func TestUpdatePaymentV2DifferentID(t *testing.T) {
+	response := ServeHTTP(methodPut, testHost+preparePaymentURL(paymentV2Path, "2"), MockPayment("1", "123"), successful)
+
+	Equal(t, 400, response.Code)
+}
+
+func TestDeletePaymentV2(t *testing.T) {
+	Equal(t, 200, ServeHTTP(methodDelete, testHost+preparePaymentURL(paymentV2Path, "1"), http.NoBody, successful).Code)
+	Equal(t, 404, ServeHTTP(methodDelete, testHost+preparePaymentURL(paymentV2Path, "1"), http.NoBody, notFound).Code)
+}
+
+func TestGetPaymentVersionsV2(t *testing.T) {
+	response := ServeHTTP(methodGet, testHost+preparePaymentURL(paymentVersionsV2Path, "1"), http.NoBody, successful)
+
+	var result PaymentVersionListResult
+	_ = json.NewDecoder(response.Body).Decode(&result)
+
+	Equal(t, 200, response.Code)
+	Equal(t, "http://example.com/v2/payments/1/versions", result.Links.Self)
+}
+
+// Test v1 deprecation
+
+func TestV1PaymentRoutesDeprecated(t *testing.T) {
+	setV1Deprecation(time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), time.Date(2027, 11, 1, 0, 0, 0, 0, time.UTC))
+	defer setV1Deprecation(time.Time{}, time.Time{})
+
+	response := ServeHTTP(methodGet, testHost+preparePaymentURL(getPaymentPath, "2"), http.NoBody, successful)
+
+	var result PaymentResult
+	_ = json.NewDecoder(response.Body).Decode(&result)
+
+	Equal(t, 200, response.Code)
+	Equal(t, "@1793491200", response.Header().Get("Deprecation"))
+	Equal(t, "Mon, 01 Nov 2027 00:00:00 GMT", response.Header().Get("Sunset"))
+	Equal(t, `<http://example.com/v2/payments/2>; rel="successor-version"`, response.Header().Get("Link"))
+	Equal(t, "http://example.com/v1/payments/get/2", result.Links.Self)
+	Equal(t, "http://example.com/v1/payments/update", result.Links.Update)
+}
+
+func TestV1OtherRoutesNotDeprecated(t *testing.T) {
+	setV1Deprecation(time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), time.Time{})
+	defer setV1Deprecation(time.Time{}, time.Time{})
+
+	setPaymentRepository(&PaymentRepositoryMock{mode: successful})
+	request, _ := http.NewRequest(methodGet, testHost+exportPaymentsPath, http.NoBody)
+	response := httptest.NewRecorder()
+	configureRouter().ServeHTTP(response, request)
+
+	Equal(t, 200, response.Code)
+	Empty(t, response.Header().Get("Deprecation"))
+}
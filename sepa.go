@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+)
+
+const (
+	sepaPaymentScheme string = "SEPA"
+	sepaCurrency      string = "EUR"
+	sepaServiceLevel  string = "SEPA"
+	sepaChargeBearer  string = "SLEV"
+	sepaPaymentMethod string = "TRF"
+	sepaNamespace     string = pain001NamespacePrefix + "001.03"
+
+	sepaNameLength       int     = 70
+	sepaReferenceLength  int     = 35
+	sepaRemittanceLength int     = 140
+	sepaMaxAmount        float64 = 999999999.99
+)
+
+var (
+	// The Latin characters of the SEPA character set
+	sepaCharset = regexp.MustCompile(`^[a-zA-Z0-9/\-?:().,'+ ]*$`)
+
+	bicPattern = regexp.MustCompile(`^[A-Z]{6}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+)
+
+// validateSepaPayment checks the rules of the SEPA Credit Transfer scheme
+func validateSepaPayment(payment Payment) error {
+	attributes := payment.Attributes
+	debtor := attributes.DebtorParty
+	beneficiary := attributes.BeneficiaryParty.debtorParty()
+
+	if attributes.Currency != sepaCurrency {
+		return &SchemeValidationError{sepaPaymentScheme, "currency", "must be " + sepaCurrency}
+	}
+	if attributes.Amount <= 0 || attributes.Amount > sepaMaxAmount || !hasCents(attributes.Amount) {
+		return &SchemeValidationError{sepaPaymentScheme, "amount", "must be between 0.01 and 999999999.99 with at most two decimals"}
+	}
+	if len([]rune(attributes.Reference)) > sepaRemittanceLength {
+		return &SchemeValidationError{sepaPaymentScheme, "reference", fmt.Sprintf("must have at most %d characters", sepaRemittanceLength)}
+	}
+	if len([]rune(attributes.EndToEndReference)) > sepaReferenceLength {
+		return &SchemeValidationError{sepaPaymentScheme, "end_to_end_reference", fmt.Sprintf("must have at most %d characters", sepaReferenceLength)}
+	}
+
+	if err := validateSepaParty("debtor_party", debtor); err != nil {
+		return err
+	}
+	if err := validateSepaParty("beneficiary_party", beneficiary); err != nil {
+		return err
+	}
+
+	texts := map[string]string{"reference": attributes.Reference, "end_to_end_reference": attributes.EndToEndReference}
+	return validateSepaCharset(texts)
+}
+
+func validateSepaParty(field string, party DebtorParty) error {
+	sponsor := party.sponsorParty()
+
+	if party.AccountNumberCode != ibanAccountCode || !isValidIBAN(sponsor.AccountNumber) {
+		return &SchemeValidationError{sepaPaymentScheme, field + ".account_number", "must be a valid IBAN"}
+	}
+	if len(sponsor.BankID) > 0 && (sponsor.BankIDCode != swiftBankIDCode || !bicPattern.MatchString(sponsor.BankID)) {
+		return &SchemeValidationError{sepaPaymentScheme, field + ".bank_id", "must be a BIC when provided"}
+	}
+
+	return validateSepaCharset(map[string]string{
+		field + ".name":         party.Name,
+		field + ".account_name": party.AccountName,
+		field + ".address":      party.Address})
+}
+
+func validateSepaCharset(texts map[string]string) error {
+	for field, text := range texts {
+		if !sepaCharset.MatchString(text) {
+			return &SchemeValidationError{sepaPaymentScheme, field, "must be written in the SEPA character set"}
+		}
+	}
+	return nil
+}
+
+func isSepaEligible(payment Payment) bool {
+	return validateSepaPayment(payment) == nil
+}
+
+// isValidIBAN checks the format and the ISO 7064 MOD 97-10 check digits of the IBAN
+func isValidIBAN(number string) bool {
+	if len(number) > 34 || !iban.MatchString(number) {
+		return false
+	}
+
+	remainder := 0
+	for _, character := range number[4:] + number[:4] {
+		if character >= 'A' {
+			remainder = (remainder*100 + int(character-'A') + 10) % 97
+		} else {
+			remainder = (remainder*10 + int(character-'0')) % 97
+		}
+	}
+	return remainder == 1
+}
+
+func hasCents(amount float64) bool {
+	cents := amount * 100
+	return math.Abs(cents-math.Round(cents)) < 1e-6
+}
+
+// A sepaDebtor is the account debited by a payment information block on the execution date
+type sepaDebtor struct {
+	iban          string
+	bic           string
+	name          string
+	address       string
+	executionDate string
+}
+
+// renderSepaCreditTransfer writes the payments into a pain.001.001.03 SEPA Credit Transfer initiation
+func renderSepaCreditTransfer(payments []Payment, initiatingParty string, messageID string, createdAt time.Time) ([]byte, error) {
+	var debtors []sepaDebtor
+	paymentsByDebtor := make(map[sepaDebtor][]Payment)
+
+	for _, payment := range payments {
+		if err := validateSepaPayment(payment); err != nil {
+			return nil, err
+		}
+		debtor := newSepaDebtor(payment, createdAt)
+		if _, ok := paymentsByDebtor[debtor]; !ok {
+			debtors = append(debtors, debtor)
+		}
+		paymentsByDebtor[debtor] = append(paymentsByDebtor[debtor], payment)
+	}
+
+	messageID = truncateText(messageID, 28)
+	initiation := pain001Initiation{
+		GroupHeader: pain001GroupHeader{
+			MessageID:        messageID,
+			CreationDateTime: createdAt.UTC().Format(iso20022DateTimeLayout),
+			InitiatingParty:  iso20022Party{Name: truncateText(initiatingParty, sepaNameLength)}}}
+
+	var totalCents int64
+	for i, debtor := range debtors {
+		information := pain001PaymentInformation{
+			ID:                     fmt.Sprintf("%s-%d", messageID, i+1),
+			Method:                 sepaPaymentMethod,
+			PaymentType:            &pain001PaymentType{ServiceLevel: iso20022Code{Code: sepaServiceLevel}},
+			RequestedExecutionDate: pain001ExecutionDate{Value: debtor.executionDate},
+			Debtor:                 newSepaParty(debtor.name, debtor.address),
+			DebtorAccount:          iso20022Account{ID: iso20022AccountID{IBAN: debtor.iban}},
+			DebtorAgent:            newSepaAgent(debtor.bic),
+			ChargeBearer:           sepaChargeBearer}
+
+		var informationCents int64
+		for _, payment := range paymentsByDebtor[debtor] {
+			cents := int64(math.Round(payment.Attributes.Amount * 100))
+			information.Transactions = append(information.Transactions, newSepaTransaction(payment, cents))
+			informationCents += cents
+		}
+
+		information.NumberOfTransactions = fmt.Sprint(len(information.Transactions))
+		information.ControlSum = sepaAmount(informationCents)
+		initiation.PaymentInformation = append(initiation.PaymentInformation, information)
+		totalCents += informationCents
+	}
+
+	initiation.GroupHeader.NumberOfTransactions = fmt.Sprint(len(payments))
+	initiation.GroupHeader.ControlSum = sepaAmount(totalCents)
+
+	return marshalISO20022(pain001Document{
+		XMLName:    xml.Name{Space: sepaNamespace, Local: "Document"},
+		Initiation: initiation})
+}
+
+func newSepaDebtor(payment Payment, createdAt time.Time) sepaDebtor {
+	debtor := payment.Attributes.DebtorParty
+	executionDate := iso20022Date(payment.Attributes.ProcessingDate)
+	if len(executionDate) == 0 {
+		executionDate = createdAt.UTC().Format(iso20022DateLayout)
+	}
+
+	return sepaDebtor{
+		iban:          debtor.sponsorParty().AccountNumber,
+		bic:           debtor.sponsorParty().BankID,
+		name:          debtor.Name,
+		address:       debtor.Address,
+		executionDate: executionDate}
+}
+
+func newSepaTransaction(payment Payment, cents int64) pain001Transaction {
+	attributes := payment.Attributes
+	beneficiary := attributes.BeneficiaryParty.debtorParty()
+
+	creditor := newSepaParty(beneficiary.Name, beneficiary.Address)
+	transaction := pain001Transaction{
+		PaymentID: iso20022PaymentID{
+			InstructionID: iso20022MessageID(payment),
+			EndToEndID:    defaultText(attributes.EndToEndReference, iso20022NotProvided)},
+		Amount:                pain001Amount{InstructedAmount: iso20022Amount{Currency: sepaCurrency, Value: sepaAmount(cents)}},
+		Creditor:              &creditor,
+		CreditorAccount:       &iso20022Account{ID: iso20022AccountID{IBAN: beneficiary.sponsorParty().AccountNumber}},
+		RemittanceInformation: newISO20022Remittance(attributes.Reference)}
+
+	if bic := beneficiary.sponsorParty().BankID; len(bic) > 0 {
+		agent := newSepaAgent(bic)
+		transaction.CreditorAgent = &agent
+	}
+	return transaction
+}
+
+// The names of the SEPA parties are limited to 70 characters
+func newSepaParty(name string, address string) iso20022Party {
+	party := newISO20022Party(name, address)
+	party.Name = truncateText(party.Name, sepaNameLength)
+	return party
+}
+
+// pain.001.001.03 identifies the agents by BIC, a debtor agent without BIC is reported as not provided
+func newSepaAgent(bic string) iso20022Agent {
+	var agent iso20022Agent
+	if len(bic) > 0 {
+		agent.FinancialInstitutionID.BIC = bic
+	} else {
+		agent.FinancialInstitutionID.Other = &iso20022GenericAccount{ID: iso20022NotProvided}
+	}
+	return agent
+}
+
+func sepaAmount(cents int64) string {
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
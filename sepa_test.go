@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	. "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test SEPA scheme profile
+
+func TestValidateSepaPaymentSuccessful(t *testing.T) {
+	for _, payment := range MockSepaPayments(t)[:4] {
+		Nil(t, validateSepaPayment(payment), payment.ID)
+	}
+}
+
+func TestValidateSepaPaymentViolations(t *testing.T) {
+	tests := map[string]func(attributes *Attributes){
+		"currency":                    func(attributes *Attributes) { attributes.Currency = "GBP" },
+		"amount":                      func(attributes *Attributes) { attributes.Amount = 10.005 },
+		"reference":                   func(attributes *Attributes) { attributes.Reference = strings.Repeat("x", 141) },
+		"end_to_end_reference":        func(attributes *Attributes) { attributes.EndToEndReference = "Invoice #42" },
+		"debtor_party.account_number": func(attributes *Attributes) { attributes.DebtorParty.AccountNumberCode = bbanAccountCode },
+		"debtor_party.bank_id":        func(attributes *Attributes) { attributes.DebtorParty.SponsorParty.BankID = "COBA" },
+		"beneficiary_party.account_number": func(attributes *Attributes) {
+			attributes.BeneficiaryParty.DebtorParty.SponsorParty.AccountNumber = "FR1520041010050500013M02606"
+		},
+		"beneficiary_party.name": func(attributes *Attributes) { attributes.BeneficiaryParty.DebtorParty.Name = "Jürgen" },
+	}
+
+	for field, breakRule := range tests {
+		payment := MockSepaPayments(t)[0]
+		breakRule(&payment.Attributes)
+
+		err := validateSepaPayment(payment)
+
+		IsType(t, &SchemeValidationError{}, err, field)
+		if err, ok := err.(*SchemeValidationError); ok {
+			Equal(t, field, err.field)
+		}
+	}
+}
+
+func TestIsValidIBAN(t *testing.T) {
+	True(t, isValidIBAN("DE89370400440532013000"))
+	True(t, isValidIBAN("FR1420041010050500013M02606"))
+	True(t, isValidIBAN("GB29NWBK60161331926819"))
+	False(t, isValidIBAN("DE88370400440532013000"))
+	False(t, isValidIBAN("de89370400440532013000"))
+	False(t, isValidIBAN("12345678"))
+}
+
+func TestCreateSepaPaymentValidated(t *testing.T) {
+	valid, _ := json.Marshal(MockSepaPayments(t)[0])
+	invalid, _ := json.Marshal(MockSepaPayments(t)[4])
+
+	Equal(t, 201, ServeHTTP(methodPost, createPaymentPath, bytes.NewBuffer(valid), successful).Code)
+	Equal(t, 400, ServeHTTP(methodPost, createPaymentPath, bytes.NewBuffer(invalid), successful).Code)
+}
+
+// Test SEPA Credit Transfer file
+
+func TestRenderSepaCreditTransferMatchesGolden(t *testing.T) {
+	createdAt := time.Date(2019, 5, 3, 11, 0, 0, 0, time.UTC)
+
+	document, err := renderSepaCreditTransfer(MockSepaPayments(t)[:4], "Payments Backend", "1f4a2b3c4d5e6f708192a3b4c5d6e7f8", createdAt)
+	Nil(t, err)
+
+	AssertGolden(t, "test_resources/sepa/pain.001.001.03.golden.xml", document)
+}
+
+func TestRenderSepaCreditTransferGroupsByDebtorAndDate(t *testing.T) {
+	document, err := renderSepaCreditTransfer(MockSepaPayments(t)[:4], "Payments Backend", "1", time.Now())
+	Nil(t, err)
+
+	var pain001 pain001Document
+	Nil(t, xml.Unmarshal(document, &pain001))
+
+	Equal(t, sepaNamespace, pain001.XMLName.Space)
+	header := pain001.Initiation.GroupHeader
+	Equal(t, "4", header.NumberOfTransactions)
+	Equal(t, "1125.75", header.ControlSum)
+
+	var blocks []string
+	for _, information := range pain001.Initiation.PaymentInformation {
+		blocks = append(blocks, strings.Join([]string{information.DebtorAccount.ID.IBAN, information.RequestedExecutionDate.date(),
+			information.NumberOfTransactions, information.ControlSum}, " "))
+	}
+	Equal(t, []string{
+		"DE89370400440532013000 2019-05-06 2 120.50",
+		"DE89370400440532013000 2019-05-07 1 5.25",
+		"BE68539007547034 2019-05-06 1 1000.00"}, blocks)
+}
+
+func TestRenderSepaCreditTransferParsedBack(t *testing.T) {
+	document, err := renderSepaCreditTransfer(MockSepaPayments(t)[:4], "Payments Backend", "1", time.Now())
+	Nil(t, err)
+
+	payments, err := parsePain001(bytes.NewReader(document))
+	Nil(t, err)
+
+	Equal(t, 4, len(payments))
+	for i, payment := range MockSepaPayments(t)[:4] {
+		Equal(t, payment.Attributes.Amount, payments[i].Attributes.Amount)
+		Equal(t, payment.Attributes.ProcessingDate, payments[i].Attributes.ProcessingDate)
+		Equal(t, payment.Attributes.BeneficiaryParty.SponsorParty.AccountNumber, payments[i].Attributes.BeneficiaryParty.SponsorParty.AccountNumber)
+	}
+}
+
+func TestRenderSepaCreditTransferInvalidPayment(t *testing.T) {
+	_, err := renderSepaCreditTransfer(MockSepaPayments(t)[4:], "Payments Backend", "1", time.Now())
+
+	IsType(t, &SchemeValidationError{}, err)
+}
+
+// Test SEPA batch handler
+
+func TestCreateSepaBatchSuccessful(t *testing.T) {
+	repository := NewBatchRepositoryMock()
+
+	response := ServeBatchHTTP(methodPost, sepaBatchesPath+"?organisation_id=123", MockSepaPayments(t), repository)
+
+	Equal(t, 201, response.Code)
+	Equal(t, "application/xml; charset=UTF-8", response.Header().Get("Content-Type"))
+	Equal(t, 4, len(repository.submitted))
+	NotContains(t, repository.submitted, "s5")
+	Contains(t, response.Body.String(), "<NbOfTxs>4</NbOfTxs>")
+}
+
+func TestCreateSepaBatchOnProcessingDate(t *testing.T) {
+	repository := NewBatchRepositoryMock()
+
+	response := ServeBatchHTTP(methodPost, sepaBatchesPath+"?organisation_id=123&processing_date=2019-05-07", MockSepaPayments(t), repository)
+
+	Equal(t, 201, response.Code)
+	for _, batch := range repository.batches {
+		Equal(t, []string{"s3"}, batch.PaymentIDs)
+	}
+}
+
+func TestCreateSepaBatchNothingToSubmit(t *testing.T) {
+	response := ServeBatchHTTP(methodPost, sepaBatchesPath+"?organisation_id=456", MockSepaPayments(t), NewBatchRepositoryMock())
+
+	Equal(t, 404, response.Code)
+}
+
+func TestCreateSepaBatchMissingOrganisation(t *testing.T) {
+	response := ServeBatchHTTP(methodPost, sepaBatchesPath, MockSepaPayments(t), NewBatchRepositoryMock())
+
+	Equal(t, 400, response.Code)
+}
+
+// ---------------------------------------------------- //
+
+func MockSepaPayments(t *testing.T) []Payment {
+	file, err := ioutil.ReadFile("test_resources/sepa/sepa_payments.json")
+	Nil(t, err)
+
+	var payments PaymentListResult
+	Nil(t, json.Unmarshal(file, &payments))
+	return payments.Data
+}
@@ -1,40 +1,95 @@
 package main
 
 import (
-	"context"
+	"encoding/json"
 	"flag"
-	"github.com/spf13/viper"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
-	"os/signal"
 	"time"
 )
 
-const (
-	serverHost    string = "server_host"
-	serverPort    string = "server_port"
-	serverTimeout string = "server_timeout"
-
-	mongoDbHost    string = "mongodb_host"
-	mongoDbPort    string = "mongodb_port"
-	mongoDbTimeout string = "mongodb_timeout"
+var (
+	rebuildProjection = flag.Bool("rebuild-projection", false, "rebuild the event-sourced payment projection on start")
+	gracefulTimeout   = flag.Duration("graceful-timeout", time.Second*15, "graceful server shutdown timeout")
+	printConfig       = flag.Bool("print-config", false, "print the effective configuration with the secrets redacted and exit")
 )
 
 func main() {
 	log.Print("Start Payments Server Application")
 
-	initializeEnvironmentProperties()
+	log.Print("Checking environment properties...")
+	config, err := loadConfiguration(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Print("Environment properties - OK")
 
-	repository, mongoClient := initializeMongoRepository()
+	if *printConfig {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(config.redacted())
+		return
+	}
 
-	setPaymentRepository(repository)
+	initializeLogging(config.LogLevel, config.LogFormat)
+	slog.Info("Configuration loaded", "configuration", config.redacted())
+
+	life := newLifecycle(*gracefulTimeout, time.Duration(config.ShutdownDrainDelay)*time.Second)
+
+	tracerProvider := initializeTracing(config)
+	life.addCloser(func() { shutdownTracing(tracerProvider) })
+
+	setMongoDbTimeout(time.Duration(config.MongoDbTimeout) * time.Second)
+	repository, mongoClient := initializeMongoRepository(config)
+	life.addCloser(func() { shutdownMongoRepository(mongoClient) })
+
+	setPaymentRepository(instrumentPaymentRepository(repository))
+	setPaymentTransactionsSupported(mongoSupportsTransactions(mongoClient))
+	setWebhookRepository(&mongoWebhookRepository{client: mongoClient})
+	setBatchRepository(&mongoBatchRepository{client: mongoClient})
+	setBacsServiceUser(config.BacsServiceUserNumber, config.BacsServiceUserName)
+	setSepaInitiatingParty(config.SepaInitiatingPartyName)
+	setExportTimeout(time.Duration(config.ExportTimeout) * time.Second)
+	setV1Deprecation(parseConfigDate(config.V1DeprecationDate), parseConfigDate(config.V1SunsetDate))
+	setOpenAPIValidation(config.OpenAPIValidation)
+	setGraphQLMaxCost(config.GraphQLMaxCost)
+
+	publisher := initializeEventPublisher(config, !config.OutboxEnabled)
+	life.addCloser(func() { shutdownEventPublisher(publisher) })
+
+	if config.OutboxEnabled {
+		// The events are published from the outbox by the dispatcher rather than by the endpoints
+		setEventPublisher(noopPublisher{})
+		dispatcher := newWebhookDispatcher(webhookRepository, publisher,
+			time.Duration(config.WebhookTimeout)*time.Second,
+			config.WebhookMaxAttempts,
+			time.Duration(config.WebhookInitialBackoff)*time.Second,
+			time.Duration(config.WebhookPollInterval)*time.Second)
+		life.runWorker(dispatcher.run)
+
+		// Payment streams are fed from the outbox, which also allows clients to resume them
+		setPaymentEventLog(&mongoEventLog{client: mongoClient})
+		life.runWorker(func(stop <-chan struct{}) { watchPaymentOutbox(mongoClient, paymentEvents, stop) })
+	} else {
+		setEventPublisher(publisher)
+	}
+
+	checks := []*readinessCheck{mongoReadinessCheck(mongoClient)}
+	if config.OutboxEnabled {
+		checks = append(checks, outboxReadinessCheck(mongoClient, config.OutboxBacklogLimit))
+	}
+	checks = append(checks, publisherReadinessChecks(publisher)...)
+	setReadinessChecks(time.Duration(config.ReadinessTimeout)*time.Second,
+		time.Duration(config.ReadinessCacheTTL)*time.Second, checks...)
 
 	router := configureRouter()
 
-	host := viper.GetString(serverHost)
-	port := viper.GetString(serverPort)
-	timeout := time.Duration(viper.GetInt(serverTimeout)) * time.Second
+	host := config.ServerHost
+	port := config.ServerPort
+	timeout := time.Duration(config.ServerTimeout) * time.Second
 
 	server := &http.Server{
 		Handler:      router,
@@ -42,70 +97,35 @@ func main() {
 		WriteTimeout: timeout,
 		ReadTimeout:  timeout,
 	}
+	// The payment streams never become idle, so they are ended for the server to drain its connections
+	server.RegisterOnShutdown(func() { _ = paymentEvents.Close() })
+	life.addServer("http", stopHTTPServer(server))
 
-	var wait time.Duration
-	flag.DurationVar(&wait, "graceful-timeout", time.Second*15, "graceful server shutdown timeout")
-	flag.Parse()
+	grpcServer := newGRPCServer()
+	life.addServer("grpc", stopGRPCServer(grpcServer))
 
 	go func() {
-		log.Printf("Starting web server at [%s:%s] ...", host, port)
-		log.Fatal(server.ListenAndServe())
+		slog.Info("Starting web server ...", "host", host, "port", port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fatal("Web server failed", "error", err)
+		}
 	}()
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-
-	// Block until we receive our signal.
-	<-c
-
-	// Create a deadline to wait for.
-	ctx, cancel := context.WithTimeout(context.Background(), wait)
-	defer cancel()
-
-	shutdownMongoRepository(mongoClient)
-
-	log.Printf("Stopping web server at [%s:%s] ...", host, port)
-	_ = server.Shutdown(ctx)
-	log.Println("Web server stopped")
-
-	os.Exit(0)
-}
-
-func initializeEnvironmentProperties() {
-	log.Print("Checking environment properties...")
-
-	configurationFile := flag.String("conf", "./config/server.json", "Path to configuration file")
-	flag.Parse()
-
-	viper.SetConfigFile(*configurationFile)
-
-	if err := viper.ReadInConfig(); err != nil {
-		log.Fatalf("Error reading config file, %s", err)
-	}
-
-	if !viper.IsSet(serverHost) {
-		log.Fatal("Server host property is not configured")
-	}
-
-	if !viper.IsSet(serverPort) {
-		log.Fatal("Server port property is not configured")
-	}
-
-	if !viper.IsSet(mongoDbTimeout) {
-		log.Fatal("MongoDB timeout property is not configured")
-	}
-
-	if !viper.IsSet(mongoDbHost) {
-		log.Fatal("MongoDB host property is not configured")
-	}
+	go func() {
+		slog.Info("Starting gRPC server ...", "host", host, "port", config.GRPCPort)
+		listener, err := net.Listen("tcp", host+":"+config.GRPCPort)
+		if err == nil {
+			err = grpcServer.Serve(listener)
+		}
+		if err != nil {
+			fatal("gRPC server failed", "error", err)
+		}
+	}()
 
-	if !viper.IsSet(mongoDbPort) {
-		log.Fatal("MongoDB port property is not configured")
-	}
+	received := <-shutdownSignals()
+	slog.Info("Shutdown signal received", "signal", received.String())
 
-	if !viper.IsSet(mongoDbTimeout) {
-		log.Fatal("MongoDB timeout property is not configured")
+	if err := life.shutdown(); err != nil {
+		os.Exit(1)
 	}
-
-	log.Print("Environment properties - OK")
 }
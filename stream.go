@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	streamBufferSize        int           = 64
+	streamReplayLimit       int           = 1000
+	streamHeartbeatInterval time.Duration = 15 * time.Second
+	streamWriteWindow       time.Duration = 30 * time.Second
+
+	streamReplayHeader      string = "X-Payments-Stream-Replay"
+	streamReplayUnavailable string = "unavailable"
+)
+
+// PaymentEventLog is an interface which defines the methods must be implemented by a durable log of payment events
+type PaymentEventLog interface {
+	GetPaymentEventsAfter(eventID string, limit int) (events []PaymentEvent, err error)
+}
+
+var paymentEventLog PaymentEventLog
+
+func setPaymentEventLog(eventLog PaymentEventLog) {
+	paymentEventLog = eventLog
+}
+
+// paymentEventHub fans out payment events to the open payment streams
+type paymentEventHub struct {
+	mutex       sync.Mutex
+	subscribers map[chan PaymentEvent]bool
+}
+
+var paymentEvents = newPaymentEventHub()
+
+func newPaymentEventHub() *paymentEventHub {
+	return &paymentEventHub{subscribers: make(map[chan PaymentEvent]bool)}
+}
+
+func (h *paymentEventHub) subscribe() chan PaymentEvent {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	events := make(chan PaymentEvent, streamBufferSize)
+	h.subscribers[events] = true
+	return events
+}
+
+func (h *paymentEventHub) unsubscribe(events chan PaymentEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.subscribers[events] {
+		delete(h.subscribers, events)
+		close(events)
+	}
+}
+
+func (h *paymentEventHub) Publish(event PaymentEvent) (err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for events := range h.subscribers {
+		select {
+		case events <- event:
+		default:
+			delete(h.subscribers, events)
+			close(events)
+		}
+	}
+	return nil
+}
+
+// Close ends all open payment streams
+func (h *paymentEventHub) Close() (err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for events := range h.subscribers {
+		delete(h.subscribers, events)
+		close(events)
+	}
+	return nil
+}
+
+// A paymentStreamFilter selects the events of a payment stream by the organisation and the payment scheme
+type paymentStreamFilter struct {
+	organisationID string
+	paymentScheme  string
+}
+
+func (f paymentStreamFilter) matches(event PaymentEvent) bool {
+	if len(f.organisationID) > 0 && event.OrganisationID != f.organisationID {
+		return false
+	}
+	if len(f.paymentScheme) > 0 && (event.Payment == nil || event.Payment.Attributes.PaymentScheme != f.paymentScheme) {
+		return false
+	}
+	return true
+}
+
+// streamPaymentsEndpoint streams payment events as Server-Sent Events until the client disconnects
+func streamPaymentsEndpoint(writer http.ResponseWriter, request *http.Request) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		prepareFailureHeader(writer, request, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	filter := paymentStreamFilter{
+		organisationID: request.URL.Query().Get("organisation_id"),
+		paymentScheme:  request.URL.Query().Get("payment_scheme")}
+
+	// Subscribing before the replay guarantees no event is lost between the replay and the live events
+	events := paymentEvents.subscribe()
+	defer paymentEvents.unsubscribe(events)
+
+	lastEventID := request.Header.Get("Last-Event-ID")
+	if _, err := primitive.ObjectIDFromHex(lastEventID); len(lastEventID) > 0 && err != nil {
+		prepareFailureHeader(writer, request, &InvalidLastEventIDError{lastEventID})
+		return
+	}
+
+	// The live events up to the last event are skipped only when the events after it have been replayed
+	var replayedEventID string
+	var missedEvents []PaymentEvent
+	if len(lastEventID) > 0 && paymentEventLog == nil {
+		writer.Header().Set(streamReplayHeader, streamReplayUnavailable)
+	} else if len(lastEventID) > 0 {
+		var err error
+		missedEvents, err = paymentEventLog.GetPaymentEventsAfter(lastEventID, streamReplayLimit)
+		if err != nil {
+			prepareFailureHeader(writer, request, err)
+			return
+		}
+		replayedEventID = lastEventID
+	}
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("Connection", "keep-alive")
+	writer.WriteHeader(http.StatusOK)
+
+	// The server WriteTimeout applies to the whole response, so the deadline is extended before every write
+	controller := http.NewResponseController(writer)
+	write := func(message string) bool {
+		_ = controller.SetWriteDeadline(time.Now().Add(streamWriteWindow))
+		if _, err := fmt.Fprint(writer, message); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	if !write(": connected\n\n") {
+		return
+	}
+
+	for _, event := range missedEvents {
+		replayedEventID = event.ID
+		if filter.matches(event) && !write(formatServerSentEvent(event)) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if !write(": heartbeat\n\n") {
+				return
+			}
+		case event, open := <-events:
+			if !open {
+				slog.WarnContext(request.Context(), "Payment stream closed by the server, the client resumes it from the last event")
+				return
+			}
+			if event.ID <= replayedEventID || !filter.matches(event) {
+				continue
+			}
+			if !write(formatServerSentEvent(event)) {
+				return
+			}
+		}
+	}
+}
+
+// notifyPaymentEvent publishes the event of a successful change
+func notifyPaymentEvent(eventType string, payment Payment) {
+	if eventType == paymentCreatedEvent {
+		countCreatedPayment(payment)
+	}
+
+	event := newPaymentEvent(eventType, payment)
+	if err := eventPublisher.Publish(event); err != nil {
+		slog.Error("Unexpected error while publishing event", "event_id", event.ID, "payment_id", payment.ID, "error", err)
+	}
+}
+
+func formatServerSentEvent(event PaymentEvent) string {
+	data, _ := json.Marshal(event)
+	return fmt.Sprintf("id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	. "github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type PaymentEventLogMock struct {
+	events []PaymentEvent
+}
+
+func (m *PaymentEventLogMock) GetPaymentEventsAfter(eventID string, limit int) (events []PaymentEvent, err error) {
+	for _, event := range m.events {
+		if event.ID > eventID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func TestStreamPaymentsFiltersByOrganisationAndScheme(t *testing.T) {
+	server := httptest.NewServer(MockRouter(successful))
+	t.Cleanup(server.Close)
+
+	reader := OpenPaymentStream(t, server.URL+streamPaymentsPath+"?organisation_id=123&payment_scheme=FPS", "")
+
+	otherOrganisation := Payment{ID: "1", OrganisationID: "456", Version: 1}
+	otherOrganisation.Attributes.PaymentScheme = "FPS"
+	otherScheme := Payment{ID: "2", OrganisationID: "123", Version: 1}
+	otherScheme.Attributes.PaymentScheme = "Bacs"
+	matching := Payment{ID: "3", OrganisationID: "123", Version: 1}
+	matching.Attributes.PaymentScheme = "FPS"
+
+	notifyPaymentEvent(paymentCreatedEvent, otherOrganisation)
+	notifyPaymentEvent(paymentCreatedEvent, otherScheme)
+	notifyPaymentEvent(paymentCreatedEvent, matching)
+
+	event := ReadServerSentEvent(t, reader)
+	Equal(t, "event: "+paymentCreatedEvent, event[1])
+	Contains(t, event[2], `"payment_id":"3"`)
+}
+
+func TestStreamPaymentsResumesFromLastEventID(t *testing.T) {
+	missed := newPaymentEvent(paymentUpdatedEvent, Payment{ID: "1", OrganisationID: "123", Version: 2})
+	received := newPaymentEvent(paymentCreatedEvent, Payment{ID: "1", OrganisationID: "123", Version: 1})
+	received.ID = primitive.NewObjectIDFromTimestamp(time.Unix(0, 0)).Hex()
+	setPaymentEventLog(&PaymentEventLogMock{events: []PaymentEvent{received, missed}})
+	defer setPaymentEventLog(nil)
+
+	server := httptest.NewServer(MockRouter(successful))
+	t.Cleanup(server.Close)
+
+	reader := OpenPaymentStream(t, server.URL+streamPaymentsPath, received.ID)
+
+	event := ReadServerSentEvent(t, reader)
+	Equal(t, "id: "+missed.ID, event[0])
+	Equal(t, "event: "+paymentUpdatedEvent, event[1])
+}
+
+func TestStreamPaymentsInvalidLastEventID(t *testing.T) {
+	server := httptest.NewServer(MockRouter(successful))
+	t.Cleanup(server.Close)
+
+	request, _ := http.NewRequest(methodGet, server.URL+streamPaymentsPath, nil)
+	request.Header.Set("Last-Event-ID", "0")
+	response, err := http.DefaultClient.Do(request)
+	Nil(t, err)
+	_ = response.Body.Close()
+
+	Equal(t, 400, response.StatusCode)
+}
+
+func TestStreamPaymentsReplayUnavailable(t *testing.T) {
+	server := httptest.NewServer(MockRouter(successful))
+	t.Cleanup(server.Close)
+
+	request, _ := http.NewRequest(methodGet, server.URL+streamPaymentsPath, nil)
+	request.Header.Set("Last-Event-ID", primitive.NewObjectID().Hex())
+	response, err := http.DefaultClient.Do(request)
+	Nil(t, err)
+	_ = response.Body.Close()
+
+	Equal(t, 200, response.StatusCode)
+	Equal(t, streamReplayUnavailable, response.Header.Get(streamReplayHeader))
+}
+
+func TestDeletePaymentPublishesEvent(t *testing.T) {
+	events := paymentEvents.subscribe()
+	defer paymentEvents.unsubscribe(events)
+
+	response := ServeHTTP(methodDelete, preparePaymentURL(deletePaymentPath, "1"), http.NoBody, successful)
+
+	Equal(t, 200, response.Code)
+	select {
+	case event := <-events:
+		Equal(t, paymentDeletedEvent, event.Type)
+		Equal(t, "123", event.OrganisationID)
+		Equal(t, 2, event.Version)
+	case <-time.After(time.Second):
+		Fail(t, "payment deleted event not published")
+	}
+}
+
+// ---------------------------------------------------- //
+
+func OpenPaymentStream(t *testing.T, url string, lastEventID string) *bufio.Reader {
+	request, _ := http.NewRequest(methodGet, url, nil)
+	if len(lastEventID) > 0 {
+		request.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	Nil(t, err)
+	t.Cleanup(func() { _ = response.Body.Close() })
+
+	Equal(t, 200, response.StatusCode)
+	Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(response.Body)
+	Equal(t, []string{": connected"}, ReadServerSentEvent(t, reader))
+	return reader
+}
+
+func ReadServerSentEvent(t *testing.T, reader *bufio.Reader) (lines []string) {
+	for {
+		line, err := reader.ReadString('\n')
+		Nil(t, err)
+
+		line = strings.TrimSuffix(line, "\n")
+		if len(line) == 0 {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
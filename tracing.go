@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	noTracingExporter     string = "none"
+	otlpTracingExporter   string = "otlp"
+	stdoutTracingExporter string = "stdout"
+
+	tracerName         string = "github.com/vba270419/payments-backend-go"
+	tracingServiceName string = "payments-backend"
+
+	tracingShutdownTimeout time.Duration = 5 * time.Second
+)
+
+// tracer returns the tracer of the application from the current tracer provider
+func tracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(tracerName)
+}
+
+// initializeTracing sets the tracer provider exporting the spans to the configured exporter
+func initializeTracing(config configuration) *sdktrace.TracerProvider {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch config.TracingExporter {
+	case otlpTracingExporter:
+		endpoint := config.TracingOTLPEndpoint
+		slog.Info("Exporting traces to OTLP collector", "endpoint", endpoint)
+		exporter, err = otlptracehttp.New(context.Background(), otlptracehttp.WithEndpoint(endpoint), otlptracehttp.WithInsecure())
+	case stdoutTracingExporter:
+		slog.Info("Exporting traces to standard output")
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil
+	}
+	if err != nil {
+		fatal("Failed to create trace exporter", "error", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", tracingServiceName))))
+	otel.SetTracerProvider(provider)
+	return provider
+}
+
+// shutdownTracing exports the spans which are still buffered by the provider
+func shutdownTracing(provider *sdktrace.TracerProvider) {
+	if provider == nil {
+		return
+	}
+
+	slog.Info("Closing tracer provider ...")
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancel()
+	if err := provider.Shutdown(ctx); err != nil {
+		slog.Error("Unexpected error while closing tracer provider", "error", err)
+	}
+	slog.Info("Tracer provider closed")
+}
+
+// tracingMiddleware serves the request in a server span named by the route template
+func tracingMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		route := requestRoute(request)
+
+		ctx := otel.GetTextMapPropagator().Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+		ctx, span := tracer().Start(ctx, request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", request.URL.Path)))
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: writer, statusCode: http.StatusOK}
+		handler.ServeHTTP(recorder, request.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", recorder.statusCode))
+		if recorder.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.statusCode))
+		}
+	})
+}
+
+// endSpan ends the span, recording the error when the traced call has failed
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// newMongoTracingMonitor traces the MongoDB commands run within a traced context as client spans
+func newMongoTracingMonitor() *event.CommandMonitor {
+	var lock sync.Mutex
+	spans := make(map[int64]trace.Span)
+
+	finished := func(requestID int64, err error) {
+		lock.Lock()
+		span, ok := spans[requestID]
+		delete(spans, requestID)
+		lock.Unlock()
+
+		if ok {
+			endSpan(span, err)
+		}
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, started *event.CommandStartedEvent) {
+			if !trace.SpanContextFromContext(ctx).IsValid() {
+				return
+			}
+
+			_, span := tracer().Start(ctx, "mongodb."+started.CommandName,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					attribute.String("db.system", "mongodb"),
+					attribute.String("db.name", started.DatabaseName),
+					attribute.String("db.operation", started.CommandName),
+					attribute.String("db.mongodb.connection_id", started.ConnectionID)))
+
+			lock.Lock()
+			defer lock.Unlock()
+			spans[started.RequestID] = span
+		},
+		Succeeded: func(_ context.Context, succeeded *event.CommandSucceededEvent) {
+			finished(succeeded.RequestID, nil)
+		},
+		Failed: func(_ context.Context, failed *event.CommandFailedEvent) {
+			finished(failed.RequestID, errors.New(failed.Failure))
+		},
+	}
+}
+
+// joinCommandMonitors joins the monitors into the single monitor the MongoDB client accepts
+func joinCommandMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, started *event.CommandStartedEvent) {
+			for _, monitor := range monitors {
+				if monitor.Started != nil {
+					monitor.Started(ctx, started)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, succeeded *event.CommandSucceededEvent) {
+			for _, monitor := range monitors {
+				if monitor.Succeeded != nil {
+					monitor.Succeeded(ctx, succeeded)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, failed *event.CommandFailedEvent) {
+			for _, monitor := range monitors {
+				if monitor.Failed != nil {
+					monitor.Failed(ctx, failed)
+				}
+			}
+		},
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	. "github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// ContextRepositoryMock runs the MongoDB commands of the payment reads and deletes through the command monitor with
+// the context it is bound to, like the MongoDB driver does
+type ContextRepositoryMock struct {
+	*PaymentRepositoryMock
+	monitor *event.CommandMonitor
+	ctx     context.Context
+}
+
+func (m *ContextRepositoryMock) WithContext(ctx context.Context) PaymentRepository {
+	return &ContextRepositoryMock{m.PaymentRepositoryMock, m.monitor, ctx}
+}
+
+func (m *ContextRepositoryMock) GetPayment(paymentID string) (payment Payment, err error) {
+	m.runCommand("find")
+	return m.PaymentRepositoryMock.GetPayment(paymentID)
+}
+
+func (m *ContextRepositoryMock) DeletePayment(paymentID string) (err error) {
+	m.runCommand("delete")
+	return m.PaymentRepositoryMock.DeletePayment(paymentID)
+}
+
+func (m *ContextRepositoryMock) runCommand(command string) {
+	m.monitor.Started(m.ctx, &event.CommandStartedEvent{CommandName: command, DatabaseName: "payments", RequestID: 1})
+	m.monitor.Succeeded(m.ctx, &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: command, RequestID: 1}})
+}
+
+// Test request traces
+
+func TestRequestTraceTree(t *testing.T) {
+	spans := TraceSpans(t)
+	setPaymentRepository(instrumentPaymentRepository(&ContextRepositoryMock{PaymentRepositoryMock: &PaymentRepositoryMock{}, monitor: newMongoTracingMonitor()}))
+
+	request, _ := http.NewRequest(methodDelete, preparePaymentURL(deletePaymentPath, "1"), http.NoBody)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	configureRouter().ServeHTTP(httptest.NewRecorder(), request)
+
+	Equal(t, []string{
+		"DELETE /v1/payments/delete/{id}",
+		"  PaymentRepository.GetPayment",
+		"    mongodb.find",
+		"  PaymentRepository.DeletePayment",
+		"    mongodb.delete"}, SpanTree(spans.GetSpans()))
+
+	root := spans.GetSpans()[len(spans.GetSpans())-1]
+	Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", root.SpanContext.TraceID().String())
+	Equal(t, "00f067aa0ba902b7", root.Parent.SpanID().String())
+	True(t, root.Parent.IsRemote())
+}
+
+func TestRequestTraceFailure(t *testing.T) {
+	spans := TraceSpans(t)
+	setPaymentRepository(instrumentPaymentRepository(&PaymentRepositoryMock{mode: dbFailure}))
+
+	request, _ := http.NewRequest(methodGet, preparePaymentURL(getPaymentPath, "1"), http.NoBody)
+	configureRouter().ServeHTTP(httptest.NewRecorder(), request)
+
+	Equal(t, []string{"GET /v1/payments/get/{id}", "  PaymentRepository.GetPayment"}, SpanTree(spans.GetSpans()))
+	for _, span := range spans.GetSpans() {
+		Equal(t, codes.Error, span.Status.Code, span.Name)
+	}
+	False(t, spans.GetSpans()[1].Parent.IsValid())
+}
+
+func TestTransactionTraceTree(t *testing.T) {
+	spans := TraceSpans(t)
+	ctx, span := tracer().Start(context.Background(), "batch")
+
+	repository := bindPaymentRepository(instrumentPaymentRepository(NewTransactionalRepositoryMock()), ctx)
+	err := repository.(TransactionalPaymentRepository).RunInTransaction(func(repository PaymentRepository) error {
+		return repository.UpdatePayment(Payment{ID: "p1", Version: 1})
+	})
+	span.End()
+
+	Nil(t, err)
+	Equal(t, []string{
+		"batch",
+		"  PaymentRepository.RunInTransaction",
+		"    PaymentRepository.UpdatePayment"}, SpanTree(spans.GetSpans()))
+}
+
+// Test MongoDB traces
+
+func TestMongoCommandsTracedWithinTraces(t *testing.T) {
+	spans := TraceSpans(t)
+	monitor := newMongoTracingMonitor()
+
+	monitor.Started(context.Background(), &event.CommandStartedEvent{CommandName: "find", RequestID: 1})
+	monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{RequestID: 1}})
+	Empty(t, spans.GetSpans())
+
+	ctx, span := tracer().Start(context.Background(), "call")
+	monitor.Started(ctx, &event.CommandStartedEvent{CommandName: "insert", DatabaseName: "payments", RequestID: 2})
+	monitor.Failed(ctx, &event.CommandFailedEvent{CommandFinishedEvent: event.CommandFinishedEvent{RequestID: 2}, Failure: "E11000 duplicate key error"})
+	span.End()
+
+	Equal(t, []string{"call", "  mongodb.insert"}, SpanTree(spans.GetSpans()))
+	Equal(t, codes.Error, spans.GetSpans()[0].Status.Code)
+	Equal(t, "E11000 duplicate key error", spans.GetSpans()[0].Status.Description)
+}
+
+func TestJoinCommandMonitors(t *testing.T) {
+	var commands []string
+	counting := &event.CommandMonitor{Started: func(_ context.Context, started *event.CommandStartedEvent) {
+		commands = append(commands, started.CommandName)
+	}}
+
+	monitor := joinCommandMonitors(counting, &event.CommandMonitor{}, counting)
+	monitor.Started(context.Background(), &event.CommandStartedEvent{CommandName: "find"})
+	monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{})
+	monitor.Failed(context.Background(), &event.CommandFailedEvent{})
+
+	Equal(t, []string{"find", "find"}, commands)
+}
+
+// ---------------------------------------------------- //
+
+// TraceSpans records the spans of the test in memory, the spans are exported as soon as they end
+func TraceSpans(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+	return exporter
+}
+
+// SpanTree lists the names of the spans indented by their depth in the tree, the children of a span follow it in the
+// order they are started
+func SpanTree(spans tracetest.SpanStubs) (tree []string) {
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].StartTime.Before(spans[j].StartTime) })
+
+	var walk func(parent string, depth int)
+	walk = func(parent string, depth int) {
+		for _, span := range spans {
+			if span.Parent.IsValid() && !span.Parent.IsRemote() && span.Parent.SpanID().String() == parent ||
+				len(parent) == 0 && (!span.Parent.IsValid() || span.Parent.IsRemote()) {
+				tree = append(tree, strings.Repeat("  ", depth)+span.Name)
+				walk(span.SpanContext.SpanID().String(), depth+1)
+			}
+		}
+	}
+	walk("", 0)
+	return tree
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var webhookRepository WebhookRepository
+
+func setWebhookRepository(repository WebhookRepository) {
+	webhookRepository = repository
+}
+
+// The subscription must belong to an organisation and point to an absolute http(s) URL, the secret is generated when not provided
+func decodeAndValidateSubscription(request *http.Request) (subscription WebhookSubscription, err error) {
+	err = json.NewDecoder(request.Body).Decode(&subscription)
+	if err != nil {
+		return subscription, err
+	}
+
+	subscriptionURL, err := url.Parse(subscription.URL)
+	if err != nil || len(subscription.OrganisationID) == 0 ||
+		(subscriptionURL.Scheme != "http" && subscriptionURL.Scheme != "https") || len(subscriptionURL.Host) == 0 {
+		return subscription, &InvalidWebhookSubscriptionError{subscription}
+	}
+
+	if len(subscription.Secret) == 0 {
+		secret := make([]byte, 32)
+		_, _ = rand.Read(secret)
+		subscription.Secret = hex.EncodeToString(secret)
+	}
+
+	return subscription, nil
+}
+
+func createWebhookSubscriptionEndpoint(writer http.ResponseWriter, request *http.Request) {
+	subscription, err := decodeAndValidateSubscription(request)
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	newUUID, _ := uuid.NewUUID()
+	subscription.ID = newUUID.String()
+
+	err = webhookRepository.InsertSubscription(subscription)
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	prepareSuccessHeader(writer, http.StatusCreated)
+
+	// The secret is returned only once, when the subscription is created
+	_ = json.NewEncoder(writer).Encode(subscription)
+}
+
+func getWebhookSubscriptionsEndpoint(writer http.ResponseWriter, request *http.Request) {
+	subscriptions, err := webhookRepository.GetSubscriptions(request.URL.Query().Get("organisation_id"))
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+
+	prepareSuccessHeader(writer, http.StatusOK)
+
+	links := Links{
+		Self: prepareFullPaymentURL(request.Host, webhookSubscriptionsPath, "")}
+
+	result := WebhookSubscriptionListResult{subscriptions, links}
+	_ = json.NewEncoder(writer).Encode(result)
+}
+
+func deleteWebhookSubscriptionEndpoint(writer http.ResponseWriter, request *http.Request) {
+	subscriptionID := mux.Vars(request)["id"]
+
+	err := webhookRepository.DeleteSubscription(subscriptionID)
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	prepareSuccessHeader(writer, http.StatusOK)
+}
+
+func getWebhookDeadLettersEndpoint(writer http.ResponseWriter, request *http.Request) {
+	deliveries, err := webhookRepository.GetDeadLetters()
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	prepareSuccessHeader(writer, http.StatusOK)
+
+	links := Links{
+		Self: prepareFullPaymentURL(request.Host, webhookDeadLettersPath, "")}
+
+	result := WebhookDeliveryListResult{deliveries, links}
+	_ = json.NewEncoder(writer).Encode(result)
+}
+
+// redeliverWebhookEndpoint schedules the delivery for an immediate attempt with a fresh retry budget
+func redeliverWebhookEndpoint(writer http.ResponseWriter, request *http.Request) {
+	deliveryID := mux.Vars(request)["id"]
+
+	err := webhookRepository.RedeliverDelivery(deliveryID, time.Now().UTC())
+	if err != nil {
+		prepareFailureHeader(writer, request, err)
+		return
+	}
+
+	prepareSuccessHeader(writer, http.StatusAccepted)
+}
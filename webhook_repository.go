@@ -0,0 +1,254 @@
+package main
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log/slog"
+	"time"
+)
+
+const (
+	webhookSubscriptionsCollectionName string = "webhook_subscriptions"
+	webhookDeliveriesCollectionName    string = "webhook_deliveries"
+)
+
+// WebhookRepository is an interface which defines the methods must be implemented by a specific repository that persist webhooks to storage
+type WebhookRepository interface {
+	InsertSubscription(subscription WebhookSubscription) (err error)
+
+	DeleteSubscription(subscriptionID string) (err error)
+
+	GetSubscription(subscriptionID string) (subscription WebhookSubscription, err error)
+
+	GetSubscriptions(organisationID string) (subscriptions []WebhookSubscription, err error)
+
+	GetUndispatchedEvents(limit int) (events []PaymentEvent, err error)
+
+	MarkEventDispatched(event PaymentEvent, deliveries []WebhookDelivery) (err error)
+
+	ClaimDueDelivery(now time.Time, lease time.Duration) (delivery WebhookDelivery, claimed bool, err error)
+
+	GetDelivery(deliveryID string) (delivery WebhookDelivery, err error)
+
+	UpdateDelivery(delivery WebhookDelivery) (err error)
+
+	RedeliverDelivery(deliveryID string, now time.Time) (err error)
+
+	GetDeadLetters() (deliveries []WebhookDelivery, err error)
+}
+
+type mongoWebhookRepository struct {
+	client *mongo.Client
+}
+
+func (m *mongoWebhookRepository) InsertSubscription(subscription WebhookSubscription) (err error) {
+	_, err = m.subscriptions().InsertOne(getContextWithTimeout(), subscription)
+	if err != nil {
+		slog.Error("Unexpected error while inserting subscription", "error", err)
+		return &PersistenceError{}
+	}
+	return nil
+}
+
+func (m *mongoWebhookRepository) DeleteSubscription(subscriptionID string) (err error) {
+	result, err := m.subscriptions().DeleteOne(getContextWithTimeout(), bson.M{"_id": subscriptionID})
+	if err != nil {
+		slog.Error("Unexpected error while deleting subscription", "error", err)
+		return &PersistenceError{}
+	}
+
+	if result.DeletedCount == 0 {
+		return &WebhookSubscriptionNotFoundError{subscriptionID}
+	}
+	return nil
+}
+
+func (m *mongoWebhookRepository) GetSubscription(subscriptionID string) (subscription WebhookSubscription, err error) {
+	err = m.subscriptions().FindOne(getContextWithTimeout(), bson.M{"_id": subscriptionID}).Decode(&subscription)
+
+	if err == mongo.ErrNoDocuments {
+		return subscription, &WebhookSubscriptionNotFoundError{subscriptionID}
+	}
+
+	if err != nil {
+		slog.Error("Unexpected error while loading subscription", "error", err)
+		return subscription, &PersistenceError{}
+	}
+	return subscription, nil
+}
+
+func (m *mongoWebhookRepository) GetSubscriptions(organisationID string) (subscriptions []WebhookSubscription, err error) {
+	ctx := getContextWithTimeout()
+
+	filter := bson.M{}
+	if len(organisationID) > 0 {
+		filter["organisation_id"] = organisationID
+	}
+
+	cursor, err := m.subscriptions().Find(ctx, filter)
+	if err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while loading subscriptions", "error", err)
+		return subscriptions, &PersistenceError{}
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+
+	for cursor.Next(ctx) {
+		var subscription WebhookSubscription
+		if err = cursor.Decode(&subscription); err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while loading subscriptions", "error", err)
+			return subscriptions, &PersistenceError{}
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, nil
+}
+
+func (m *mongoWebhookRepository) GetUndispatchedEvents(limit int) (events []PaymentEvent, err error) {
+	ctx := getContextWithTimeout()
+
+	filter := bson.M{"dispatched_at": bson.M{"$exists": false}}
+	findOptions := options.Find().SetSort(bson.M{"_id": 1}).SetLimit(int64(limit))
+
+	cursor, err := getOutboxCollection(m.client).Find(ctx, filter, findOptions)
+	if err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while loading outbox", "error", err)
+		return events, &PersistenceError{}
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+
+	for cursor.Next(ctx) {
+		var event PaymentEvent
+		if err = cursor.Decode(&event); err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while loading outbox", "error", err)
+			return events, &PersistenceError{}
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (m *mongoWebhookRepository) MarkEventDispatched(event PaymentEvent, deliveries []WebhookDelivery) (err error) {
+	ctx := getContextWithTimeout()
+
+	// Delivery ids are derived from the event and the subscription, so deliveries inserted before a crash
+	// are not duplicated when the event is dispatched again
+	for _, delivery := range deliveries {
+		_, err = m.deliveries().InsertOne(ctx, delivery)
+		if err != nil && !isDuplicateKeyError(err) {
+			slog.ErrorContext(ctx, "Unexpected error while inserting delivery", "error", err)
+			return &PersistenceError{}
+		}
+	}
+
+	update := bson.M{"$set": bson.M{"dispatched_at": time.Now().UTC()}}
+	_, err = getOutboxCollection(m.client).UpdateOne(ctx, bson.M{"_id": event.ID}, update)
+	if err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while updating outbox", "error", err)
+		return &PersistenceError{}
+	}
+	return nil
+}
+
+// ClaimDueDelivery marks the oldest due delivery in flight until the lease expires, so it is sent by a single dispatcher
+func (m *mongoWebhookRepository) ClaimDueDelivery(now time.Time, lease time.Duration) (delivery WebhookDelivery, claimed bool, err error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": deliveryPending, "next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"status": deliveryInFlight, "lease_expires_at": bson.M{"$lte": now}}}}
+	update := bson.M{"$set": bson.M{"status": deliveryInFlight, "lease_expires_at": now.Add(lease)}}
+	updateOptions := options.FindOneAndUpdate().SetSort(bson.M{"next_attempt_at": 1}).SetReturnDocument(options.After)
+
+	err = m.deliveries().FindOneAndUpdate(getContextWithTimeout(), filter, update, updateOptions).Decode(&delivery)
+
+	if err == mongo.ErrNoDocuments {
+		return delivery, false, nil
+	}
+
+	if err != nil {
+		slog.Error("Unexpected error while claiming delivery", "error", err)
+		return delivery, false, &PersistenceError{}
+	}
+	return delivery, true, nil
+}
+
+func (m *mongoWebhookRepository) GetDelivery(deliveryID string) (delivery WebhookDelivery, err error) {
+	err = m.deliveries().FindOne(getContextWithTimeout(), bson.M{"_id": deliveryID}).Decode(&delivery)
+
+	if err == mongo.ErrNoDocuments {
+		return delivery, &WebhookDeliveryNotFoundError{deliveryID}
+	}
+
+	if err != nil {
+		slog.Error("Unexpected error while loading delivery", "error", err)
+		return delivery, &PersistenceError{}
+	}
+	return delivery, nil
+}
+
+func (m *mongoWebhookRepository) UpdateDelivery(delivery WebhookDelivery) (err error) {
+	result, err := m.deliveries().ReplaceOne(getContextWithTimeout(), bson.M{"_id": delivery.ID}, delivery)
+	if err != nil {
+		slog.Error("Unexpected error while updating delivery", "error", err)
+		return &PersistenceError{}
+	}
+
+	if result.MatchedCount == 0 {
+		return &WebhookDeliveryNotFoundError{delivery.ID}
+	}
+	return nil
+}
+
+// RedeliverDelivery schedules the delivery for an immediate attempt with a fresh retry budget unless it is in flight
+func (m *mongoWebhookRepository) RedeliverDelivery(deliveryID string, now time.Time) (err error) {
+	filter := bson.M{"_id": deliveryID, "status": bson.M{"$ne": deliveryInFlight}}
+	update := bson.M{
+		"$set":   bson.M{"status": deliveryPending, "attempts": 0, "next_attempt_at": now},
+		"$unset": bson.M{"lease_expires_at": ""}}
+
+	result, err := m.deliveries().UpdateOne(getContextWithTimeout(), filter, update)
+	if err != nil {
+		slog.Error("Unexpected error while updating delivery", "error", err)
+		return &PersistenceError{}
+	}
+
+	if result.MatchedCount == 0 {
+		if _, err = m.GetDelivery(deliveryID); err != nil {
+			return err
+		}
+		return &WebhookDeliveryInFlightError{deliveryID}
+	}
+	return nil
+}
+
+func (m *mongoWebhookRepository) GetDeadLetters() (deliveries []WebhookDelivery, err error) {
+	return m.findDeliveries(bson.M{"status": deliveryDeadLettered}, options.Find().SetSort(bson.M{"_id": 1}))
+}
+
+func (m *mongoWebhookRepository) findDeliveries(filter bson.M, findOptions *options.FindOptions) (deliveries []WebhookDelivery, err error) {
+	ctx := getContextWithTimeout()
+
+	cursor, err := m.deliveries().Find(ctx, filter, findOptions)
+	if err != nil {
+		slog.ErrorContext(ctx, "Unexpected error while loading deliveries", "error", err)
+		return deliveries, &PersistenceError{}
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+
+	for cursor.Next(ctx) {
+		var delivery WebhookDelivery
+		if err = cursor.Decode(&delivery); err != nil {
+			slog.ErrorContext(ctx, "Unexpected error while loading deliveries", "error", err)
+			return deliveries, &PersistenceError{}
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+func (m *mongoWebhookRepository) subscriptions() *mongo.Collection {
+	return m.client.Database(databaseName).Collection(webhookSubscriptionsCollectionName)
+}
+
+func (m *mongoWebhookRepository) deliveries() *mongo.Collection {
+	return m.client.Database(databaseName).Collection(webhookDeliveriesCollectionName)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	deliveryPending      string = "pending"
+	deliveryInFlight     string = "in_flight"
+	deliveryDelivered    string = "delivered"
+	deliveryDeadLettered string = "dead_lettered"
+
+	webhookSignatureHeader string = "X-Payments-Signature"
+	webhookTimestampHeader string = "X-Payments-Timestamp"
+	webhookEventTypeHeader string = "X-Payments-Event-Type"
+	webhookDeliveryHeader  string = "X-Payments-Delivery"
+
+	webhookBatchSize  int           = 100
+	maxWebhookBackoff time.Duration = time.Hour
+)
+
+// webhookDispatcher moves payment events from the outbox into webhook deliveries and delivers them
+type webhookDispatcher struct {
+	repository     WebhookRepository
+	publisher      EventPublisher
+	httpClient     *http.Client
+	lease          time.Duration
+	maxAttempts    int
+	initialBackoff time.Duration
+	pollInterval   time.Duration
+	now            func() time.Time
+}
+
+func newWebhookDispatcher(repository WebhookRepository, publisher EventPublisher, timeout time.Duration, maxAttempts int,
+	initialBackoff time.Duration, pollInterval time.Duration) *webhookDispatcher {
+
+	return &webhookDispatcher{
+		repository:     repository,
+		publisher:      publisher,
+		httpClient:     &http.Client{Timeout: timeout},
+		lease:          2 * timeout,
+		maxAttempts:    maxAttempts,
+		initialBackoff: initialBackoff,
+		pollInterval:   pollInterval,
+		now:            time.Now}
+}
+
+// run dispatches the outbox every poll interval until the stop channel is closed
+func (d *webhookDispatcher) run(stop <-chan struct{}) {
+	slog.Info("Starting webhook dispatcher ...")
+
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.dispatch()
+
+		select {
+		case <-stop:
+			slog.Info("Webhook dispatcher stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *webhookDispatcher) dispatch() {
+	d.dispatchOutbox()
+	d.deliverDue()
+}
+
+func (d *webhookDispatcher) dispatchOutbox() {
+	events, err := d.repository.GetUndispatchedEvents(webhookBatchSize)
+	if err != nil {
+		return
+	}
+
+	for _, event := range events {
+		subscriptions, err := d.repository.GetSubscriptions(event.OrganisationID)
+		if err != nil {
+			return
+		}
+
+		// An event which fails to be published stays in the outbox, so the following events keep their order
+		if err = d.publisher.Publish(event); err != nil {
+			slog.Error("Unexpected error while publishing event", "event_id", event.ID, "payment_id", event.PaymentID, "error", err)
+			return
+		}
+
+		var deliveries []WebhookDelivery
+		for _, subscription := range subscriptions {
+			deliveries = append(deliveries, WebhookDelivery{
+				ID:             event.ID + ":" + subscription.ID,
+				SubscriptionID: subscription.ID,
+				Event:          event,
+				Status:         deliveryPending,
+				NextAttemptAt:  d.now().UTC()})
+		}
+
+		if err = d.repository.MarkEventDispatched(event, deliveries); err != nil {
+			return
+		}
+	}
+}
+
+func (d *webhookDispatcher) deliverDue() {
+	for i := 0; i < webhookBatchSize; i++ {
+		delivery, claimed, err := d.repository.ClaimDueDelivery(d.now().UTC(), d.lease)
+		if err != nil || !claimed {
+			return
+		}
+		d.deliver(delivery)
+	}
+}
+
+func (d *webhookDispatcher) deliver(delivery WebhookDelivery) {
+	subscription, err := d.repository.GetSubscription(delivery.SubscriptionID)
+	if _, notFound := err.(*WebhookSubscriptionNotFoundError); notFound {
+		delivery.Status = deliveryDeadLettered
+		delivery.LastError = err.Error()
+		_ = d.repository.UpdateDelivery(delivery)
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	err = d.send(subscription, delivery)
+	delivery.Attempts++
+	delivery.LeaseExpiresAt = time.Time{}
+
+	switch {
+	case err == nil:
+		delivery.Status = deliveryDelivered
+		delivery.LastError = ""
+	case delivery.Attempts >= d.maxAttempts:
+		slog.Warn("Webhook delivery dead-lettered", "delivery_id", delivery.ID, "attempts", delivery.Attempts, "error", err)
+		delivery.Status = deliveryDeadLettered
+		delivery.LastError = err.Error()
+	default:
+		delivery.Status = deliveryPending
+		delivery.NextAttemptAt = d.now().UTC().Add(webhookBackoff(d.initialBackoff, delivery.Attempts))
+		delivery.LastError = err.Error()
+	}
+
+	_ = d.repository.UpdateDelivery(delivery)
+}
+
+func (d *webhookDispatcher) send(subscription WebhookSubscription, delivery WebhookDelivery) error {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(methodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	request.Header.Set(webhookTimestampHeader, timestamp)
+	request.Header.Set(webhookSignatureHeader, "sha256="+signWebhookPayload(subscription.Secret, timestamp, body))
+	request.Header.Set(webhookEventTypeHeader, delivery.Event.Type)
+	request.Header.Set(webhookDeliveryHeader, delivery.ID)
+
+	response, err := d.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	_ = response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("subscriber responded with status %d", response.StatusCode)
+	}
+	return nil
+}
+
+// signWebhookPayload returns the hex encoded HMAC-SHA256 of the timestamp and the payload joined by a dot
+func signWebhookPayload(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff doubles the initial backoff with every failed attempt up to maxWebhookBackoff
+func webhookBackoff(initialBackoff time.Duration, attempts int) time.Duration {
+	backoff := initialBackoff
+	for i := 1; i < attempts && backoff < maxWebhookBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxWebhookBackoff {
+		return maxWebhookBackoff
+	}
+	return backoff
+}
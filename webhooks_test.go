@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	. "github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// PublisherMock records the published events, it fails to publish them while failing is set
+type PublisherMock struct {
+	events  []PaymentEvent
+	failing bool
+}
+
+func (m *PublisherMock) Publish(event PaymentEvent) (err error) {
+	if m.failing {
+		return errors.New("broker unavailable")
+	}
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *PublisherMock) Close() (err error) {
+	return nil
+}
+
+type WebhookRepositoryMock struct {
+	subscriptions map[string]WebhookSubscription
+	events        []PaymentEvent
+	dispatched    map[string]bool
+	deliveries    map[string]WebhookDelivery
+}
+
+func NewWebhookRepositoryMock() *WebhookRepositoryMock {
+	return &WebhookRepositoryMock{
+		subscriptions: make(map[string]WebhookSubscription),
+		dispatched:    make(map[string]bool),
+		deliveries:    make(map[string]WebhookDelivery)}
+}
+
+func (m *WebhookRepositoryMock) InsertSubscription(subscription WebhookSubscription) (err error) {
+	m.subscriptions[subscription.ID] = subscription
+	return nil
+}
+
+func (m *WebhookRepositoryMock) DeleteSubscription(subscriptionID string) (err error) {
+	if _, ok := m.subscriptions[subscriptionID]; !ok {
+		return &WebhookSubscriptionNotFoundError{subscriptionID}
+	}
+	delete(m.subscriptions, subscriptionID)
+	return nil
+}
+
+func (m *WebhookRepositoryMock) GetSubscription(subscriptionID string) (subscription WebhookSubscription, err error) {
+	subscription, ok := m.subscriptions[subscriptionID]
+	if !ok {
+		return subscription, &WebhookSubscriptionNotFoundError{subscriptionID}
+	}
+	return subscription, nil
+}
+
+func (m *WebhookRepositoryMock) GetSubscriptions(organisationID string) (subscriptions []WebhookSubscription, err error) {
+	for _, subscription := range m.subscriptions {
+		if len(organisationID) == 0 || subscription.OrganisationID == organisationID {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions, nil
+}
+
+func (m *WebhookRepositoryMock) GetUndispatchedEvents(limit int) (events []PaymentEvent, err error) {
+	for _, event := range m.events {
+		if !m.dispatched[event.ID] {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (m *WebhookRepositoryMock) MarkEventDispatched(event PaymentEvent, deliveries []WebhookDelivery) (err error) {
+	for _, delivery := range deliveries {
+		m.deliveries[delivery.ID] = delivery
+	}
+	m.dispatched[event.ID] = true
+	return nil
+}
+
+func (m *WebhookRepositoryMock) ClaimDueDelivery(now time.Time, lease time.Duration) (delivery WebhookDelivery, claimed bool, err error) {
+	for _, delivery := range m.deliveries {
+		pending := delivery.Status == deliveryPending && !delivery.NextAttemptAt.After(now)
+		expired := delivery.Status == deliveryInFlight && !delivery.LeaseExpiresAt.After(now)
+		if pending || expired {
+			delivery.Status = deliveryInFlight
+			delivery.LeaseExpiresAt = now.Add(lease)
+			m.deliveries[delivery.ID] = delivery
+			return delivery, true, nil
+		}
+	}
+	return delivery, false, nil
+}
+
+func (m *WebhookRepositoryMock) GetDelivery(deliveryID string) (delivery WebhookDelivery, err error) {
+	delivery, ok := m.deliveries[deliveryID]
+	if !ok {
+		return delivery, &WebhookDeliveryNotFoundError{deliveryID}
+	}
+	return delivery, nil
+}
+
+func (m *WebhookRepositoryMock) UpdateDelivery(delivery WebhookDelivery) (err error) {
+	m.deliveries[delivery.ID] = delivery
+	return nil
+}
+
+func (m *WebhookRepositoryMock) RedeliverDelivery(deliveryID string, now time.Time) (err error) {
+	delivery, err := m.GetDelivery(deliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.Status == deliveryInFlight {
+		return &WebhookDeliveryInFlightError{deliveryID}
+	}
+
+	delivery.Status = deliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	m.deliveries[deliveryID] = delivery
+	return nil
+}
+
+func (m *WebhookRepositoryMock) GetDeadLetters() (deliveries []WebhookDelivery, err error) {
+	for _, delivery := range m.deliveries {
+		if delivery.Status == deliveryDeadLettered {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+// Test webhook dispatcher
+
+func TestWebhookDispatcherDeliversSignedEvent(t *testing.T) {
+	var received []*http.Request
+	var body []byte
+	subscriber := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ = ioutil.ReadAll(request.Body)
+		received = append(received, request)
+	}))
+	defer subscriber.Close()
+
+	repository := NewWebhookRepositoryMock()
+	repository.subscriptions["s1"] = WebhookSubscription{ID: "s1", OrganisationID: "123", URL: subscriber.URL, Secret: "secret"}
+	repository.subscriptions["s2"] = WebhookSubscription{ID: "s2", OrganisationID: "456", URL: subscriber.URL, Secret: "secret"}
+	repository.events = append(repository.events, newPaymentEvent(paymentCreatedEvent, Payment{ID: "1", OrganisationID: "123", Version: 1}))
+
+	MockDispatcher(repository, time.Now()).dispatch()
+
+	Equal(t, 1, len(received))
+	timestamp := received[0].Header.Get(webhookTimestampHeader)
+	Equal(t, "sha256="+signWebhookPayload("secret", timestamp, body), received[0].Header.Get(webhookSignatureHeader))
+	Equal(t, paymentCreatedEvent, received[0].Header.Get(webhookEventTypeHeader))
+
+	var event PaymentEvent
+	Nil(t, json.Unmarshal(body, &event))
+	Equal(t, "1", event.PaymentID)
+
+	delivery := repository.deliveries[received[0].Header.Get(webhookDeliveryHeader)]
+	Equal(t, deliveryDelivered, delivery.Status)
+	Equal(t, 1, delivery.Attempts)
+	True(t, repository.dispatched[event.ID])
+}
+
+func TestWebhookDispatcherPublishesOutboxEvents(t *testing.T) {
+	repository := NewWebhookRepositoryMock()
+	event := newPaymentEvent(paymentCreatedEvent, Payment{ID: "1", OrganisationID: "123", Version: 1})
+	repository.events = append(repository.events, event)
+	publisher := &PublisherMock{failing: true}
+	dispatcher := MockDispatcher(repository, time.Now())
+	dispatcher.publisher = publisher
+
+	dispatcher.dispatch()
+	False(t, repository.dispatched[event.ID])
+
+	publisher.failing = false
+	dispatcher.dispatch()
+	True(t, repository.dispatched[event.ID])
+	Equal(t, []PaymentEvent{event}, publisher.events)
+}
+
+func TestWebhookDispatcherRetriesWithBackoff(t *testing.T) {
+	subscriber := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer subscriber.Close()
+
+	repository := NewWebhookRepositoryMock()
+	repository.subscriptions["s1"] = WebhookSubscription{ID: "s1", OrganisationID: "123", URL: subscriber.URL}
+	repository.events = append(repository.events, newPaymentEvent(paymentUpdatedEvent, Payment{ID: "1", OrganisationID: "123", Version: 2}))
+
+	now := time.Date(2019, 5, 5, 11, 0, 0, 0, time.UTC)
+	MockDispatcher(repository, now).dispatch()
+	MockDispatcher(repository, now.Add(time.Second)).dispatch()
+
+	delivery := repository.deliveries[repository.events[0].ID+":s1"]
+	Equal(t, deliveryPending, delivery.Status)
+	Equal(t, 1, delivery.Attempts)
+	Equal(t, now.Add(5*time.Second), delivery.NextAttemptAt)
+	Contains(t, delivery.LastError, "503")
+
+	MockDispatcher(repository, now.Add(5*time.Second)).dispatch()
+
+	delivery = repository.deliveries[delivery.ID]
+	Equal(t, 2, delivery.Attempts)
+	Equal(t, now.Add(15*time.Second), delivery.NextAttemptAt)
+}
+
+func TestWebhookDispatcherDeadLettersAfterMaxAttempts(t *testing.T) {
+	subscriber := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer subscriber.Close()
+
+	repository := NewWebhookRepositoryMock()
+	repository.subscriptions["s1"] = WebhookSubscription{ID: "s1", OrganisationID: "123", URL: subscriber.URL}
+	repository.events = append(repository.events, newPaymentEvent(paymentDeletedEvent, Payment{ID: "1", OrganisationID: "123", Version: 2}))
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		MockDispatcher(repository, now.Add(time.Duration(i)*time.Hour)).dispatch()
+	}
+
+	deadLetters, _ := repository.GetDeadLetters()
+	Equal(t, 1, len(deadLetters))
+	Equal(t, 3, deadLetters[0].Attempts)
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	Equal(t, 5*time.Second, webhookBackoff(5*time.Second, 1))
+	Equal(t, 10*time.Second, webhookBackoff(5*time.Second, 2))
+	Equal(t, 40*time.Second, webhookBackoff(5*time.Second, 4))
+	Equal(t, maxWebhookBackoff, webhookBackoff(5*time.Second, 100))
+}
+
+// Test webhook handlers
+
+func TestCreateWebhookSubscriptionSuccessful(t *testing.T) {
+	repository := NewWebhookRepositoryMock()
+	body := MockSubscription("123", "https://example.com/hooks")
+
+	response := ServeWebhookHTTP(methodPost, webhookSubscriptionsPath, body, repository)
+
+	var subscription WebhookSubscription
+	_ = json.NewDecoder(response.Body).Decode(&subscription)
+
+	Equal(t, 201, response.Code)
+	NotEmpty(t, subscription.ID)
+	NotEmpty(t, subscription.Secret)
+	Equal(t, 1, len(repository.subscriptions))
+}
+
+func TestCreateWebhookSubscriptionInvalidURL(t *testing.T) {
+	response := ServeWebhookHTTP(methodPost, webhookSubscriptionsPath, MockSubscription("123", "ftp://example.com"), NewWebhookRepositoryMock())
+
+	Equal(t, 400, response.Code)
+}
+
+func TestGetWebhookSubscriptionsHidesSecrets(t *testing.T) {
+	repository := NewWebhookRepositoryMock()
+	repository.subscriptions["s1"] = WebhookSubscription{ID: "s1", OrganisationID: "123", URL: "https://example.com", Secret: "secret"}
+
+	response := ServeWebhookHTTP(methodGet, webhookSubscriptionsPath+"?organisation_id=123", http.NoBody, repository)
+
+	var result WebhookSubscriptionListResult
+	_ = json.NewDecoder(response.Body).Decode(&result)
+
+	Equal(t, 200, response.Code)
+	Equal(t, 1, len(result.Data))
+	Empty(t, result.Data[0].Secret)
+}
+
+func TestDeleteWebhookSubscriptionNotFound(t *testing.T) {
+	response := ServeWebhookHTTP(methodDelete, preparePaymentURL(webhookSubscriptionPath, "s1"), http.NoBody, NewWebhookRepositoryMock())
+
+	Equal(t, 404, response.Code)
+}
+
+func TestRedeliverWebhookSuccessful(t *testing.T) {
+	repository := NewWebhookRepositoryMock()
+	repository.deliveries["d1"] = WebhookDelivery{ID: "d1", SubscriptionID: "s1", Status: deliveryDeadLettered, Attempts: 8}
+
+	response := ServeWebhookHTTP(methodPost, preparePaymentURL(webhookRedeliveryPath, "d1"), http.NoBody, repository)
+
+	Equal(t, 202, response.Code)
+	Equal(t, deliveryPending, repository.deliveries["d1"].Status)
+	Equal(t, 0, repository.deliveries["d1"].Attempts)
+}
+
+func TestRedeliverWebhookInFlight(t *testing.T) {
+	repository := NewWebhookRepositoryMock()
+	repository.deliveries["d1"] = WebhookDelivery{ID: "d1", SubscriptionID: "s1", Status: deliveryInFlight, Attempts: 2}
+
+	response := ServeWebhookHTTP(methodPost, preparePaymentURL(webhookRedeliveryPath, "d1"), http.NoBody, repository)
+
+	Equal(t, 409, response.Code)
+	Equal(t, 2, repository.deliveries["d1"].Attempts)
+}
+
+func TestRedeliverWebhookNotFound(t *testing.T) {
+	response := ServeWebhookHTTP(methodPost, preparePaymentURL(webhookRedeliveryPath, "d1"), http.NoBody, NewWebhookRepositoryMock())
+
+	Equal(t, 404, response.Code)
+}
+
+// ---------------------------------------------------- //
+
+func MockDispatcher(repository WebhookRepository, now time.Time) *webhookDispatcher {
+	dispatcher := newWebhookDispatcher(repository, noopPublisher{}, time.Second, 3, 5*time.Second, time.Second)
+	dispatcher.now = func() time.Time { return now }
+	return dispatcher
+}
+
+func MockSubscription(organisationID string, url string) *bytes.Buffer {
+	subscription := &WebhookSubscription{OrganisationID: organisationID, URL: url}
+	jsonSubscription, _ := json.Marshal(subscription)
+	return bytes.NewBuffer(jsonSubscription)
+}
+
+func ServeWebhookHTTP(method string, url string, body io.Reader, repository WebhookRepository) *httptest.ResponseRecorder {
+	setWebhookRepository(repository)
+	return ServeHTTP(method, url, body, successful)
+}